@@ -0,0 +1,116 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tomatool/tomato/internal/report"
+	"github.com/urfave/cli/v2"
+)
+
+var flakyCommand = &cli.Command{
+	Name:  "flaky",
+	Usage: "List scenarios whose outcome changed between runs",
+	Description: `Analyze the results index of past runs (.tomato/runs/index.jsonl) and list
+the scenarios that both passed and failed with the same inputs: the same
+feature file, scenario name and steps. A scenario that passed only on retry
+counts as a flip too.
+
+Use --format locations to get the file:line of each flaky scenario.
+
+--format tags quarantines them: it adds the tag (@flaky, or --tag) above
+each flaky scenario in its feature file, lists the scenarios it tagged on
+stderr and prints the tag expression that leaves them out on stdout:
+
+  tomato run --tags "$(tomato flaky --format tags)"`,
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:  "runs",
+			Value: 50,
+			Usage: "number of most recent runs to analyze",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Value: "text",
+			Usage: "output format (text, json, locations, tags)",
+		},
+		&cli.StringFlag{
+			Name:  "tag",
+			Value: "@flaky",
+			Usage: "tag added by --format tags",
+		},
+	},
+	Action: runFlaky,
+}
+
+func runFlaky(c *cli.Context) error {
+	format := c.String("format")
+	switch format {
+	case "text", "json", "locations", "tags":
+	default:
+		return fmt.Errorf("unsupported format %q (supported: text, json, locations, tags)", format)
+	}
+	tag := c.String("tag")
+	if !strings.HasPrefix(tag, "@") || strings.ContainsAny(tag, " \t()") {
+		return fmt.Errorf("--tag: %q is not a tag, such as @flaky", tag)
+	}
+
+	entries, err := report.ReadIndex(report.IndexPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no results index found at %s; it is written by tomato run", report.IndexPath)
+		}
+		return err
+	}
+	if n := c.Int("runs"); n > 0 && len(entries) > n {
+		entries = entries[len(entries)-n:]
+	}
+	flaky := report.FindFlaky(entries)
+
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(flaky, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	case "locations":
+		for _, f := range flaky {
+			fmt.Println(f.Location())
+		}
+		return nil
+	case "tags":
+		tagged, err := report.TagScenarios(flaky, tag)
+		for _, loc := range tagged {
+			fmt.Fprintf(os.Stderr, "tagged %s with %s\n", loc, tag)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("not %s\n", tag)
+		return nil
+	}
+
+	fmt.Println()
+	fmt.Println(titleStyle.Render(fmt.Sprintf("Flaky scenarios (last %d runs)", len(entries))))
+	if len(flaky) == 0 {
+		fmt.Printf("  %s no scenario changed its outcome\n", checkStyle.Render("✓"))
+		fmt.Println()
+		return nil
+	}
+	for _, f := range flaky {
+		fmt.Printf("  %s  %s  %s\n", warnStyle.Render(fmt.Sprintf("%3.0f%%", f.FlipRate*100)), f.Location(), f.Name)
+		fmt.Println(unselectedStyle.Render(fmt.Sprintf("        %d of %d runs flipped, %d failed, avg %s",
+			f.Flips, f.Runs, f.Failures, f.AvgDuration.Round(time.Millisecond))))
+		if f.LastError != "" {
+			msg, _, _ := strings.Cut(f.LastError, "\n")
+			fmt.Println(unselectedStyle.Render(fmt.Sprintf("        last failure (run %s): %s", f.LastFailed, msg)))
+		}
+	}
+	fmt.Println()
+	return nil
+}
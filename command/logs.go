@@ -0,0 +1,187 @@
+package command
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/tomatool/tomato/internal/runlog"
+	"github.com/urfave/cli/v2"
+)
+
+// followInterval is how often a followed log is checked for new lines
+const followInterval = 250 * time.Millisecond
+
+var logsCommand = &cli.Command{
+	Name:      "logs",
+	Usage:     "Print the logs of a run",
+	ArgsUsage: "<run-id> [container]",
+	Description: `Print a log file of a stored run: the tomato output by default, or the
+log of a container, the app (app) or any other log file of the run directory.
+The run ID can be "latest". Container log lines are printed without the time
+they were received, unless --timestamps is given.`,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:    "follow",
+			Aliases: []string{"f"},
+			Usage:   "keep printing lines as they are written, until interrupted",
+		},
+		&cli.StringFlag{
+			Name:  "grep",
+			Usage: "only print lines matching this regular expression",
+		},
+		&cli.BoolFlag{
+			Name:  "timestamps",
+			Usage: "keep the time each line of a container log was received",
+		},
+	},
+	Action: runLogs,
+}
+
+func runLogs(c *cli.Context) error {
+	args, opts, err := logsArgs(c)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("expected a run ID and optionally a container name")
+	}
+	run, err := runlog.FindRun(args[0])
+	if err != nil {
+		return err
+	}
+
+	var grep *regexp.Regexp
+	if opts.grep != "" {
+		if grep, err = regexp.Compile(opts.grep); err != nil {
+			return fmt.Errorf("--grep: %w", err)
+		}
+	}
+
+	name := "tomato"
+	if len(args) == 2 {
+		name = args[1]
+	}
+	path, err := findLog(run, name)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	ctx := c.Context
+	if opts.follow {
+		var stop context.CancelFunc
+		ctx, stop = signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+	}
+	strip := !opts.timestamps && runlog.IsTimestamped(strings.TrimSuffix(filepath.Base(path), ".log"))
+	return printLog(ctx, os.Stdout, f, grep, opts.follow, strip)
+}
+
+// logsOptions are the flags of the logs command
+type logsOptions struct {
+	follow     bool
+	grep       string
+	timestamps bool
+}
+
+// logsArgs returns the arguments and flags of the logs command. The flags
+// may also follow the arguments, as in "tomato logs latest db --follow",
+// which the flag parser leaves among the arguments.
+func logsArgs(c *cli.Context) (args []string, opts logsOptions, err error) {
+	opts = logsOptions{follow: c.Bool("follow"), grep: c.String("grep"), timestamps: c.Bool("timestamps")}
+	rest := c.Args().Slice()
+	for i := 0; i < len(rest); i++ {
+		switch arg := rest[i]; {
+		case arg == "--follow" || arg == "-follow" || arg == "-f":
+			opts.follow = true
+		case arg == "--timestamps" || arg == "-timestamps":
+			opts.timestamps = true
+		case arg == "--grep" || arg == "-grep":
+			if i+1 == len(rest) {
+				return nil, opts, fmt.Errorf("--grep requires a pattern")
+			}
+			i++
+			opts.grep = rest[i]
+		case strings.HasPrefix(arg, "--grep=") || strings.HasPrefix(arg, "-grep="):
+			_, opts.grep, _ = strings.Cut(arg, "=")
+		default:
+			args = append(args, arg)
+		}
+	}
+	return args, opts, nil
+}
+
+// findLog returns the path of the named log of a run: container-<name>.log
+// for a container, or <name>.log
+func findLog(run *runlog.RunInfo, name string) (string, error) {
+	var names []string
+	for _, log := range run.Logs {
+		if log.Name == "container-"+name || log.Name == name {
+			return log.Path, nil
+		}
+		names = append(names, strings.TrimPrefix(log.Name, "container-"))
+	}
+	return "", fmt.Errorf("run %s has no log %q (available: %s)", run.ID(), name, strings.Join(names, ", "))
+}
+
+// printLog copies the lines of r that match grep to w, without their
+// timestamp when strip is set. When following, it waits for lines to be
+// appended until ctx is done.
+func printLog(ctx context.Context, w io.Writer, r io.Reader, grep *regexp.Regexp, follow, strip bool) error {
+	reader := bufio.NewReader(r)
+	var partial string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if err == io.EOF {
+			// Keep an unterminated line until the rest of it is written
+			partial += line
+			done := !follow
+			if follow {
+				select {
+				case <-ctx.Done():
+					done = true
+				case <-time.After(followInterval):
+				}
+			}
+			if done {
+				if partial != "" {
+					printLine(w, partial, grep, strip)
+				}
+				return nil
+			}
+			continue
+		}
+		printLine(w, partial+line, grep, strip)
+		partial = ""
+	}
+}
+
+// printLine prints a log line if its text, without the timestamp, matches grep
+func printLine(w io.Writer, line string, grep *regexp.Regexp, strip bool) {
+	line = strings.TrimSuffix(line, "\n")
+	text := runlog.ParseLogLine(line).Text
+	if grep != nil && !grep.MatchString(text) {
+		return
+	}
+	if strip {
+		line = text
+	}
+	fmt.Fprintln(w, line)
+}
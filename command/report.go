@@ -0,0 +1,41 @@
+package command
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/tomatool/tomato/internal/report"
+	"github.com/urfave/cli/v2"
+)
+
+var reportCommand = &cli.Command{
+	Name:      "report",
+	Usage:     "Regenerate the HTML report of a run",
+	ArgsUsage: "[run-id]",
+	Description: `Write report.html in the run directory from the stored results
+(results.json) and container logs. Without a run ID, the latest run with
+results is used.
+
+The report is a single file that can be opened in a browser or kept as a
+CI artifact.`,
+	Action: func(c *cli.Context) error {
+		if c.NArg() > 1 {
+			return fmt.Errorf("expected at most one run ID")
+		}
+
+		runID := c.Args().First()
+		if runID == "" {
+			runID = "latest"
+		}
+		run, results, err := loadResults(runID)
+		if err != nil {
+			return err
+		}
+
+		if err := report.WriteHTMLFile(run.Dir, results); err != nil {
+			return err
+		}
+		fmt.Println(filepath.Join(run.Dir, report.HTMLFileName))
+		return nil
+	},
+}
@@ -18,6 +18,10 @@ One config to rule them all.`,
 		Commands: []*cli.Command{
 			initCommand,
 			runCommand,
+			reportCommand,
+			flakyCommand,
+			runsCommand,
+			logsCommand,
 			validateCommand,
 			docsCommand,
 			stepsCommand,
@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 	"syscall"
 	"time"
@@ -14,6 +15,8 @@ import (
 	"github.com/tomatool/tomato/internal/apprunner"
 	"github.com/tomatool/tomato/internal/config"
 	"github.com/tomatool/tomato/internal/container"
+	"github.com/tomatool/tomato/internal/har"
+	"github.com/tomatool/tomato/internal/report"
 	"github.com/tomatool/tomato/internal/runlog"
 	"github.com/tomatool/tomato/internal/runner"
 	"github.com/urfave/cli/v2"
@@ -44,6 +47,11 @@ are reset between each scenario to ensure clean state.`,
 			Aliases: []string{"s"},
 			Usage:   "filter scenarios by name (regex pattern)",
 		},
+		&cli.GenericFlag{
+			Name:  "rerun-failed",
+			Value: &rerunValue{},
+			Usage: "rerun only the scenarios that failed in the latest run, or in the run given as --rerun-failed=<run-id>",
+		},
 		&cli.BoolFlag{
 			Name:  "no-reset",
 			Usage: "skip state reset between scenarios (for debugging)",
@@ -59,9 +67,20 @@ are reset between each scenario to ensure clean state.`,
 			Usage:   "hide application logs during startup",
 		},
 		&cli.StringFlag{
-			Name:   "format",
-			Usage:  "output format (pretty, progress, tomato)",
-			Hidden: true, // Internal use for UI
+			Name:  "format",
+			Usage: "console output format (pretty, progress, junit, cucumber, tomato)",
+		},
+		&cli.StringSliceFlag{
+			Name:  "report",
+			Usage: "also write a report file, as format:path (junit, cucumber or messages, e.g. junit:reports/junit.xml); can be repeated",
+		},
+		&cli.BoolFlag{
+			Name:  "har",
+			Usage: "record the traffic of all HTTP resources to traffic.har in the run directory",
+		},
+		&cli.BoolFlag{
+			Name:  "update-snapshots",
+			Usage: "write snapshots that don't match instead of failing, to accept intended changes",
 		},
 		&cli.BoolFlag{
 			Name:    "keep-alive",
@@ -102,6 +121,30 @@ func runTests(c *cli.Context) error {
 		cfg.Features.Scenario = c.String("scenario")
 	}
 
+	for _, value := range c.StringSlice("report") {
+		rep, err := config.ParseReport(value)
+		if err != nil {
+			return fmt.Errorf("--report: %w", err)
+		}
+		cfg.Settings.Reports = append(cfg.Settings.Reports, rep)
+	}
+
+	// Restrict the run to the failed scenarios of a previous run
+	var rerunFrom *report.Run
+	if rerun := c.Generic("rerun-failed").(*rerunValue); rerun.runID != "" {
+		_, previous, err := loadResults(rerun.runID)
+		if err != nil {
+			return fmt.Errorf("--rerun-failed: %w", err)
+		}
+		locations := previous.FailedLocations()
+		if len(locations) == 0 {
+			fmt.Printf("No failed scenarios in run %s, nothing to rerun\n", previous.ID)
+			return nil
+		}
+		cfg.Features.Paths = locations
+		rerunFrom = previous
+	}
+
 	// Create run context for logging
 	runCtx, err := runlog.New()
 	if err != nil {
@@ -134,6 +177,9 @@ func runTests(c *cli.Context) error {
 	fmt.Println()
 	fmt.Println(titleStyle.Render("🍅 Tomato"))
 	fmt.Printf("  %s run: %s\n", helpStyle.Render("📋"), runCtx.ID)
+	if rerunFrom != nil {
+		fmt.Printf("  %s rerunning %d failed scenario(s) of run %s\n", helpStyle.Render("🔁"), len(cfg.Features.Paths), rerunFrom.ID)
+	}
 	if cfg.Features.Scenario != "" {
 		fmt.Printf("  %s filtering scenarios matching: %s\n", helpStyle.Render("⚡"), cfg.Features.Scenario)
 	}
@@ -163,11 +209,14 @@ func runTests(c *cli.Context) error {
 	}
 	cm.SetRunContext(runCtx)
 
-	// Only auto-cleanup if not in keep-alive mode
+	// Only auto-cleanup if not in keep-alive mode. keepAlive may also be
+	// switched on later when a failure state is kept (reset.on_failure: keep).
 	keepAlive := c.Bool("keep-alive")
-	if !keepAlive {
-		defer cm.Cleanup()
-	}
+	defer func() {
+		if !keepAlive {
+			cm.Cleanup()
+		}
+	}()
 
 	if err := cm.StartAll(c.Context); err != nil {
 		return fmt.Errorf("failed to start containers: %w", err)
@@ -230,9 +279,11 @@ func runTests(c *cli.Context) error {
 			return fmt.Errorf("failed to start application: %w", err)
 		}
 		// Only auto-stop if not in keep-alive mode
-		if !keepAlive {
-			defer appRunner.Stop()
-		}
+		defer func() {
+			if !keepAlive {
+				appRunner.Stop()
+			}
+		}()
 
 		if !c.Bool("quiet") {
 			fmt.Println()
@@ -269,10 +320,17 @@ func runTests(c *cli.Context) error {
 	r, err := runner.New(cfg, cm, runner.Options{
 		NoReset: c.Bool("no-reset"),
 		Format:  c.String("format"),
+		RunID:   runCtx.ID,
+		HAR:     c.Bool("har"),
+
+		UpdateSnapshots: c.Bool("update-snapshots"),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to initialize runner: %w", err)
 	}
+	if appRunner != nil {
+		r.SetAppLogs(appRunner)
+	}
 
 	// Show resource status
 	for name := range cfg.Resources {
@@ -282,10 +340,55 @@ func runTests(c *cli.Context) error {
 	// Step 4: Run tests
 	fmt.Println()
 	fmt.Println(subtitleStyle.Render("Running tests..."))
+	fmt.Println(helpStyle.Render(fmt.Sprintf("🔄 reset level: %s", r.ResetLevel())))
 	fmt.Println()
 
 	testErr := r.Run(c.Context)
 
+	// Persist results so failed scenarios can be rerun with --rerun-failed
+	if results := r.Results(); results != nil {
+		if err := results.Save(runCtx.Path(report.FileName)); err != nil {
+			fmt.Printf("  %s failed to write results: %v\n", errorStyle.Render("✗"), err)
+		} else {
+			fmt.Printf("  %s results: %s\n", helpStyle.Render("📄"), runCtx.Path(report.FileName))
+		}
+		if err := report.AppendIndex(report.IndexPath, results); err != nil {
+			fmt.Printf("  %s %v\n", errorStyle.Render("✗"), err)
+		}
+		if err := report.WriteHTMLFile(runCtx.Dir, results); err != nil {
+			fmt.Printf("  %s %v\n", errorStyle.Render("✗"), err)
+		} else {
+			fmt.Printf("  %s report: %s\n", helpStyle.Render("📄"), runCtx.Path(report.HTMLFileName))
+		}
+		for _, rep := range cfg.Settings.Reports {
+			if err := results.WriteFile(rep.Format, rep.Path); err != nil {
+				fmt.Printf("  %s %v\n", errorStyle.Render("✗"), err)
+			} else {
+				fmt.Printf("  %s %s report: %s\n", helpStyle.Render("📄"), rep.Format, rep.Path)
+			}
+		}
+	}
+
+	if traffic := r.HAR(); traffic != nil {
+		if err := traffic.WriteFile(runCtx.Path(har.FileName)); err != nil {
+			fmt.Printf("  %s %v\n", errorStyle.Render("✗"), err)
+		} else {
+			fmt.Printf("  %s HTTP traffic: %s\n", helpStyle.Render("📄"), runCtx.Path(har.FileName))
+		}
+	}
+
+	// Keep containers alive when a failed scenario's state was kept
+	if failure := r.FailureState(); failure != nil {
+		fmt.Println()
+		fmt.Printf("  %s state kept after failure in %q\n", errorStyle.Render("✗"), failure.Scenario)
+		if err := runCtx.WriteLog("failure-state", []byte(failure.Summary(cfg.Resources))); err != nil {
+			fmt.Printf("  %s failed to write failure state: %v\n", errorStyle.Render("✗"), err)
+		} else {
+			fmt.Printf("  %s failure state: %s\n", helpStyle.Render("📄"), runCtx.LogPath("failure-state"))
+		}
+		keepAlive = true
+	}
+
 	// Handle keep-alive mode
 	if keepAlive {
 		printKeepAliveInfo(cm, appRunner, cfg)
@@ -303,6 +406,57 @@ func runTests(c *cli.Context) error {
 	return testErr
 }
 
+// rerunValue is the value of --rerun-failed. It can be given without a
+// value to use the latest run, or as --rerun-failed=<run-id>.
+type rerunValue struct {
+	runID string
+}
+
+func (v *rerunValue) Set(s string) error {
+	switch s {
+	case "true":
+		v.runID = "latest"
+	case "false":
+		v.runID = ""
+	default:
+		v.runID = s
+	}
+	return nil
+}
+
+func (v *rerunValue) String() string { return v.runID }
+
+// IsBoolFlag allows the flag to be given without a value
+func (v *rerunValue) IsBoolFlag() bool { return true }
+
+// loadResults loads the results of the given run, or of the most recent
+// run that recorded results when runID is "latest"
+func loadResults(runID string) (*runlog.RunInfo, *report.Run, error) {
+	if runID != "latest" {
+		run, err := runlog.FindRun(runID)
+		if err != nil {
+			return nil, nil, err
+		}
+		results, err := report.Load(filepath.Join(run.Dir, report.FileName))
+		return run, results, err
+	}
+
+	runs, err := runlog.ListRuns()
+	if err != nil {
+		return nil, nil, fmt.Errorf("listing runs: %w", err)
+	}
+	for _, run := range runs {
+		results, err := report.Load(filepath.Join(run.Dir, report.FileName))
+		if err == nil {
+			return &run, results, nil
+		}
+		if !os.IsNotExist(err) {
+			return nil, nil, err
+		}
+	}
+	return nil, nil, fmt.Errorf("no previous run with results found")
+}
+
 // printKeepAliveInfo prints connection info for all running containers
 func printKeepAliveInfo(cm *container.Manager, app *apprunner.Runner, cfg *config.Config) {
 	fmt.Println()
@@ -0,0 +1,361 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/tomatool/tomato/internal/report"
+	"github.com/tomatool/tomato/internal/runlog"
+	"github.com/urfave/cli/v2"
+)
+
+var runsCommand = &cli.Command{
+	Name:  "runs",
+	Usage: "List, inspect, compare and prune stored runs",
+	Description: `Every run stores its logs and results in .tomato/runs/<timestamp>_<id>/.
+Runs are given by the short ID printed at the start of a run, the full
+directory name or "latest".`,
+	Subcommands: []*cli.Command{
+		{
+			Name:  "list",
+			Usage: "List stored runs, most recent first",
+			Flags: []cli.Flag{
+				&cli.IntFlag{
+					Name:    "limit",
+					Aliases: []string{"n"},
+					Value:   20,
+					Usage:   "number of runs to list (0 for all)",
+				},
+				&cli.BoolFlag{
+					Name:  "json",
+					Usage: "Output in JSON format",
+				},
+			},
+			Action: listRuns,
+		},
+		{
+			Name:      "show",
+			Usage:     "Show the results and log files of a run",
+			ArgsUsage: "[run-id]",
+			Action:    showRun,
+		},
+		{
+			Name:      "diff",
+			Usage:     "Show the scenarios whose outcome differs between two runs",
+			ArgsUsage: "[run-id] [run-id]",
+			Description: `Compare the results of two runs. With one run ID, it is compared to the
+latest run; without one, the two most recent runs with results are compared.`,
+			Action: diffRuns,
+		},
+		{
+			Name:  "prune",
+			Usage: "Delete old runs",
+			Description: `Delete the runs beyond the --keep most recent ones that are older than
+--older-than. At least one of them is required; given both, a run is
+deleted only when it matches both.`,
+			Flags: []cli.Flag{
+				&cli.IntFlag{
+					Name:  "keep",
+					Usage: "number of most recent runs to keep",
+				},
+				&cli.StringFlag{
+					Name:  "older-than",
+					Usage: "only delete runs older than this, e.g. 7d, 12h or 2w",
+				},
+				&cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "list the runs that would be deleted",
+				},
+			},
+			Action: pruneRuns,
+		},
+	},
+}
+
+// runSummary is a stored run with its results, if it recorded any
+type runSummary struct {
+	ID        string        `json:"id"`
+	Dir       string        `json:"dir"`
+	StartedAt time.Time     `json:"started_at"`
+	Status    string        `json:"status,omitempty"`
+	Duration  time.Duration `json:"duration,omitempty"`
+	Passed    int           `json:"passed"`
+	Failed    int           `json:"failed"`
+	Skipped   int           `json:"skipped"`
+	Flaky     int           `json:"flaky"`
+}
+
+func summarizeRun(run runlog.RunInfo) runSummary {
+	s := runSummary{ID: run.ID(), Dir: run.Dir, StartedAt: run.StartedAt()}
+	if results, err := report.Load(filepath.Join(run.Dir, report.FileName)); err == nil {
+		s.Status, s.Duration = results.Status, results.Duration
+		s.Passed, s.Failed, s.Skipped = results.Counts()
+		s.Flaky = len(results.Flaky())
+	}
+	return s
+}
+
+func listRuns(c *cli.Context) error {
+	runs, err := runlog.ListRuns()
+	if err != nil {
+		return fmt.Errorf("listing runs: %w", err)
+	}
+	if n := c.Int("limit"); n > 0 && len(runs) > n {
+		runs = runs[:n]
+	}
+
+	summaries := make([]runSummary, len(runs))
+	for i, run := range runs {
+		summaries[i] = summarizeRun(run)
+	}
+
+	if c.Bool("json") {
+		data, err := json.MarshalIndent(summaries, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(summaries) == 0 {
+		fmt.Println("No runs found in " + filepath.Join(".tomato", "runs"))
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTARTED\tSTATUS\tPASSED\tFAILED\tSKIPPED\tDURATION")
+	for _, s := range summaries {
+		status, duration := s.Status, ""
+		if status == "" {
+			status = "no results"
+		} else {
+			duration = s.Duration.Round(time.Millisecond).String()
+		}
+		if s.Flaky > 0 {
+			status += fmt.Sprintf(" (%d flaky)", s.Flaky)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%s\n", s.ID, s.StartedAt.Format("2006-01-02 15:04:05"),
+			status, s.Passed, s.Failed, s.Skipped, duration)
+	}
+	return w.Flush()
+}
+
+func showRun(c *cli.Context) error {
+	if c.NArg() > 1 {
+		return fmt.Errorf("expected at most one run ID")
+	}
+	runID := c.Args().First()
+	if runID == "" {
+		runID = "latest"
+	}
+	run, err := runlog.FindRun(runID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Run:       %s\n", run.ID())
+	fmt.Printf("Started:   %s\n", run.StartedAt().Format("2006-01-02 15:04:05"))
+	fmt.Printf("Dir:       %s\n", run.Dir)
+
+	results, err := report.Load(filepath.Join(run.Dir, report.FileName))
+	switch {
+	case err == nil:
+		passed, failed, skipped := results.Counts()
+		fmt.Printf("Status:    %s in %s\n", results.Status, results.Duration.Round(time.Millisecond))
+		fmt.Printf("Scenarios: %d passed, %d failed, %d skipped\n", passed, failed, skipped)
+	case os.IsNotExist(err):
+		fmt.Println("Status:    no results (the run did not finish)")
+	default:
+		return err
+	}
+
+	if results != nil {
+		if failed := results.Failed(); len(failed) > 0 {
+			fmt.Println()
+			fmt.Println("Failed scenarios:")
+			for _, sc := range failed {
+				fmt.Printf("  %s  %s (%s)\n", sc.Location(), sc.Name, sc.Outcome())
+				if sc.Error != "" {
+					msg, _, _ := strings.Cut(sc.Error, "\n")
+					fmt.Printf("      %s\n", msg)
+				}
+			}
+		}
+		if flaky := results.Flaky(); len(flaky) > 0 {
+			fmt.Println()
+			fmt.Println("Flaky scenarios:")
+			for _, sc := range flaky {
+				fmt.Printf("  %s  %s (%s)\n", sc.Location(), sc.Name, sc.Outcome())
+			}
+		}
+	}
+
+	fmt.Println()
+	fmt.Println("Files:")
+	entries, err := os.ReadDir(run.Dir)
+	if err != nil {
+		return fmt.Errorf("reading run directory: %w", err)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && !entry.IsDir() {
+			fmt.Fprintf(w, "  %s\t%s\n", entry.Name(), formatSize(info.Size()))
+		}
+	}
+	return w.Flush()
+}
+
+func diffRuns(c *cli.Context) error {
+	var a, b *report.Run
+	switch c.NArg() {
+	case 0:
+		runs, err := runsWithResults(2)
+		if err != nil {
+			return err
+		}
+		if len(runs) < 2 {
+			return fmt.Errorf("need two runs with results to compare")
+		}
+		a, b = runs[1], runs[0]
+	case 1, 2:
+		second := "latest"
+		if c.NArg() == 2 {
+			second = c.Args().Get(1)
+		}
+		var err error
+		if _, a, err = loadResults(c.Args().First()); err != nil {
+			return err
+		}
+		if _, b, err = loadResults(second); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("expected at most two run IDs")
+	}
+
+	fmt.Printf("Comparing run %s (%s) with run %s (%s)\n", a.ID, a.StartedAt.Format("2006-01-02 15:04:05"),
+		b.ID, b.StartedAt.Format("2006-01-02 15:04:05"))
+	pa, fa, sa := a.Counts()
+	pb, fb, sb := b.Counts()
+	fmt.Printf("  passed %d → %d, failed %d → %d, skipped %d → %d, duration %s → %s\n", pa, pb, fa, fb, sa, sb,
+		a.Duration.Round(time.Millisecond), b.Duration.Round(time.Millisecond))
+	fmt.Println()
+
+	changes := report.Diff(a, b)
+	if len(changes) == 0 {
+		fmt.Println("No scenario changed its outcome")
+		return nil
+	}
+	for _, change := range changes {
+		sc := change.Scenario()
+		switch {
+		case change.Before == nil:
+			fmt.Printf("  + %-20s %s  %s\n", "new, "+sc.Status, sc.Location(), sc.Name)
+		case change.After == nil:
+			fmt.Printf("  - %-20s %s  %s\n", "not run", sc.Location(), sc.Name)
+		default:
+			fmt.Printf("  ~ %-20s %s  %s\n", change.Before.Status+" → "+sc.Status, sc.Location(), sc.Name)
+		}
+		if change.After != nil && sc.Error != "" {
+			msg, _, _ := strings.Cut(sc.Error, "\n")
+			fmt.Printf("      %s\n", msg)
+		}
+	}
+	return nil
+}
+
+// runsWithResults returns the results of up to n most recent runs that recorded them
+func runsWithResults(n int) ([]*report.Run, error) {
+	runs, err := runlog.ListRuns()
+	if err != nil {
+		return nil, fmt.Errorf("listing runs: %w", err)
+	}
+	var results []*report.Run
+	for _, run := range runs {
+		if len(results) == n {
+			break
+		}
+		r, err := report.Load(filepath.Join(run.Dir, report.FileName))
+		if err == nil {
+			results = append(results, r)
+		} else if !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+func pruneRuns(c *cli.Context) error {
+	keep := c.Int("keep")
+	if keep < 0 {
+		return fmt.Errorf("--keep must not be negative")
+	}
+	var olderThan time.Duration
+	if s := c.String("older-than"); s != "" {
+		d, err := parseAge(s)
+		if err != nil {
+			return fmt.Errorf("--older-than: %w", err)
+		}
+		olderThan = d
+	}
+	if !c.IsSet("keep") && olderThan == 0 {
+		return fmt.Errorf("give --keep, --older-than or both")
+	}
+
+	runs, err := runlog.ListRuns()
+	if err != nil {
+		return fmt.Errorf("listing runs: %w", err)
+	}
+	prune := runlog.PruneCandidates(runs, keep, olderThan, time.Now())
+	if len(prune) == 0 {
+		fmt.Println("No runs to prune")
+		return nil
+	}
+
+	for _, run := range prune {
+		if c.Bool("dry-run") {
+			fmt.Printf("would delete %s\n", run.Dir)
+			continue
+		}
+		if err := os.RemoveAll(run.Dir); err != nil {
+			return fmt.Errorf("deleting run %s: %w", run.ID(), err)
+		}
+		fmt.Printf("deleted %s\n", run.Dir)
+	}
+	return nil
+}
+
+// parseAge parses a duration that may also be given in days (7d) or weeks (2w)
+func parseAge(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			v, err := strconv.Atoi(n)
+			if err != nil || v <= 0 {
+				return 0, fmt.Errorf("invalid age %q", s)
+			}
+			return time.Duration(v) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid age %q: expected e.g. 7d, 12h or 2w", s)
+	}
+	return d, nil
+}
+
+// formatSize formats a file size in bytes for display
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", n)
+}
@@ -133,10 +133,13 @@ type TomatoEvent struct {
 	Status   string `json:"status,omitempty"`
 	Error    string `json:"error,omitempty"`
 	File     string `json:"file,omitempty"`
+	Retry    int    `json:"retry,omitempty"`
 	Total    int    `json:"total,omitempty"`
 	Passed   int    `json:"passed,omitempty"`
 	Failed   int    `json:"failed,omitempty"`
 	Skipped  int    `json:"skipped,omitempty"`
+	Retried  int    `json:"retried,omitempty"`
+	Flaky    int    `json:"flaky,omitempty"`
 }
 
 func runWebUI(c *cli.Context) error {
@@ -549,6 +552,9 @@ func (s *UIServer) runTests(scenarioFilter string) {
 					featureResults[event.Feature] = "failed"
 				} else if event.Status == "passed" {
 					s.broadcastRunStatus("scenario_passed", event.Scenario, "passed", "")
+					if event.Retry > 0 {
+						s.broadcastRunStatus("run_output", "", "", ansiToHTML(fmt.Sprintf("%s passed on retry %d", event.Scenario, event.Retry)))
+					}
 					if featureResults[event.Feature] != "failed" {
 						featureResults[event.Feature] = "passed"
 					}
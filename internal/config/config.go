@@ -3,8 +3,12 @@ package config
 import (
 	"fmt"
 	"os"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/tomatool/tomato/internal/tagexpr"
 	"gopkg.in/yaml.v3"
 )
 
@@ -79,11 +83,58 @@ func (a *AppConfig) GetName() string {
 }
 
 type Settings struct {
-	Timeout  time.Duration `yaml:"timeout"`
-	Parallel int           `yaml:"parallel"`
-	FailFast bool          `yaml:"fail_fast"`
-	Output   string        `yaml:"output"`
-	Reset    ResetSettings `yaml:"reset"`
+	Timeout     time.Duration      `yaml:"timeout"`      // deadline for the whole run
+	StepTimeout time.Duration      `yaml:"step_timeout"` // deadline for a single step; 0 means none
+	Parallel    int                `yaml:"parallel"`
+	FailFast    bool               `yaml:"fail_fast"`
+	Retry       int                `yaml:"retry"` // default number of retries of a failed scenario
+	Output      string             `yaml:"output"`
+	Reports     []Report           `yaml:"reports"` // report files written after the run
+	Reset       ResetSettings      `yaml:"reset"`
+	Eventually  EventuallySettings `yaml:"eventually"`
+	HAR         HARSettings        `yaml:"har"`
+}
+
+// HARSettings configures the HAR export of HTTP traffic
+type HARSettings struct {
+	Enabled bool     `yaml:"enabled"` // record all http-client and http-server resources
+	Redact  []string `yaml:"redact"`  // headers redacted in addition to Authorization, Proxy-Authorization, Cookie and Set-Cookie
+}
+
+// EventuallySettings configures polling of assertions in @eventually
+// scenarios and of steps with a `within` suffix
+type EventuallySettings struct {
+	Timeout  time.Duration `yaml:"timeout"`  // polling duration for @eventually without a value
+	Interval time.Duration `yaml:"interval"` // delay between attempts
+}
+
+// Report is a report file written after the run, next to the console output
+type Report struct {
+	Format string `yaml:"format"` // junit, cucumber or messages
+	Path   string `yaml:"path"`
+}
+
+// ReportFormats are the supported report formats
+var ReportFormats = []string{"junit", "cucumber", "messages"}
+
+// ParseReport parses a report given as format:path, e.g. junit:reports/junit.xml
+func ParseReport(s string) (Report, error) {
+	format, path, ok := strings.Cut(s, ":")
+	if !ok || format == "" || path == "" {
+		return Report{}, fmt.Errorf("invalid report %q: expected format:path, e.g. junit:reports/junit.xml", s)
+	}
+	r := Report{Format: format, Path: path}
+	return r, r.validate()
+}
+
+func (r Report) validate() error {
+	if !slices.Contains(ReportFormats, r.Format) {
+		return fmt.Errorf("unsupported report format %q (supported: %s)", r.Format, strings.Join(ReportFormats, ", "))
+	}
+	if r.Path == "" {
+		return fmt.Errorf("report %q requires a path", r.Format)
+	}
+	return nil
 }
 
 type ResetSettings struct {
@@ -161,12 +212,68 @@ type Hooks struct {
 }
 
 type Hook struct {
-	SQL       string `yaml:"sql,omitempty"`
-	SQLFile   string `yaml:"sql_file,omitempty"`
-	Exec      string `yaml:"exec,omitempty"`
-	Shell     string `yaml:"shell,omitempty"`
+	SQL     string       `yaml:"sql,omitempty"`
+	SQLFile string       `yaml:"sql_file,omitempty"`
+	Exec    string       `yaml:"exec,omitempty"`  // runs in container
+	Shell   string       `yaml:"shell,omitempty"` // runs on the host, or in container if set
+	HTTP    *HTTPHook    `yaml:"http,omitempty"`
+	Redis   string       `yaml:"redis,omitempty"` // Redis command, e.g. "SET flag on"
+	Publish *PublishHook `yaml:"publish,omitempty"`
+
 	Resource  string `yaml:"resource,omitempty"`
 	Container string `yaml:"container,omitempty"`
+
+	// Tags limits before_scenario and after_scenario hooks to scenarios
+	// matching a tag expression, e.g. "@billing and not @wip"
+	Tags string `yaml:"tags,omitempty"`
+	// ContinueOnError logs a failing hook instead of failing the run or scenario
+	ContinueOnError bool          `yaml:"continue_on_error,omitempty"`
+	Timeout         time.Duration `yaml:"timeout,omitempty"`
+}
+
+// HTTPHook sends an HTTP request. With a resource, URL is a path relative
+// to the base URL of that http client; otherwise it must be absolute.
+type HTTPHook struct {
+	Method  string            `yaml:"method,omitempty"` // default GET
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	Body    string            `yaml:"body,omitempty"`
+	Status  int               `yaml:"status,omitempty"` // expected status; any status below 400 if unset
+}
+
+// PublishHook publishes a message to a Kafka topic or RabbitMQ queue
+type PublishHook struct {
+	Target  string            `yaml:"target"`
+	Payload string            `yaml:"payload"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+}
+
+// Kind returns the action performed by the hook, e.g. "sql" or "http"
+func (h Hook) Kind() string {
+	kinds := h.kinds()
+	if len(kinds) == 0 {
+		return ""
+	}
+	return kinds[0]
+}
+
+func (h Hook) kinds() []string {
+	var kinds []string
+	for kind, set := range map[string]bool{
+		"sql":      h.SQL != "",
+		"sql_file": h.SQLFile != "",
+		"exec":     h.Exec != "",
+		"shell":    h.Shell != "",
+		"http":     h.HTTP != nil,
+		"redis":    h.Redis != "",
+		"publish":  h.Publish != nil,
+	} {
+		if set {
+			kinds = append(kinds, kind)
+		}
+	}
+	sort.Strings(kinds)
+	return kinds
 }
 
 type Features struct {
@@ -214,6 +321,12 @@ func (c *Config) applyDefaults() {
 	if c.Settings.Output == "" {
 		c.Settings.Output = "pretty"
 	}
+	if c.Settings.Eventually.Timeout == 0 {
+		c.Settings.Eventually.Timeout = 5 * time.Second
+	}
+	if c.Settings.Eventually.Interval == 0 {
+		c.Settings.Eventually.Interval = 100 * time.Millisecond
+	}
 	if c.Settings.Reset.Level == "" {
 		c.Settings.Reset.Level = "scenario"
 	}
@@ -251,8 +364,41 @@ func (c *Config) validate() error {
 		return fmt.Errorf("invalid reset level: %s", c.Settings.Reset.Level)
 	}
 
+	if c.Settings.Retry < 0 {
+		return fmt.Errorf("invalid retry count: %d", c.Settings.Retry)
+	}
+
+	for i, report := range c.Settings.Reports {
+		if err := report.validate(); err != nil {
+			return fmt.Errorf("settings.reports[%d]: %w", i, err)
+		}
+	}
+
 	// Validate resource references
 	for name, res := range c.Resources {
+		if isolation, ok := res.Options["isolation"].(string); ok && isolation != "" {
+			if !validIsolation(res.Type, isolation) {
+				return fmt.Errorf("resource %q: isolation %q is not supported for type %q", name, isolation, res.Type)
+			}
+			// Postgres can't clone the database tomato is connected to
+			if isolation == "database" {
+				template, _ := res.Options["template"].(string)
+				if template == "" || template == res.Database {
+					return fmt.Errorf("resource %q: isolation \"database\" needs options.template, a database other than %q that nothing connects to", name, res.Database)
+				}
+			}
+			// Each worker uses the logical database db + its ID
+			if isolation == "db" {
+				db, _ := res.Options["db"].(int)
+				databases := 16 // the redis default
+				if n, ok := res.Options["databases"].(int); ok {
+					databases = n
+				}
+				if last := db + max(c.Settings.Parallel, 1); last >= databases {
+					return fmt.Errorf("resource %q: isolation \"db\" uses logical databases %d to %d, but the server has %d (0-%d); lower options.db or settings.parallel, or set options.databases to the server's databases setting", name, db+1, last, databases, databases-1)
+				}
+			}
+		}
 		if res.Container != "" {
 			// Check if it references a configured container
 			if _, ok := c.Containers[res.Container]; !ok {
@@ -264,6 +410,20 @@ func (c *Config) validate() error {
 		}
 	}
 
+	// Validate hooks
+	for phase, hooks := range map[string][]Hook{
+		"before_all":      c.Hooks.BeforeAll,
+		"after_all":       c.Hooks.AfterAll,
+		"before_scenario": c.Hooks.BeforeScenario,
+		"after_scenario":  c.Hooks.AfterScenario,
+	} {
+		for i, hook := range hooks {
+			if err := hook.validate(phase); err != nil {
+				return fmt.Errorf("hooks.%s[%d]: %w", phase, i, err)
+			}
+		}
+	}
+
 	// Validate container dependencies
 	for name, cont := range c.Containers {
 		for _, dep := range cont.DependsOn {
@@ -275,3 +435,49 @@ func (c *Config) validate() error {
 
 	return nil
 }
+
+func (h Hook) validate(phase string) error {
+	if kinds := h.kinds(); len(kinds) > 1 {
+		return fmt.Errorf("hook has more than one action: %s", strings.Join(kinds, ", "))
+	}
+	if h.Tags != "" {
+		if phase != "before_scenario" && phase != "after_scenario" {
+			return fmt.Errorf("tags are only supported in before_scenario and after_scenario hooks")
+		}
+		if _, err := tagexpr.Parse(h.Tags); err != nil {
+			return err
+		}
+	}
+
+	switch h.Kind() {
+	case "sql", "sql_file", "redis", "publish":
+		if h.Resource == "" {
+			return fmt.Errorf("%s hook requires a resource", h.Kind())
+		}
+	case "exec":
+		if h.Container == "" {
+			return fmt.Errorf("exec hook requires a container")
+		}
+	case "http":
+		if h.HTTP.URL == "" {
+			return fmt.Errorf("http hook requires a url")
+		}
+	}
+	if h.Publish != nil && h.Publish.Target == "" {
+		return fmt.Errorf("publish hook requires a target")
+	}
+	return nil
+}
+
+// validIsolation reports whether a worker isolation strategy is supported by a resource type
+func validIsolation(resourceType, isolation string) bool {
+	switch resourceType {
+	case "postgres", "postgresql":
+		return isolation == "schema" || isolation == "database"
+	case "redis":
+		return isolation == "db"
+	case "kafka", "rabbitmq":
+		return isolation == "prefix"
+	}
+	return false
+}
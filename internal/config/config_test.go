@@ -228,6 +228,16 @@ settings:
 			wantErr:     true,
 			errContains: "invalid reset level",
 		},
+		{
+			name: "negative retry count",
+			content: `
+version: 2
+settings:
+  retry: -1
+`,
+			wantErr:     true,
+			errContains: "invalid retry count",
+		},
 		{
 			name: "resource references unknown container",
 			content: `
@@ -335,6 +345,12 @@ func TestApplyDefaults(t *testing.T) {
 				if cfg.Settings.Timeout != 5*time.Minute {
 					t.Errorf("expected default timeout 5m, got %v", cfg.Settings.Timeout)
 				}
+				if cfg.Settings.StepTimeout != 0 {
+					t.Errorf("expected no default step timeout, got %v", cfg.Settings.StepTimeout)
+				}
+				if cfg.Settings.Eventually.Timeout != 5*time.Second || cfg.Settings.Eventually.Interval != 100*time.Millisecond {
+					t.Errorf("expected default eventually 5s/100ms, got %+v", cfg.Settings.Eventually)
+				}
 				if cfg.Settings.Parallel != 1 {
 					t.Errorf("expected default parallel 1, got %d", cfg.Settings.Parallel)
 				}
@@ -357,9 +373,10 @@ func TestApplyDefaults(t *testing.T) {
 			config: Config{
 				Version: 2,
 				Settings: Settings{
-					Timeout:  10 * time.Minute,
-					Parallel: 8,
-					Output:   "json",
+					Timeout:     10 * time.Minute,
+					StepTimeout: 20 * time.Second,
+					Parallel:    8,
+					Output:      "json",
 					Reset: ResetSettings{
 						Level:     "feature",
 						OnFailure: "keep",
@@ -373,6 +390,9 @@ func TestApplyDefaults(t *testing.T) {
 				if cfg.Settings.Timeout != 10*time.Minute {
 					t.Errorf("timeout should be preserved, got %v", cfg.Settings.Timeout)
 				}
+				if cfg.Settings.StepTimeout != 20*time.Second {
+					t.Errorf("step timeout should be preserved, got %v", cfg.Settings.StepTimeout)
+				}
 				if cfg.Settings.Parallel != 8 {
 					t.Errorf("parallel should be preserved, got %d", cfg.Settings.Parallel)
 				}
@@ -512,6 +532,155 @@ func TestValidate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "valid worker isolation",
+			config: Config{
+				Version: 2,
+				Settings: Settings{
+					Reset: ResetSettings{Level: "scenario"},
+				},
+				Resources: map[string]Resource{
+					"db":    {Type: "postgres", Options: map[string]any{"isolation": "schema"}},
+					"clone": {Type: "postgres", Database: "app", Options: map[string]any{"isolation": "database", "template": "app_template"}},
+					"cache": {Type: "redis", Options: map[string]any{"isolation": "db"}},
+					"queue": {Type: "kafka", Options: map[string]any{"isolation": "prefix"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "database isolation without template",
+			config: Config{
+				Version: 2,
+				Settings: Settings{
+					Reset: ResetSettings{Level: "scenario"},
+				},
+				Resources: map[string]Resource{
+					"db": {Type: "postgres", Database: "app", Options: map[string]any{"isolation": "database"}},
+				},
+			},
+			wantErr:     true,
+			errContains: "needs options.template",
+		},
+		{
+			name: "database isolation from the connected database",
+			config: Config{
+				Version: 2,
+				Settings: Settings{
+					Reset: ResetSettings{Level: "scenario"},
+				},
+				Resources: map[string]Resource{
+					"db": {Type: "postgres", Database: "app", Options: map[string]any{"isolation": "database", "template": "app"}},
+				},
+			},
+			wantErr:     true,
+			errContains: "needs options.template",
+		},
+		{
+			name: "db isolation past the logical databases",
+			config: Config{
+				Version: 2,
+				Settings: Settings{
+					Reset:    ResetSettings{Level: "scenario"},
+					Parallel: 8,
+				},
+				Resources: map[string]Resource{
+					"cache": {Type: "redis", Options: map[string]any{"isolation": "db", "db": 8}},
+				},
+			},
+			wantErr:     true,
+			errContains: "uses logical databases 9 to 16, but the server has 16",
+		},
+		{
+			name: "db isolation with more logical databases",
+			config: Config{
+				Version: 2,
+				Settings: Settings{
+					Reset:    ResetSettings{Level: "scenario"},
+					Parallel: 8,
+				},
+				Resources: map[string]Resource{
+					"cache": {Type: "redis", Options: map[string]any{"isolation": "db", "db": 8, "databases": 32}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unsupported worker isolation",
+			config: Config{
+				Version: 2,
+				Settings: Settings{
+					Reset: ResetSettings{Level: "scenario"},
+				},
+				Resources: map[string]Resource{
+					"cache": {Type: "redis", Options: map[string]any{"isolation": "schema"}},
+				},
+			},
+			wantErr:     true,
+			errContains: "isolation \"schema\" is not supported",
+		},
+		{
+			name: "valid tagged scenario hook",
+			config: Config{
+				Version:  2,
+				Settings: Settings{Reset: ResetSettings{Level: "scenario"}},
+				Hooks: Hooks{
+					BeforeScenario: []Hook{
+						{SQL: "INSERT INTO plans VALUES (1)", Resource: "db", Tags: "@billing and not @wip"},
+						{HTTP: &HTTPHook{Method: "POST", URL: "http://localhost:8080/reset"}, ContinueOnError: true},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "hook tags outside scenario hooks",
+			config: Config{
+				Version:  2,
+				Settings: Settings{Reset: ResetSettings{Level: "scenario"}},
+				Hooks: Hooks{
+					BeforeAll: []Hook{{Shell: "./seed.sh", Tags: "@billing"}},
+				},
+			},
+			wantErr:     true,
+			errContains: "hooks.before_all[0]: tags are only supported",
+		},
+		{
+			name: "invalid hook tag expression",
+			config: Config{
+				Version:  2,
+				Settings: Settings{Reset: ResetSettings{Level: "scenario"}},
+				Hooks: Hooks{
+					AfterScenario: []Hook{{Shell: "./cleanup.sh", Tags: "@billing and"}},
+				},
+			},
+			wantErr:     true,
+			errContains: "invalid tag expression",
+		},
+		{
+			name: "hook with two actions",
+			config: Config{
+				Version:  2,
+				Settings: Settings{Reset: ResetSettings{Level: "scenario"}},
+				Hooks: Hooks{
+					BeforeScenario: []Hook{{SQL: "SELECT 1", Redis: "FLUSHDB", Resource: "db"}},
+				},
+			},
+			wantErr:     true,
+			errContains: "more than one action: redis, sql",
+		},
+		{
+			name: "publish hook without resource",
+			config: Config{
+				Version:  2,
+				Settings: Settings{Reset: ResetSettings{Level: "scenario"}},
+				Hooks: Hooks{
+					BeforeAll: []Hook{{Publish: &PublishHook{Target: "orders", Payload: "{}"}}},
+				},
+			},
+			wantErr:     true,
+			errContains: "publish hook requires a resource",
+		},
 		{
 			name: "container dependencies valid",
 			config: Config{
@@ -540,6 +709,30 @@ func TestValidate(t *testing.T) {
 			wantErr:     true,
 			errContains: "depends on unknown container",
 		},
+		{
+			name: "unsupported report format",
+			config: Config{
+				Version: 2,
+				Settings: Settings{
+					Reset:   ResetSettings{Level: "scenario"},
+					Reports: []Report{{Format: "pdf", Path: "report.pdf"}},
+				},
+			},
+			wantErr:     true,
+			errContains: "settings.reports[0]: unsupported report format",
+		},
+		{
+			name: "report without path",
+			config: Config{
+				Version: 2,
+				Settings: Settings{
+					Reset:   ResetSettings{Level: "scenario"},
+					Reports: []Report{{Format: "junit"}},
+				},
+			},
+			wantErr:     true,
+			errContains: "requires a path",
+		},
 	}
 
 	for _, tt := range tests {
@@ -562,6 +755,33 @@ func TestValidate(t *testing.T) {
 	}
 }
 
+func TestParseReport(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Report
+		wantErr bool
+	}{
+		{in: "junit:reports/junit.xml", want: Report{Format: "junit", Path: "reports/junit.xml"}},
+		{in: `junit:C:\reports\junit.xml`, want: Report{Format: "junit", Path: `C:\reports\junit.xml`}},
+		{in: "messages:out/run.ndjson", want: Report{Format: "messages", Path: "out/run.ndjson"}},
+		{in: "junit", wantErr: true},
+		{in: "junit:", wantErr: true},
+		{in: "pdf:report.pdf", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseReport(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseReport(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want && !tt.wantErr {
+				t.Errorf("ParseReport(%q) = %+v, want %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
 // Tests for AppConfig.IsConfigured
 
 func TestAppConfigIsConfigured(t *testing.T) {
@@ -406,10 +406,10 @@ func (m *Manager) captureContainerLogs(ctx context.Context, name string, contain
 		return
 	}
 
-	// Stream logs to file
+	// Stream logs to file, timestamped so they can be matched to scenarios
 	go func() {
 		defer logs.Close()
-		io.Copy(logFile, logs)
+		io.Copy(runlog.NewTimestampWriter(logFile), logs)
 	}()
 }
 
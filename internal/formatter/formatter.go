@@ -4,10 +4,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/cucumber/godog"
 	"github.com/cucumber/godog/formatters"
 	messages "github.com/cucumber/messages/go/v21"
+	"github.com/tomatool/tomato/internal/report"
 )
 
 // Event types for structured output
@@ -29,35 +34,76 @@ type Event struct {
 	Status   string `json:"status,omitempty"`
 	Error    string `json:"error,omitempty"`
 	File     string `json:"file,omitempty"`
+	Retry    int    `json:"retry,omitempty"` // retry attempt of the scenario, 0 for the first run
+
+	// Timing of scenario and step events. Scenario durations include Reset
+	// and Hooks, the time spent resetting resources and in scenario hooks.
+	StartedAt  time.Time     `json:"started_at,omitzero"`
+	FinishedAt time.Time     `json:"finished_at,omitzero"`
+	Duration   time.Duration `json:"duration,omitempty"`
+	Reset      time.Duration `json:"reset,omitempty"`
+	Hooks      time.Duration `json:"hooks,omitempty"`
 
 	// Summary fields
 	Total   int `json:"total,omitempty"`
 	Passed  int `json:"passed,omitempty"`
 	Failed  int `json:"failed,omitempty"`
 	Skipped int `json:"skipped,omitempty"`
+	Retried int `json:"retried,omitempty"` // scenarios that were retried
+	Flaky   int `json:"flaky,omitempty"`   // scenarios that passed on a retry
+
+	SlowestScenarios []Timing `json:"slowest_scenarios,omitempty"`
+	SlowestSteps     []Timing `json:"slowest_steps,omitempty"`
+}
+
+// Timing is a slow scenario or step listed in the summary
+type Timing struct {
+	Scenario string        `json:"scenario"`
+	Step     string        `json:"step,omitempty"`
+	File     string        `json:"file"` // file:line
+	Duration time.Duration `json:"duration"`
+}
+
+// slowestCount is the number of slowest scenarios and steps in the summary
+const slowestCount = 5
+
+// Timings provides the timing of scenarios and steps, which is measured by
+// the runner's hooks. report.Recorder implements it.
+type Timings interface {
+	Scenario(pickleID string) (report.Scenario, bool)
+	Step(stepID string) (report.Step, bool)
 }
 
-// TomatoFormatter outputs structured JSON events for UI parsing
+var (
+	timingsMu sync.Mutex
+	timings   Timings
+)
+
+// SetTimings sets the source of timings for the events of tomato formatters
+// created afterwards
+func SetTimings(t Timings) {
+	timingsMu.Lock()
+	defer timingsMu.Unlock()
+	timings = t
+}
+
+// TomatoFormatter outputs structured JSON events for UI parsing. The summary
+// event is written by the runner with WriteSummary once retries are done.
 type TomatoFormatter struct {
-	out io.Writer
+	out     io.Writer
+	retry   int
+	timings Timings // nil if there is no runner measuring timings
 
 	// Track current context
 	currentFeature     string
 	currentFeatureFile string
 	currentScenario    string
 	currentScenarioErr string
+	currentPickleID    string
 
 	// Track scenario status
 	scenarioHadFailure bool
-
-	// Counters
-	scenarioTotal   int
-	scenarioPassed  int
-	scenarioFailed  int
-	scenarioSkipped int
-	stepsPassed     int
-	stepsFailed     int
-	stepsSkipped    int
+	scenarioHadPass    bool
 }
 
 func init() {
@@ -66,14 +112,39 @@ func init() {
 
 // TomatoFormatterFunc creates a new TomatoFormatter
 func TomatoFormatterFunc(suite string, out io.Writer) formatters.Formatter {
+	timingsMu.Lock()
+	defer timingsMu.Unlock()
 	return &TomatoFormatter{
-		out: out,
+		out:     out,
+		retry:   retryAttempt(suite),
+		timings: timings,
 	}
 }
 
+// RetrySuiteName returns the godog suite name for retry attempt n. Formatters
+// created for such a suite mark their scenario and step events as retries.
+func RetrySuiteName(suite string, attempt int) string {
+	return fmt.Sprintf("%s (retry %d)", suite, attempt)
+}
+
+func retryAttempt(suite string) int {
+	var attempt int
+	if i := strings.LastIndex(suite, " (retry "); i >= 0 {
+		fmt.Sscanf(suite[i:], " (retry %d)", &attempt)
+	}
+	return attempt
+}
+
 func (f *TomatoFormatter) emit(event Event) {
+	if event.Type != EventFeatureStart && event.Type != EventFeatureEnd {
+		event.Retry = f.retry
+	}
+	writeEvent(f.out, event)
+}
+
+func writeEvent(out io.Writer, event Event) {
 	data, _ := json.Marshal(event)
-	fmt.Fprintf(f.out, "TOMATO_EVENT:%s\n", string(data))
+	fmt.Fprintf(out, "TOMATO_EVENT:%s\n", string(data))
 }
 
 // TestRunStarted is called when the test run starts
@@ -81,7 +152,8 @@ func (f *TomatoFormatter) TestRunStarted() {}
 
 // Feature is called when a feature file is parsed
 func (f *TomatoFormatter) Feature(doc *messages.GherkinDocument, uri string, content []byte) {
-	// Emit end of previous feature if any
+	// Emit end of previous scenario and feature if any
+	f.emitScenarioEndIfNeeded()
 	if f.currentFeature != "" {
 		f.emit(Event{
 			Type:    EventFeatureEnd,
@@ -107,15 +179,53 @@ func (f *TomatoFormatter) Pickle(pickle *messages.Pickle) {
 
 	f.currentScenario = pickle.Name
 	f.currentScenarioErr = ""
+	f.currentPickleID = pickle.Id
 	f.scenarioHadFailure = false
-	f.scenarioTotal++
+	f.scenarioHadPass = false
 
 	f.emit(Event{
-		Type:     EventScenarioStart,
+		Type:      EventScenarioStart,
+		Feature:   f.currentFeature,
+		Scenario:  pickle.Name,
+		File:      f.currentFeatureFile,
+		StartedAt: time.Now(),
+	})
+}
+
+// scenarioTiming adds the recorded timing of the current scenario to event
+func (f *TomatoFormatter) scenarioTiming(event Event) Event {
+	if f.timings == nil {
+		return event
+	}
+	if sc, ok := f.timings.Scenario(f.currentPickleID); ok && !sc.StartedAt.IsZero() {
+		event.StartedAt = sc.StartedAt
+		event.FinishedAt = sc.StartedAt.Add(sc.Duration)
+		event.Duration = sc.Duration
+		event.Reset = sc.Reset
+		event.Hooks = sc.Hooks
+	}
+	return event
+}
+
+// stepEnd creates the step_end event of a step, with its recorded timing
+func (f *TomatoFormatter) stepEnd(pickle *messages.Pickle, step *messages.PickleStep, status, errMsg string) Event {
+	event := Event{
+		Type:     EventStepEnd,
 		Feature:  f.currentFeature,
 		Scenario: pickle.Name,
-		File:     f.currentFeatureFile,
-	})
+		Step:     step.Text,
+		Status:   status,
+		Error:    errMsg,
+	}
+	if f.timings == nil {
+		return event
+	}
+	if st, ok := f.timings.Step(step.Id); ok && !st.StartedAt.IsZero() {
+		event.StartedAt = st.StartedAt
+		event.FinishedAt = st.StartedAt.Add(st.Duration)
+		event.Duration = st.Duration
+	}
+	return event
 }
 
 func (f *TomatoFormatter) emitScenarioEndIfNeeded() {
@@ -123,25 +233,22 @@ func (f *TomatoFormatter) emitScenarioEndIfNeeded() {
 		return
 	}
 
-	status := "passed"
-	if f.scenarioHadFailure {
+	// A scenario without passed or failed steps was skipped
+	status := "skipped"
+	switch {
+	case f.scenarioHadFailure:
 		status = "failed"
+	case f.scenarioHadPass:
+		status = "passed"
 	}
 
-	f.emit(Event{
+	f.emit(f.scenarioTiming(Event{
 		Type:     EventScenarioEnd,
 		Feature:  f.currentFeature,
 		Scenario: f.currentScenario,
 		Status:   status,
 		Error:    f.currentScenarioErr,
-	})
-
-	// Update counters
-	if f.scenarioHadFailure {
-		f.scenarioFailed++
-	} else {
-		f.scenarioPassed++
-	}
+	}))
 
 	f.currentScenario = ""
 }
@@ -152,19 +259,12 @@ func (f *TomatoFormatter) Defined(pickle *messages.Pickle, step *messages.Pickle
 
 // Passed is called when a step passes
 func (f *TomatoFormatter) Passed(pickle *messages.Pickle, step *messages.PickleStep, def *formatters.StepDefinition) {
-	f.stepsPassed++
-	f.emit(Event{
-		Type:     EventStepEnd,
-		Feature:  f.currentFeature,
-		Scenario: pickle.Name,
-		Step:     step.Text,
-		Status:   "passed",
-	})
+	f.scenarioHadPass = true
+	f.emit(f.stepEnd(pickle, step, "passed", ""))
 }
 
 // Failed is called when a step fails
 func (f *TomatoFormatter) Failed(pickle *messages.Pickle, step *messages.PickleStep, def *formatters.StepDefinition, err error) {
-	f.stepsFailed++
 	f.scenarioHadFailure = true
 
 	errMsg := ""
@@ -173,59 +273,29 @@ func (f *TomatoFormatter) Failed(pickle *messages.Pickle, step *messages.PickleS
 		f.currentScenarioErr = errMsg
 	}
 
-	f.emit(Event{
-		Type:     EventStepEnd,
-		Feature:  f.currentFeature,
-		Scenario: pickle.Name,
-		Step:     step.Text,
-		Status:   "failed",
-		Error:    errMsg,
-	})
+	f.emit(f.stepEnd(pickle, step, "failed", errMsg))
 }
 
 // Skipped is called when a step is skipped
 func (f *TomatoFormatter) Skipped(pickle *messages.Pickle, step *messages.PickleStep, def *formatters.StepDefinition) {
-	f.stepsSkipped++
-	f.emit(Event{
-		Type:     EventStepEnd,
-		Feature:  f.currentFeature,
-		Scenario: pickle.Name,
-		Step:     step.Text,
-		Status:   "skipped",
-	})
+	f.emit(f.stepEnd(pickle, step, "skipped", ""))
 }
 
 // Undefined is called when a step has no matching definition
 func (f *TomatoFormatter) Undefined(pickle *messages.Pickle, step *messages.PickleStep, def *formatters.StepDefinition) {
-	f.stepsFailed++
 	f.scenarioHadFailure = true
 	f.currentScenarioErr = fmt.Sprintf("step undefined: %s", step.Text)
 
-	f.emit(Event{
-		Type:     EventStepEnd,
-		Feature:  f.currentFeature,
-		Scenario: pickle.Name,
-		Step:     step.Text,
-		Status:   "undefined",
-		Error:    "step undefined",
-	})
+	f.emit(f.stepEnd(pickle, step, "undefined", "step undefined"))
 }
 
 // Pending is called when a step is pending
 func (f *TomatoFormatter) Pending(pickle *messages.Pickle, step *messages.PickleStep, def *formatters.StepDefinition) {
-	f.stepsSkipped++
-	f.emit(Event{
-		Type:     EventStepEnd,
-		Feature:  f.currentFeature,
-		Scenario: pickle.Name,
-		Step:     step.Text,
-		Status:   "pending",
-	})
+	f.emit(f.stepEnd(pickle, step, "pending", ""))
 }
 
 // Ambiguous is called when a step matches multiple definitions
 func (f *TomatoFormatter) Ambiguous(pickle *messages.Pickle, step *messages.PickleStep, def *formatters.StepDefinition, err error) {
-	f.stepsFailed++
 	f.scenarioHadFailure = true
 
 	errMsg := "ambiguous step"
@@ -234,14 +304,7 @@ func (f *TomatoFormatter) Ambiguous(pickle *messages.Pickle, step *messages.Pick
 		f.currentScenarioErr = errMsg
 	}
 
-	f.emit(Event{
-		Type:     EventStepEnd,
-		Feature:  f.currentFeature,
-		Scenario: pickle.Name,
-		Step:     step.Text,
-		Status:   "ambiguous",
-		Error:    errMsg,
-	})
+	f.emit(f.stepEnd(pickle, step, "ambiguous", errMsg))
 }
 
 // Summary is called after all tests complete
@@ -256,34 +319,123 @@ func (f *TomatoFormatter) Summary() {
 			Feature: f.currentFeature,
 		})
 	}
+}
 
-	// Emit summary
-	f.emit(Event{
-		Type:    EventSummary,
-		Total:   f.scenarioTotal,
-		Passed:  f.scenarioPassed,
-		Failed:  f.scenarioFailed,
-		Skipped: f.scenarioSkipped,
+// WriteSummary writes the summary event and a human-readable summary of a
+// finished run, including scenarios that were retried
+func WriteSummary(out io.Writer, run *report.Run) {
+	passed, failed, skipped := run.Counts()
+	slowestScenarios, slowestSteps := slowest(run, slowestCount)
+	writeEvent(out, Event{
+		Type:             EventSummary,
+		Total:            len(run.Scenarios),
+		Passed:           passed,
+		Failed:           failed,
+		Skipped:          skipped,
+		Retried:          run.Retried(),
+		Flaky:            len(run.Flaky()),
+		Duration:         run.Duration,
+		SlowestScenarios: slowestScenarios,
+		SlowestSteps:     slowestSteps,
 	})
 
-	// Also print human-readable summary
-	fmt.Fprintln(f.out)
-	fmt.Fprintf(f.out, "%d scenarios (%d passed", f.scenarioTotal, f.scenarioPassed)
-	if f.scenarioFailed > 0 {
-		fmt.Fprintf(f.out, ", %d failed", f.scenarioFailed)
+	var stepsPassed, stepsFailed, stepsSkipped int
+	for _, sc := range run.Scenarios {
+		for _, step := range sc.Steps {
+			switch step.Status {
+			case report.StatusPassed:
+				stepsPassed++
+			case report.StatusSkipped, report.StatusPending:
+				stepsSkipped++
+			default:
+				stepsFailed++
+			}
+		}
+	}
+
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "%d scenarios (%d passed", len(run.Scenarios), passed)
+	if failed > 0 {
+		fmt.Fprintf(out, ", %d failed", failed)
+	}
+	if skipped > 0 {
+		fmt.Fprintf(out, ", %d skipped", skipped)
+	}
+	fmt.Fprintln(out, ")")
+
+	totalSteps := stepsPassed + stepsFailed + stepsSkipped
+	fmt.Fprintf(out, "%d steps (%d passed", totalSteps, stepsPassed)
+	if stepsFailed > 0 {
+		fmt.Fprintf(out, ", %d failed", stepsFailed)
+	}
+	if stepsSkipped > 0 {
+		fmt.Fprintf(out, ", %d skipped", stepsSkipped)
+	}
+	fmt.Fprintln(out, ")")
+	fmt.Fprintln(out, run.Duration.Round(time.Millisecond))
+
+	if len(slowestScenarios) > 0 {
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "Slowest scenarios:")
+		for _, t := range slowestScenarios {
+			fmt.Fprintf(out, "  %9s  %s (%s)\n", t.Duration.Round(time.Millisecond), t.Scenario, t.File)
+		}
+	}
+	if len(slowestSteps) > 0 {
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "Slowest steps:")
+		for _, t := range slowestSteps {
+			fmt.Fprintf(out, "  %9s  %s (%s)\n", t.Duration.Round(time.Millisecond), t.Step, t.File)
+		}
+	}
+
+	WriteRetries(out, run)
+}
+
+// slowest returns the n slowest scenarios and steps of a run that ran
+func slowest(run *report.Run, n int) (scenarios, steps []Timing) {
+	for _, sc := range run.Scenarios {
+		if sc.Status == report.StatusSkipped || sc.Duration == 0 {
+			continue
+		}
+		scenarios = append(scenarios, Timing{Scenario: sc.Name, File: sc.Location(), Duration: sc.Duration})
+		for _, step := range sc.Steps {
+			if step.Status == report.StatusSkipped || step.Duration == 0 {
+				continue
+			}
+			steps = append(steps, Timing{
+				Scenario: sc.Name,
+				Step:     strings.TrimSpace(step.Keyword + step.Text),
+				File:     fmt.Sprintf("%s:%d", sc.URI, step.Line),
+				Duration: step.Duration,
+			})
+		}
 	}
-	if f.scenarioSkipped > 0 {
-		fmt.Fprintf(f.out, ", %d skipped", f.scenarioSkipped)
+	return slowestN(scenarios, n), slowestN(steps, n)
+}
+
+func slowestN(timings []Timing, n int) []Timing {
+	sort.SliceStable(timings, func(i, j int) bool {
+		return timings[i].Duration > timings[j].Duration
+	})
+	if len(timings) > n {
+		timings = timings[:n]
 	}
-	fmt.Fprintln(f.out, ")")
+	return timings
+}
 
-	totalSteps := f.stepsPassed + f.stepsFailed + f.stepsSkipped
-	fmt.Fprintf(f.out, "%d steps (%d passed", totalSteps, f.stepsPassed)
-	if f.stepsFailed > 0 {
-		fmt.Fprintf(f.out, ", %d failed", f.stepsFailed)
+// WriteRetries lists the scenarios that were retried and their outcome,
+// so that flaky scenarios are not hidden by a passing run
+func WriteRetries(out io.Writer, run *report.Run) {
+	if run.Retried() == 0 {
+		return
 	}
-	if f.stepsSkipped > 0 {
-		fmt.Fprintf(f.out, ", %d skipped", f.stepsSkipped)
+
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "%d scenarios retried (%d flaky)\n", run.Retried(), len(run.Flaky()))
+	for _, sc := range run.Scenarios {
+		if sc.Retries > 0 {
+			fmt.Fprintf(out, "  %s: %s (%s)\n", sc.Name, sc.Outcome(), sc.Location())
+		}
 	}
-	fmt.Fprintln(f.out, ")")
 }
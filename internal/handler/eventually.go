@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultPollInterval is the delay between attempts of a polled assertion
+const DefaultPollInterval = 100 * time.Millisecond
+
+type (
+	eventuallyKey   struct{}
+	pollIntervalKey struct{}
+)
+
+// WithEventually returns a copy of ctx in which every assertion step is
+// polled for up to d, as if it had a `within` suffix
+func WithEventually(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, eventuallyKey{}, d)
+}
+
+// EventuallyTimeout returns how long assertions are polled, or 0 if they run once
+func EventuallyTimeout(ctx context.Context) time.Duration {
+	if ctx == nil {
+		return 0
+	}
+	d, _ := ctx.Value(eventuallyKey{}).(time.Duration)
+	return d
+}
+
+// WithPollInterval returns a copy of ctx carrying the delay between attempts of polled assertions
+func WithPollInterval(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, pollIntervalKey{}, d)
+}
+
+// PollInterval returns the delay between attempts of polled assertions
+func PollInterval(ctx context.Context) time.Duration {
+	if ctx != nil {
+		if d, ok := ctx.Value(pollIntervalKey{}).(time.Duration); ok && d > 0 {
+			return d
+		}
+	}
+	return DefaultPollInterval
+}
+
+// poll runs attempt until it succeeds or timeout expires, returning the
+// error of the last attempt. The last attempt is made at the deadline.
+func poll(ctx context.Context, timeout time.Duration, attempt func(context.Context) error) error {
+	deadline := time.Now().Add(timeout)
+	interval := PollInterval(ctx)
+
+	for attempts := 1; ; attempts++ {
+		err := attempt(ctx)
+		if err == nil {
+			return nil
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return fmt.Errorf("still failing after %s (%d attempts): %w", timeout, attempts, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(min(interval, remaining)):
+		}
+	}
+}
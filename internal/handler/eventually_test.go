@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cucumber/godog"
+)
+
+func TestWithinPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{
+			pattern: `^"db" table "([^"]*)" has "(\d+)" rows$`,
+			want:    `^"db" table "([^"]*)" has "(\d+)" rows within "([^"]*)"$`,
+		},
+		{
+			pattern: `^"db" table "([^"]*)" contains:$`,
+			want:    `^"db" table "([^"]*)" contains within "([^"]*)":$`,
+		},
+	}
+
+	for _, tt := range tests {
+		if got := withinPattern(tt.pattern); got != tt.want {
+			t.Errorf("withinPattern(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestIsAssertion(t *testing.T) {
+	tests := []struct {
+		group string
+		want  bool
+	}{
+		{"Assertions", true},
+		{"String Assertions", true},
+		{"Verification", true},
+		{"Response Status", false},
+		{"Data Setup", false},
+	}
+
+	for _, tt := range tests {
+		if got := (StepDef{Group: tt.group}).IsAssertion(); got != tt.want {
+			t.Errorf("IsAssertion(%q) = %v, want %v", tt.group, got, tt.want)
+		}
+	}
+}
+
+// flakyStep fails until it has been called n times
+func flakyStep(n int, calls *int) func(context.Context, string, *godog.DocString) error {
+	return func(ctx context.Context, table string, doc *godog.DocString) error {
+		*calls++
+		if *calls < n {
+			return errors.New("row not found")
+		}
+		return nil
+	}
+}
+
+func TestWrapWithin(t *testing.T) {
+	ctx := WithPollInterval(context.Background(), time.Millisecond)
+	doc := &godog.DocString{Content: "id: 1"}
+
+	var calls int
+	wrapped := wrapWithin(flakyStep(3, &calls), StepMatch{}).(func(context.Context, string, string, *godog.DocString) error)
+	if err := wrapped(ctx, "orders", "1s", doc); err != nil {
+		t.Fatalf("expected assertion to pass eventually, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls)
+	}
+
+	failing := wrapWithin(flakyStep(1000, &calls), StepMatch{}).(func(context.Context, string, string, *godog.DocString) error)
+	err := failing(ctx, "orders", "20ms", doc)
+	if err == nil || !strings.Contains(err.Error(), "still failing after 20ms") || !strings.Contains(err.Error(), "row not found") {
+		t.Fatalf("expected polling error with last failure, got %v", err)
+	}
+
+	if err := wrapped(ctx, "orders", "soon", doc); err == nil || !strings.Contains(err.Error(), `invalid duration "soon"`) {
+		t.Fatalf("expected invalid duration error, got %v", err)
+	}
+}
+
+func TestWrapStepEventually(t *testing.T) {
+	ctx := WithPollInterval(context.Background(), time.Millisecond)
+	doc := &godog.DocString{Content: "id: 1"}
+
+	// Without @eventually an assertion runs once
+	var calls int
+	wrapped := wrapStep(flakyStep(3, &calls), true, StepMatch{}).(func(context.Context, string, *godog.DocString) error)
+	if err := wrapped(ctx, "orders", doc); err == nil || calls != 1 {
+		t.Fatalf("expected a single failing attempt, got %d attempts (err %v)", calls, err)
+	}
+
+	calls = 0
+	if err := wrapped(WithEventually(ctx, time.Second), "orders", doc); err != nil || calls != 3 {
+		t.Fatalf("expected assertion to pass on attempt 3, got %d attempts (err %v)", calls, err)
+	}
+
+	// Non-assertion steps are never retried
+	calls = 0
+	action := wrapStep(flakyStep(3, &calls), false, StepMatch{}).(func(context.Context, string, *godog.DocString) error)
+	if err := action(WithEventually(ctx, time.Second), "orders", doc); err == nil || calls != 1 {
+		t.Fatalf("expected a single failing attempt, got %d attempts (err %v)", calls, err)
+	}
+}
@@ -38,6 +38,17 @@ type MessagePublisher interface {
 	Publish(ctx context.Context, target string, payload []byte, headers map[string]string) error
 }
 
+// HTTPRequester is implemented by handlers that can send a single HTTP request.
+// A relative target is resolved against the handler's base URL.
+type HTTPRequester interface {
+	Request(ctx context.Context, method, target string, headers map[string]string, body []byte) (int, []byte, error)
+}
+
+// CommandExecutor is implemented by handlers that accept raw commands (e.g. Redis)
+type CommandExecutor interface {
+	ExecCommand(ctx context.Context, args []string) (string, error)
+}
+
 // MessageConsumer is implemented by handlers that can consume messages
 type MessageConsumer interface {
 	Consume(ctx context.Context, target string, timeout int) ([]byte, error)
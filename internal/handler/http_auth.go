@@ -0,0 +1,459 @@
+package handler
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/tomatool/tomato/internal/config"
+)
+
+// tokenExpiryLeeway is how long before it expires a token is refreshed, so
+// that it doesn't expire on its way to the app
+const tokenExpiryLeeway = 10 * time.Second
+
+// httpAuth authenticates the requests of an HTTP client, set with
+// options.auth. Profiles override the fields of options.auth, e.g. the
+// username and password of each user a scenario can authenticate as.
+type httpAuth struct {
+	scheme         string // basic, bearer, oauth2 or hmac
+	fields         map[string]string
+	profiles       map[string]map[string]string
+	defaultProfile string
+
+	// Tokens fetched from the token endpoint, by credentials. They are kept
+	// across scenarios and refreshed when they expire.
+	mu     sync.Mutex
+	tokens map[string]*authToken
+}
+
+type authToken struct {
+	access  string
+	refresh string
+	expiry  time.Time // zero when the token endpoint didn't tell
+}
+
+func (t *authToken) valid(now time.Time) bool {
+	return t.expiry.IsZero() || now.Add(tokenExpiryLeeway).Before(t.expiry)
+}
+
+// authRequired lists the fields each scheme needs, after the fields of the
+// profile are applied
+var authRequired = map[string][]string{
+	"basic":                     {"username"},
+	"bearer":                    {"token"},
+	"oauth2:client_credentials": {"token_url", "client_id"},
+	"oauth2:password":           {"token_url", "username", "password"},
+	"hmac":                      {"secret"},
+}
+
+// loadAuth reads options.auth. It returns nil when the option isn't set.
+func loadAuth(cfg config.Resource) (*httpAuth, error) {
+	raw, ok := cfg.Options["auth"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	opts, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("options.auth must be a map with a type key")
+	}
+
+	a := &httpAuth{
+		fields:   make(map[string]string),
+		profiles: make(map[string]map[string]string),
+		tokens:   make(map[string]*authToken),
+	}
+	for k, v := range opts {
+		switch k {
+		case "type":
+			a.scheme, _ = v.(string)
+		case "default":
+			a.defaultProfile, _ = v.(string)
+		case "profiles":
+			profiles, ok := v.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("options.auth.profiles must map profile names to their fields")
+			}
+			for name, p := range profiles {
+				fields, ok := p.(map[string]any)
+				if !ok {
+					return nil, fmt.Errorf("options.auth.profiles.%s must be a map of fields", name)
+				}
+				a.profiles[name] = authFields(fields)
+			}
+		default:
+			maps.Copy(a.fields, authFields(map[string]any{k: v}))
+		}
+	}
+
+	switch a.scheme {
+	case "basic", "bearer", "hmac":
+	case "oauth2":
+		switch a.fields["grant"] {
+		case "":
+			a.fields["grant"] = "client_credentials"
+		case "client_credentials", "password":
+		default:
+			return nil, fmt.Errorf("options.auth: unknown OAuth2 grant %q (client_credentials, password)", a.fields["grant"])
+		}
+	default:
+		return nil, fmt.Errorf("options.auth: unknown type %q (basic, bearer, oauth2, hmac)", a.scheme)
+	}
+	if a.defaultProfile != "" && a.profiles[a.defaultProfile] == nil {
+		return nil, fmt.Errorf("options.auth: default profile %q is not in profiles", a.defaultProfile)
+	}
+
+	// With profiles, options.auth alone may lack credentials; it's checked
+	// when a request uses it
+	if len(a.profiles) == 0 {
+		if err := a.checkFields("options.auth", a.fields); err != nil {
+			return nil, err
+		}
+	}
+	for name := range a.profiles {
+		if err := a.checkFields("options.auth profile "+name, a.merge(name)); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// authFields converts option values to strings; lists, such as scopes, are
+// joined with spaces
+func authFields(opts map[string]any) map[string]string {
+	fields := make(map[string]string, len(opts))
+	for k, v := range opts {
+		if k == "scopes" {
+			k = "scope"
+		}
+		switch v := v.(type) {
+		case string:
+			fields[k] = v
+		case []any:
+			parts := make([]string, len(v))
+			for i, p := range v {
+				parts[i] = fmt.Sprint(p)
+			}
+			fields[k] = strings.Join(parts, " ")
+		default:
+			fields[k] = fmt.Sprint(v)
+		}
+	}
+	return fields
+}
+
+func (a *httpAuth) checkFields(subject string, fields map[string]string) error {
+	key := a.scheme
+	if a.scheme == "oauth2" {
+		key += ":" + fields["grant"]
+	}
+	var missing []string
+	for _, f := range authRequired[key] {
+		if fields[f] == "" {
+			missing = append(missing, f)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s: %s auth needs %s", subject, a.scheme, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// merge returns the fields of options.auth with those of a profile applied
+func (a *httpAuth) merge(profile string) map[string]string {
+	fields := maps.Clone(a.fields)
+	if profile == "" {
+		profile = a.defaultProfile
+	}
+	maps.Copy(fields, a.profiles[profile])
+	return fields
+}
+
+// credentials returns the fields a request authenticates with, with the
+// variables of the scenario replaced
+func (a *httpAuth) credentials(ctx context.Context, profile string) (map[string]string, error) {
+	fields := a.merge(profile)
+	vars := VariablesFrom(ctx)
+	for k, v := range fields {
+		fields[k] = vars.Replace(v)
+	}
+	if profile == "" {
+		profile = a.defaultProfile
+	}
+	if profile == "" {
+		if err := a.checkFields("options.auth", fields); err != nil {
+			return nil, fmt.Errorf("%w; pick a profile with \"authenticates as\" (%s)", err, strings.Join(a.profileNames(), ", "))
+		}
+	} else if err := a.checkFields("auth profile "+profile, fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+func (a *httpAuth) profileNames() []string {
+	return slices.Sorted(maps.Keys(a.profiles))
+}
+
+// secretHeaders returns the headers apply sets that carry credentials,
+// besides Authorization
+func (a *httpAuth) secretHeaders() []string {
+	var headers []string
+	if a.scheme == "hmac" {
+		headers = append(headers, a.fields["header"])
+		for _, p := range a.profiles {
+			headers = append(headers, p["header"])
+		}
+	}
+	return slices.DeleteFunc(headers, func(h string) bool { return h == "" })
+}
+
+// apply authenticates a request. body is the request body, which HMAC
+// signatures cover.
+func (a *httpAuth) apply(ctx context.Context, r *HTTPClient, req *http.Request, body []byte, profile string) error {
+	fields, err := a.credentials(ctx, profile)
+	if err != nil {
+		return err
+	}
+	switch a.scheme {
+	case "basic":
+		req.SetBasicAuth(fields["username"], fields["password"])
+	case "bearer":
+		req.Header.Set("Authorization", "Bearer "+fields["token"])
+	case "oauth2":
+		token, err := a.token(ctx, r, fields)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token.access)
+	case "hmac":
+		return signHMAC(req, body, fields, time.Now())
+	}
+	return nil
+}
+
+// accessToken returns the token requests are authenticated with, for the
+// schemes that have one
+func (a *httpAuth) accessToken(ctx context.Context, r *HTTPClient, profile string) (string, error) {
+	fields, err := a.credentials(ctx, profile)
+	if err != nil {
+		return "", err
+	}
+	switch a.scheme {
+	case "bearer":
+		return fields["token"], nil
+	case "oauth2":
+		token, err := a.token(ctx, r, fields)
+		if err != nil {
+			return "", err
+		}
+		return token.access, nil
+	}
+	return "", fmt.Errorf("%s auth has no access token", a.scheme)
+}
+
+// token returns a cached token for the credentials, refreshing it or
+// fetching a new one when it expires
+func (a *httpAuth) token(ctx context.Context, r *HTTPClient, fields map[string]string) (*authToken, error) {
+	key := tokenCacheKey(fields)
+
+	// Holding the lock while fetching lets concurrent requests share a token
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	cached := a.tokens[key]
+	if cached != nil && cached.valid(time.Now()) {
+		return cached, nil
+	}
+	if cached != nil && cached.refresh != "" {
+		form := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {cached.refresh}}
+		token, err := fetchToken(ctx, r, fields, form)
+		if err == nil {
+			if token.refresh == "" {
+				token.refresh = cached.refresh
+			}
+			a.tokens[key] = token
+			return token, nil
+		}
+		log.Debug().Err(err).Str("resource", r.name).Msg("token refresh failed, requesting a new token")
+	}
+
+	form := url.Values{"grant_type": {fields["grant"]}}
+	if fields["grant"] == "password" {
+		form.Set("username", fields["username"])
+		form.Set("password", fields["password"])
+	}
+	for _, f := range []string{"scope", "audience"} {
+		if fields[f] != "" {
+			form.Set(f, fields[f])
+		}
+	}
+	token, err := fetchToken(ctx, r, fields, form)
+	if err != nil {
+		return nil, err
+	}
+	a.tokens[key] = token
+	return token, nil
+}
+
+func tokenCacheKey(fields map[string]string) string {
+	var sb strings.Builder
+	for _, k := range slices.Sorted(maps.Keys(fields)) {
+		fmt.Fprintf(&sb, "%s=%q;", k, fields[k])
+	}
+	return sb.String()
+}
+
+// fetchToken requests a token from the token endpoint. The client
+// authenticates with HTTP basic auth, or with form fields when client_auth
+// is body.
+func fetchToken(ctx context.Context, r *HTTPClient, fields map[string]string, form url.Values) (*authToken, error) {
+	tokenURL := fields["token_url"]
+	if !strings.HasPrefix(tokenURL, "http://") && !strings.HasPrefix(tokenURL, "https://") {
+		tokenURL = r.baseURL + tokenURL
+	}
+	if fields["client_auth"] == "body" {
+		form.Set("client_id", fields["client_id"])
+		if fields["client_secret"] != "" {
+			form.Set("client_secret", fields["client_secret"])
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("creating token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if fields["client_id"] != "" && fields["client_auth"] != "body" {
+		req.SetBasicAuth(url.QueryEscape(fields["client_id"]), url.QueryEscape(fields["client_secret"]))
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("requesting %s token: %w", form.Get("grant_type"), tlsHandshakeError(err, req.URL.Host))
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading token response: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		msg := string(body)
+		if len(msg) > 500 {
+			msg = msg[:500] + "..."
+		}
+		return nil, fmt.Errorf("token endpoint %s returned %d for the %s grant: %s", tokenURL, resp.StatusCode, form.Get("grant_type"), msg)
+	}
+
+	var parsed struct {
+		AccessToken  string      `json:"access_token"`
+		RefreshToken string      `json:"refresh_token"`
+		ExpiresIn    json.Number `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, fmt.Errorf("invalid token response from %s: %w", tokenURL, err)
+	}
+	if parsed.AccessToken == "" {
+		return nil, fmt.Errorf("token response from %s has no access_token", tokenURL)
+	}
+	token := &authToken{access: parsed.AccessToken, refresh: parsed.RefreshToken}
+	if parsed.ExpiresIn != "" {
+		seconds, err := strconv.ParseFloat(parsed.ExpiresIn.String(), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expires_in in token response from %s: %w", tokenURL, err)
+		}
+		token.expiry = time.Now().Add(time.Duration(seconds * float64(time.Second)))
+	}
+	log.Debug().Str("resource", r.name).Str("grant", form.Get("grant_type")).Msg("fetched token")
+	return token, nil
+}
+
+// HMAC signature defaults: the signature covers the method, the path with
+// the query, the timestamp and the SHA-256 of the body
+const (
+	defaultStringToSign = "{method}\n{path}\n{timestamp}\n{body_sha256}"
+	defaultSignature    = "HMAC {key_id}:{signature}"
+)
+
+// signHMAC signs a request with the secret of the fields. The string to
+// sign, the header and its value are templates of {method}, {path}, {host},
+// {timestamp}, {body}, {body_sha256} and {key_id}.
+func signHMAC(req *http.Request, body []byte, fields map[string]string, now time.Time) error {
+	var newHash func() hash.Hash
+	switch strings.ToLower(fields["algorithm"]) {
+	case "", "sha256":
+		newHash = sha256.New
+	case "sha512":
+		newHash = sha512.New
+	case "sha1":
+		newHash = sha1.New
+	default:
+		return fmt.Errorf("hmac auth: unknown algorithm %q (sha256, sha512, sha1)", fields["algorithm"])
+	}
+
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	bodySum := sha256.Sum256(body)
+	template := fields["string_to_sign"]
+	if template == "" {
+		template = defaultStringToSign
+	}
+	toSign := strings.NewReplacer(
+		"{method}", req.Method,
+		"{path}", req.URL.RequestURI(),
+		"{host}", req.URL.Host,
+		"{timestamp}", timestamp,
+		"{body}", string(body),
+		"{body_sha256}", hex.EncodeToString(bodySum[:]),
+		"{key_id}", fields["key_id"],
+	).Replace(template)
+
+	mac := hmac.New(newHash, []byte(fields["secret"]))
+	mac.Write([]byte(toSign))
+	var signature string
+	switch fields["encoding"] {
+	case "", "hex":
+		signature = hex.EncodeToString(mac.Sum(nil))
+	case "base64":
+		signature = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	default:
+		return fmt.Errorf("hmac auth: unknown encoding %q (hex, base64)", fields["encoding"])
+	}
+
+	value := fields["signature"]
+	if value == "" {
+		value = defaultSignature
+	}
+	header := fields["header"]
+	if header == "" {
+		header = "Authorization"
+	}
+	req.Header.Set(header, strings.NewReplacer(
+		"{key_id}", fields["key_id"],
+		"{signature}", signature,
+		"{timestamp}", timestamp,
+	).Replace(value))
+
+	timestampHeader := fields["timestamp_header"]
+	if timestampHeader == "" {
+		timestampHeader = "X-Timestamp"
+	}
+	req.Header.Set(timestampHeader, timestamp)
+	return nil
+}
@@ -0,0 +1,335 @@
+package handler
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tomatool/tomato/internal/config"
+)
+
+func TestLoadAuth(t *testing.T) {
+	tests := []struct {
+		name    string
+		auth    any
+		wantErr string
+	}{
+		{name: "basic", auth: map[string]any{"type": "basic", "username": "alice", "password": "secret"}},
+		{name: "oauth2 defaults to client credentials", auth: map[string]any{"type": "oauth2", "token_url": "/token", "client_id": "tomato"}},
+		{
+			name: "profiles",
+			auth: map[string]any{
+				"type": "oauth2", "grant": "password", "token_url": "/token", "default": "alice",
+				"profiles": map[string]any{
+					"alice": map[string]any{"username": "alice", "password": "a"},
+					"bob":   map[string]any{"username": "bob", "password": "b"},
+				},
+			},
+		},
+		{name: "not a map", auth: "basic", wantErr: "must be a map"},
+		{name: "unknown type", auth: map[string]any{"type": "digest"}, wantErr: `unknown type "digest"`},
+		{name: "unknown grant", auth: map[string]any{"type": "oauth2", "grant": "implicit"}, wantErr: `unknown OAuth2 grant "implicit"`},
+		{name: "missing fields", auth: map[string]any{"type": "oauth2", "grant": "password", "token_url": "/token"}, wantErr: "needs username, password"},
+		{
+			name:    "incomplete profile",
+			auth:    map[string]any{"type": "basic", "profiles": map[string]any{"alice": map[string]any{"password": "a"}}},
+			wantErr: "profile alice: basic auth needs username",
+		},
+		{
+			name:    "unknown default profile",
+			auth:    map[string]any{"type": "bearer", "token": "t", "default": "carol"},
+			wantErr: `default profile "carol" is not in profiles`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadAuth(config.Resource{Options: map[string]any{"auth": tt.auth}})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// tokenServer is an OAuth2 token endpoint and an API that echoes the
+// Authorization header it receives
+type tokenServer struct {
+	*httptest.Server
+	expiresIn int
+
+	mu     sync.Mutex
+	grants []string
+}
+
+func newTokenServer(t *testing.T, expiresIn int) *tokenServer {
+	ts := &tokenServer{expiresIn: expiresIn}
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/oauth/token" {
+			w.Write([]byte(r.Header.Get("Authorization")))
+			return
+		}
+		r.ParseForm()
+		grant := r.PostForm.Get("grant_type")
+		ts.mu.Lock()
+		ts.grants = append(ts.grants, grant)
+		n := len(ts.grants)
+		ts.mu.Unlock()
+
+		if id, secret, _ := r.BasicAuth(); id != "tomato" || secret != "s3cr3t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": "invalid_client"}`))
+			return
+		}
+		var subject string
+		switch grant {
+		case "client_credentials":
+			subject = "service"
+		case "password":
+			if r.PostForm.Get("password") != r.PostForm.Get("username")+"-pw" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error": "invalid_grant"}`))
+				return
+			}
+			subject = r.PostForm.Get("username")
+		case "refresh_token":
+			subject = strings.TrimPrefix(r.PostForm.Get("refresh_token"), "refresh-")
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  fmt.Sprintf("%s-%d", subject, n),
+			"token_type":    "Bearer",
+			"expires_in":    ts.expiresIn,
+			"refresh_token": "refresh-" + subject,
+		})
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func (ts *tokenServer) grantsRequested() []string {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return append([]string(nil), ts.grants...)
+}
+
+// authorization sends a request and returns the Authorization header the server received
+func authorization(t *testing.T, ctx context.Context, client *HTTPClient) string {
+	t.Helper()
+	if err := client.sendRequest(ctx, "GET", "/me"); err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	return string(client.state(ctx).lastBody)
+}
+
+func TestHTTPClient_AuthProfiles(t *testing.T) {
+	server := newTokenServer(t, 3600)
+	client := newTestHTTPClient(t, server.URL, map[string]any{
+		"auth": map[string]any{
+			"type":          "oauth2",
+			"grant":         "password",
+			"token_url":     "/oauth/token",
+			"client_id":     "tomato",
+			"client_secret": "s3cr3t",
+			"default":       "alice",
+			"profiles": map[string]any{
+				"alice": map[string]any{"username": "alice", "password": "alice-pw"},
+				"bob":   map[string]any{"username": "bob", "password": "{{bob_password}}"},
+			},
+		},
+	})
+
+	ctx := WithScenarioState(context.Background(), NewScenarioState())
+	if got := authorization(t, ctx, client); got != "Bearer alice-1" {
+		t.Errorf("default profile: Authorization = %q", got)
+	}
+	if got := authorization(t, ctx, client); got != "Bearer alice-1" {
+		t.Errorf("cached token: Authorization = %q", got)
+	}
+
+	VariablesFrom(ctx).Set("bob_password", "bob-pw")
+	if err := client.authenticateAs(ctx, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if got := authorization(t, ctx, client); got != "Bearer bob-2" {
+		t.Errorf("bob: Authorization = %q", got)
+	}
+	if err := client.saveAccessTokenToVariable(ctx, "token"); err != nil {
+		t.Fatal(err)
+	}
+	if got := VariablesFrom(ctx).Replace("{{token}}"); got != "bob-2" {
+		t.Errorf("saved token = %q", got)
+	}
+
+	if err := client.setHeader(ctx, "Authorization", "Bearer forged"); err != nil {
+		t.Fatal(err)
+	}
+	if got := authorization(t, ctx, client); got != "Bearer forged" {
+		t.Errorf("the header the scenario sets should win: Authorization = %q", got)
+	}
+
+	// Tokens are kept across scenarios; the profile isn't
+	other := WithScenarioState(context.Background(), NewScenarioState())
+	if got := authorization(t, other, client); got != "Bearer alice-1" {
+		t.Errorf("next scenario: Authorization = %q", got)
+	}
+	if err := client.stopAuthenticating(other); err != nil {
+		t.Fatal(err)
+	}
+	if got := authorization(t, other, client); got != "" {
+		t.Errorf("anonymous: Authorization = %q", got)
+	}
+
+	if err := client.authenticateAs(other, "carol"); err == nil || !strings.Contains(err.Error(), "alice, bob") {
+		t.Errorf("error = %v, want the known profiles", err)
+	}
+	if got := server.grantsRequested(); len(got) != 2 {
+		t.Errorf("token requests = %v, want one for each user", got)
+	}
+}
+
+func TestHTTPClient_AuthRefreshesTokens(t *testing.T) {
+	// Tokens that expire within the leeway are refreshed before each request
+	server := newTokenServer(t, 1)
+	client := newTestHTTPClient(t, server.URL, map[string]any{
+		"auth": map[string]any{
+			"type":          "oauth2",
+			"token_url":     server.URL + "/oauth/token",
+			"client_id":     "tomato",
+			"client_secret": "s3cr3t",
+			"scopes":        []any{"read", "write"},
+		},
+	})
+
+	ctx := WithScenarioState(context.Background(), NewScenarioState())
+	if got := authorization(t, ctx, client); got != "Bearer service-1" {
+		t.Errorf("Authorization = %q", got)
+	}
+	if got := authorization(t, ctx, client); got != "Bearer service-2" {
+		t.Errorf("refreshed: Authorization = %q", got)
+	}
+	want := []string{"client_credentials", "refresh_token"}
+	if got := server.grantsRequested(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("grants = %v, want %v", got, want)
+	}
+}
+
+func TestHTTPClient_AuthErrors(t *testing.T) {
+	server := newTokenServer(t, 3600)
+	client := newTestHTTPClient(t, server.URL, map[string]any{
+		"auth": map[string]any{
+			"type":          "oauth2",
+			"grant":         "password",
+			"token_url":     "/oauth/token",
+			"client_id":     "tomato",
+			"client_secret": "s3cr3t",
+			"profiles": map[string]any{
+				"mallory": map[string]any{"username": "mallory", "password": "guess"},
+			},
+		},
+	})
+	ctx := WithScenarioState(context.Background(), NewScenarioState())
+
+	err := client.sendRequest(ctx, "GET", "/me")
+	if err == nil || !strings.Contains(err.Error(), `pick a profile with "authenticates as" (mallory)`) {
+		t.Errorf("error = %v, want a hint to pick a profile", err)
+	}
+
+	if err := client.authenticateAs(ctx, "mallory"); err != nil {
+		t.Fatal(err)
+	}
+	err = client.sendRequest(ctx, "GET", "/me")
+	if err == nil || !strings.Contains(err.Error(), `returned 400 for the password grant: {"error": "invalid_grant"}`) {
+		t.Errorf("error = %v, want the token endpoint's response", err)
+	}
+}
+
+func TestHTTPClient_StaticAuth(t *testing.T) {
+	server := newTokenServer(t, 0)
+	tests := []struct {
+		auth map[string]any
+		want string
+	}{
+		{map[string]any{"type": "basic", "username": "alice", "password": "secret"}, "Basic YWxpY2U6c2VjcmV0"},
+		{map[string]any{"type": "bearer", "token": "${TOKEN}"}, "Bearer ${TOKEN}"},
+		{map[string]any{"type": "bearer", "token": "{{token}}"}, "Bearer from-variable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			client := newTestHTTPClient(t, server.URL, map[string]any{"auth": tt.auth})
+			ctx := WithScenarioState(context.Background(), NewScenarioState())
+			VariablesFrom(ctx).Set("token", "from-variable")
+			if got := authorization(t, ctx, client); got != tt.want {
+				t.Errorf("Authorization = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignHMAC(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"amount": 10}`)
+	bodySum := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte("key-secret"))
+	mac.Write([]byte("POST\n/payments?dry_run=1\n1700000000\n" + hex.EncodeToString(bodySum[:])))
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name    string
+		fields  map[string]string
+		header  string
+		want    string
+		wantErr string
+	}{
+		{
+			name:   "defaults",
+			fields: map[string]string{"key_id": "k1", "secret": "key-secret"},
+			header: "Authorization",
+			want:   "HMAC k1:" + signature,
+		},
+		{
+			name: "templates",
+			fields: map[string]string{
+				"secret": "key-secret", "header": "X-Signature", "signature": "t={timestamp},v1={signature}",
+				"string_to_sign": "{timestamp}.{body}", "encoding": "base64",
+			},
+			header: "X-Signature",
+			want:   "t=1700000000,v1=ChvgzBxFsEx4j6w0p04/YcC/T2Ot7O5MWzQ8aAU5AZk=",
+		},
+		{name: "unknown algorithm", fields: map[string]string{"secret": "s", "algorithm": "md5"}, wantErr: `unknown algorithm "md5"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "http://api.local/payments?dry_run=1", nil)
+			err := signHMAC(req, body, tt.fields, now)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := req.Header.Get(tt.header); got != tt.want {
+				t.Errorf("%s = %q, want %q", tt.header, got, tt.want)
+			}
+			if got := req.Header.Get("X-Timestamp"); got != "1700000000" {
+				t.Errorf("X-Timestamp = %q", got)
+			}
+		})
+	}
+}
@@ -11,11 +11,13 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/cucumber/godog"
 	"github.com/tomatool/tomato/internal/config"
 	"github.com/tomatool/tomato/internal/container"
+	"github.com/tomatool/tomato/internal/har"
 )
 
 type HTTPClient struct {
@@ -24,23 +26,46 @@ type HTTPClient struct {
 	container *container.Manager
 	client    *http.Client
 	baseURL   string
+	har       *har.Recorder // set when the traffic is exported as HAR
+	contract  *contract     // set when traffic is validated against an OpenAPI spec
+	auth      *httpAuth     // set when requests are authenticated, see options.auth
 
+	// defaultState is used when a step runs outside of a scenario context.
+	// Parallel workers reset it while others read it.
+	defaultState atomic.Pointer[httpClientState]
+}
+
+// httpClientState is the per-scenario request/response state of an HTTP client
+type httpClientState struct {
 	requestHeaders map[string]string
 	requestBody    []byte
 	requestParams  url.Values
+	skipContract   bool           // the next request isn't validated against the contract
+	jar            http.CookieJar // nil unless cookies are kept, see jar
+	authProfile    string         // profile of options.auth requests authenticate as
+	anonymous      bool           // requests aren't authenticated
 
 	lastResponse *http.Response
 	lastBody     []byte
+
+	transcript []string // last exchanges, see Transcript
 }
 
-func NewHTTPClient(name string, cfg config.Resource, cm *container.Manager) (*HTTPClient, error) {
-	return &HTTPClient{
-		name:           name,
-		config:         cfg,
-		container:      cm,
+func newHTTPClientState() *httpClientState {
+	return &httpClientState{
 		requestHeaders: make(map[string]string),
 		requestParams:  make(url.Values),
-	}, nil
+	}
+}
+
+func NewHTTPClient(name string, cfg config.Resource, cm *container.Manager) (*HTTPClient, error) {
+	r := &HTTPClient{
+		name:      name,
+		config:    cfg,
+		container: cm,
+	}
+	r.defaultState.Store(newHTTPClientState())
+	return r, nil
 }
 
 func (r *HTTPClient) Name() string { return r.name }
@@ -63,6 +88,16 @@ func (r *HTTPClient) Init(ctx context.Context) error {
 		},
 	}
 
+	tlsConfig, err := loadTLSConfig(r.config)
+	if err != nil {
+		return err
+	}
+	if tlsConfig != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		r.client.Transport = transport
+	}
+
 	if r.config.BaseURL != "" {
 		r.baseURL = r.config.BaseURL
 	} else if r.config.Container != "" {
@@ -84,11 +119,23 @@ func (r *HTTPClient) Init(ctx context.Context) error {
 		scheme := "http"
 		if s, ok := r.config.Options["scheme"].(string); ok {
 			scheme = s
+		} else if tlsConfig != nil {
+			scheme = "https"
 		}
 
 		r.baseURL = fmt.Sprintf("%s://%s:%s", scheme, host, mappedPort)
 	}
 
+	contract, err := loadContract(ctx, r.config)
+	if err != nil {
+		return err
+	}
+	r.contract = contract
+
+	if r.auth, err = loadAuth(r.config); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -96,7 +143,7 @@ func (r *HTTPClient) Ready(ctx context.Context) error {
 	if healthPath, ok := r.config.Options["health_path"].(string); ok {
 		resp, err := r.client.Get(r.baseURL + healthPath)
 		if err != nil {
-			return fmt.Errorf("health check failed: %w", err)
+			return fmt.Errorf("health check failed: %w", tlsHandshakeError(err, r.baseURL))
 		}
 		defer resp.Body.Close()
 
@@ -108,14 +155,19 @@ func (r *HTTPClient) Ready(ctx context.Context) error {
 }
 
 func (r *HTTPClient) Reset(ctx context.Context) error {
-	r.requestHeaders = make(map[string]string)
-	r.requestBody = nil
-	r.requestParams = make(url.Values)
-	r.lastResponse = nil
-	r.lastBody = nil
+	// Scenario states are discarded by the runner; only the default state lives here
+	r.defaultState.Store(newHTTPClientState())
 	return nil
 }
 
+// state returns the request/response state of the scenario carried by ctx
+func (r *HTTPClient) state(ctx context.Context) *httpClientState {
+	if sc := ScenarioStateFrom(ctx); sc != nil {
+		return sc.Load("http_client:"+r.name, func() any { return newHTTPClientState() }).(*httpClientState)
+	}
+	return r.defaultState.Load()
+}
+
 func (r *HTTPClient) RegisterSteps(ctx *godog.ScenarioContext) {
 	RegisterStepsToGodog(ctx, r.name, r.Steps())
 }
@@ -169,6 +221,29 @@ func (r *HTTPClient) Steps() StepCategory {
 				Example:     `"api" form body is:`,
 				Handler:     r.setFormBody,
 			},
+			{
+				Group:       "Request Setup",
+				Pattern:     `^"{resource}" next request is not validated against the contract$`,
+				Description: "Send the next request even if it violates options.openapi, e.g. to test how invalid input is rejected",
+				Example:     `"api" next request is not validated against the contract`,
+				Handler:     r.skipContractForNextRequest,
+			},
+
+			// Authentication
+			{
+				Group:       "Authentication",
+				Pattern:     `^"{resource}" authenticates as "([^"]*)"$`,
+				Description: "Authenticate the next requests with a profile of options.auth",
+				Example:     `"api" authenticates as "alice"`,
+				Handler:     r.authenticateAs,
+			},
+			{
+				Group:       "Authentication",
+				Pattern:     `^"{resource}" does not authenticate$`,
+				Description: "Send the next requests without the credentials of options.auth",
+				Example:     `"api" does not authenticate`,
+				Handler:     r.stopAuthenticating,
+			},
 
 			// Request Execution
 			{
@@ -326,6 +401,69 @@ func (r *HTTPClient) Steps() StepCategory {
 				Example:     `"api" response json "created_at" is iso-timestamp`,
 				Handler:     r.responseJSONPathIsISOTimestamp,
 			},
+			{
+				Group:       "Response JSON",
+				Pattern:     `^"{resource}" response json all of "([^"]*)" are "([^"]*)"$`,
+				Description: "Assert every value selected by a JSON path equals the value",
+				Example:     `"api" response json all of "items[*].status" are "active"`,
+				Handler:     r.responseJSONPathAllShouldBe,
+			},
+			{
+				Group:       "Response JSON",
+				Pattern:     `^"{resource}" response json any of "([^"]*)" is "([^"]*)"$`,
+				Description: "Assert at least one value selected by a JSON path equals the value",
+				Example:     `"api" response json any of "items[?(@.sku=='X1')].qty" is "2"`,
+				Handler:     r.responseJSONPathAnyShouldBe,
+			},
+			{
+				Group:       "Response JSON",
+				Pattern:     `^"{resource}" response json all of "([^"]*)" match pattern "([^"]*)"$`,
+				Description: "Assert every value selected by a JSON path matches a regex pattern",
+				Example:     `"api" response json all of "$..email" match pattern "@example\.com$"`,
+				Handler:     r.responseJSONPathAllMatchPattern,
+			},
+			{
+				Group:       "Response JSON",
+				Pattern:     `^"{resource}" response json any of "([^"]*)" matches pattern "([^"]*)"$`,
+				Description: "Assert at least one value selected by a JSON path matches a regex pattern",
+				Example:     `"api" response json any of "items[*].name" matches pattern "^Tomato"`,
+				Handler:     r.responseJSONPathAnyMatchesPattern,
+			},
+			{
+				Group:       "Response JSON",
+				Pattern:     `^"{resource}" response json "([^"]*)" has "(\d+)" values$`,
+				Description: "Assert the number of values a JSON path selects",
+				Example:     `"api" response json "items[?(@.active==true)]" has "3" values`,
+				Handler:     r.responseJSONPathShouldHaveCount,
+			},
+			{
+				Group:       "Response JSON",
+				Pattern:     `^"{resource}" response json matches schema "([^"]*)"$`,
+				Description: "Assert JSON body is valid against a JSON Schema (draft 2020-12) file or URL",
+				Example:     `"api" response json matches schema "schemas/order.json"`,
+				Handler:     r.responseJSONShouldMatchSchemaFile,
+			},
+			{
+				Group:       "Response JSON",
+				Pattern:     `^"{resource}" response json matches schema:$`,
+				Description: "Assert JSON body is valid against an inline JSON Schema",
+				Example:     "\"api\" response json matches schema:\n  \"\"\"\n  {\"type\": \"object\", \"required\": [\"id\"]}\n  \"\"\"",
+				Handler:     r.responseJSONShouldMatchSchema,
+			},
+			{
+				Group:       "Response JSON",
+				Pattern:     `^"{resource}" response json matches snapshot "([^"]*)"$`,
+				Description: "Assert JSON body equals a named snapshot, written on the first run or with --update-snapshots",
+				Example:     `"api" response json matches snapshot "orders/list"`,
+				Handler:     r.responseJSONShouldMatchSnapshot,
+			},
+			{
+				Group:       "Response JSON",
+				Pattern:     `^"{resource}" response json matches snapshot "([^"]*)" ignoring:$`,
+				Description: "Assert JSON body equals a snapshot, with the values at the given paths replaced by matchers",
+				Example:     "\"api\" response json matches snapshot \"orders/list\" ignoring:\n  | path              | matcher |\n  | items[*].id       | @string |\n  | items[*].created  | @string |",
+				Handler:     r.responseJSONShouldMatchSnapshotIgnoring,
+			},
 
 			// Response Timing
 			{
@@ -336,6 +474,64 @@ func (r *HTTPClient) Steps() StepCategory {
 				Handler:     r.responseTimeShouldBeLessThan,
 			},
 
+			// Cookies
+			{
+				Group:       "Cookies",
+				Pattern:     `^"{resource}" cookie "([^"]*)" is "([^"]*)"$`,
+				Description: "Set a cookie for the base URL; the client keeps a cookie jar for the scenario from then on",
+				Example:     `"api" cookie "session" is "abc123"`,
+				Handler:     r.setCookie,
+			},
+			{
+				Group:       "Cookies",
+				Pattern:     `^"{resource}" clears cookies$`,
+				Description: "Remove all cookies of the scenario",
+				Example:     `"api" clears cookies`,
+				Handler:     r.clearCookies,
+			},
+			{
+				Group:       "Cookies",
+				Pattern:     `^"{resource}" has cookie "([^"]*)"$`,
+				Description: "Assert the cookie jar has a cookie for the base URL",
+				Example:     `"api" has cookie "session"`,
+				Handler:     r.shouldHaveCookie,
+			},
+			{
+				Group:       "Cookies",
+				Pattern:     `^"{resource}" has cookie "([^"]*)" with value "([^"]*)"$`,
+				Description: "Assert the value of a cookie in the cookie jar",
+				Example:     `"api" has cookie "session" with value "abc123"`,
+				Handler:     r.shouldHaveCookieWithValue,
+			},
+			{
+				Group:       "Cookies",
+				Pattern:     `^"{resource}" has no cookie "([^"]*)"$`,
+				Description: "Assert the cookie jar has no cookie for the base URL, e.g. after logout",
+				Example:     `"api" has no cookie "session"`,
+				Handler:     r.shouldNotHaveCookie,
+			},
+			{
+				Group:       "Cookies",
+				Pattern:     `^"{resource}" response sets cookie "([^"]*)"$`,
+				Description: "Assert the response has a Set-Cookie header for the cookie",
+				Example:     `"api" response sets cookie "session"`,
+				Handler:     r.responseShouldSetCookie,
+			},
+			{
+				Group:       "Cookies",
+				Pattern:     `^"{resource}" response cookie "([^"]*)" is "([^"]*)"$`,
+				Description: "Assert the value of a cookie the response sets",
+				Example:     `"api" response cookie "theme" is "dark"`,
+				Handler:     r.responseCookieShouldBe,
+			},
+			{
+				Group:       "Cookies",
+				Pattern:     `^"{resource}" response cookie "([^"]*)" has:$`,
+				Description: "Assert attributes of a cookie the response sets: value, domain, path, httponly, secure, samesite, max-age, expires (session, expired or a duration it expires within)",
+				Example:     "\"api\" response cookie \"session\" has:\n  | attribute | value  |\n  | httponly  | true   |\n  | secure    | true   |\n  | samesite  | Strict |\n  | expires   | 24h    |",
+				Handler:     r.responseCookieShouldHave,
+			},
+
 			// Variable Capture
 			{
 				Group:       "Variable Capture",
@@ -351,144 +547,276 @@ func (r *HTTPClient) Steps() StepCategory {
 				Example:     `"api" response header "Location" saved as "{{location}}"`,
 				Handler:     r.saveHeaderToVariable,
 			},
+			{
+				Group:       "Variable Capture",
+				Pattern:     `^"{resource}" cookie "([^"]*)" saved as "\{\{([^}]+)\}\}"$`,
+				Description: "Save a cookie of the cookie jar, or of the response without a jar, to variable",
+				Example:     `"api" cookie "session" saved as "{{session}}"`,
+				Handler:     r.saveCookieToVariable,
+			},
+			{
+				Group:       "Variable Capture",
+				Pattern:     `^"{resource}" access token saved as "\{\{([^}]+)\}\}"$`,
+				Description: "Save the bearer or OAuth2 token of options.auth to variable, e.g. to authenticate a websocket",
+				Example:     `"api" access token saved as "{{token}}"`,
+				Handler:     r.saveAccessTokenToVariable,
+			},
 		},
 	}
 }
 
-func (r *HTTPClient) setHeader(key, value string) error {
-	r.requestHeaders[key] = value
+func (r *HTTPClient) setHeader(ctx context.Context, key, value string) error {
+	s := r.state(ctx)
+	s.requestHeaders[key] = value
 	return nil
 }
 
-func (r *HTTPClient) setHeaders(table *godog.Table) error {
+func (r *HTTPClient) setHeaders(ctx context.Context, table *godog.Table) error {
+	s := r.state(ctx)
 	for _, row := range table.Rows[1:] {
 		if len(row.Cells) >= 2 {
-			r.requestHeaders[row.Cells[0].Value] = row.Cells[1].Value
+			s.requestHeaders[row.Cells[0].Value] = row.Cells[1].Value
 		}
 	}
 	return nil
 }
 
-func (r *HTTPClient) setQueryParam(key, value string) error {
-	r.requestParams.Set(key, value)
+func (r *HTTPClient) setQueryParam(ctx context.Context, key, value string) error {
+	s := r.state(ctx)
+	s.requestParams.Set(key, value)
 	return nil
 }
 
-func (r *HTTPClient) setRequestBody(doc *godog.DocString) error {
-	r.requestBody = []byte(doc.Content)
+func (r *HTTPClient) setRequestBody(ctx context.Context, doc *godog.DocString) error {
+	s := r.state(ctx)
+	s.requestBody = []byte(doc.Content)
 	return nil
 }
 
-func (r *HTTPClient) setJSONBody(doc *godog.DocString) error {
+func (r *HTTPClient) setJSONBody(ctx context.Context, doc *godog.DocString) error {
+	s := r.state(ctx)
 	var js json.RawMessage
 	if err := json.Unmarshal([]byte(doc.Content), &js); err != nil {
 		return fmt.Errorf("invalid JSON: %w", err)
 	}
-	r.requestBody = []byte(doc.Content)
-	if r.requestHeaders["Content-Type"] == "" {
-		r.requestHeaders["Content-Type"] = "application/json"
+	s.requestBody = []byte(doc.Content)
+	if s.requestHeaders["Content-Type"] == "" {
+		s.requestHeaders["Content-Type"] = "application/json"
 	}
 	return nil
 }
 
-func (r *HTTPClient) setFormBody(table *godog.Table) error {
+func (r *HTTPClient) setFormBody(ctx context.Context, table *godog.Table) error {
+	s := r.state(ctx)
 	form := url.Values{}
 	for _, row := range table.Rows[1:] {
 		if len(row.Cells) >= 2 {
 			form.Set(row.Cells[0].Value, row.Cells[1].Value)
 		}
 	}
-	r.requestBody = []byte(form.Encode())
-	if r.requestHeaders["Content-Type"] == "" {
-		r.requestHeaders["Content-Type"] = "application/x-www-form-urlencoded"
+	s.requestBody = []byte(form.Encode())
+	if s.requestHeaders["Content-Type"] == "" {
+		s.requestHeaders["Content-Type"] = "application/x-www-form-urlencoded"
 	}
 	return nil
 }
 
-func (r *HTTPClient) sendRequest(method, path string) error {
-	return r.doRequest(method, path, nil)
+func (r *HTTPClient) authenticateAs(ctx context.Context, profile string) error {
+	if r.auth == nil {
+		return fmt.Errorf("%s has no options.auth", r.name)
+	}
+	if _, ok := r.auth.profiles[profile]; !ok {
+		return fmt.Errorf("unknown auth profile %q (%s)", profile, strings.Join(r.auth.profileNames(), ", "))
+	}
+	s := r.state(ctx)
+	s.authProfile = profile
+	s.anonymous = false
+	return nil
+}
+
+func (r *HTTPClient) stopAuthenticating(ctx context.Context) error {
+	r.state(ctx).anonymous = true
+	return nil
+}
+
+func (r *HTTPClient) skipContractForNextRequest(ctx context.Context) error {
+	r.state(ctx).skipContract = true
+	return nil
+}
+
+func (r *HTTPClient) sendRequest(ctx context.Context, method, path string) error {
+	return r.doRequest(ctx, method, path, nil)
 }
 
-func (r *HTTPClient) sendRequestWithBody(method, path string, doc *godog.DocString) error {
-	return r.doRequest(method, path, []byte(doc.Content))
+func (r *HTTPClient) sendRequestWithBody(ctx context.Context, method, path string, doc *godog.DocString) error {
+	return r.doRequest(ctx, method, path, []byte(doc.Content))
 }
 
-func (r *HTTPClient) sendRequestWithJSON(method, path string, doc *godog.DocString) error {
+func (r *HTTPClient) sendRequestWithJSON(ctx context.Context, method, path string, doc *godog.DocString) error {
+	s := r.state(ctx)
 	var js json.RawMessage
 	if err := json.Unmarshal([]byte(doc.Content), &js); err != nil {
 		return fmt.Errorf("invalid JSON: %w", err)
 	}
-	if r.requestHeaders["Content-Type"] == "" {
-		r.requestHeaders["Content-Type"] = "application/json"
+	if s.requestHeaders["Content-Type"] == "" {
+		s.requestHeaders["Content-Type"] = "application/json"
 	}
-	return r.doRequest(method, path, []byte(doc.Content))
+	return r.doRequest(ctx, method, path, []byte(doc.Content))
 }
 
-func (r *HTTPClient) doRequest(method, path string, body []byte) error {
+func (r *HTTPClient) doRequest(ctx context.Context, method, path string, body []byte) error {
+	s := r.state(ctx)
 	// Replace variables in path
-	path = ReplaceVariables(path)
+	vars := VariablesFrom(ctx)
+	path = vars.Replace(path)
 
 	reqURL := r.baseURL + path
-	if len(r.requestParams) > 0 {
-		reqURL += "?" + r.requestParams.Encode()
+	if len(s.requestParams) > 0 {
+		reqURL += "?" + s.requestParams.Encode()
 	}
 
 	var bodyReader io.Reader
 	if body != nil {
 		// Replace variables in body
-		body = []byte(ReplaceVariables(string(body)))
+		body = []byte(vars.Replace(string(body)))
 		bodyReader = bytes.NewReader(body)
-	} else if r.requestBody != nil {
+	} else if s.requestBody != nil {
 		// Replace variables in stored body
-		replacedBody := []byte(ReplaceVariables(string(r.requestBody)))
+		replacedBody := []byte(vars.Replace(string(s.requestBody)))
 		bodyReader = bytes.NewReader(replacedBody)
 	}
 
-	req, err := http.NewRequest(method, reqURL, bodyReader)
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, bodyReader)
 	if err != nil {
 		return fmt.Errorf("creating request: %w", err)
 	}
 
-	for k, v := range r.requestHeaders {
+	var reqBody []byte
+	if req.GetBody != nil {
+		if rc, err := req.GetBody(); err == nil {
+			reqBody, _ = io.ReadAll(rc)
+		}
+	}
+
+	// The app under test can route the request to the worker's namespace
+	if h, _ := r.config.Options["worker_header"].(string); h != "" {
+		if id := WorkerID(ctx); id > 0 {
+			req.Header.Set(h, strconv.Itoa(id))
+		}
+	}
+
+	// Headers the scenario sets win over those of options.auth
+	if r.auth != nil && !s.anonymous {
+		if err := r.auth.apply(ctx, r, req, reqBody, s.authProfile); err != nil {
+			return fmt.Errorf("authenticating request: %w", err)
+		}
+	}
+
+	for k, v := range s.requestHeaders {
 		// Replace variables in header values
-		req.Header.Set(k, ReplaceVariables(v))
+		req.Header.Set(k, vars.Replace(v))
+	}
+
+	if r.contract != nil && !s.skipContract {
+		if err := r.contract.checkRequest(ctx, req, reqBody); err != nil {
+			return err
+		}
+	}
+
+	client := r.client
+	if jar := r.jar(s, false); jar != nil {
+		withJar := *r.client
+		withJar.Jar = jar
+		client = &withJar
 	}
 
 	start := time.Now()
-	resp, err := r.client.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
+		err = tlsHandshakeError(err, req.URL.Host)
+		took := time.Since(start)
+		r.record(s, formatHTTPExchange(r.redactor(ctx), start, method, reqURL, req.Header, reqBody, 0, nil, nil, took, err))
+		r.recordHAR(ctx, har.Exchange{
+			Started: start, Duration: took, Method: method, URL: reqURL,
+			RequestHeader: req.Header, RequestBody: reqBody, Err: err,
+		})
 		return fmt.Errorf("sending request: %w", err)
 	}
 
-	r.lastResponse = resp
-	r.lastBody, _ = io.ReadAll(resp.Body)
+	s.lastResponse = resp
+	s.lastBody, _ = io.ReadAll(resp.Body)
 	resp.Body.Close()
 
-	r.lastResponse.Header.Set("X-Response-Time", time.Since(start).String())
+	took := time.Since(start)
+	r.record(s, formatHTTPExchange(r.redactor(ctx), start, method, reqURL, req.Header, reqBody, resp.StatusCode, resp.Header, s.lastBody, took, nil))
+	r.recordHAR(ctx, har.Exchange{
+		Started: start, Duration: took, Method: method, URL: reqURL,
+		RequestHeader: req.Header, RequestBody: reqBody,
+		Status: resp.StatusCode, ResponseHeader: resp.Header.Clone(), ResponseBody: s.lastBody,
+	})
+	s.lastResponse.Header.Set("X-Response-Time", took.String())
 
 	// Clear single-use request data, but keep headers persistent within the scenario
-	r.requestBody = nil
-	r.requestParams = make(url.Values)
+	s.requestBody = nil
+	s.requestParams = make(url.Values)
+	s.skipContract = false
 
+	if r.contract != nil {
+		return r.contract.checkResponse(ctx, req, resp.StatusCode, resp.Header, s.lastBody)
+	}
 	return nil
 }
 
-func (r *HTTPClient) responseStatusShouldBe(expected int) error {
-	if r.lastResponse == nil {
+// record adds an exchange to the scenario's transcript
+func (r *HTTPClient) record(s *httpClientState, exchange string) {
+	if n := transcriptSize(r.config); n > 0 {
+		s.transcript = lastN(append(s.transcript, exchange), n)
+	}
+}
+
+// redactor returns the headers redacted in transcripts, including the
+// signature header of options.auth
+func (r *HTTPClient) redactor(ctx context.Context) har.Redactor {
+	redact := redactorFrom(ctx)
+	if r.auth != nil {
+		redact = redact.With(r.auth.secretHeaders()...)
+	}
+	return redact
+}
+
+// recordHAR adds an exchange to the page of the scenario carried by ctx
+func (r *HTTPClient) recordHAR(ctx context.Context, ex har.Exchange) {
+	if r.har != nil {
+		ex.Resource = r.name
+		r.har.Add(har.PageFrom(ctx), ex)
+	}
+}
+
+func (r *HTTPClient) setHAR(rec *har.Recorder) { r.har = rec }
+
+// Transcript returns the last requests and responses of the scenario
+func (r *HTTPClient) Transcript(ctx context.Context) string {
+	return joinTranscript(r.state(ctx).transcript)
+}
+
+func (r *HTTPClient) responseStatusShouldBe(ctx context.Context, expected int) error {
+	s := r.state(ctx)
+	if s.lastResponse == nil {
 		return fmt.Errorf("no response received")
 	}
-	if r.lastResponse.StatusCode != expected {
-		return fmt.Errorf("expected status %d, got %d\nBody: %s", expected, r.lastResponse.StatusCode, string(r.lastBody))
+	if s.lastResponse.StatusCode != expected {
+		return fmt.Errorf("expected status %d, got %d\nBody: %s", expected, s.lastResponse.StatusCode, string(s.lastBody))
 	}
 	return nil
 }
 
-func (r *HTTPClient) responseStatusClassShouldBe(class string) error {
-	if r.lastResponse == nil {
+func (r *HTTPClient) responseStatusClassShouldBe(ctx context.Context, class string) error {
+	s := r.state(ctx)
+	if s.lastResponse == nil {
 		return fmt.Errorf("no response received")
 	}
 
-	status := r.lastResponse.StatusCode
+	status := s.lastResponse.StatusCode
 	var ok bool
 
 	switch class {
@@ -510,152 +838,210 @@ func (r *HTTPClient) responseStatusClassShouldBe(class string) error {
 	return nil
 }
 
-func (r *HTTPClient) responseHeaderShouldBe(header, expected string) error {
-	if r.lastResponse == nil {
+func (r *HTTPClient) responseHeaderShouldBe(ctx context.Context, header, expected string) error {
+	s := r.state(ctx)
+	if s.lastResponse == nil {
 		return fmt.Errorf("no response received")
 	}
-	actual := r.lastResponse.Header.Get(header)
+	actual := s.lastResponse.Header.Get(header)
 	if actual != expected {
 		return fmt.Errorf("header %q: expected %q, got %q", header, expected, actual)
 	}
 	return nil
 }
 
-func (r *HTTPClient) responseHeaderShouldContain(header, substr string) error {
-	if r.lastResponse == nil {
+func (r *HTTPClient) responseHeaderShouldContain(ctx context.Context, header, substr string) error {
+	s := r.state(ctx)
+	if s.lastResponse == nil {
 		return fmt.Errorf("no response received")
 	}
-	actual := r.lastResponse.Header.Get(header)
+	actual := s.lastResponse.Header.Get(header)
 	if !strings.Contains(actual, substr) {
 		return fmt.Errorf("header %q value %q does not contain %q", header, actual, substr)
 	}
 	return nil
 }
 
-func (r *HTTPClient) responseHeaderShouldExist(header string) error {
-	if r.lastResponse == nil {
+func (r *HTTPClient) responseHeaderShouldExist(ctx context.Context, header string) error {
+	s := r.state(ctx)
+	if s.lastResponse == nil {
 		return fmt.Errorf("no response received")
 	}
-	if r.lastResponse.Header.Get(header) == "" {
+	if s.lastResponse.Header.Get(header) == "" {
 		return fmt.Errorf("header %q does not exist", header)
 	}
 	return nil
 }
 
-func (r *HTTPClient) responseBodyShouldBe(doc *godog.DocString) error {
-	if r.lastResponse == nil {
+func (r *HTTPClient) responseBodyShouldBe(ctx context.Context, doc *godog.DocString) error {
+	s := r.state(ctx)
+	if s.lastResponse == nil {
 		return fmt.Errorf("no response received")
 	}
 	expected := strings.TrimSpace(doc.Content)
-	actual := strings.TrimSpace(string(r.lastBody))
+	actual := strings.TrimSpace(string(s.lastBody))
 	if actual != expected {
 		return fmt.Errorf("body mismatch:\nexpected: %s\nactual: %s", expected, actual)
 	}
 	return nil
 }
 
-func (r *HTTPClient) responseBodyShouldContain(substr string) error {
-	if r.lastResponse == nil {
+func (r *HTTPClient) responseBodyShouldContain(ctx context.Context, substr string) error {
+	s := r.state(ctx)
+	if s.lastResponse == nil {
 		return fmt.Errorf("no response received")
 	}
-	if !strings.Contains(string(r.lastBody), substr) {
-		return fmt.Errorf("body does not contain %q\nbody: %s", substr, string(r.lastBody))
+	if !strings.Contains(string(s.lastBody), substr) {
+		return fmt.Errorf("body does not contain %q\nbody: %s", substr, string(s.lastBody))
 	}
 	return nil
 }
 
-func (r *HTTPClient) responseBodyShouldNotContain(substr string) error {
-	if r.lastResponse == nil {
+func (r *HTTPClient) responseBodyShouldNotContain(ctx context.Context, substr string) error {
+	s := r.state(ctx)
+	if s.lastResponse == nil {
 		return fmt.Errorf("no response received")
 	}
-	if strings.Contains(string(r.lastBody), substr) {
-		return fmt.Errorf("body should not contain %q\nbody: %s", substr, string(r.lastBody))
+	if strings.Contains(string(s.lastBody), substr) {
+		return fmt.Errorf("body should not contain %q\nbody: %s", substr, string(s.lastBody))
 	}
 	return nil
 }
 
-func (r *HTTPClient) responseBodyShouldBeEmpty() error {
-	if r.lastResponse == nil {
+func (r *HTTPClient) responseBodyShouldBeEmpty(ctx context.Context) error {
+	s := r.state(ctx)
+	if s.lastResponse == nil {
 		return fmt.Errorf("no response received")
 	}
-	if len(r.lastBody) > 0 {
-		return fmt.Errorf("expected empty body, got: %s", string(r.lastBody))
+	if len(s.lastBody) > 0 {
+		return fmt.Errorf("expected empty body, got: %s", string(s.lastBody))
 	}
 	return nil
 }
 
-func (r *HTTPClient) responseJSONPathShouldBe(path, expected string) error {
-	if r.lastResponse == nil {
+func (r *HTTPClient) responseJSONPathShouldBe(ctx context.Context, path, expected string) error {
+	s := r.state(ctx)
+	if s.lastResponse == nil {
 		return fmt.Errorf("no response received")
 	}
+	return jsonPathShouldBe(s.lastBody, path, expected)
+}
 
-	actual, err := r.getJSONPath(path)
-	if err != nil {
-		return err
+func (r *HTTPClient) responseJSONPathShouldExist(ctx context.Context, path string) error {
+	s := r.state(ctx)
+	if s.lastResponse == nil {
+		return fmt.Errorf("no response received")
 	}
+	return jsonPathShouldExist(s.lastBody, path)
+}
 
-	actualStr := fmt.Sprintf("%v", actual)
-	if actualStr != expected {
-		return fmt.Errorf("JSON path %q: expected %q, got %q", path, expected, actualStr)
+func (r *HTTPClient) responseJSONPathShouldNotExist(ctx context.Context, path string) error {
+	s := r.state(ctx)
+	if s.lastResponse == nil {
+		return fmt.Errorf("no response received")
 	}
-	return nil
+	return jsonPathShouldNotExist(s.lastBody, path)
 }
 
-func (r *HTTPClient) responseJSONPathShouldExist(path string) error {
-	if r.lastResponse == nil {
+func (r *HTTPClient) responseJSONPathMatchesPattern(ctx context.Context, path, pattern string) error {
+	s := r.state(ctx)
+	if s.lastResponse == nil {
 		return fmt.Errorf("no response received")
 	}
-	_, err := r.getJSONPath(path)
-	return err
+	return jsonPathShouldMatch(s.lastBody, path, pattern)
 }
 
-func (r *HTTPClient) responseJSONPathShouldNotExist(path string) error {
-	if r.lastResponse == nil {
+func (r *HTTPClient) responseJSONPathAllShouldBe(ctx context.Context, path, expected string) error {
+	s := r.state(ctx)
+	if s.lastResponse == nil {
 		return fmt.Errorf("no response received")
 	}
-	_, err := r.getJSONPath(path)
-	if err == nil {
-		return fmt.Errorf("JSON path %q exists but should not", path)
-	}
-	return nil
+	return jsonPathAllShouldBe(s.lastBody, path, expected)
 }
 
-func (r *HTTPClient) responseJSONPathMatchesPattern(path, pattern string) error {
-	if r.lastResponse == nil {
+func (r *HTTPClient) responseJSONPathAnyShouldBe(ctx context.Context, path, expected string) error {
+	s := r.state(ctx)
+	if s.lastResponse == nil {
 		return fmt.Errorf("no response received")
 	}
-	val, err := r.getJSONPath(path)
-	if err != nil {
-		return err
+	return jsonPathAnyShouldBe(s.lastBody, path, expected)
+}
+
+func (r *HTTPClient) responseJSONPathAllMatchPattern(ctx context.Context, path, pattern string) error {
+	s := r.state(ctx)
+	if s.lastResponse == nil {
+		return fmt.Errorf("no response received")
 	}
-	str, ok := val.(string)
-	if !ok {
-		return fmt.Errorf("JSON path %q is not a string: %T", path, val)
+	return jsonPathAllShouldMatch(s.lastBody, path, pattern)
+}
+
+func (r *HTTPClient) responseJSONPathAnyMatchesPattern(ctx context.Context, path, pattern string) error {
+	s := r.state(ctx)
+	if s.lastResponse == nil {
+		return fmt.Errorf("no response received")
 	}
-	re, err := regexp.Compile(pattern)
-	if err != nil {
-		return fmt.Errorf("invalid regex pattern: %w", err)
+	return jsonPathAnyShouldMatch(s.lastBody, path, pattern)
+}
+
+func (r *HTTPClient) responseJSONPathShouldHaveCount(ctx context.Context, path string, count int) error {
+	s := r.state(ctx)
+	if s.lastResponse == nil {
+		return fmt.Errorf("no response received")
 	}
-	if !re.MatchString(str) {
-		return fmt.Errorf("JSON path %q value %q does not match pattern %q", path, str, pattern)
+	return jsonPathShouldHaveCount(s.lastBody, path, count)
+}
+
+func (r *HTTPClient) responseJSONPathIsUUID(ctx context.Context, path string) error {
+	return r.responseJSONPathMatchesPattern(ctx, path, `^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+}
+
+func (r *HTTPClient) responseJSONPathIsEmail(ctx context.Context, path string) error {
+	return r.responseJSONPathMatchesPattern(ctx, path, `^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
+}
+
+func (r *HTTPClient) responseJSONPathIsISOTimestamp(ctx context.Context, path string) error {
+	return r.responseJSONPathMatchesPattern(ctx, path, `^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(.\d+)?(Z|[+-]\d{2}:\d{2})?$`)
+}
+
+func (r *HTTPClient) responseJSONShouldMatchSchemaFile(ctx context.Context, location string) error {
+	s := r.state(ctx)
+	if s.lastResponse == nil {
+		return fmt.Errorf("no response received")
 	}
-	return nil
+	return validateJSONSchema(s.lastBody, location)
 }
 
-func (r *HTTPClient) responseJSONPathIsUUID(path string) error {
-	return r.responseJSONPathMatchesPattern(path, `^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+func (r *HTTPClient) responseJSONShouldMatchSchema(ctx context.Context, doc *godog.DocString) error {
+	s := r.state(ctx)
+	if s.lastResponse == nil {
+		return fmt.Errorf("no response received")
+	}
+	return validateInlineJSONSchema(s.lastBody, doc.Content)
 }
 
-func (r *HTTPClient) responseJSONPathIsEmail(path string) error {
-	return r.responseJSONPathMatchesPattern(path, `^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
+func (r *HTTPClient) responseJSONShouldMatchSnapshot(ctx context.Context, name string) error {
+	s := r.state(ctx)
+	if s.lastResponse == nil {
+		return fmt.Errorf("no response received")
+	}
+	return matchJSONSnapshot(ctx, name, s.lastBody, nil)
 }
 
-func (r *HTTPClient) responseJSONPathIsISOTimestamp(path string) error {
-	return r.responseJSONPathMatchesPattern(path, `^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(.\d+)?(Z|[+-]\d{2}:\d{2})?$`)
+func (r *HTTPClient) responseJSONShouldMatchSnapshotIgnoring(ctx context.Context, name string, table *godog.Table) error {
+	ignores, err := parseSnapshotIgnores(table)
+	if err != nil {
+		return err
+	}
+	s := r.state(ctx)
+	if s.lastResponse == nil {
+		return fmt.Errorf("no response received")
+	}
+	return matchJSONSnapshot(ctx, name, s.lastBody, ignores)
 }
 
-func (r *HTTPClient) responseJSONShouldMatch(doc *godog.DocString) error {
-	if r.lastResponse == nil {
+func (r *HTTPClient) responseJSONShouldMatch(ctx context.Context, doc *godog.DocString) error {
+	s := r.state(ctx)
+	if s.lastResponse == nil {
 		return fmt.Errorf("no response received")
 	}
 
@@ -663,15 +1049,16 @@ func (r *HTTPClient) responseJSONShouldMatch(doc *godog.DocString) error {
 	if err := json.Unmarshal([]byte(doc.Content), &expected); err != nil {
 		return fmt.Errorf("invalid expected JSON: %w", err)
 	}
-	if err := json.Unmarshal(r.lastBody, &actual); err != nil {
+	if err := json.Unmarshal(s.lastBody, &actual); err != nil {
 		return fmt.Errorf("invalid response JSON: %w", err)
 	}
 
 	return CompareJSON(expected, actual, "", false)
 }
 
-func (r *HTTPClient) responseJSONShouldContain(doc *godog.DocString) error {
-	if r.lastResponse == nil {
+func (r *HTTPClient) responseJSONShouldContain(ctx context.Context, doc *godog.DocString) error {
+	s := r.state(ctx)
+	if s.lastResponse == nil {
 		return fmt.Errorf("no response received")
 	}
 
@@ -679,7 +1066,7 @@ func (r *HTTPClient) responseJSONShouldContain(doc *godog.DocString) error {
 	if err := json.Unmarshal([]byte(doc.Content), &expected); err != nil {
 		return fmt.Errorf("invalid expected JSON: %w", err)
 	}
-	if err := json.Unmarshal(r.lastBody, &actual); err != nil {
+	if err := json.Unmarshal(s.lastBody, &actual); err != nil {
 		return fmt.Errorf("invalid response JSON: %w", err)
 	}
 
@@ -1016,58 +1403,9 @@ func matchNotEmpty(actual interface{}, path string) error {
 	return nil
 }
 
-func (r *HTTPClient) getJSONPath(path string) (interface{}, error) {
-	var data interface{}
-	if err := json.Unmarshal(r.lastBody, &data); err != nil {
-		return nil, fmt.Errorf("invalid JSON: %w", err)
-	}
-
-	parts := strings.Split(path, ".")
-	current := data
-
-	for _, part := range parts {
-		if idx := strings.Index(part, "["); idx != -1 {
-			key := part[:idx]
-			indexStr := part[idx+1 : len(part)-1]
-			index, err := strconv.Atoi(indexStr)
-			if err != nil {
-				return nil, fmt.Errorf("invalid array index: %s", indexStr)
-			}
-
-			if key != "" {
-				obj, ok := current.(map[string]interface{})
-				if !ok {
-					return nil, fmt.Errorf("expected object at %s", key)
-				}
-				current = obj[key]
-			}
-
-			arr, ok := current.([]interface{})
-			if !ok {
-				return nil, fmt.Errorf("expected array at %s", part)
-			}
-			if index >= len(arr) {
-				return nil, fmt.Errorf("array index out of bounds: %d", index)
-			}
-			current = arr[index]
-		} else {
-			obj, ok := current.(map[string]interface{})
-			if !ok {
-				return nil, fmt.Errorf("expected object at %s", part)
-			}
-			var exists bool
-			current, exists = obj[part]
-			if !exists {
-				return nil, fmt.Errorf("key not found: %s", part)
-			}
-		}
-	}
-
-	return current, nil
-}
-
-func (r *HTTPClient) responseTimeShouldBeLessThan(duration string) error {
-	if r.lastResponse == nil {
+func (r *HTTPClient) responseTimeShouldBeLessThan(ctx context.Context, duration string) error {
+	s := r.state(ctx)
+	if s.lastResponse == nil {
 		return fmt.Errorf("no response received")
 	}
 
@@ -1076,7 +1414,7 @@ func (r *HTTPClient) responseTimeShouldBeLessThan(duration string) error {
 		return fmt.Errorf("invalid duration: %w", err)
 	}
 
-	actualStr := r.lastResponse.Header.Get("X-Response-Time")
+	actualStr := s.lastResponse.Header.Get("X-Response-Time")
 	actual, err := time.ParseDuration(actualStr)
 	if err != nil {
 		return fmt.Errorf("invalid response time: %w", err)
@@ -1088,38 +1426,83 @@ func (r *HTTPClient) responseTimeShouldBeLessThan(duration string) error {
 	return nil
 }
 
-func (r *HTTPClient) saveJSONPathToVariable(path, varName string) error {
-	if r.lastResponse == nil {
+func (r *HTTPClient) saveJSONPathToVariable(ctx context.Context, path, varName string) error {
+	s := r.state(ctx)
+	if s.lastResponse == nil {
 		return fmt.Errorf("no response received")
 	}
 
-	value, err := r.getJSONPath(path)
+	value, err := getJSON(s.lastBody, path)
 	if err != nil {
 		return fmt.Errorf("failed to get JSON path %q: %w", path, err)
 	}
-
-	// Convert to string
-	strValue := fmt.Sprintf("%v", value)
-	SetVariable(varName, strValue)
+	VariablesFrom(ctx).Set(varName, jsonValueString(value))
 	return nil
 }
 
-func (r *HTTPClient) saveHeaderToVariable(header, varName string) error {
-	if r.lastResponse == nil {
+func (r *HTTPClient) saveHeaderToVariable(ctx context.Context, header, varName string) error {
+	s := r.state(ctx)
+	if s.lastResponse == nil {
 		return fmt.Errorf("no response received")
 	}
 
-	value := r.lastResponse.Header.Get(header)
+	value := s.lastResponse.Header.Get(header)
 	if value == "" {
 		return fmt.Errorf("header %q not found or empty", header)
 	}
 
-	SetVariable(varName, value)
+	VariablesFrom(ctx).Set(varName, value)
 	return nil
 }
 
+func (r *HTTPClient) saveAccessTokenToVariable(ctx context.Context, varName string) error {
+	if r.auth == nil {
+		return fmt.Errorf("%s has no options.auth", r.name)
+	}
+	token, err := r.auth.accessToken(ctx, r, r.state(ctx).authProfile)
+	if err != nil {
+		return err
+	}
+	VariablesFrom(ctx).Set(varName, token)
+	return nil
+}
+
+// Request sends a request outside of the step flow (e.g. from a hook).
+// It does not touch the scenario's request/response state.
+func (r *HTTPClient) Request(ctx context.Context, method, target string, headers map[string]string, body []byte) (int, []byte, error) {
+	reqURL := target
+	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+		reqURL = r.baseURL + target
+	}
+
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, bodyReader)
+	if err != nil {
+		return 0, nil, fmt.Errorf("creating request: %w", err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("sending request: %w", tlsHandshakeError(err, req.URL.Host))
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, nil, fmt.Errorf("reading response: %w", err)
+	}
+	return resp.StatusCode, respBody, nil
+}
+
 func (r *HTTPClient) Cleanup(ctx context.Context) error {
 	return nil
 }
 
 var _ Handler = (*HTTPClient)(nil)
+var _ HTTPRequester = (*HTTPClient)(nil)
@@ -5,9 +5,12 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/cucumber/godog"
 	"github.com/tomatool/tomato/internal/config"
+	"github.com/tomatool/tomato/internal/har"
 )
 
 func TestHTTPClient_HeadersPersistBetweenRequests(t *testing.T) {
@@ -28,25 +31,26 @@ func TestHTTPClient_HeadersPersistBetweenRequests(t *testing.T) {
 		t.Fatalf("failed to create client: %v", err)
 	}
 
-	if err := client.Init(context.Background()); err != nil {
+	ctx := context.Background()
+	if err := client.Init(ctx); err != nil {
 		t.Fatalf("failed to init client: %v", err)
 	}
 
 	// Set header once
-	client.setHeader("Authorization", "Bearer test-token")
+	client.setHeader(ctx, "Authorization", "Bearer test-token")
 
 	// First request
-	if err := client.sendRequest("GET", "/first"); err != nil {
+	if err := client.sendRequest(ctx, "GET", "/first"); err != nil {
 		t.Fatalf("first request failed: %v", err)
 	}
 
 	// Second request - header should still be present
-	if err := client.sendRequest("GET", "/second"); err != nil {
+	if err := client.sendRequest(ctx, "GET", "/second"); err != nil {
 		t.Fatalf("second request failed: %v", err)
 	}
 
 	// Third request - header should still be present
-	if err := client.sendRequest("DELETE", "/third"); err != nil {
+	if err := client.sendRequest(ctx, "DELETE", "/third"); err != nil {
 		t.Fatalf("third request failed: %v", err)
 	}
 
@@ -78,18 +82,19 @@ func TestHTTPClient_BodyClearedBetweenRequests(t *testing.T) {
 		t.Fatalf("failed to create client: %v", err)
 	}
 
-	if err := client.Init(context.Background()); err != nil {
+	ctx := context.Background()
+	if err := client.Init(ctx); err != nil {
 		t.Fatalf("failed to init client: %v", err)
 	}
 
 	// Set body and send first request
-	client.requestBody = []byte(`{"name": "test"}`)
-	if err := client.sendRequest("POST", "/first"); err != nil {
+	client.state(ctx).requestBody = []byte(`{"name": "test"}`)
+	if err := client.sendRequest(ctx, "POST", "/first"); err != nil {
 		t.Fatalf("first request failed: %v", err)
 	}
 
 	// Second request without setting body - should have empty body
-	if err := client.sendRequest("POST", "/second"); err != nil {
+	if err := client.sendRequest(ctx, "POST", "/second"); err != nil {
 		t.Fatalf("second request failed: %v", err)
 	}
 
@@ -124,23 +129,24 @@ func TestHTTPClient_ResetClearsHeaders(t *testing.T) {
 		t.Fatalf("failed to create client: %v", err)
 	}
 
-	if err := client.Init(context.Background()); err != nil {
+	ctx := context.Background()
+	if err := client.Init(ctx); err != nil {
 		t.Fatalf("failed to init client: %v", err)
 	}
 
 	// Set header and make request
-	client.setHeader("Authorization", "Bearer test-token")
-	if err := client.sendRequest("GET", "/first"); err != nil {
+	client.setHeader(ctx, "Authorization", "Bearer test-token")
+	if err := client.sendRequest(ctx, "GET", "/first"); err != nil {
 		t.Fatalf("first request failed: %v", err)
 	}
 
 	// Reset (simulates new scenario)
-	if err := client.Reset(context.Background()); err != nil {
+	if err := client.Reset(ctx); err != nil {
 		t.Fatalf("reset failed: %v", err)
 	}
 
 	// Request after reset - header should be gone
-	if err := client.sendRequest("GET", "/second"); err != nil {
+	if err := client.sendRequest(ctx, "GET", "/second"); err != nil {
 		t.Fatalf("second request failed: %v", err)
 	}
 
@@ -156,3 +162,192 @@ func TestHTTPClient_ResetClearsHeaders(t *testing.T) {
 		t.Errorf("second request (after reset): expected empty, got %q", receivedAuth[1])
 	}
 }
+
+func TestHTTPClient_ScenarioStateIsolated(t *testing.T) {
+	var receivedAuth []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedAuth = append(receivedAuth, r.Header.Get("Authorization"))
+		w.Write([]byte(`{"id": "42"}`))
+	}))
+	defer server.Close()
+
+	client, err := NewHTTPClient("api", config.Resource{
+		BaseURL: server.URL,
+	}, nil)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	if err := client.Init(context.Background()); err != nil {
+		t.Fatalf("failed to init client: %v", err)
+	}
+
+	first := WithScenarioState(context.Background(), NewScenarioState())
+	second := WithScenarioState(context.Background(), NewScenarioState())
+
+	client.setHeader(first, "Authorization", "Bearer first")
+	if err := client.sendRequest(first, "GET", "/users"); err != nil {
+		t.Fatalf("first scenario request failed: %v", err)
+	}
+	if err := client.saveJSONPathToVariable(first, "id", "user_id"); err != nil {
+		t.Fatalf("saving variable failed: %v", err)
+	}
+
+	// The second scenario must not see the first scenario's headers, response or variables
+	if err := client.responseStatusShouldBe(second, 200); err == nil {
+		t.Error("expected no response in second scenario")
+	}
+	if err := client.sendRequest(second, "GET", "/users/{{user_id}}"); err != nil {
+		t.Fatalf("second scenario request failed: %v", err)
+	}
+
+	if receivedAuth[1] != "" {
+		t.Errorf("second scenario: expected no Authorization header, got %q", receivedAuth[1])
+	}
+	if v, ok := VariablesFrom(first).Get("user_id"); !ok || v != "42" {
+		t.Errorf("first scenario: expected user_id 42, got %q", v)
+	}
+	if _, ok := VariablesFrom(second).Get("user_id"); ok {
+		t.Error("second scenario: expected user_id to be unset")
+	}
+}
+
+func TestHTTPClient_Transcript(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error":"boom"}`))
+	}))
+	defer server.Close()
+
+	client, err := NewHTTPClient("api", config.Resource{
+		BaseURL: server.URL,
+		Options: map[string]any{"transcript": 2},
+	}, nil)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	if err := client.Init(context.Background()); err != nil {
+		t.Fatalf("failed to init client: %v", err)
+	}
+
+	ctx := WithScenarioState(context.Background(), NewScenarioState())
+	client.setHeader(ctx, "X-Trace", "abc")
+	for _, path := range []string{"/first", "/second", "/third"} {
+		if err := client.sendRequestWithJSON(ctx, "POST", path, &godog.DocString{Content: `{"name":"alice"}`}); err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+	}
+
+	transcript := client.Transcript(ctx)
+	if strings.Contains(transcript, "/first") {
+		t.Errorf("transcript should only keep the last 2 exchanges:\n%s", transcript)
+	}
+	for _, want := range []string{
+		"POST " + server.URL + "/third → 500",
+		"> X-Trace: abc",
+		`> {"name":"alice"}`,
+		"< Content-Type: application/json",
+		`< {"error":"boom"}`,
+	} {
+		if !strings.Contains(transcript, want) {
+			t.Errorf("transcript should contain %q:\n%s", want, transcript)
+		}
+	}
+
+	if got := client.Transcript(WithScenarioState(context.Background(), NewScenarioState())); got != "" {
+		t.Errorf("transcript of another scenario = %q, want empty", got)
+	}
+}
+
+func TestHTTPClient_TranscriptRedactsCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "cookie-secret"})
+		w.Header().Set("X-Api-Key", "key-secret")
+	}))
+	defer server.Close()
+
+	for _, auth := range []map[string]any{
+		{"type": "bearer", "token": "token-secret"},
+		{"type": "hmac", "secret": "s", "header": "X-Signature", "signature": "sig-secret-{signature}"},
+	} {
+		t.Run(auth["type"].(string), func(t *testing.T) {
+			client := newTestHTTPClient(t, server.URL, map[string]any{"auth": auth})
+			ctx := WithRedactedHeaders(WithScenarioState(context.Background(), NewScenarioState()), []string{"X-Api-Key"})
+			client.setHeader(ctx, "Cookie", "session=cookie-secret")
+			if err := client.sendRequest(ctx, "GET", "/"); err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+
+			transcript := client.Transcript(ctx)
+			if strings.Contains(transcript, "secret") {
+				t.Errorf("transcript leaks a credential:\n%s", transcript)
+			}
+			if !strings.Contains(transcript, "< X-Api-Key: [REDACTED]") {
+				t.Errorf("transcript should redact the headers of settings.har.redact:\n%s", transcript)
+			}
+		})
+	}
+}
+
+func TestHTTPClient_WorkerHeader(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("X-Tomato-Worker"))
+	}))
+	defer server.Close()
+
+	client := newTestHTTPClient(t, server.URL, map[string]any{"worker_header": "X-Tomato-Worker"})
+	for _, worker := range []int{3, 0} {
+		ctx := WithWorker(WithScenarioState(context.Background(), NewScenarioState()), worker)
+		if err := client.sendRequest(ctx, "GET", "/"); err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+	}
+	if len(got) != 2 || got[0] != "3" || got[1] != "" {
+		t.Errorf("worker headers = %q, want the worker ID and none outside of a worker", got)
+	}
+}
+
+func TestHTTPClient_HAR(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	registry := &Registry{
+		handlers:  make(map[string]Handler),
+		harConfig: map[string]bool{"api": true},
+	}
+	for _, name := range []string{"api", "other"} {
+		client, err := NewHTTPClient(name, config.Resource{BaseURL: server.URL}, nil)
+		if err != nil {
+			t.Fatalf("failed to create client: %v", err)
+		}
+		if err := client.Init(context.Background()); err != nil {
+			t.Fatalf("failed to init client: %v", err)
+		}
+		registry.handlers[name] = client
+	}
+
+	rec := har.NewRecorder(nil)
+	registry.SetHAR(rec, false)
+
+	page := rec.StartPage("scenario", "")
+	ctx := har.WithPage(WithScenarioState(context.Background(), NewScenarioState()), page)
+	for _, name := range []string{"api", "other"} {
+		h, _ := registry.Get(name)
+		if err := h.(*HTTPClient).sendRequest(ctx, "DELETE", "/users/1"); err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+	}
+
+	entries := rec.HAR().Log.Entries
+	if len(entries) != 1 {
+		t.Fatalf("only the resource with options.har should be recorded, got %d entries", len(entries))
+	}
+	e := entries[0]
+	if e.PageRef != page || e.Comment != "api" || e.Request.URL != server.URL+"/users/1" || e.Response.Status != http.StatusNoContent {
+		t.Errorf("entry = %+v", e)
+	}
+}
@@ -0,0 +1,261 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cucumber/godog"
+)
+
+// cookiesEnabled reports whether the client keeps a cookie jar for each
+// scenario (options.cookies)
+func (r *HTTPClient) cookiesEnabled() bool {
+	enabled, _ := r.config.Options["cookies"].(bool)
+	return enabled
+}
+
+// jar returns the cookie jar of a scenario, creating it when cookies are
+// enabled or create is set. It returns nil when cookies are disabled.
+func (r *HTTPClient) jar(s *httpClientState, create bool) http.CookieJar {
+	if s.jar == nil && (create || r.cookiesEnabled()) {
+		// cookiejar.New only fails on invalid options
+		s.jar, _ = cookiejar.New(nil)
+	}
+	return s.jar
+}
+
+// cookieURL is the URL cookies of the resource are set and read for
+func (r *HTTPClient) cookieURL() (*url.URL, error) {
+	u, err := url.Parse(r.baseURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("cookies need a base URL with a host, got %q", r.baseURL)
+	}
+	u.Path = "/"
+	return u, nil
+}
+
+func (r *HTTPClient) setCookie(ctx context.Context, name, value string) error {
+	u, err := r.cookieURL()
+	if err != nil {
+		return err
+	}
+	r.jar(r.state(ctx), true).SetCookies(u, []*http.Cookie{{Name: name, Value: value, Path: "/"}})
+	return nil
+}
+
+func (r *HTTPClient) clearCookies(ctx context.Context) error {
+	s := r.state(ctx)
+	s.jar = nil
+	return nil
+}
+
+// jarCookie returns the cookie the jar would send to the base URL
+func (r *HTTPClient) jarCookie(ctx context.Context, name string) (*http.Cookie, error) {
+	jar := r.jar(r.state(ctx), false)
+	if jar == nil {
+		return nil, fmt.Errorf("no cookie jar; set options.cookies to keep cookies between requests")
+	}
+	u, err := r.cookieURL()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range jar.Cookies(u) {
+		if c.Name == name {
+			return c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *HTTPClient) shouldHaveCookie(ctx context.Context, name string) error {
+	c, err := r.jarCookie(ctx, name)
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		return fmt.Errorf("cookie %q not found", name)
+	}
+	return nil
+}
+
+func (r *HTTPClient) shouldHaveCookieWithValue(ctx context.Context, name, expected string) error {
+	c, err := r.jarCookie(ctx, name)
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		return fmt.Errorf("cookie %q not found", name)
+	}
+	if c.Value != expected {
+		return fmt.Errorf("cookie %q: expected %q, got %q", name, expected, c.Value)
+	}
+	return nil
+}
+
+func (r *HTTPClient) shouldNotHaveCookie(ctx context.Context, name string) error {
+	c, err := r.jarCookie(ctx, name)
+	if err != nil {
+		return err
+	}
+	if c != nil {
+		return fmt.Errorf("cookie %q is set to %q but should not be", name, c.Value)
+	}
+	return nil
+}
+
+// responseCookie returns the last cookie the last response sets with the name
+func (r *HTTPClient) responseCookie(ctx context.Context, name string) (*http.Cookie, error) {
+	s := r.state(ctx)
+	if s.lastResponse == nil {
+		return nil, fmt.Errorf("no response received")
+	}
+	var found *http.Cookie
+	var names []string
+	for _, c := range s.lastResponse.Cookies() {
+		if c.Name == name {
+			found = c
+		}
+		names = append(names, c.Name)
+	}
+	if found == nil {
+		return nil, fmt.Errorf("response does not set cookie %q (sets %v)", name, names)
+	}
+	return found, nil
+}
+
+func (r *HTTPClient) responseShouldSetCookie(ctx context.Context, name string) error {
+	_, err := r.responseCookie(ctx, name)
+	return err
+}
+
+func (r *HTTPClient) responseCookieShouldBe(ctx context.Context, name, expected string) error {
+	c, err := r.responseCookie(ctx, name)
+	if err != nil {
+		return err
+	}
+	if c.Value != expected {
+		return fmt.Errorf("cookie %q: expected %q, got %q", name, expected, c.Value)
+	}
+	return nil
+}
+
+// responseCookieShouldHave asserts attributes of a cookie the last response
+// sets, from a table of attribute and value rows
+func (r *HTTPClient) responseCookieShouldHave(ctx context.Context, name string, table *godog.Table) error {
+	c, err := r.responseCookie(ctx, name)
+	if err != nil {
+		return err
+	}
+	var mismatches []string
+	for _, row := range table.Rows[1:] {
+		if len(row.Cells) < 2 {
+			continue
+		}
+		attr, expected := strings.ToLower(row.Cells[0].Value), row.Cells[1].Value
+		actual, ok, err := cookieAttribute(c, attr, expected)
+		if err != nil {
+			return fmt.Errorf("cookie %q: %w", name, err)
+		}
+		if !ok {
+			mismatches = append(mismatches, fmt.Sprintf("%s: expected %q, got %q", attr, expected, actual))
+		}
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("cookie %q does not match:\n  %s", name, strings.Join(mismatches, "\n  "))
+	}
+	return nil
+}
+
+// cookieAttribute returns the value of a cookie attribute and whether it
+// matches expected. expires is "session" for a cookie without expiry,
+// "expired" for one that deletes the cookie, or a duration the cookie
+// expires within.
+func cookieAttribute(c *http.Cookie, attr, expected string) (string, bool, error) {
+	switch attr {
+	case "value":
+		return c.Value, c.Value == expected, nil
+	case "domain":
+		return c.Domain, strings.TrimPrefix(c.Domain, ".") == strings.TrimPrefix(expected, "."), nil
+	case "path":
+		return c.Path, c.Path == expected, nil
+	case "httponly":
+		actual := strconv.FormatBool(c.HttpOnly)
+		return actual, strings.EqualFold(actual, expected), nil
+	case "secure":
+		actual := strconv.FormatBool(c.Secure)
+		return actual, strings.EqualFold(actual, expected), nil
+	case "samesite":
+		actual := sameSiteName(c.SameSite)
+		return actual, strings.EqualFold(actual, expected), nil
+	case "max-age":
+		actual := strconv.Itoa(c.MaxAge)
+		return actual, actual == expected, nil
+	case "expires":
+		return cookieExpiry(c, expected)
+	}
+	return "", false, fmt.Errorf("unknown attribute %q (value, domain, path, httponly, secure, samesite, max-age, expires)", attr)
+}
+
+func cookieExpiry(c *http.Cookie, expected string) (string, bool, error) {
+	var expiry time.Time
+	switch {
+	case c.MaxAge < 0:
+		return "expired", expected == "expired", nil
+	case c.MaxAge > 0:
+		expiry = time.Now().Add(time.Duration(c.MaxAge) * time.Second)
+	case !c.Expires.IsZero():
+		expiry = c.Expires
+	default:
+		return "session", expected == "session", nil
+	}
+	actual := expiry.UTC().Format(http.TimeFormat)
+	if !expiry.After(time.Now()) {
+		return actual, expected == "expired", nil
+	}
+	switch expected {
+	case "session", "expired":
+		return actual, false, nil
+	}
+	within, err := time.ParseDuration(expected)
+	if err != nil {
+		return "", false, fmt.Errorf("expires: expected session, expired or a duration, got %q", expected)
+	}
+	return actual, !expiry.After(time.Now().Add(within)), nil
+}
+
+func sameSiteName(s http.SameSite) string {
+	switch s {
+	case http.SameSiteLaxMode:
+		return "Lax"
+	case http.SameSiteStrictMode:
+		return "Strict"
+	case http.SameSiteNoneMode:
+		return "None"
+	}
+	return ""
+}
+
+// saveCookieToVariable saves a cookie of the jar, or the last response
+// when the client keeps no jar
+func (r *HTTPClient) saveCookieToVariable(ctx context.Context, name, varName string) error {
+	var c *http.Cookie
+	var err error
+	if r.jar(r.state(ctx), false) != nil {
+		if c, err = r.jarCookie(ctx, name); err == nil && c == nil {
+			err = fmt.Errorf("cookie %q not found", name)
+		}
+	} else {
+		c, err = r.responseCookie(ctx, name)
+	}
+	if err != nil {
+		return err
+	}
+	VariablesFrom(ctx).Set(varName, c.Value)
+	return nil
+}
@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cucumber/godog"
+	messages "github.com/cucumber/messages/go/v21"
+	"github.com/tomatool/tomato/internal/config"
+)
+
+func newCookieServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{
+				Name: "session", Value: "s3cr3t", Path: "/", HttpOnly: true, Secure: true,
+				SameSite: http.SameSiteStrictMode, MaxAge: 3600,
+			})
+			http.SetCookie(w, &http.Cookie{Name: "theme", Value: "dark", Path: "/"})
+		case "/logout":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "", Path: "/", MaxAge: -1})
+		case "/me":
+			c, err := r.Cookie("session")
+			if err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(c.Value))
+		}
+	}))
+}
+
+func newTestHTTPClient(t *testing.T, baseURL string, options map[string]any) *HTTPClient {
+	t.Helper()
+	client, err := NewHTTPClient("api", config.Resource{BaseURL: baseURL, Options: options}, nil)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	if err := client.Init(context.Background()); err != nil {
+		t.Fatalf("failed to init client: %v", err)
+	}
+	return client
+}
+
+func TestHTTPClient_CookieJar(t *testing.T) {
+	server := newCookieServer()
+	defer server.Close()
+
+	ctx := WithScenarioState(context.Background(), NewScenarioState())
+	client := newTestHTTPClient(t, server.URL, map[string]any{"cookies": true})
+
+	if err := client.sendRequest(ctx, "POST", "/login"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.shouldHaveCookieWithValue(ctx, "session", "s3cr3t"); err != nil {
+		t.Error(err)
+	}
+	if err := client.sendRequest(ctx, "GET", "/me"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.responseStatusShouldBe(ctx, 200); err != nil {
+		t.Errorf("the session cookie wasn't sent: %v", err)
+	}
+
+	if err := client.saveCookieToVariable(ctx, "session", "sid"); err != nil {
+		t.Fatal(err)
+	}
+	if got := VariablesFrom(ctx).Replace("{{sid}}"); got != "s3cr3t" {
+		t.Errorf("saved cookie = %q", got)
+	}
+
+	if err := client.sendRequest(ctx, "POST", "/logout"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.shouldNotHaveCookie(ctx, "session"); err != nil {
+		t.Error(err)
+	}
+
+	// Another scenario starts with an empty jar
+	other := WithScenarioState(context.Background(), NewScenarioState())
+	if err := client.sendRequest(other, "POST", "/login"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.shouldHaveCookie(other, "theme"); err != nil {
+		t.Error(err)
+	}
+	if err := client.clearCookies(other); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.shouldNotHaveCookie(other, "theme"); err != nil {
+		t.Errorf("cookies weren't cleared: %v", err)
+	}
+}
+
+func TestHTTPClient_CookiesDisabled(t *testing.T) {
+	server := newCookieServer()
+	defer server.Close()
+
+	ctx := WithScenarioState(context.Background(), NewScenarioState())
+	client := newTestHTTPClient(t, server.URL, nil)
+
+	if err := client.sendRequest(ctx, "POST", "/login"); err != nil {
+		t.Fatal(err)
+	}
+	// Without a jar, cookies are read from the response only
+	if err := client.saveCookieToVariable(ctx, "theme", "theme"); err != nil {
+		t.Error(err)
+	}
+	if err := client.shouldHaveCookie(ctx, "session"); err == nil || !strings.Contains(err.Error(), "options.cookies") {
+		t.Errorf("error = %v, want a hint to enable cookies", err)
+	}
+	if err := client.sendRequest(ctx, "GET", "/me"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.responseStatusShouldBe(ctx, 401); err != nil {
+		t.Errorf("a cookie was kept without a jar: %v", err)
+	}
+
+	// Setting a cookie starts a jar for the scenario
+	if err := client.setCookie(ctx, "session", "manual"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.sendRequest(ctx, "GET", "/me"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.responseBodyShouldBe(ctx, &godog.DocString{Content: "manual"}); err != nil {
+		t.Errorf("the cookie that was set wasn't sent: %v", err)
+	}
+}
+
+func TestHTTPClient_ResponseCookieAttributes(t *testing.T) {
+	server := newCookieServer()
+	defer server.Close()
+
+	ctx := WithScenarioState(context.Background(), NewScenarioState())
+	client := newTestHTTPClient(t, server.URL, nil)
+	if err := client.sendRequest(ctx, "POST", "/login"); err != nil {
+		t.Fatal(err)
+	}
+
+	table := func(rows ...[2]string) *godog.Table {
+		tbl := &godog.Table{Rows: []*messages.PickleTableRow{{Cells: []*messages.PickleTableCell{{Value: "attribute"}, {Value: "value"}}}}}
+		for _, r := range rows {
+			tbl.Rows = append(tbl.Rows, &messages.PickleTableRow{Cells: []*messages.PickleTableCell{{Value: r[0]}, {Value: r[1]}}})
+		}
+		return tbl
+	}
+
+	tests := []struct {
+		name    string
+		cookie  string
+		rows    [][2]string
+		wantErr string
+	}{
+		{
+			name:   "all attributes",
+			cookie: "session",
+			rows: [][2]string{
+				{"value", "s3cr3t"}, {"path", "/"}, {"HttpOnly", "true"}, {"secure", "true"},
+				{"samesite", "Strict"}, {"max-age", "3600"}, {"expires", "2h"},
+			},
+		},
+		{name: "session cookie", cookie: "theme", rows: [][2]string{{"expires", "session"}, {"httponly", "false"}}},
+		{name: "expires later", cookie: "session", rows: [][2]string{{"expires", "30m"}}, wantErr: "expires: expected"},
+		{name: "wrong attributes", cookie: "theme", rows: [][2]string{{"secure", "true"}, {"samesite", "Lax"}}, wantErr: "secure: expected \"true\", got \"false\"\n  samesite"},
+		{name: "unknown attribute", cookie: "theme", rows: [][2]string{{"color", "red"}}, wantErr: "unknown attribute"},
+		{name: "missing cookie", cookie: "other", wantErr: `response does not set cookie "other"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := client.responseCookieShouldHave(ctx, tt.cookie, table(tt.rows...))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if err := client.responseCookieShouldBe(ctx, "theme", "dark"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCookieExpiry(t *testing.T) {
+	past := &http.Cookie{Expires: time.Now().Add(-time.Hour)}
+	if _, ok, _ := cookieExpiry(past, "expired"); !ok {
+		t.Error("a cookie that expired in the past should be expired")
+	}
+	future := &http.Cookie{Expires: time.Now().Add(time.Hour)}
+	if _, ok, _ := cookieExpiry(future, "2h"); !ok {
+		t.Error("a cookie that expires in 1h should expire within 2h")
+	}
+	if _, _, err := cookieExpiry(future, "tomorrow"); err == nil {
+		t.Error("expected an error for an invalid expiry")
+	}
+}
@@ -3,17 +3,21 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/cucumber/godog"
+	"github.com/rs/zerolog/log"
 	"github.com/tomatool/tomato/internal/config"
 	"github.com/tomatool/tomato/internal/container"
+	"github.com/tomatool/tomato/internal/har"
 )
 
 // HTTPServer provides a mock HTTP server for testing
@@ -30,6 +34,11 @@ type HTTPServer struct {
 	calls    []*RecordedCall
 	stubsMu  sync.RWMutex
 	callsMu  sync.RWMutex
+
+	har *har.Recorder // set when the traffic is exported as HAR
+
+	contract   *contract // set when stubs and calls are validated against an OpenAPI spec
+	violations []error   // contract violations of received calls, guarded by callsMu
 }
 
 // HTTPStub represents a stub configuration
@@ -49,6 +58,7 @@ type RecordedCall struct {
 	Headers http.Header
 	Body    string
 	Time    time.Time
+	Status  int // status of the response sent
 }
 
 func NewHTTPServer(name string, cfg config.Resource, cm *container.Manager) (*HTTPServer, error) {
@@ -76,6 +86,11 @@ func (r *HTTPServer) Init(ctx context.Context) error {
 	r.listener = listener
 	r.port = listener.Addr().(*net.TCPAddr).Port
 
+	if r.contract, err = loadContract(ctx, r.config); err != nil {
+		listener.Close()
+		return err
+	}
+
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", r.handleRequest)
 
@@ -99,20 +114,33 @@ func (r *HTTPServer) handleRequest(w http.ResponseWriter, req *http.Request) {
 		body = string(buf[:n])
 	}
 
-	r.callsMu.Lock()
-	r.calls = append(r.calls, &RecordedCall{
+	call := &RecordedCall{
 		Method:  req.Method,
 		Path:    req.URL.Path,
 		Headers: req.Header.Clone(),
 		Body:    body,
 		Time:    time.Now(),
-	})
+		Status:  http.StatusNotFound,
+	}
+	r.callsMu.Lock()
+	r.calls = append(r.calls, call)
 	r.callsMu.Unlock()
 
+	if r.contract != nil {
+		// The stub is still served, the violation fails the running step
+		if err := r.contract.checkRequest(req.Context(), req, []byte(body)); err != nil {
+			r.callsMu.Lock()
+			r.violations = append(r.violations, fmt.Errorf("%s received a request that violates the contract: %w", r.name, err))
+			r.callsMu.Unlock()
+		}
+	}
+
 	// Find matching stub
+	// The stub registered last wins, as stubs of earlier scenarios remain
+	// while other workers are running
 	r.stubsMu.RLock()
 	var matchedStub *HTTPStub
-	for _, stub := range r.stubs {
+	for _, stub := range slices.Backward(r.stubs) {
 		if stub.Method != req.Method {
 			continue
 		}
@@ -129,34 +157,97 @@ func (r *HTTPServer) handleRequest(w http.ResponseWriter, req *http.Request) {
 	r.stubsMu.RUnlock()
 
 	if matchedStub == nil {
+		msg := fmt.Sprintf("No stub found for %s %s", req.Method, req.URL.Path)
 		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte(fmt.Sprintf("No stub found for %s %s", req.Method, req.URL.Path)))
+		w.Write([]byte(msg))
+		r.recordHAR(req, call, w.Header(), []byte(msg))
 		return
 	}
 
+	r.callsMu.Lock()
+	call.Status = matchedStub.Status
+	r.callsMu.Unlock()
+
 	for k, v := range matchedStub.Headers {
 		w.Header().Set(k, v)
 	}
 	w.WriteHeader(matchedStub.Status)
 	w.Write([]byte(matchedStub.Body))
+	r.recordHAR(req, call, w.Header(), []byte(matchedStub.Body))
 }
 
+// recordHAR adds a received call and the response it was answered with.
+// The call belongs to the running scenario, as requests carry no scenario.
+func (r *HTTPServer) recordHAR(req *http.Request, call *RecordedCall, header http.Header, body []byte) {
+	if r.har == nil {
+		return
+	}
+	r.har.Add("", har.Exchange{
+		Resource:       r.name,
+		Started:        call.Time,
+		Duration:       time.Since(call.Time),
+		Method:         call.Method,
+		URL:            "http://" + req.Host + req.URL.RequestURI(),
+		RequestHeader:  call.Headers,
+		RequestBody:    []byte(call.Body),
+		Status:         call.Status,
+		ResponseHeader: header.Clone(),
+		ResponseBody:   body,
+	})
+}
+
+func (r *HTTPServer) setHAR(rec *har.Recorder) { r.har = rec }
+
 func (r *HTTPServer) Ready(ctx context.Context) error {
 	return nil
 }
 
 func (r *HTTPServer) Reset(ctx context.Context) error {
+	// The server is shared by all workers
+	if othersRunning(ctx) {
+		log.Debug().Str("resource", r.name).Msg("not resetting stubs and calls while other workers are running")
+		return nil
+	}
+
 	r.stubsMu.Lock()
 	r.stubs = make([]*HTTPStub, 0)
 	r.stubsMu.Unlock()
 
 	r.callsMu.Lock()
 	r.calls = make([]*RecordedCall, 0)
+	r.violations = nil
 	r.callsMu.Unlock()
 
 	return nil
 }
 
+// takeContractViolations returns the contract violations of the calls
+// received since the last call, and forgets them
+func (r *HTTPServer) takeContractViolations() error {
+	r.callsMu.Lock()
+	defer r.callsMu.Unlock()
+	err := errors.Join(r.violations...)
+	r.violations = nil
+	return err
+}
+
+// Transcript returns the last calls received and the status they were answered with
+func (r *HTTPServer) Transcript(ctx context.Context) string {
+	n := transcriptSize(r.config)
+	if n == 0 {
+		return ""
+	}
+
+	r.callsMu.RLock()
+	defer r.callsMu.RUnlock()
+
+	var entries []string
+	for _, call := range lastN(r.calls, n) {
+		entries = append(entries, formatHTTPExchange(redactorFrom(ctx), call.Time, call.Method, call.Path, call.Headers, []byte(call.Body), call.Status, nil, nil, 0, nil))
+	}
+	return joinTranscript(entries)
+}
+
 func (r *HTTPServer) RegisterSteps(ctx *godog.ScenarioContext) {
 	RegisterStepsToGodog(ctx, r.name, r.Steps())
 }
@@ -254,28 +345,20 @@ func (r *HTTPServer) Steps() StepCategory {
 }
 
 func (r *HTTPServer) stubReturnsStatus(method, path string, status int) error {
-	r.stubsMu.Lock()
-	defer r.stubsMu.Unlock()
-
-	r.stubs = append(r.stubs, &HTTPStub{
+	return r.addStub(&HTTPStub{
 		Method: method,
 		Path:   path,
 		Status: status,
 	})
-	return nil
 }
 
 func (r *HTTPServer) stubReturnsBody(method, path string, status int, doc *godog.DocString) error {
-	r.stubsMu.Lock()
-	defer r.stubsMu.Unlock()
-
-	r.stubs = append(r.stubs, &HTTPStub{
+	return r.addStub(&HTTPStub{
 		Method: method,
 		Path:   path,
 		Status: status,
 		Body:   doc.Content,
 	})
-	return nil
 }
 
 func (r *HTTPServer) stubReturnsJSON(method, path string, status int, doc *godog.DocString) error {
@@ -284,17 +367,13 @@ func (r *HTTPServer) stubReturnsJSON(method, path string, status int, doc *godog
 		return fmt.Errorf("invalid JSON: %w", err)
 	}
 
-	r.stubsMu.Lock()
-	defer r.stubsMu.Unlock()
-
-	r.stubs = append(r.stubs, &HTTPStub{
+	return r.addStub(&HTTPStub{
 		Method:  method,
 		Path:    path,
 		Status:  status,
 		Headers: map[string]string{"Content-Type": "application/json"},
 		Body:    doc.Content,
 	})
-	return nil
 }
 
 func (r *HTTPServer) stubReturnsHeaders(method, path string, status int, table *godog.Table) error {
@@ -305,15 +384,35 @@ func (r *HTTPServer) stubReturnsHeaders(method, path string, status int, table *
 		}
 	}
 
-	r.stubsMu.Lock()
-	defer r.stubsMu.Unlock()
-
-	r.stubs = append(r.stubs, &HTTPStub{
+	return r.addStub(&HTTPStub{
 		Method:  method,
 		Path:    path,
 		Status:  status,
 		Headers: headers,
 	})
+}
+
+// addStub registers a stub, after checking the response it returns is one
+// the contract documents for the operation, so stubs can't drift from the API
+func (r *HTTPServer) addStub(stub *HTTPStub) error {
+	if r.contract != nil {
+		req, err := http.NewRequest(stub.Method, stub.Path, nil)
+		if err != nil {
+			return fmt.Errorf("invalid stub: %w", err)
+		}
+		header := make(http.Header)
+		for k, v := range stub.Headers {
+			header.Set(k, v)
+		}
+		if err := r.contract.checkResponse(context.Background(), req, stub.Status, header, []byte(stub.Body)); err != nil {
+			return fmt.Errorf("stub violates the contract: %w", err)
+		}
+	}
+
+	r.stubsMu.Lock()
+	defer r.stubsMu.Unlock()
+
+	r.stubs = append(r.stubs, stub)
 	return nil
 }
 
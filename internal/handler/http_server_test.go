@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/tomatool/tomato/internal/config"
+)
+
+func TestHTTPServer_ResetWithOtherWorkersRunning(t *testing.T) {
+	server, _ := NewHTTPServer("mock", config.Resource{}, nil)
+	if err := server.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Cleanup(context.Background())
+
+	get := func() string {
+		t.Helper()
+		resp, err := http.Get(server.GetURL() + "/users")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	// Worker 1 stubs the route, then worker 2 resets while worker 1 runs
+	server.addStub(&HTTPStub{Method: "GET", Path: "/users", Status: 200, Body: "first"})
+	busy := WithRunningWorkers(WithWorker(context.Background(), 2), 2)
+	if err := server.Reset(busy); err != nil {
+		t.Fatal(err)
+	}
+	if got := get(); got != "first" {
+		t.Fatalf("stub of the running worker = %q, want it kept", got)
+	}
+
+	// The stub registered last wins over stubs of earlier scenarios
+	server.addStub(&HTTPStub{Method: "GET", Path: "/users", Status: 200, Body: "second"})
+	if got := get(); got != "second" {
+		t.Errorf("response = %q, want the latest stub", got)
+	}
+	if err := server.receivedTotalRequests(2); err != nil {
+		t.Error(err)
+	}
+
+	// The only running worker resets everything
+	alone := WithRunningWorkers(WithWorker(context.Background(), 1), 1)
+	if err := server.Reset(alone); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.receivedTotalRequests(0); err != nil {
+		t.Error(err)
+	}
+	if got := get(); got == "first" || got == "second" {
+		t.Errorf("response = %q, want no stub after the reset", got)
+	}
+}
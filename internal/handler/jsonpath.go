@@ -0,0 +1,278 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ohler55/ojg/jp"
+)
+
+// JSONPath is a compiled JSONPath expression, evaluated by
+// github.com/ohler55/ojg/jp as specified by RFC 9535. It supports:
+//
+//	$.a.b  a.b  [0].id      child keys and indexes; the leading $ is optional
+//	['a.b'] ["x y"]         keys that contain dots, spaces or brackets
+//	[-1]  [1:3]  [::2]      negative indexes and slices
+//	.*  [*]  [0,2]  ['a','b'] wildcards and unions
+//	..name  ..*  ..[0]      recursive descent
+//	[?(@.sku=='X')]         filters with == != < <= > >=, &&, || and !
+//	[?(@.sku =~ /^X/)]      regular expressions, as /.../ or a quoted string
+//
+// Object members are selected in no particular order, as RFC 9535 leaves it
+// unspecified and JSON documents decoded into maps don't keep it.
+type JSONPath struct {
+	expr string
+	x    jp.Expr
+}
+
+// ParseJSONPath compiles a JSONPath expression
+func ParseJSONPath(expr string) (*JSONPath, error) {
+	x, err := parseJSONPathExpr(strings.TrimSpace(expr))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON path %q: %w", expr, err)
+	}
+	return &JSONPath{expr: expr, x: x}, nil
+}
+
+func parseJSONPathExpr(src string) (jp.Expr, error) {
+	switch {
+	case src == "" || src[0] == '$':
+	case src[0] == '.' || src[0] == '[':
+		src = "$" + src
+	default:
+		src = "$." + src
+	}
+	x, err := jp.ParseString(src)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := x[len(x)-1].(jp.Descent); ok {
+		return nil, errors.New("recursive descent must be followed by a key, index or wildcard")
+	}
+	if err := checkFilterPatterns(x); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// checkFilterPatterns compiles the quoted regular expressions of =~ filters,
+// which are otherwise only compiled, and silently never match when invalid,
+// while the path is evaluated
+func checkFilterPatterns(x jp.Expr) error {
+	for _, frag := range x {
+		if f, ok := frag.(*jp.Filter); ok {
+			if err := checkFormPatterns(inspectFilter(f)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// inspectFilter returns the operations of a filter, or nil when it is a lone
+// path such as [?(@.active)], which Inspect doesn't handle
+func inspectFilter(f *jp.Filter) (form *jp.Form) {
+	defer func() {
+		if recover() != nil {
+			form = nil
+		}
+	}()
+	return f.Inspect()
+}
+
+func checkFormPatterns(v any) error {
+	switch v := v.(type) {
+	case *jp.Form:
+		if v == nil {
+			return nil
+		}
+		if s, ok := v.Right.(string); ok && (v.Op == "=~" || v.Op == "~=") {
+			if _, err := regexp.Compile(s); err != nil {
+				return fmt.Errorf("invalid pattern in filter: %w", err)
+			}
+		}
+		if err := checkFormPatterns(v.Left); err != nil {
+			return err
+		}
+		return checkFormPatterns(v.Right)
+	case jp.Expr:
+		return checkFilterPatterns(v)
+	}
+	return nil
+}
+
+// String returns the expression the path was compiled from
+func (p *JSONPath) String() string { return p.expr }
+
+// Definite reports whether the path selects at most one value: it only
+// names keys and indexes, without wildcards, slices, unions, filters or
+// recursive descent
+func (p *JSONPath) Definite() bool {
+	for _, frag := range p.x {
+		switch frag.(type) {
+		case jp.Root, jp.Bracket, jp.Child, jp.Nth:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Query returns the values the path selects in doc, a document decoded by
+// encoding/json
+func (p *JSONPath) Query(doc any) []any {
+	return p.x.Get(doc)
+}
+
+// Get returns the single value the path selects in doc. When a definite path
+// selects nothing, the error tells which part of it is missing; an indefinite
+// path must select exactly one value.
+func (p *JSONPath) Get(doc any) (any, error) {
+	if !p.Definite() {
+		values := p.Query(doc)
+		switch len(values) {
+		case 0:
+			return nil, fmt.Errorf("JSON path %q matches nothing", p.expr)
+		case 1:
+			return values[0], nil
+		}
+		return nil, fmt.Errorf("JSON path %q matches %d values; use \"all of\" or \"any of\" to assert on each of them", p.expr, len(values))
+	}
+
+	current, at := doc, "$"
+	for _, frag := range p.x {
+		switch frag := frag.(type) {
+		case jp.Child:
+			obj, ok := current.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("JSON path %q: expected object at %s, got %s", p.expr, at, jsonTypeName(current))
+			}
+			if current, ok = obj[string(frag)]; !ok {
+				return nil, fmt.Errorf("JSON path %q: key %q not found at %s", p.expr, string(frag), at)
+			}
+			at += formatPathKey(string(frag))
+		case jp.Nth:
+			arr, ok := current.([]any)
+			if !ok {
+				return nil, fmt.Errorf("JSON path %q: expected array at %s, got %s", p.expr, at, jsonTypeName(current))
+			}
+			i, ok := normalizeIndex(int(frag), len(arr))
+			if !ok {
+				return nil, fmt.Errorf("JSON path %q: index %d out of bounds at %s (length %d)", p.expr, int(frag), at, len(arr))
+			}
+			current = arr[i]
+			at += fmt.Sprintf("[%d]", int(frag))
+		}
+	}
+	return current, nil
+}
+
+// Replace replaces each value the path selects in doc with the result of fn,
+// in place, and returns the document, which is only a new value when the
+// path is the root itself
+func (p *JSONPath) Replace(doc any, fn func(v any) (any, error)) (any, error) {
+	if len(p.x) == 1 {
+		return fn(doc)
+	}
+	var fnErr error
+	doc, err := p.x.Modify(doc, func(v any) (any, bool) {
+		if fnErr != nil {
+			return v, false
+		}
+		altered, err := fn(v)
+		if err != nil {
+			fnErr = err
+			return v, false
+		}
+		return altered, true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("JSON path %q: %w", p.expr, err)
+	}
+	if fnErr != nil {
+		return nil, fnErr
+	}
+	return doc, nil
+}
+
+// queryJSON decodes a JSON document and returns the values path selects in it
+func queryJSON(data []byte, path string) ([]any, error) {
+	p, doc, err := parseJSONQuery(data, path)
+	if err != nil {
+		return nil, err
+	}
+	return p.Query(doc), nil
+}
+
+// getJSON decodes a JSON document and returns the single value path selects in it
+func getJSON(data []byte, path string) (any, error) {
+	p, doc, err := parseJSONQuery(data, path)
+	if err != nil {
+		return nil, err
+	}
+	return p.Get(doc)
+}
+
+func parseJSONQuery(data []byte, path string) (*JSONPath, any, error) {
+	p, err := ParseJSONPath(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	return p, doc, nil
+}
+
+// jsonValueString formats a value selected by a JSON path for comparison
+// with a step argument: strings as they are, other values as JSON
+func jsonValueString(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func jsonTypeName(v any) string {
+	switch v.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func formatPathKey(key string) string {
+	if key != "" && !strings.ContainsAny(key, ".[]'\" *") {
+		return "." + key
+	}
+	return "[" + strconv.Quote(key) + "]"
+}
+
+func normalizeIndex(i, length int) (int, bool) {
+	if i < 0 {
+		i += length
+	}
+	return i, i >= 0 && i < length
+}
@@ -0,0 +1,312 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/cucumber/godog"
+)
+
+// jsonSource returns the JSON document a step asserts on, such as the body
+// of the last response or the last consumed message
+type jsonSource func(ctx context.Context) ([]byte, error)
+
+// messageJSONSteps returns the JSON path, schema and snapshot assertions on the last
+// message of a resource. example is the resource name used in the step examples.
+func messageJSONSteps(example string, last jsonSource) []StepDef {
+	steps := []StepDef{
+		{
+			Pattern:     `^"{resource}" last message json "([^"]*)" is "([^"]*)"$`,
+			Description: "Asserts the value at a JSON path of the last message",
+			Example:     `"` + example + `" last message json "data.id" is "123"`,
+			Handler: func(ctx context.Context, path, expected string) error {
+				data, err := last(ctx)
+				if err != nil {
+					return err
+				}
+				return jsonPathShouldBe(data, path, expected)
+			},
+		},
+		{
+			Pattern:     `^"{resource}" last message json "([^"]*)" exists$`,
+			Description: "Asserts a JSON path of the last message exists",
+			Example:     `"` + example + `" last message json "data.id" exists`,
+			Handler: func(ctx context.Context, path string) error {
+				data, err := last(ctx)
+				if err != nil {
+					return err
+				}
+				return jsonPathShouldExist(data, path)
+			},
+		},
+		{
+			Pattern:     `^"{resource}" last message json "([^"]*)" does not exist$`,
+			Description: "Asserts a JSON path of the last message doesn't exist",
+			Example:     `"` + example + `" last message json "data.deleted" does not exist`,
+			Handler: func(ctx context.Context, path string) error {
+				data, err := last(ctx)
+				if err != nil {
+					return err
+				}
+				return jsonPathShouldNotExist(data, path)
+			},
+		},
+		{
+			Pattern:     `^"{resource}" last message json "([^"]*)" matches pattern "([^"]*)"$`,
+			Description: "Asserts the value at a JSON path of the last message matches a regex pattern",
+			Example:     `"` + example + `" last message json "id" matches pattern "^[0-9a-f-]{36}$"`,
+			Handler: func(ctx context.Context, path, pattern string) error {
+				data, err := last(ctx)
+				if err != nil {
+					return err
+				}
+				return jsonPathShouldMatch(data, path, pattern)
+			},
+		},
+		{
+			Pattern:     `^"{resource}" last message json all of "([^"]*)" are "([^"]*)"$`,
+			Description: "Asserts every value selected by a JSON path of the last message equals the value",
+			Example:     `"` + example + `" last message json all of "items[*].status" are "shipped"`,
+			Handler: func(ctx context.Context, path, expected string) error {
+				data, err := last(ctx)
+				if err != nil {
+					return err
+				}
+				return jsonPathAllShouldBe(data, path, expected)
+			},
+		},
+		{
+			Pattern:     `^"{resource}" last message json any of "([^"]*)" is "([^"]*)"$`,
+			Description: "Asserts at least one value selected by a JSON path of the last message equals the value",
+			Example:     `"` + example + `" last message json any of "items[*].sku" is "ABC-1"`,
+			Handler: func(ctx context.Context, path, expected string) error {
+				data, err := last(ctx)
+				if err != nil {
+					return err
+				}
+				return jsonPathAnyShouldBe(data, path, expected)
+			},
+		},
+		{
+			Pattern:     `^"{resource}" last message json "([^"]*)" has "(\d+)" values$`,
+			Description: "Asserts the number of values a JSON path of the last message selects",
+			Example:     `"` + example + `" last message json "items[*]" has "3" values`,
+			Handler: func(ctx context.Context, path string, count int) error {
+				data, err := last(ctx)
+				if err != nil {
+					return err
+				}
+				return jsonPathShouldHaveCount(data, path, count)
+			},
+		},
+		{
+			Pattern:     `^"{resource}" last message json matches schema "([^"]*)"$`,
+			Description: "Asserts the last message is valid against a JSON Schema (draft 2020-12) file or URL",
+			Example:     `"` + example + `" last message json matches schema "schemas/order-created.json"`,
+			Handler: func(ctx context.Context, location string) error {
+				data, err := last(ctx)
+				if err != nil {
+					return err
+				}
+				return validateJSONSchema(data, location)
+			},
+		},
+		{
+			Pattern:     `^"{resource}" last message json matches schema:$`,
+			Description: "Asserts the last message is valid against an inline JSON Schema",
+			Example:     "\"" + example + "\" last message json matches schema:\n  \"\"\"\n  {\"type\": \"object\", \"required\": [\"id\"]}\n  \"\"\"",
+			Handler: func(ctx context.Context, doc *godog.DocString) error {
+				data, err := last(ctx)
+				if err != nil {
+					return err
+				}
+				return validateInlineJSONSchema(data, doc.Content)
+			},
+		},
+		{
+			Pattern:     `^"{resource}" last message json matches snapshot "([^"]*)"$`,
+			Description: "Asserts the last message equals a named snapshot, written on the first run or with --update-snapshots",
+			Example:     `"` + example + `" last message json matches snapshot "events/order-created"`,
+			Handler: func(ctx context.Context, name string) error {
+				data, err := last(ctx)
+				if err != nil {
+					return err
+				}
+				return matchJSONSnapshot(ctx, name, data, nil)
+			},
+		},
+		{
+			Pattern:     `^"{resource}" last message json matches snapshot "([^"]*)" ignoring:$`,
+			Description: "Asserts the last message equals a snapshot, with the values at the given paths replaced by matchers",
+			Example:     "\"" + example + "\" last message json matches snapshot \"events/order-created\" ignoring:\n  | path       | matcher |\n  | id         | @string |\n  | created_at | @string |",
+			Handler: func(ctx context.Context, name string, table *godog.Table) error {
+				ignores, err := parseSnapshotIgnores(table)
+				if err != nil {
+					return err
+				}
+				data, err := last(ctx)
+				if err != nil {
+					return err
+				}
+				return matchJSONSnapshot(ctx, name, data, ignores)
+			},
+		},
+	}
+	for i := range steps {
+		steps[i].Group = "Assertions"
+	}
+	return steps
+}
+
+func jsonPathShouldBe(data []byte, path, expected string) error {
+	value, err := getJSON(data, path)
+	if err != nil {
+		return err
+	}
+	if actual := jsonValueString(value); actual != expected {
+		return fmt.Errorf("JSON path %q: expected %q, got %q", path, expected, actual)
+	}
+	return nil
+}
+
+func jsonPathShouldExist(data []byte, path string) error {
+	p, doc, err := parseJSONQuery(data, path)
+	if err != nil {
+		return err
+	}
+	if p.Definite() {
+		_, err := p.Get(doc)
+		return err
+	}
+	if len(p.Query(doc)) == 0 {
+		return fmt.Errorf("JSON path %q matches nothing", path)
+	}
+	return nil
+}
+
+func jsonPathShouldNotExist(data []byte, path string) error {
+	values, err := queryJSON(data, path)
+	if err != nil {
+		return err
+	}
+	if len(values) > 0 {
+		return fmt.Errorf("JSON path %q exists but should not", path)
+	}
+	return nil
+}
+
+func jsonPathShouldMatch(data []byte, path, pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid regex pattern: %w", err)
+	}
+	value, err := getJSON(data, path)
+	if err != nil {
+		return err
+	}
+	str, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("JSON path %q is not a string: %s", path, jsonTypeName(value))
+	}
+	if !re.MatchString(str) {
+		return fmt.Errorf("JSON path %q value %q does not match pattern %q", path, str, pattern)
+	}
+	return nil
+}
+
+// jsonPathValues returns the values a path selects, requiring at least one so
+// that "all of" can't pass on a path that matches nothing
+func jsonPathValues(data []byte, path string) ([]any, error) {
+	values, err := queryJSON(data, path)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("JSON path %q matches nothing", path)
+	}
+	return values, nil
+}
+
+func jsonPathAllShouldBe(data []byte, path, expected string) error {
+	values, err := jsonPathValues(data, path)
+	if err != nil {
+		return err
+	}
+	var mismatches []string
+	for i, v := range values {
+		if actual := jsonValueString(v); actual != expected {
+			mismatches = append(mismatches, fmt.Sprintf("#%d: %q", i+1, actual))
+		}
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("JSON path %q: expected all %d values to be %q, %d are not:\n  %s",
+			path, len(values), expected, len(mismatches), strings.Join(mismatches, "\n  "))
+	}
+	return nil
+}
+
+func jsonPathAnyShouldBe(data []byte, path, expected string) error {
+	values, err := jsonPathValues(data, path)
+	if err != nil {
+		return err
+	}
+	actual := make([]string, len(values))
+	for i, v := range values {
+		if actual[i] = jsonValueString(v); actual[i] == expected {
+			return nil
+		}
+	}
+	return fmt.Errorf("JSON path %q: expected any of %d values to be %q, got %q", path, len(values), expected, actual)
+}
+
+func jsonPathAllShouldMatch(data []byte, path, pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid regex pattern: %w", err)
+	}
+	values, err := jsonPathValues(data, path)
+	if err != nil {
+		return err
+	}
+	var mismatches []string
+	for i, v := range values {
+		if str, ok := v.(string); !ok || !re.MatchString(str) {
+			mismatches = append(mismatches, fmt.Sprintf("#%d: %s", i+1, jsonValueString(v)))
+		}
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("JSON path %q: expected all %d values to match pattern %q, %d do not:\n  %s",
+			path, len(values), pattern, len(mismatches), strings.Join(mismatches, "\n  "))
+	}
+	return nil
+}
+
+func jsonPathAnyShouldMatch(data []byte, path, pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid regex pattern: %w", err)
+	}
+	values, err := jsonPathValues(data, path)
+	if err != nil {
+		return err
+	}
+	for _, v := range values {
+		if str, ok := v.(string); ok && re.MatchString(str) {
+			return nil
+		}
+	}
+	return fmt.Errorf("JSON path %q: none of %d values matches pattern %q", path, len(values), pattern)
+}
+
+func jsonPathShouldHaveCount(data []byte, path string, expected int) error {
+	values, err := queryJSON(data, path)
+	if err != nil {
+		return err
+	}
+	if len(values) != expected {
+		return fmt.Errorf("JSON path %q: expected %d values, got %d", path, expected, len(values))
+	}
+	return nil
+}
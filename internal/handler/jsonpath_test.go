@@ -0,0 +1,262 @@
+package handler
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const jsonPathDoc = `{
+	"id": 42,
+	"name": "order",
+	"a.b": {"c": "dotted"},
+	"items": [
+		{"sku": "X1", "qty": 2, "price": 9.5, "tags": ["new"]},
+		{"sku": "X2", "qty": 1, "price": 20},
+		{"sku": "X3", "qty": 5, "price": 3, "active": false}
+	],
+	"customer": {"email": "a@example.com", "address": {"email": "b@example.com"}}
+}`
+
+func TestJSONPath_Query(t *testing.T) {
+	var doc any
+	if err := json.Unmarshal([]byte(jsonPathDoc), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path     string
+		want     string // values as a JSON array
+		definite bool
+	}{
+		{"id", `[42]`, true},
+		{"$.id", `[42]`, true},
+		{"items[0].sku", `["X1"]`, true},
+		{"$.items[-1].sku", `["X3"]`, true},
+		{"['a.b'].c", `["dotted"]`, true},
+		{`$["a.b"]["c"]`, `["dotted"]`, true},
+		{"items[*].qty", `[2,1,5]`, false},
+		{"items.*.sku", `["X1","X2","X3"]`, false},
+		{"items[1:].sku", `["X2","X3"]`, false},
+		{"items[::-1].qty", `[5,1,2]`, false},
+		{"items[0,2].sku", `["X1","X3"]`, false},
+		{"items[?(@.sku=='X2')].qty", `[1]`, false},
+		{"items[?@.qty > 1 && @.price < 5].sku", `["X3"]`, false},
+		{"items[?(@.qty >= 5 || @.sku == \"X1\")].sku", `["X1","X3"]`, false},
+		{"items[?(@.active)].sku", `["X3"]`, false},
+		{"items[?(!@.active)].sku", `["X1","X2"]`, false},
+		{"items[?(@.sku =~ '^X[12]$')].sku", `["X1","X2"]`, false},
+		{"items[?(@.sku =~ /^X[12]$/)].sku", `["X1","X2"]`, false},
+		{"items[?match(@.sku, 'X[23]')].sku", `["X2","X3"]`, false},
+		{"items[?length(@.tags) == 1].sku", `["X1"]`, false},
+		{"items[?search(@.sku, '3')].sku", `["X3"]`, false},
+		{"items[?(@.price == $.items[0].price)].sku", `["X1"]`, false},
+		{"items[?(@.tags[0] == 'new')].sku", `["X1"]`, false},
+		{"$..email", `["a@example.com","b@example.com"]`, false}, // object members come in any order
+		{"$..tags[0]", `["new"]`, false},
+		{"missing", `[]`, true},
+		{"items[7]", `[]`, true},
+		{"items[?(@.sku=='none')]", `[]`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			p, err := ParseJSONPath(tt.path)
+			if err != nil {
+				t.Fatalf("ParseJSONPath() error = %v", err)
+			}
+			got := p.Query(doc)
+			if got == nil {
+				got = []any{}
+			}
+			data, _ := json.Marshal(got)
+			if string(data) != tt.want && !sameValues(got, tt.want) {
+				t.Errorf("Query() = %s, want %s", data, tt.want)
+			}
+			if p.Definite() != tt.definite {
+				t.Errorf("Definite() = %v, want %v", p.Definite(), tt.definite)
+			}
+		})
+	}
+}
+
+// TestJSONPath_RFC9535 checks the examples of RFC 9535, section 1.5
+func TestJSONPath_RFC9535(t *testing.T) {
+	var doc any
+	if err := json.Unmarshal([]byte(`{ "store": {
+		"book": [
+			{ "category": "reference", "author": "Nigel Rees", "title": "Sayings of the Century", "price": 8.95 },
+			{ "category": "fiction", "author": "Evelyn Waugh", "title": "Sword of Honour", "price": 12.99 },
+			{ "category": "fiction", "author": "Herman Melville", "title": "Moby Dick", "isbn": "0-553-21311-3", "price": 8.99 },
+			{ "category": "fiction", "author": "J. R. R. Tolkien", "title": "The Lord of the Rings", "isbn": "0-395-19395-8", "price": 22.99 }
+		],
+		"bicycle": { "color": "red", "price": 399 }
+	} }`), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want string // values as a JSON array, in any order
+	}{
+		{"$.store.book[*].author", `["Nigel Rees","Evelyn Waugh","Herman Melville","J. R. R. Tolkien"]`},
+		{"$..author", `["Nigel Rees","Evelyn Waugh","Herman Melville","J. R. R. Tolkien"]`},
+		{"$.store..price", `[8.95,12.99,8.99,22.99,399]`},
+		{"$..book[2].author", `["Herman Melville"]`},
+		{"$..book[2].publisher", `[]`},
+		{"$..book[-1].title", `["The Lord of the Rings"]`},
+		{"$..book[0,1].title", `["Sayings of the Century","Sword of Honour"]`},
+		{"$..book[:2].title", `["Sayings of the Century","Sword of Honour"]`},
+		{"$..book[?@.isbn].title", `["Moby Dick","The Lord of the Rings"]`},
+		{"$..book[?@.price<10].title", `["Sayings of the Century","Moby Dick"]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			p, err := ParseJSONPath(tt.path)
+			if err != nil {
+				t.Fatalf("ParseJSONPath() error = %v", err)
+			}
+			if got := p.Query(doc); !sameValues(got, tt.want) {
+				data, _ := json.Marshal(got)
+				t.Errorf("Query() = %s, want %s", data, tt.want)
+			}
+		})
+	}
+
+	all, _ := ParseJSONPath("$..*")
+	if got := len(all.Query(doc)); got != 27 {
+		t.Errorf("$..* selects %d values, want 27", got)
+	}
+}
+
+// sameValues reports whether got holds the values of the JSON array want, in any order
+func sameValues(got []any, want string) bool {
+	var values []any
+	if err := json.Unmarshal([]byte(want), &values); err != nil {
+		return false
+	}
+	encode := func(values []any) []string {
+		out := make([]string, len(values))
+		for i, v := range values {
+			data, _ := json.Marshal(v)
+			out[i] = string(data)
+		}
+		slices.Sort(out)
+		return out
+	}
+	return slices.Equal(encode(got), encode(values))
+}
+
+func TestJSONPath_RootArray(t *testing.T) {
+	got, err := getJSON([]byte(`[{"id": 1}, {"id": 2}]`), "[1].id")
+	if err != nil {
+		t.Fatalf("getJSON() error = %v", err)
+	}
+	if got != 2.0 {
+		t.Errorf("getJSON() = %v, want 2", got)
+	}
+}
+
+func TestJSONPath_Replace(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"id", `"id":"X"`},
+		{"items[*].sku", `"sku":"X","tags":["new"]},{"price":20,"qty":1,"sku":"X"},{"active":false,"price":3,"qty":5,"sku":"X"}`},
+		{"items[-1].qty", `"qty":"X","sku":"X3"`},
+		{"items[0:2].price", `{"price":"X","qty":2,"sku":"X1","tags":["new"]},{"price":"X","qty":1,"sku":"X2"}`},
+		{"items[?(@.qty > 1)].sku", `{"price":9.5,"qty":2,"sku":"X","tags":["new"]},{"price":20,"qty":1,"sku":"X2"},{"active":false,"price":3,"qty":5,"sku":"X"}`},
+		{"$..email", `"customer":{"address":{"email":"X"},"email":"X"}`},
+		{"missing", `"id":42`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			var doc any
+			if err := json.Unmarshal([]byte(jsonPathDoc), &doc); err != nil {
+				t.Fatal(err)
+			}
+			p, err := ParseJSONPath(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			doc, err = p.Replace(doc, func(any) (any, error) { return "X", nil })
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ := json.Marshal(doc)
+			if !strings.Contains(string(got), tt.want) {
+				t.Errorf("Replace(%q) = %s, want it to contain %s", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	root, _ := ParseJSONPath("$")
+	if got, _ := root.Replace(1.0, func(any) (any, error) { return "X", nil }); got != "X" {
+		t.Errorf("replacing the root = %v, want X", got)
+	}
+}
+
+func TestJSONPath_ParseErrors(t *testing.T) {
+	for _, path := range []string{
+		"items[",
+		"items[0",
+		"items[a]",
+		"items[?(@.sku==)]",
+		"items[?(@.sku=~'[')]",
+		"items[?(@.sku=~/[/)]",
+		"items[1:2:3:4]",
+		"a..",
+		"items['x",
+	} {
+		t.Run(path, func(t *testing.T) {
+			if _, err := ParseJSONPath(path); err == nil {
+				t.Errorf("ParseJSONPath(%q) expected error", path)
+			}
+		})
+	}
+}
+
+func TestJSONPathAssertions(t *testing.T) {
+	data := []byte(jsonPathDoc)
+	tests := []struct {
+		name    string
+		check   func() error
+		wantErr string
+	}{
+		{"is", func() error { return jsonPathShouldBe(data, "items[0].sku", "X1") }, ""},
+		{"is number", func() error { return jsonPathShouldBe(data, "id", "42") }, ""},
+		{"is object", func() error { return jsonPathShouldBe(data, "['a.b']", `{"c":"dotted"}`) }, ""},
+		{"is mismatch", func() error { return jsonPathShouldBe(data, "name", "x") }, `expected "x", got "order"`},
+		{"is missing key", func() error { return jsonPathShouldBe(data, "customer.phone", "x") }, `key "phone" not found at $.customer`},
+		{"is out of bounds", func() error { return jsonPathShouldBe(data, "items[3].sku", "x") }, "index 3 out of bounds at $.items (length 3)"},
+		{"is single filter match", func() error { return jsonPathShouldBe(data, "items[?(@.sku=='X2')].qty", "1") }, ""},
+		{"is several values", func() error { return jsonPathShouldBe(data, "items[*].qty", "1") }, "matches 3 values"},
+		{"exists", func() error { return jsonPathShouldExist(data, "items[*].tags") }, ""},
+		{"exists nothing", func() error { return jsonPathShouldExist(data, "items[*].color") }, "matches nothing"},
+		{"does not exist", func() error { return jsonPathShouldNotExist(data, "items[*].color") }, ""},
+		{"does not exist invalid path", func() error { return jsonPathShouldNotExist(data, "items[") }, "invalid JSON path"},
+		{"all of", func() error { return jsonPathAllShouldBe(data, "items[?(@.qty>1)].sku", "X1") }, `1 are not:`},
+		{"all of empty", func() error { return jsonPathAllShouldBe(data, "items[*].color", "red") }, "matches nothing"},
+		{"any of", func() error { return jsonPathAnyShouldBe(data, "items[*].sku", "X3") }, ""},
+		{"any of mismatch", func() error { return jsonPathAnyShouldBe(data, "items[*].sku", "X9") }, `got ["X1" "X2" "X3"]`},
+		{"all match", func() error { return jsonPathAllShouldMatch(data, "$..email", `@example\.com$`) }, ""},
+		{"any match", func() error { return jsonPathAnyShouldMatch(data, "$..email", `^b@`) }, ""},
+		{"count", func() error { return jsonPathShouldHaveCount(data, "items[?(@.price < 10)]", 2) }, ""},
+		{"count mismatch", func() error { return jsonPathShouldHaveCount(data, "items[*]", 2) }, "expected 2 values, got 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.check()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// inlineSchemaURL is the location given to schemas passed as a DocString, so
+// that their relative $refs resolve against the working directory
+const inlineSchemaURL = "tomato-inline-schema.json"
+
+var schemaMessages = message.NewPrinter(language.English)
+
+// validateJSONSchema validates a JSON document against the schema at location,
+// a file path relative to the working directory or an http(s) URL. Schemas
+// without $schema are read as draft 2020-12, and formats are asserted.
+func validateJSONSchema(data []byte, location string) error {
+	c := newSchemaCompiler()
+	schema, err := c.Compile(location)
+	if err != nil {
+		return fmt.Errorf("loading schema %q: %w", location, err)
+	}
+	return validateAgainst(schema, data, fmt.Sprintf("schema %q", location))
+}
+
+// validateInlineJSONSchema validates a JSON document against a schema given as text
+func validateInlineJSONSchema(data []byte, schemaText string) error {
+	doc, err := jsonschema.UnmarshalJSON(strings.NewReader(schemaText))
+	if err != nil {
+		return fmt.Errorf("invalid schema JSON: %w", err)
+	}
+	c := newSchemaCompiler()
+	if err := c.AddResource(inlineSchemaURL, doc); err != nil {
+		return fmt.Errorf("loading schema: %w", err)
+	}
+	schema, err := c.Compile(inlineSchemaURL)
+	if err != nil {
+		return fmt.Errorf("compiling schema: %w", err)
+	}
+	return validateAgainst(schema, data, "the schema")
+}
+
+func newSchemaCompiler() *jsonschema.Compiler {
+	c := jsonschema.NewCompiler()
+	c.DefaultDraft(jsonschema.Draft2020)
+	c.AssertFormat()
+	c.UseLoader(jsonschema.SchemeURLLoader{
+		"file":  jsonschema.FileLoader{},
+		"http":  httpSchemaLoader{},
+		"https": httpSchemaLoader{},
+	})
+	return c
+}
+
+func validateAgainst(schema *jsonschema.Schema, data []byte, name string) error {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	err = schema.Validate(doc)
+	var verr *jsonschema.ValidationError
+	if !errors.As(err, &verr) {
+		return err
+	}
+
+	var problems []string
+	seen := make(map[string]bool)
+	for _, leaf := range schemaErrorLeaves(verr, nil) {
+		problem := fmt.Sprintf("at %s: %s", instancePath(leaf.InstanceLocation), leaf.ErrorKind.LocalizedString(schemaMessages))
+		if !seen[problem] {
+			seen[problem] = true
+			problems = append(problems, problem)
+		}
+	}
+	return fmt.Errorf("JSON does not match %s:\n  %s", name, strings.Join(problems, "\n  "))
+}
+
+// schemaErrorLeaves returns the errors of a validation error tree that have
+// no causes: the keywords that failed, such as type or required
+func schemaErrorLeaves(err *jsonschema.ValidationError, out []*jsonschema.ValidationError) []*jsonschema.ValidationError {
+	if len(err.Causes) == 0 {
+		return append(out, err)
+	}
+	for _, cause := range err.Causes {
+		out = schemaErrorLeaves(cause, out)
+	}
+	return out
+}
+
+// instancePath formats the location of a value as a JSON path, like the paths
+// of the response json steps
+func instancePath(location []string) string {
+	var sb strings.Builder
+	sb.WriteString("$")
+	for _, token := range location {
+		if _, err := strconv.Atoi(token); err == nil {
+			sb.WriteString("[" + token + "]")
+		} else {
+			sb.WriteString(formatPathKey(token))
+		}
+	}
+	return sb.String()
+}
+
+// httpSchemaLoader loads schemas published over HTTP
+type httpSchemaLoader struct{}
+
+var schemaHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+func (httpSchemaLoader) Load(url string) (any, error) {
+	resp, err := schemaHTTPClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return jsonschema.UnmarshalJSON(resp.Body)
+}
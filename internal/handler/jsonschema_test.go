@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const orderSchema = `{
+	"type": "object",
+	"required": ["id", "items"],
+	"properties": {
+		"id": {"type": "string", "format": "uuid"},
+		"items": {"type": "array", "items": {"$ref": "item.json"}}
+	}
+}`
+
+const itemSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"required": ["sku"],
+	"properties": {
+		"sku": {"type": "string"},
+		"qty": {"type": "integer", "minimum": 1}
+	}
+}`
+
+func TestValidateJSONSchema(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "order.json"), []byte(orderSchema), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "item.json"), []byte(itemSchema), 0644); err != nil {
+		t.Fatal(err)
+	}
+	schema := filepath.Join(dir, "order.json")
+
+	tests := []struct {
+		name     string
+		data     string
+		wantErrs []string
+	}{
+		{
+			name: "valid",
+			data: `{"id": "f47ac10b-58cc-4372-a567-0e02b2c3d479", "items": [{"sku": "X1", "qty": 2}]}`,
+		},
+		{
+			name:     "wrong type in referenced schema",
+			data:     `{"id": "f47ac10b-58cc-4372-a567-0e02b2c3d479", "items": [{"sku": "X1"}, {"sku": "X2", "qty": "2"}]}`,
+			wantErrs: []string{"at $.items[1].qty: got string, want integer"},
+		},
+		{
+			name:     "several errors",
+			data:     `{"id": "not-a-uuid", "items": [{"qty": 0}]}`,
+			wantErrs: []string{"at $.id: 'not-a-uuid' is not valid uuid", "at $.items[0]: missing property 'sku'", "at $.items[0].qty: minimum"},
+		},
+		{
+			name:     "missing property",
+			data:     `{"items": []}`,
+			wantErrs: []string{"at $: missing property 'id'"},
+		},
+		{
+			name:     "invalid JSON",
+			data:     `{"id": `,
+			wantErrs: []string{"invalid JSON"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateJSONSchema([]byte(tt.data), schema)
+			if len(tt.wantErrs) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, want := range tt.wantErrs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateJSONSchema_MissingSchema(t *testing.T) {
+	err := validateJSONSchema([]byte(`{}`), filepath.Join(t.TempDir(), "missing.json"))
+	if err == nil || !strings.Contains(err.Error(), "loading schema") {
+		t.Errorf("error = %v, want a loading error", err)
+	}
+}
+
+func TestValidateJSONSchema_URL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/item.json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(itemSchema))
+	}))
+	defer srv.Close()
+
+	if err := validateJSONSchema([]byte(`{"sku": "X1"}`), srv.URL+"/item.json"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := validateJSONSchema([]byte(`{}`), srv.URL+"/item.json"); err == nil {
+		t.Error("expected an error for a missing property")
+	}
+	if err := validateJSONSchema([]byte(`{}`), srv.URL+"/other.json"); err == nil {
+		t.Error("expected an error for a schema that can't be loaded")
+	}
+}
+
+func TestValidateInlineJSONSchema(t *testing.T) {
+	schema := `{"type": "array", "items": {"type": "number"}, "maxItems": 2}`
+	if err := validateInlineJSONSchema([]byte(`[1, 2]`), schema); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	err := validateInlineJSONSchema([]byte(`[1, "2", 3]`), schema)
+	if err == nil || !strings.Contains(err.Error(), "at $[1]: got string, want number") {
+		t.Errorf("error = %v, want the offending item", err)
+	}
+	if err := validateInlineJSONSchema([]byte(`[]`), `{"type": `); err == nil {
+		t.Error("expected an error for an invalid schema")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -26,7 +27,7 @@ type Kafka struct {
 
 	messages     map[string][]*sarama.ConsumerMessage
 	messagesMu   sync.RWMutex
-	lastMessage  *sarama.ConsumerMessage
+	lastMessages map[string]*sarama.ConsumerMessage // worker namespace -> last consumed message
 	consuming    map[string]bool
 	consumingMu  sync.RWMutex
 	stopChannels map[string]chan struct{}
@@ -38,6 +39,7 @@ func NewKafka(name string, cfg config.Resource, cm *container.Manager) (*Kafka,
 		config:       cfg,
 		container:    cm,
 		messages:     make(map[string][]*sarama.ConsumerMessage),
+		lastMessages: make(map[string]*sarama.ConsumerMessage),
 		consuming:    make(map[string]bool),
 		stopChannels: make(map[string]chan struct{}),
 	}, nil
@@ -102,17 +104,26 @@ func (r *Kafka) Ready(ctx context.Context) error {
 }
 
 func (r *Kafka) Reset(ctx context.Context) error {
-	r.stopAllConsumers()
+	// With worker isolation only the worker's own topics are reset
+	ns := r.namespace(ctx)
+	r.stopConsumers(ns)
 
 	r.messagesMu.Lock()
-	r.messages = make(map[string][]*sarama.ConsumerMessage)
-	r.lastMessage = nil
+	for topic := range r.messages {
+		if strings.HasPrefix(topic, ns) {
+			delete(r.messages, topic)
+		}
+	}
+	delete(r.lastMessages, ns)
 	r.messagesMu.Unlock()
 
 	topics := r.getTopicsToReset()
 	if len(topics) == 0 {
 		return nil
 	}
+	for i, topic := range topics {
+		topics[i] = r.topicName(ctx, topic)
+	}
 
 	strategy := "delete_recreate"
 	if s, ok := r.config.Options["reset_strategy"].(string); ok {
@@ -176,23 +187,81 @@ func (r *Kafka) deleteAndRecreatTopics(topics []string) error {
 }
 
 func (r *Kafka) stopAllConsumers() {
+	r.stopConsumers("")
+}
+
+// stopConsumers stops consumers of all topics starting with prefix
+func (r *Kafka) stopConsumers(prefix string) {
 	r.consumingMu.Lock()
 	defer r.consumingMu.Unlock()
 
 	for topic, stopCh := range r.stopChannels {
+		if !strings.HasPrefix(topic, prefix) {
+			continue
+		}
 		close(stopCh)
 		delete(r.stopChannels, topic)
 		r.consuming[topic] = false
 	}
 }
 
+// namespace returns the topic prefix of the worker carried by ctx when
+// options.isolation is "prefix", or an empty string otherwise
+func (r *Kafka) namespace(ctx context.Context) string {
+	if isolationMode(r.config) != "prefix" {
+		return ""
+	}
+	return workerPrefix(ctx)
+}
+
+// topicName rewrites a topic name for the worker carried by ctx
+func (r *Kafka) topicName(ctx context.Context, topic string) string {
+	return r.namespace(ctx) + topic
+}
+
+// lastMessage returns the last message consumed within the worker's namespace
+func (r *Kafka) lastMessage(ctx context.Context) *sarama.ConsumerMessage {
+	r.messagesMu.RLock()
+	defer r.messagesMu.RUnlock()
+	return r.lastMessages[r.namespace(ctx)]
+}
+
+// Transcript returns the last messages consumed within the worker's namespace
+func (r *Kafka) Transcript(ctx context.Context) string {
+	n := transcriptSize(r.config)
+	if n == 0 {
+		return ""
+	}
+	ns := r.namespace(ctx)
+
+	r.messagesMu.RLock()
+	var msgs []*sarama.ConsumerMessage
+	for topic, consumed := range r.messages {
+		if strings.HasPrefix(topic, ns) {
+			msgs = append(msgs, consumed...)
+		}
+	}
+	r.messagesMu.RUnlock()
+	sort.SliceStable(msgs, func(i, j int) bool { return msgs[i].Timestamp.Before(msgs[j].Timestamp) })
+
+	var entries []string
+	for _, msg := range lastN(msgs, n) {
+		headers := make(map[string]string, len(msg.Headers))
+		for _, h := range msg.Headers {
+			headers[string(h.Key)] = string(h.Value)
+		}
+		entries = append(entries, formatMessage(redactorFrom(ctx), msg.Timestamp, "topic "+msg.Topic, string(msg.Key), headers, msg.Value))
+	}
+	return joinTranscript(entries)
+}
+
 func (r *Kafka) RegisterSteps(ctx *godog.ScenarioContext) {
 	RegisterStepsToGodog(ctx, r.name, r.Steps())
 }
 
 // Steps returns the structured step definitions for the Kafka handler
 func (r *Kafka) Steps() StepCategory {
-	return StepCategory{
+	category := StepCategory{
 		Name:        "Kafka",
 		Description: "Steps for interacting with Apache Kafka message broker",
 		Steps: []StepDef{
@@ -331,9 +400,22 @@ func (r *Kafka) Steps() StepCategory {
 			},
 		},
 	}
+	category.Steps = append(category.Steps, messageJSONSteps("{resource}", r.lastMessageBody)...)
+	return category
 }
 
-func (r *Kafka) topicExists(topic string) error {
+// lastMessageBody returns the value of the last message consumed within the
+// worker's namespace
+func (r *Kafka) lastMessageBody(ctx context.Context) ([]byte, error) {
+	msg := r.lastMessage(ctx)
+	if msg == nil {
+		return nil, fmt.Errorf("no message received")
+	}
+	return msg.Value, nil
+}
+
+func (r *Kafka) topicExists(ctx context.Context, topic string) error {
+	topic = r.topicName(ctx, topic)
 	topics, err := r.admin.ListTopics()
 	if err != nil {
 		return err
@@ -344,11 +426,12 @@ func (r *Kafka) topicExists(topic string) error {
 	return nil
 }
 
-func (r *Kafka) createTopic(topic string) error {
-	return r.createTopicWithPartitions(topic, 1)
+func (r *Kafka) createTopic(ctx context.Context, topic string) error {
+	return r.createTopicWithPartitions(ctx, topic, 1)
 }
 
-func (r *Kafka) createTopicWithPartitions(topic string, partitions int) error {
+func (r *Kafka) createTopicWithPartitions(ctx context.Context, topic string, partitions int) error {
+	topic = r.topicName(ctx, topic)
 	detail := &sarama.TopicDetail{
 		NumPartitions:     int32(partitions),
 		ReplicationFactor: 1,
@@ -360,11 +443,12 @@ func (r *Kafka) createTopicWithPartitions(topic string, partitions int) error {
 	return nil
 }
 
-func (r *Kafka) publishMessage(topic string, doc *godog.DocString) error {
-	return r.publishMessageWithKey(topic, "", doc)
+func (r *Kafka) publishMessage(ctx context.Context, topic string, doc *godog.DocString) error {
+	return r.publishMessageWithKey(ctx, topic, "", doc)
 }
 
-func (r *Kafka) publishMessageWithKey(topic, key string, doc *godog.DocString) error {
+func (r *Kafka) publishMessageWithKey(ctx context.Context, topic, key string, doc *godog.DocString) error {
+	topic = r.topicName(ctx, topic)
 	msg := &sarama.ProducerMessage{
 		Topic: topic,
 		Value: sarama.StringEncoder(doc.Content),
@@ -376,11 +460,12 @@ func (r *Kafka) publishMessageWithKey(topic, key string, doc *godog.DocString) e
 	return err
 }
 
-func (r *Kafka) publishJSON(topic string, doc *godog.DocString) error {
-	return r.publishJSONWithKey(topic, "", doc)
+func (r *Kafka) publishJSON(ctx context.Context, topic string, doc *godog.DocString) error {
+	return r.publishJSONWithKey(ctx, topic, "", doc)
 }
 
-func (r *Kafka) publishJSONWithKey(topic, key string, doc *godog.DocString) error {
+func (r *Kafka) publishJSONWithKey(ctx context.Context, topic, key string, doc *godog.DocString) error {
+	topic = r.topicName(ctx, topic)
 	var js json.RawMessage
 	if err := json.Unmarshal([]byte(doc.Content), &js); err != nil {
 		return fmt.Errorf("invalid JSON: %w", err)
@@ -397,7 +482,8 @@ func (r *Kafka) publishJSONWithKey(topic, key string, doc *godog.DocString) erro
 	return err
 }
 
-func (r *Kafka) publishMessages(topic string, table *godog.Table) error {
+func (r *Kafka) publishMessages(ctx context.Context, topic string, table *godog.Table) error {
+	topic = r.topicName(ctx, topic)
 	if len(table.Rows) < 2 {
 		return fmt.Errorf("table must have headers and at least one data row")
 	}
@@ -434,7 +520,14 @@ func (r *Kafka) publishMessages(topic string, table *godog.Table) error {
 	return nil
 }
 
-func (r *Kafka) startConsuming(topic string) error {
+func (r *Kafka) startConsuming(ctx context.Context, topic string) error {
+	return r.consume(ctx, r.topicName(ctx, topic))
+}
+
+// consume starts consuming an already resolved topic name
+func (r *Kafka) consume(ctx context.Context, topic string) error {
+	ns := r.namespace(ctx)
+
 	r.consumingMu.Lock()
 	if r.consuming[topic] {
 		r.consumingMu.Unlock()
@@ -463,7 +556,7 @@ func (r *Kafka) startConsuming(topic string) error {
 				case msg := <-pc.Messages():
 					r.messagesMu.Lock()
 					r.messages[topic] = append(r.messages[topic], msg)
-					r.lastMessage = msg
+					r.lastMessages[ns] = msg
 					r.messagesMu.Unlock()
 				case <-stopCh:
 					return
@@ -475,13 +568,14 @@ func (r *Kafka) startConsuming(topic string) error {
 	return nil
 }
 
-func (r *Kafka) consumeMessage(topic, timeout string) error {
+func (r *Kafka) consumeMessage(ctx context.Context, topic, timeout string) error {
 	duration, err := time.ParseDuration(timeout)
 	if err != nil {
 		return fmt.Errorf("invalid timeout: %w", err)
 	}
 
-	if err := r.startConsuming(topic); err != nil {
+	topic = r.topicName(ctx, topic)
+	if err := r.consume(ctx, topic); err != nil {
 		return err
 	}
 
@@ -498,14 +592,12 @@ func (r *Kafka) consumeMessage(topic, timeout string) error {
 	return fmt.Errorf("no message received within %s", timeout)
 }
 
-func (r *Kafka) shouldReceiveMessage(topic, timeout string, doc *godog.DocString) error {
-	if err := r.consumeMessage(topic, timeout); err != nil {
+func (r *Kafka) shouldReceiveMessage(ctx context.Context, topic, timeout string, doc *godog.DocString) error {
+	if err := r.consumeMessage(ctx, topic, timeout); err != nil {
 		return err
 	}
 
-	r.messagesMu.RLock()
-	lastMsg := r.lastMessage
-	r.messagesMu.RUnlock()
+	lastMsg := r.lastMessage(ctx)
 
 	if lastMsg == nil {
 		return fmt.Errorf("no message received")
@@ -521,27 +613,28 @@ func (r *Kafka) shouldReceiveMessage(topic, timeout string, doc *godog.DocString
 	return nil
 }
 
-func (r *Kafka) shouldReceiveMessageWithKey(topic, key, timeout string) error {
+func (r *Kafka) shouldReceiveMessageWithKey(ctx context.Context, topic, key, timeout string) error {
 	duration, err := time.ParseDuration(timeout)
 	if err != nil {
 		return fmt.Errorf("invalid timeout: %w", err)
 	}
 
-	if err := r.startConsuming(topic); err != nil {
+	topic = r.topicName(ctx, topic)
+	if err := r.consume(ctx, topic); err != nil {
 		return err
 	}
 
 	deadline := time.Now().Add(duration)
 	for time.Now().Before(deadline) {
-		r.messagesMu.RLock()
+		r.messagesMu.Lock()
 		for _, msg := range r.messages[topic] {
 			if string(msg.Key) == key {
-				r.lastMessage = msg
-				r.messagesMu.RUnlock()
+				r.lastMessages[r.namespace(ctx)] = msg
+				r.messagesMu.Unlock()
 				return nil
 			}
 		}
-		r.messagesMu.RUnlock()
+		r.messagesMu.Unlock()
 		time.Sleep(100 * time.Millisecond)
 	}
 
@@ -554,22 +647,20 @@ func (r *Kafka) getMessageCount(topic string) int {
 	return len(r.messages[topic])
 }
 
-func (r *Kafka) topicShouldHaveMessages(topic string, expected int) error {
-	count := r.getMessageCount(topic)
+func (r *Kafka) topicShouldHaveMessages(ctx context.Context, topic string, expected int) error {
+	count := r.getMessageCount(r.topicName(ctx, topic))
 	if count != expected {
 		return fmt.Errorf("topic %q: expected %d messages, got %d", topic, expected, count)
 	}
 	return nil
 }
 
-func (r *Kafka) topicShouldBeEmpty(topic string) error {
-	return r.topicShouldHaveMessages(topic, 0)
+func (r *Kafka) topicShouldBeEmpty(ctx context.Context, topic string) error {
+	return r.topicShouldHaveMessages(ctx, topic, 0)
 }
 
-func (r *Kafka) lastMessageShouldContain(doc *godog.DocString) error {
-	r.messagesMu.RLock()
-	lastMsg := r.lastMessage
-	r.messagesMu.RUnlock()
+func (r *Kafka) lastMessageShouldContain(ctx context.Context, doc *godog.DocString) error {
+	lastMsg := r.lastMessage(ctx)
 
 	if lastMsg == nil {
 		return fmt.Errorf("no message received")
@@ -585,10 +676,8 @@ func (r *Kafka) lastMessageShouldContain(doc *godog.DocString) error {
 	return nil
 }
 
-func (r *Kafka) lastMessageShouldHaveKey(key string) error {
-	r.messagesMu.RLock()
-	lastMsg := r.lastMessage
-	r.messagesMu.RUnlock()
+func (r *Kafka) lastMessageShouldHaveKey(ctx context.Context, key string) error {
+	lastMsg := r.lastMessage(ctx)
 
 	if lastMsg == nil {
 		return fmt.Errorf("no message received")
@@ -601,10 +690,8 @@ func (r *Kafka) lastMessageShouldHaveKey(key string) error {
 	return nil
 }
 
-func (r *Kafka) lastMessageShouldHaveHeader(headerKey, headerValue string) error {
-	r.messagesMu.RLock()
-	lastMsg := r.lastMessage
-	r.messagesMu.RUnlock()
+func (r *Kafka) lastMessageShouldHaveHeader(ctx context.Context, headerKey, headerValue string) error {
+	lastMsg := r.lastMessage(ctx)
 
 	if lastMsg == nil {
 		return fmt.Errorf("no message received")
@@ -622,7 +709,7 @@ func (r *Kafka) lastMessageShouldHaveHeader(headerKey, headerValue string) error
 	return fmt.Errorf("header %q not found", headerKey)
 }
 
-func (r *Kafka) shouldReceiveMessagesInOrder(topic string, table *godog.Table) error {
+func (r *Kafka) shouldReceiveMessagesInOrder(ctx context.Context, topic string, table *godog.Table) error {
 	if len(table.Rows) < 2 {
 		return fmt.Errorf("table must have headers and at least one data row")
 	}
@@ -640,7 +727,7 @@ func (r *Kafka) shouldReceiveMessagesInOrder(topic string, table *godog.Table) e
 	}
 
 	r.messagesMu.RLock()
-	messages := r.messages[topic]
+	messages := r.messages[r.topicName(ctx, topic)]
 	r.messagesMu.RUnlock()
 
 	expectedRows := table.Rows[1:]
@@ -670,6 +757,7 @@ func (r *Kafka) shouldReceiveMessagesInOrder(topic string, table *godog.Table) e
 }
 
 func (r *Kafka) Publish(ctx context.Context, topic string, payload []byte, headers map[string]string) error {
+	topic = r.topicName(ctx, topic)
 	msg := &sarama.ProducerMessage{
 		Topic: topic,
 		Value: sarama.ByteEncoder(payload),
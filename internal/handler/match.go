@@ -0,0 +1,32 @@
+package handler
+
+import "context"
+
+// StepMatch describes the step definition a step was matched to
+type StepMatch struct {
+	Pattern  string // regular expression, with the resource name filled in
+	Resource string // name of the resource whose handler implements the step
+}
+
+type stepMatchKey struct{}
+
+// WithStepMatch returns a copy of ctx in which the step that runs next
+// records the step definition it was matched to into m
+func WithStepMatch(ctx context.Context, m *StepMatch) context.Context {
+	return context.WithValue(ctx, stepMatchKey{}, m)
+}
+
+// StepMatchFrom returns the step definition recorded by the step that ran in ctx, if any
+func StepMatchFrom(ctx context.Context) (StepMatch, bool) {
+	m, ok := ctx.Value(stepMatchKey{}).(*StepMatch)
+	if !ok || m.Pattern == "" {
+		return StepMatch{}, false
+	}
+	return *m, true
+}
+
+func recordStepMatch(ctx context.Context, match StepMatch) {
+	if m, ok := ctx.Value(stepMatchKey{}).(*StepMatch); ok {
+		*m = match
+	}
+}
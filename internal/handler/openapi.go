@@ -0,0 +1,249 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/tomatool/tomato/internal/config"
+)
+
+// contract validates HTTP requests and responses against the operations of
+// an OpenAPI 3 spec, set with options.openapi
+type contract struct {
+	spec      string
+	router    routers.Router
+	requests  bool // validate requests
+	responses bool // validate responses
+}
+
+// loadContract loads the spec of options.openapi: a path, or a map with the
+// spec path and whether requests and responses are validated, both by
+// default. It returns nil when the option isn't set.
+func loadContract(ctx context.Context, cfg config.Resource) (*contract, error) {
+	c := &contract{requests: true, responses: true}
+	switch v := cfg.Options["openapi"].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		c.spec = v
+	case map[string]any:
+		c.spec, _ = v["spec"].(string)
+		if b, ok := v["requests"].(bool); ok {
+			c.requests = b
+		}
+		if b, ok := v["responses"].(bool); ok {
+			c.responses = b
+		}
+	default:
+		return nil, fmt.Errorf("options.openapi must be a spec path or a map with a spec key")
+	}
+	if c.spec == "" {
+		return nil, fmt.Errorf("options.openapi: missing spec path")
+	}
+
+	loader := openapi3.NewLoader()
+	loader.IsExternalRefsAllowed = true
+	loader.Context = ctx
+	doc, err := loader.LoadFromFile(c.spec)
+	if err != nil {
+		return nil, fmt.Errorf("loading OpenAPI spec %s: %w", c.spec, err)
+	}
+	if err := doc.Validate(ctx, openapi3.DisableExamplesValidation()); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI spec %s: %w", c.spec, err)
+	}
+	routeByPath(doc)
+	if c.router, err = gorillamux.NewRouter(doc); err != nil {
+		return nil, fmt.Errorf("routing OpenAPI spec %s: %w", c.spec, err)
+	}
+	return c, nil
+}
+
+// routeByPath replaces the servers of a spec with their paths, so operations
+// are matched by path whatever host the resource talks to. The spec's paths
+// are also matched without a server path, e.g. /users for a server
+// https://api.example.com/v1, for apps and mocks mounted at the root.
+func routeByPath(doc *openapi3.T) {
+	var servers openapi3.Servers
+	seen := make(map[string]bool)
+	for _, s := range doc.Servers {
+		path := s.URL
+		if i := strings.Index(path, "://"); i >= 0 {
+			path = path[i+len("://"):]
+			if j := strings.Index(path, "/"); j >= 0 {
+				path = path[j:]
+			} else {
+				path = ""
+			}
+		}
+		path = strings.TrimSuffix(path, "/")
+		if path != "" && !seen[path] {
+			seen[path] = true
+			servers = append(servers, &openapi3.Server{URL: path, Variables: s.Variables})
+		}
+	}
+	doc.Servers = append(servers, &openapi3.Server{URL: "/"})
+	for _, item := range doc.Paths.Map() {
+		item.Servers = nil
+	}
+}
+
+func (c *contract) options() *openapi3filter.Options {
+	opts := &openapi3filter.Options{
+		MultiError:            true,
+		IncludeResponseStatus: true,
+		AuthenticationFunc:    openapi3filter.NoopAuthenticationFunc,
+	}
+	return opts
+}
+
+func (c *contract) route(req *http.Request) (*openapi3filter.RequestValidationInput, error) {
+	route, params, err := c.router.FindRoute(req)
+	switch {
+	case errors.Is(err, routers.ErrMethodNotAllowed):
+		return nil, fmt.Errorf("%s %s is not in the OpenAPI contract (%s): method not allowed", req.Method, req.URL.Path, c.spec)
+	case err != nil:
+		return nil, fmt.Errorf("%s %s is not in the OpenAPI contract (%s): no matching path", req.Method, req.URL.Path, c.spec)
+	}
+	return &openapi3filter.RequestValidationInput{
+		Request:    req,
+		PathParams: params,
+		Route:      route,
+		Options:    c.options(),
+	}, nil
+}
+
+// checkRequest validates a request and its body: the operation must exist,
+// and its path, query and header parameters and body must match it
+func (c *contract) checkRequest(ctx context.Context, req *http.Request, body []byte) error {
+	if !c.requests {
+		return nil
+	}
+	req = req.Clone(ctx)
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	input, err := c.route(req)
+	if err != nil {
+		return err
+	}
+	if err := openapi3filter.ValidateRequest(ctx, input); err != nil {
+		return c.violation(fmt.Sprintf("%s %s", req.Method, req.URL.Path), err)
+	}
+	return nil
+}
+
+// checkResponse validates the response to a request: its status must be
+// documented for the operation, and its headers and body must match it
+func (c *contract) checkResponse(ctx context.Context, req *http.Request, status int, header http.Header, body []byte) error {
+	if !c.responses {
+		return nil
+	}
+	input, err := c.route(req)
+	if err != nil {
+		return err
+	}
+	opts := input.Options
+	if len(body) == 0 {
+		// A response without a body, like a stub of a status, has no content to check
+		opts.ExcludeResponseBody = true
+	}
+	err = openapi3filter.ValidateResponse(ctx, &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: input,
+		Status:                 status,
+		Header:                 header,
+		Body:                   io.NopCloser(bytes.NewReader(body)),
+		Options:                opts,
+	})
+	if err != nil {
+		return c.violation(fmt.Sprintf("response %d to %s %s", status, req.Method, req.URL.Path), err)
+	}
+	return nil
+}
+
+// violation formats the errors of a validation, one per line, with the JSON
+// path of each value that doesn't match its schema
+func (c *contract) violation(subject string, err error) error {
+	var problems []string
+	for _, e := range flattenErrors(err) {
+		problems = append(problems, contractProblems(e)...)
+	}
+	return fmt.Errorf("%s does not match the OpenAPI contract (%s):\n  %s", subject, c.spec, strings.Join(problems, "\n  "))
+}
+
+func contractProblems(err error) []string {
+	var prefix, reason string
+	var cause error
+	var reqErr *openapi3filter.RequestError
+	var respErr *openapi3filter.ResponseError
+	switch {
+	case errors.As(err, &reqErr):
+		reason, cause = reqErr.Reason, reqErr.Err
+		switch {
+		case reqErr.Parameter != nil:
+			prefix = fmt.Sprintf("%s parameter %q", reqErr.Parameter.In, reqErr.Parameter.Name)
+		case reqErr.RequestBody != nil:
+			prefix = "request body"
+		}
+	case errors.As(err, &respErr):
+		reason, cause = respErr.Reason, respErr.Err
+		if cause != nil {
+			prefix = "response body"
+		}
+	default:
+		return []string{err.Error()}
+	}
+
+	var schemaErrs []*openapi3.SchemaError
+	for _, e := range flattenErrors(cause) {
+		var se *openapi3.SchemaError
+		if errors.As(e, &se) {
+			schemaErrs = append(schemaErrs, se)
+		}
+	}
+	if len(schemaErrs) == 0 {
+		msg := reason
+		if cause != nil && cause.Error() != reason {
+			msg = strings.TrimPrefix(msg+": "+cause.Error(), ": ")
+		}
+		if prefix != "" {
+			msg = prefix + ": " + msg
+		}
+		return []string{msg}
+	}
+	var problems []string
+	for _, se := range schemaErrs {
+		problem := fmt.Sprintf("at %s: %s", instancePath(se.JSONPointer()), se.Reason)
+		switch {
+		case reqErr != nil && reqErr.Parameter != nil && len(se.JSONPointer()) == 0:
+			// A scalar parameter has no path of its own
+			problem = prefix + ": " + se.Reason
+		case prefix != "":
+			problem = prefix + " " + problem
+		}
+		problems = append(problems, problem)
+	}
+	return problems
+}
+
+// flattenErrors returns the errors of nested openapi3.MultiErrors
+func flattenErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	me, ok := err.(openapi3.MultiError)
+	if !ok {
+		return []error{err}
+	}
+	var out []error
+	for _, e := range me {
+		out = append(out, flattenErrors(e)...)
+	}
+	return out
+}
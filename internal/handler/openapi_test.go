@@ -0,0 +1,321 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cucumber/godog"
+	"github.com/tomatool/tomato/internal/config"
+)
+
+const usersSpec = `openapi: 3.0.3
+info:
+  title: Users
+  version: "1.0"
+servers:
+  - url: https://api.example.com/v1
+paths:
+  /users:
+    post:
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/NewUser'
+      responses:
+        "201":
+          description: Created
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/User'
+        "400":
+          description: Invalid user
+  /users/{id}:
+    get:
+      parameters:
+        - name: id
+          in: path
+          required: true
+          schema:
+            type: integer
+        - name: fields
+          in: query
+          schema:
+            type: string
+            enum: [name, email]
+      responses:
+        "200":
+          description: OK
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/User'
+        "404":
+          description: Not found
+components:
+  schemas:
+    NewUser:
+      type: object
+      required: [name]
+      properties:
+        name:
+          type: string
+        email:
+          type: string
+    User:
+      type: object
+      required: [id, name]
+      properties:
+        id:
+          type: integer
+        name:
+          type: string
+`
+
+func writeUsersSpec(t *testing.T) string {
+	t.Helper()
+	spec := filepath.Join(t.TempDir(), "users.yaml")
+	if err := os.WriteFile(spec, []byte(usersSpec), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+func TestContract_CheckRequest(t *testing.T) {
+	c, err := loadContract(context.Background(), config.Resource{Options: map[string]any{"openapi": writeUsersSpec(t)}})
+	if err != nil {
+		t.Fatalf("loading contract: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		body     string
+		wantErrs []string
+	}{
+		{name: "valid body", method: "POST", target: "/users", body: `{"name": "Alice"}`},
+		{name: "valid under the server path", method: "POST", target: "http://localhost:8080/v1/users", body: `{"name": "Alice"}`},
+		{name: "valid path param", method: "GET", target: "/users/42?fields=name"},
+		{
+			name: "missing required field", method: "POST", target: "/users", body: `{"email": "a@example.com"}`,
+			wantErrs: []string{"POST /users does not match the OpenAPI contract", `request body at $.name: property "name" is missing`},
+		},
+		{
+			name: "wrong type", method: "POST", target: "/users", body: `{"name": 1}`,
+			wantErrs: []string{"request body at $.name:"},
+		},
+		{
+			name: "invalid path param", method: "GET", target: "/users/abc",
+			wantErrs: []string{`path parameter "id"`},
+		},
+		{
+			name: "invalid query param", method: "GET", target: "/users/1?fields=age",
+			wantErrs: []string{`query parameter "fields": value is not one of the allowed values`},
+		},
+		{
+			name: "undocumented path", method: "GET", target: "/orders",
+			wantErrs: []string{"GET /orders is not in the OpenAPI contract", "no matching path"},
+		},
+		{
+			name: "undocumented method", method: "DELETE", target: "/users/1",
+			wantErrs: []string{"method not allowed"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			err := c.checkRequest(context.Background(), req, []byte(tt.body))
+			assertErrorContains(t, err, tt.wantErrs)
+		})
+	}
+}
+
+func TestContract_CheckResponse(t *testing.T) {
+	c, err := loadContract(context.Background(), config.Resource{Options: map[string]any{"openapi": writeUsersSpec(t)}})
+	if err != nil {
+		t.Fatalf("loading contract: %v", err)
+	}
+
+	jsonHeader := http.Header{"Content-Type": []string{"application/json"}}
+	tests := []struct {
+		name     string
+		status   int
+		header   http.Header
+		body     string
+		wantErrs []string
+	}{
+		{name: "valid", status: 200, header: jsonHeader, body: `{"id": 1, "name": "Alice"}`},
+		{name: "documented status without body", status: 404},
+		{
+			name: "missing required field", status: 200, header: jsonHeader, body: `{"id": 1}`,
+			wantErrs: []string{"response 200 to GET /users/1 does not match", `response body at $.name: property "name" is missing`},
+		},
+		{
+			name: "wrong type", status: 200, header: jsonHeader, body: `{"id": "1", "name": "Alice"}`,
+			wantErrs: []string{"response body at $.id:"},
+		},
+		{name: "undocumented status", status: 500, wantErrs: []string{"status is not supported"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/users/1", nil)
+			err := c.checkResponse(context.Background(), req, tt.status, tt.header, []byte(tt.body))
+			assertErrorContains(t, err, tt.wantErrs)
+		})
+	}
+}
+
+func TestLoadContract_Options(t *testing.T) {
+	spec := writeUsersSpec(t)
+	ctx := context.Background()
+
+	if c, err := loadContract(ctx, config.Resource{}); c != nil || err != nil {
+		t.Errorf("loadContract() = %v, %v without options.openapi, want nil", c, err)
+	}
+
+	c, err := loadContract(ctx, config.Resource{Options: map[string]any{
+		"openapi": map[string]any{"spec": spec, "requests": false},
+	}})
+	if err != nil {
+		t.Fatalf("loading contract: %v", err)
+	}
+	if c.requests || !c.responses {
+		t.Errorf("requests, responses = %v, %v, want false, true", c.requests, c.responses)
+	}
+	if err := c.checkRequest(ctx, httptest.NewRequest("GET", "/orders", nil), nil); err != nil {
+		t.Errorf("requests are validated with requests: false: %v", err)
+	}
+
+	if _, err := loadContract(ctx, config.Resource{Options: map[string]any{"openapi": filepath.Join(t.TempDir(), "missing.yaml")}}); err == nil {
+		t.Error("expected an error for a missing spec")
+	}
+	if _, err := loadContract(ctx, config.Resource{Options: map[string]any{"openapi": map[string]any{}}}); err == nil {
+		t.Error("expected an error for a map without spec")
+	}
+}
+
+func TestHTTPClient_Contract(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/users":
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"id": 1, "name": "Alice"}`)
+		default:
+			// Drifted from the spec: id is a string
+			fmt.Fprint(w, `{"id": "1", "name": "Alice"}`)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewHTTPClient("api", config.Resource{
+		BaseURL: server.URL,
+		Options: map[string]any{"openapi": writeUsersSpec(t)},
+	}, nil)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	ctx := context.Background()
+	if err := client.Init(ctx); err != nil {
+		t.Fatalf("failed to init client: %v", err)
+	}
+
+	if err := client.sendRequestWithJSON(ctx, "POST", "/users", &godog.DocString{Content: `{"name": "Alice"}`}); err != nil {
+		t.Errorf("valid exchange failed: %v", err)
+	}
+
+	err = client.sendRequestWithJSON(ctx, "POST", "/users", &godog.DocString{Content: `{"email": "a@example.com"}`})
+	assertErrorContains(t, err, []string{`property "name" is missing`})
+	if calls != 1 {
+		t.Errorf("an invalid request was sent: %d calls", calls)
+	}
+
+	// A negative test sends the invalid request anyway
+	client.skipContractForNextRequest(ctx)
+	if err := client.sendRequestWithJSON(ctx, "POST", "/users", &godog.DocString{Content: `{"email": "a@example.com"}`}); err != nil {
+		t.Errorf("request after skipping the contract failed: %v", err)
+	}
+	err = client.sendRequestWithJSON(ctx, "POST", "/users", &godog.DocString{Content: `{"email": "a@example.com"}`})
+	assertErrorContains(t, err, []string{`property "name" is missing`})
+
+	err = client.sendRequest(ctx, "GET", "/users/1")
+	assertErrorContains(t, err, []string{"response 200 to GET /users/1", "response body at $.id:"})
+	if err := client.responseStatusShouldBe(ctx, 200); err != nil {
+		t.Errorf("the response isn't kept after a violation: %v", err)
+	}
+}
+
+func TestHTTPServer_Contract(t *testing.T) {
+	server, err := NewHTTPServer("users", config.Resource{Options: map[string]any{"openapi": writeUsersSpec(t)}}, nil)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	ctx := context.Background()
+	if err := server.Init(ctx); err != nil {
+		t.Fatalf("failed to init server: %v", err)
+	}
+	defer server.Cleanup(ctx)
+
+	if err := server.stubReturnsJSON("GET", "/users/1", 200, &godog.DocString{Content: `{"id": 1, "name": "Alice"}`}); err != nil {
+		t.Errorf("valid stub rejected: %v", err)
+	}
+	if err := server.stubReturnsStatus("GET", "/users/2", 404); err != nil {
+		t.Errorf("valid stub rejected: %v", err)
+	}
+	err = server.stubReturnsJSON("GET", "/users/3", 200, &godog.DocString{Content: `{"id": 3}`})
+	assertErrorContains(t, err, []string{"stub violates the contract", `property "name" is missing`})
+	err = server.stubReturnsStatus("GET", "/users/3", 500)
+	assertErrorContains(t, err, []string{"status is not supported"})
+	err = server.stubReturnsStatus("GET", "/orders", 200)
+	assertErrorContains(t, err, []string{"no matching path"})
+
+	for _, path := range []string{"/users/1", "/users/abc"} {
+		resp, err := http.Get(server.GetURL() + path)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	err = server.takeContractViolations()
+	assertErrorContains(t, err, []string{`users received a request that violates the contract: GET /users/abc`, `path parameter "id"`})
+	if strings.Contains(err.Error(), "/users/1 ") {
+		t.Errorf("a valid request was reported: %v", err)
+	}
+	if err := server.takeContractViolations(); err != nil {
+		t.Errorf("violations aren't forgotten once taken: %v", err)
+	}
+}
+
+func assertErrorContains(t *testing.T, err error, wants []string) {
+	t.Helper()
+	if len(wants) == 0 {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return
+	}
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range wants {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+}
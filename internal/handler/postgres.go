@@ -3,9 +3,12 @@ package handler
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/cucumber/godog"
 	_ "github.com/lib/pq"
@@ -18,10 +21,41 @@ type Postgres struct {
 	config    config.Resource
 	container *container.Manager
 	db        *sql.DB
+
+	// Connection settings used to open per-worker connections
+	connInfo string
+	dbName   string
+
+	// Per-worker connections when options.isolation is "schema" or "database"
+	workerDBs map[int]*sql.DB
+	workerMu  sync.Mutex
+
+	// defaultState is used when a step runs outside of a scenario context.
+	// Parallel workers reset it while others read it.
+	defaultState atomic.Pointer[postgresState]
+}
+
+// postgresState is the per-scenario state of a Postgres resource
+type postgresState struct {
+	snapshotIgnores map[string][]snapshotIgnore // ignore rules by snapshot name
+}
+
+func newPostgresState() *postgresState {
+	return &postgresState{snapshotIgnores: make(map[string][]snapshotIgnore)}
 }
 
 func NewPostgres(name string, cfg config.Resource, cm *container.Manager) (*Postgres, error) {
-	return &Postgres{name: name, config: cfg, container: cm}, nil
+	r := &Postgres{name: name, config: cfg, container: cm, workerDBs: make(map[int]*sql.DB)}
+	r.defaultState.Store(newPostgresState())
+	return r, nil
+}
+
+// state returns the state of the scenario carried by ctx
+func (r *Postgres) state(ctx context.Context) *postgresState {
+	if sc := ScenarioStateFrom(ctx); sc != nil {
+		return sc.Load("postgres:"+r.name, func() any { return newPostgresState() }).(*postgresState)
+	}
+	return r.defaultState.Load()
 }
 
 func (r *Postgres) Name() string { return r.name }
@@ -46,8 +80,14 @@ func (r *Postgres) Init(ctx context.Context) error {
 	if p, ok := r.config.Options["password"].(string); ok {
 		password = p
 	}
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", host, port, user, password, dbName)
-	db, err := sql.Open("postgres", dsn)
+	r.connInfo = fmt.Sprintf("host=%s port=%s user=%s password=%s sslmode=disable", host, port, user, password)
+	r.dbName = dbName
+	if isolationMode(r.config) == "database" {
+		if template, _ := r.config.Options["template"].(string); template == "" || template == dbName {
+			return fmt.Errorf("isolation \"database\" needs options.template, a database other than %s that nothing connects to", dbName)
+		}
+	}
+	db, err := sql.Open("postgres", fmt.Sprintf("%s dbname=%s", r.connInfo, dbName))
 	if err != nil {
 		return fmt.Errorf("connecting to postgres: %w", err)
 	}
@@ -55,28 +95,232 @@ func (r *Postgres) Init(ctx context.Context) error {
 	return nil
 }
 
+// dbFor returns the connection for the worker carried by ctx. With "schema"
+// isolation each worker gets a schema holding copies of the public tables;
+// with "database" isolation each worker gets a database cloned from
+// options.template.
+func (r *Postgres) dbFor(ctx context.Context) (*sql.DB, error) {
+	id := WorkerID(ctx)
+	mode := isolationMode(r.config)
+	if id == 0 || (mode != "schema" && mode != "database") {
+		return r.db, nil
+	}
+
+	r.workerMu.Lock()
+	defer r.workerMu.Unlock()
+
+	if db, ok := r.workerDBs[id]; ok {
+		return db, nil
+	}
+
+	var dsn string
+	switch mode {
+	case "schema":
+		schema := r.workerSchema(id)
+		if err := r.createWorkerSchema(ctx, schema); err != nil {
+			return nil, err
+		}
+		dsn = fmt.Sprintf("%s dbname=%s search_path=%s", r.connInfo, r.dbName, schema)
+	case "database":
+		name := fmt.Sprintf("%s_w%d", r.dbName, id)
+		if err := r.createWorkerDatabase(ctx, name); err != nil {
+			return nil, err
+		}
+		dsn = fmt.Sprintf("%s dbname=%s", r.connInfo, name)
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to worker %d: %w", id, err)
+	}
+	r.workerDBs[id] = db
+	return db, nil
+}
+
+// workerSchema returns the schema name used by a worker
+func (r *Postgres) workerSchema(id int) string {
+	return fmt.Sprintf("tomato_w%d", id)
+}
+
+// createWorkerSchema (re)creates a schema with empty copies of all public
+// tables. LIKE copies columns, defaults, checks and indexes; the serial
+// sequences and foreign keys are copied separately so that the schema
+// behaves like public: each worker has its own IDs and references point to
+// the worker's own tables.
+func (r *Postgres) createWorkerSchema(ctx context.Context, schema string) error {
+	tables, err := r.listTables(ctx, r.db, "public")
+	if err != nil {
+		return err
+	}
+	serials, err := r.serialSequences(ctx)
+	if err != nil {
+		return err
+	}
+	foreignKeys, err := r.foreignKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("creating schema %s: %w", schema, err)
+	}
+	defer tx.Rollback()
+
+	stmts := []string{
+		fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", schema),
+		fmt.Sprintf("CREATE SCHEMA %s", schema),
+	}
+	for _, table := range tables {
+		stmts = append(stmts, fmt.Sprintf("CREATE TABLE %s.%s (LIKE public.%s INCLUDING ALL)", schema, table, table))
+		for _, s := range serials[table] {
+			stmts = append(stmts,
+				fmt.Sprintf("CREATE SEQUENCE %s.%s OWNED BY %s.%s.%s", schema, s.sequence, schema, table, s.column),
+				fmt.Sprintf("ALTER TABLE %s.%s ALTER COLUMN %s SET DEFAULT nextval('%s.%s')", schema, table, s.column, schema, s.sequence),
+			)
+		}
+	}
+	// Constraint definitions name public tables without their schema, so
+	// with the worker's schema first they reference its copies
+	stmts = append(stmts, fmt.Sprintf("SET LOCAL search_path TO %s, public", schema))
+	for _, fk := range foreignKeys {
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s %s", fk.table, fk.name, fk.definition))
+	}
+
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("copying public tables to schema %s: %s: %w", schema, stmt, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("creating schema %s: %w", schema, err)
+	}
+	return nil
+}
+
+type serialColumn struct {
+	column, sequence string
+}
+
+// serialSequences returns the columns of public tables that take their
+// default from a sequence they own (serial columns), by table. Identity
+// columns get their own sequence from LIKE.
+func (r *Postgres) serialSequences(ctx context.Context) (map[string][]serialColumn, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT t.relname, a.attname, s.relname
+		FROM pg_depend d
+		JOIN pg_class s ON s.oid = d.objid AND s.relkind = 'S'
+		JOIN pg_class t ON t.oid = d.refobjid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = d.refobjsubid
+		WHERE d.classid = 'pg_class'::regclass AND d.deptype = 'a' AND n.nspname = 'public'`)
+	if err != nil {
+		return nil, fmt.Errorf("listing sequences: %w", err)
+	}
+	defer rows.Close()
+
+	serials := make(map[string][]serialColumn)
+	for rows.Next() {
+		var table string
+		var s serialColumn
+		if err := rows.Scan(&table, &s.column, &s.sequence); err != nil {
+			return nil, fmt.Errorf("listing sequences: %w", err)
+		}
+		serials[table] = append(serials[table], s)
+	}
+	return serials, rows.Err()
+}
+
+type foreignKey struct {
+	table, name, definition string
+}
+
+// foreignKeys returns the foreign keys of public tables
+func (r *Postgres) foreignKeys(ctx context.Context) ([]foreignKey, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT t.relname, c.conname, pg_get_constraintdef(c.oid)
+		FROM pg_constraint c
+		JOIN pg_class t ON t.oid = c.conrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		WHERE c.contype = 'f' AND n.nspname = 'public'
+		ORDER BY t.relname, c.conname`)
+	if err != nil {
+		return nil, fmt.Errorf("listing foreign keys: %w", err)
+	}
+	defer rows.Close()
+
+	var fks []foreignKey
+	for rows.Next() {
+		var fk foreignKey
+		if err := rows.Scan(&fk.table, &fk.name, &fk.definition); err != nil {
+			return nil, fmt.Errorf("listing foreign keys: %w", err)
+		}
+		fks = append(fks, fk)
+	}
+	return fks, rows.Err()
+}
+
+// createWorkerDatabase (re)creates a database cloned from options.template.
+// Postgres refuses to clone a database with active connections, so the
+// template is a dedicated database nobody connects to, see Init.
+func (r *Postgres) createWorkerDatabase(ctx context.Context, name string) error {
+	template, _ := r.config.Options["template"].(string)
+
+	if _, err := r.db.ExecContext(ctx, fmt.Sprintf("DROP DATABASE IF EXISTS %s", name)); err != nil {
+		return fmt.Errorf("dropping database %s: %w", name, err)
+	}
+	if _, err := r.db.ExecContext(ctx, fmt.Sprintf("CREATE DATABASE %s TEMPLATE %s", name, template)); err != nil {
+		return fmt.Errorf("cloning database %s from template %s: %w", name, template, err)
+	}
+	return nil
+}
+
 func (r *Postgres) Ready(ctx context.Context) error { return r.db.PingContext(ctx) }
 
 func (r *Postgres) Reset(ctx context.Context) error {
-	tables, err := r.getTablesToReset(ctx)
+	r.defaultState.Store(newPostgresState())
+	db, err := r.dbFor(ctx)
+	if err != nil {
+		return err
+	}
+	tables, err := r.getTablesToReset(ctx, db)
 	if err != nil {
 		return err
 	}
 	if len(tables) == 0 {
 		return nil
 	}
-	_, err = r.db.ExecContext(ctx, fmt.Sprintf("TRUNCATE TABLE %s CASCADE", strings.Join(tables, ", ")))
+	_, err = db.ExecContext(ctx, fmt.Sprintf("TRUNCATE TABLE %s CASCADE", strings.Join(tables, ", ")))
 	return err
 }
 
-func (r *Postgres) getTablesToReset(ctx context.Context) ([]string, error) {
+func (r *Postgres) getTablesToReset(ctx context.Context, db *sql.DB) ([]string, error) {
 	// If specific tables are configured, use those
 	if configuredTables := r.getConfiguredTables(); len(configuredTables) > 0 {
 		return configuredTables, nil
 	}
 
-	// Otherwise, get all tables from public schema
-	rows, err := r.db.QueryContext(ctx, "SELECT tablename FROM pg_tables WHERE schemaname = 'public'")
+	// Otherwise, get all tables from the public schema (or the worker's schema)
+	schema := "public"
+	if id := WorkerID(ctx); id != 0 && isolationMode(r.config) == "schema" {
+		schema = r.workerSchema(id)
+	}
+	tables, err := r.listTables(ctx, db, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0, len(tables))
+	for _, table := range tables {
+		if !r.isExcluded(table) {
+			result = append(result, table)
+		}
+	}
+	return result, nil
+}
+
+func (r *Postgres) listTables(ctx context.Context, db *sql.DB, schema string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT tablename FROM pg_tables WHERE schemaname = $1", schema)
 	if err != nil {
 		return nil, fmt.Errorf("listing tables: %w", err)
 	}
@@ -88,11 +332,9 @@ func (r *Postgres) getTablesToReset(ctx context.Context) ([]string, error) {
 		if err := rows.Scan(&table); err != nil {
 			return nil, err
 		}
-		if !r.isExcluded(table) {
-			tables = append(tables, table)
-		}
+		tables = append(tables, table)
 	}
-	return tables, nil
+	return tables, rows.Err()
 }
 
 func (r *Postgres) getConfiguredTables() []string {
@@ -194,16 +436,40 @@ func (r *Postgres) Steps() StepCategory {
 				Example:     `"db" table "users" has "5" rows`,
 				Handler:     r.tableShouldHaveRows,
 			},
+			{
+				Group:       "Assertions",
+				Pattern:     `^"{resource}" query result matches snapshot "([^"]*)":$`,
+				Description: "Run a query and assert its rows, as JSON objects, equal a named snapshot",
+				Example:     "\"db\" query result matches snapshot \"orders/after-checkout\":\n  \"\"\"\n  SELECT id, status, total FROM orders ORDER BY id\n  \"\"\"",
+				Handler:     r.queryResultShouldMatchSnapshot,
+			},
+
+			// Snapshots
+			{
+				Group:       "Snapshots",
+				Pattern:     `^"{resource}" snapshot "([^"]*)" ignores:$`,
+				Description: "Replace the values at the given paths of a query result snapshot, such as timestamps and serial IDs, by matchers for the rest of the scenario",
+				Example:     "\"db\" snapshot \"orders/after-checkout\" ignores:\n  | path            | matcher |\n  | [*].id          | @number |\n  | [*].created_at  | @string |",
+				Handler:     r.ignoreInSnapshot,
+			},
 		},
 	}
 }
 
-func (r *Postgres) clearTable(table string) error {
-	_, err := r.db.Exec(fmt.Sprintf("TRUNCATE TABLE %s CASCADE", table))
+func (r *Postgres) clearTable(ctx context.Context, table string) error {
+	db, err := r.dbFor(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, fmt.Sprintf("TRUNCATE TABLE %s CASCADE", table))
 	return err
 }
 
-func (r *Postgres) clearTables(data *godog.Table) error {
+func (r *Postgres) clearTables(ctx context.Context, data *godog.Table) error {
+	db, err := r.dbFor(ctx)
+	if err != nil {
+		return err
+	}
 	var tables []string
 	for _, row := range data.Rows {
 		if len(row.Cells) > 0 {
@@ -213,14 +479,18 @@ func (r *Postgres) clearTables(data *godog.Table) error {
 	if len(tables) == 0 {
 		return nil
 	}
-	_, err := r.db.Exec(fmt.Sprintf("TRUNCATE TABLE %s CASCADE", strings.Join(tables, ", ")))
+	_, err = db.ExecContext(ctx, fmt.Sprintf("TRUNCATE TABLE %s CASCADE", strings.Join(tables, ", ")))
 	return err
 }
 
-func (r *Postgres) setTableValues(table string, data *godog.Table) error {
+func (r *Postgres) setTableValues(ctx context.Context, table string, data *godog.Table) error {
 	if len(data.Rows) < 2 {
 		return fmt.Errorf("table must have headers and at least one data row")
 	}
+	db, err := r.dbFor(ctx)
+	if err != nil {
+		return err
+	}
 	headers := data.Rows[0].Cells
 	columns := make([]string, len(headers))
 	for i, cell := range headers {
@@ -232,24 +502,28 @@ func (r *Postgres) setTableValues(table string, data *godog.Table) error {
 			values[i] = fmt.Sprintf("'%s'", cell.Value)
 		}
 		query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(columns, ", "), strings.Join(values, ", "))
-		if _, err := r.db.Exec(query); err != nil {
+		if _, err := db.ExecContext(ctx, query); err != nil {
 			return fmt.Errorf("inserting row: %w", err)
 		}
 	}
 	return nil
 }
 
-func (r *Postgres) tableShouldContain(table string, expected *godog.Table) error {
+func (r *Postgres) tableShouldContain(ctx context.Context, table string, expected *godog.Table) error {
 	if len(expected.Rows) < 2 {
 		return fmt.Errorf("expected table must have headers and at least one data row")
 	}
+	db, err := r.dbFor(ctx)
+	if err != nil {
+		return err
+	}
 	headers := expected.Rows[0].Cells
 	columns := make([]string, len(headers))
 	for i, cell := range headers {
 		columns[i] = cell.Value
 	}
 	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(columns, ", "), table)
-	rows, err := r.db.Query(query)
+	rows, err := db.QueryContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("querying table: %w", err)
 	}
@@ -283,9 +557,13 @@ func (r *Postgres) tableShouldContain(table string, expected *godog.Table) error
 	return nil
 }
 
-func (r *Postgres) tableShouldBeEmpty(table string) error {
+func (r *Postgres) tableShouldBeEmpty(ctx context.Context, table string) error {
+	db, err := r.dbFor(ctx)
+	if err != nil {
+		return err
+	}
 	var count int
-	if err := r.db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", table)).Scan(&count); err != nil {
+	if err := db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s", table)).Scan(&count); err != nil {
 		return err
 	}
 	if count != 0 {
@@ -294,9 +572,13 @@ func (r *Postgres) tableShouldBeEmpty(table string) error {
 	return nil
 }
 
-func (r *Postgres) tableShouldHaveRows(table string, expected int) error {
+func (r *Postgres) tableShouldHaveRows(ctx context.Context, table string, expected int) error {
+	db, err := r.dbFor(ctx)
+	if err != nil {
+		return err
+	}
 	var count int
-	if err := r.db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", table)).Scan(&count); err != nil {
+	if err := db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s", table)).Scan(&count); err != nil {
 		return err
 	}
 	if count != expected {
@@ -305,17 +587,80 @@ func (r *Postgres) tableShouldHaveRows(table string, expected int) error {
 	return nil
 }
 
-func (r *Postgres) executeSQL(query *godog.DocString) error {
-	_, err := r.db.Exec(query.Content)
+func (r *Postgres) queryResultShouldMatchSnapshot(ctx context.Context, name string, query *godog.DocString) error {
+	db, err := r.dbFor(ctx)
+	if err != nil {
+		return err
+	}
+	rows, err := db.QueryContext(ctx, query.Content)
+	if err != nil {
+		return fmt.Errorf("querying: %w", err)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	result := make([]map[string]any, 0)
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		valuePtrs := make([]interface{}, len(columns))
+		for i := range values {
+			valuePtrs[i] = &values[i]
+		}
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return fmt.Errorf("scanning row: %w", err)
+		}
+		row := make(map[string]any, len(columns))
+		for i, v := range values {
+			// Text and numeric columns are scanned as bytes by some drivers
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			row[columns[i]] = v
+		}
+		result = append(result, row)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("reading rows: %w", err)
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("encoding rows: %w", err)
+	}
+	return matchJSONSnapshot(ctx, name, data, r.state(ctx).snapshotIgnores[name])
+}
+
+// ignoreInSnapshot sets the ignore rules of the named query result snapshot
+// for the rest of the scenario
+func (r *Postgres) ignoreInSnapshot(ctx context.Context, name string, table *godog.Table) error {
+	ignores, err := parseSnapshotIgnores(table)
+	if err != nil {
+		return err
+	}
+	r.state(ctx).snapshotIgnores[name] = ignores
+	return nil
+}
+
+func (r *Postgres) executeSQL(ctx context.Context, query *godog.DocString) error {
+	db, err := r.dbFor(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, query.Content)
 	return err
 }
 
-func (r *Postgres) executeSQLFile(path string) error {
-	return r.ExecSQLFile(context.Background(), path)
+func (r *Postgres) executeSQLFile(ctx context.Context, path string) error {
+	return r.ExecSQLFile(ctx, path)
 }
 
 func (r *Postgres) ExecSQL(ctx context.Context, query string) (int64, error) {
-	result, err := r.db.ExecContext(ctx, query)
+	db, err := r.dbFor(ctx)
+	if err != nil {
+		return 0, err
+	}
+	result, err := db.ExecContext(ctx, query)
 	if err != nil {
 		return 0, err
 	}
@@ -327,11 +672,22 @@ func (r *Postgres) ExecSQLFile(ctx context.Context, path string) error {
 	if err != nil {
 		return fmt.Errorf("reading SQL file: %w", err)
 	}
-	_, err = r.db.ExecContext(ctx, string(content))
+	db, err := r.dbFor(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, string(content))
 	return err
 }
 
 func (r *Postgres) Cleanup(ctx context.Context) error {
+	r.workerMu.Lock()
+	for id, db := range r.workerDBs {
+		db.Close()
+		delete(r.workerDBs, id)
+	}
+	r.workerMu.Unlock()
+
 	if r.db != nil {
 		return r.db.Close()
 	}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -27,7 +28,7 @@ type RabbitMQ struct {
 	// Message storage
 	messages     map[string][]*amqp.Delivery // queue -> messages
 	messagesMu   sync.RWMutex
-	lastMessage  *amqp.Delivery
+	lastMessages map[string]*amqp.Delivery // worker namespace -> last consumed message
 	consuming    map[string]bool
 	consumingMu  sync.RWMutex
 	stopChannels map[string]chan struct{}
@@ -35,19 +36,25 @@ type RabbitMQ struct {
 	// Track declared resources for reset
 	declaredQueues    []string
 	declaredExchanges []string
+	declaredMu        sync.Mutex
+
+	// Worker namespaces whose configured resources were declared
+	declaredNamespaces map[string]bool
 }
 
 // NewRabbitMQ creates a new RabbitMQ handler
 func NewRabbitMQ(name string, cfg config.Resource, cm *container.Manager) (*RabbitMQ, error) {
 	return &RabbitMQ{
-		name:              name,
-		config:            cfg,
-		container:         cm,
-		messages:          make(map[string][]*amqp.Delivery),
-		consuming:         make(map[string]bool),
-		stopChannels:      make(map[string]chan struct{}),
-		declaredQueues:    make([]string, 0),
-		declaredExchanges: make([]string, 0),
+		name:               name,
+		config:             cfg,
+		container:          cm,
+		messages:           make(map[string][]*amqp.Delivery),
+		lastMessages:       make(map[string]*amqp.Delivery),
+		consuming:          make(map[string]bool),
+		stopChannels:       make(map[string]chan struct{}),
+		declaredQueues:     make([]string, 0),
+		declaredExchanges:  make([]string, 0),
+		declaredNamespaces: make(map[string]bool),
 	}, nil
 }
 
@@ -72,7 +79,7 @@ func (r *RabbitMQ) Init(ctx context.Context) error {
 	r.channel = ch
 
 	// Pre-declare resources from config
-	if err := r.declareConfiguredResources(); err != nil {
+	if err := r.declareConfiguredResources(ctx); err != nil {
 		return fmt.Errorf("declaring configured resources: %w", err)
 	}
 
@@ -113,12 +120,16 @@ func (r *RabbitMQ) getConnectionURL(ctx context.Context) (string, error) {
 	return fmt.Sprintf("amqp://%s:%s@%s:%s%s", user, pass, host, port, vhost), nil
 }
 
-func (r *RabbitMQ) declareConfiguredResources() error {
+// declareConfiguredResources declares the exchanges, queues and bindings from
+// config, using the worker's names when ctx carries an isolated worker
+func (r *RabbitMQ) declareConfiguredResources(ctx context.Context) error {
+	r.declaredNamespaces[r.namespace(ctx)] = true
+
 	// Declare exchanges from config
 	if exchanges, ok := r.config.Options["exchanges"].([]interface{}); ok {
 		for _, e := range exchanges {
 			if ex, ok := e.(map[string]interface{}); ok {
-				name := fmt.Sprintf("%v", ex["name"])
+				name := r.resourceName(ctx, fmt.Sprintf("%v", ex["name"]))
 				exType := "direct"
 				if t, ok := ex["type"].(string); ok {
 					exType = t
@@ -130,7 +141,7 @@ func (r *RabbitMQ) declareConfiguredResources() error {
 				if err := r.channel.ExchangeDeclare(name, exType, durable, false, false, false, nil); err != nil {
 					return fmt.Errorf("declaring exchange %s: %w", name, err)
 				}
-				r.declaredExchanges = append(r.declaredExchanges, name)
+				r.trackExchange(name)
 			}
 		}
 	}
@@ -139,7 +150,7 @@ func (r *RabbitMQ) declareConfiguredResources() error {
 	if queues, ok := r.config.Options["queues"].([]interface{}); ok {
 		for _, q := range queues {
 			if qu, ok := q.(map[string]interface{}); ok {
-				name := fmt.Sprintf("%v", qu["name"])
+				name := r.resourceName(ctx, fmt.Sprintf("%v", qu["name"]))
 				durable := false
 				if d, ok := qu["durable"].(bool); ok {
 					durable = d
@@ -151,7 +162,7 @@ func (r *RabbitMQ) declareConfiguredResources() error {
 				if _, err := r.channel.QueueDeclare(name, durable, autoDelete, false, false, nil); err != nil {
 					return fmt.Errorf("declaring queue %s: %w", name, err)
 				}
-				r.declaredQueues = append(r.declaredQueues, name)
+				r.trackQueue(name)
 			}
 		}
 	}
@@ -160,8 +171,8 @@ func (r *RabbitMQ) declareConfiguredResources() error {
 	if bindings, ok := r.config.Options["bindings"].([]interface{}); ok {
 		for _, b := range bindings {
 			if bind, ok := b.(map[string]interface{}); ok {
-				queue := fmt.Sprintf("%v", bind["queue"])
-				exchange := fmt.Sprintf("%v", bind["exchange"])
+				queue := r.resourceName(ctx, fmt.Sprintf("%v", bind["queue"]))
+				exchange := r.resourceName(ctx, fmt.Sprintf("%v", bind["exchange"]))
 				routingKey := ""
 				if rk, ok := bind["routing_key"].(string); ok {
 					routingKey = rk
@@ -190,11 +201,20 @@ func (r *RabbitMQ) Ready(ctx context.Context) error {
 }
 
 func (r *RabbitMQ) Reset(ctx context.Context) error {
-	r.stopAllConsumers()
+	// With worker isolation only the worker's own queues and exchanges are reset
+	ns := r.namespace(ctx)
+	if err := r.ensureNamespace(ctx); err != nil {
+		return err
+	}
+	r.stopConsumers(ns)
 
 	r.messagesMu.Lock()
-	r.messages = make(map[string][]*amqp.Delivery)
-	r.lastMessage = nil
+	for queue := range r.messages {
+		if strings.HasPrefix(queue, ns) {
+			delete(r.messages, queue)
+		}
+	}
+	delete(r.lastMessages, ns)
 	r.messagesMu.Unlock()
 
 	strategy := "purge"
@@ -204,18 +224,18 @@ func (r *RabbitMQ) Reset(ctx context.Context) error {
 
 	switch strategy {
 	case "purge":
-		return r.purgeQueues()
+		return r.purgeQueues(ns)
 	case "delete_recreate":
-		return r.deleteAndRecreate()
+		return r.deleteAndRecreate(ctx, ns)
 	case "none":
 		return nil
 	default:
-		return r.purgeQueues()
+		return r.purgeQueues(ns)
 	}
 }
 
-func (r *RabbitMQ) purgeQueues() error {
-	for _, queue := range r.declaredQueues {
+func (r *RabbitMQ) purgeQueues(prefix string) error {
+	for _, queue := range r.trackedQueues(prefix) {
 		if _, err := r.channel.QueuePurge(queue, false); err != nil {
 			log.Warn().Err(err).Str("queue", queue).Msg("error purging queue")
 		}
@@ -223,46 +243,173 @@ func (r *RabbitMQ) purgeQueues() error {
 	return nil
 }
 
-func (r *RabbitMQ) deleteAndRecreate() error {
+func (r *RabbitMQ) deleteAndRecreate(ctx context.Context, prefix string) error {
 	// Delete queues
-	for _, queue := range r.declaredQueues {
+	queues := r.trackedQueues(prefix)
+	for _, queue := range queues {
 		if _, err := r.channel.QueueDelete(queue, false, false, false); err != nil {
 			log.Warn().Err(err).Str("queue", queue).Msg("error deleting queue")
 		}
 	}
 
 	// Delete exchanges
-	for _, exchange := range r.declaredExchanges {
+	exchanges := r.trackedExchanges(prefix)
+	for _, exchange := range exchanges {
 		if err := r.channel.ExchangeDelete(exchange, false, false); err != nil {
 			log.Warn().Err(err).Str("exchange", exchange).Msg("error deleting exchange")
 		}
 	}
 
 	// Recreate
-	r.declaredQueues = make([]string, 0)
-	r.declaredExchanges = make([]string, 0)
+	r.declaredMu.Lock()
+	r.declaredQueues = removeNames(r.declaredQueues, queues)
+	r.declaredExchanges = removeNames(r.declaredExchanges, exchanges)
+	r.declaredMu.Unlock()
 
-	return r.declareConfiguredResources()
+	return r.declareConfiguredResources(ctx)
 }
 
 func (r *RabbitMQ) stopAllConsumers() {
+	r.stopConsumers("")
+}
+
+// stopConsumers stops consumers of all queues starting with prefix
+func (r *RabbitMQ) stopConsumers(prefix string) {
 	r.consumingMu.Lock()
 	defer r.consumingMu.Unlock()
 
 	for queue, stopCh := range r.stopChannels {
+		if !strings.HasPrefix(queue, prefix) {
+			continue
+		}
 		close(stopCh)
 		delete(r.stopChannels, queue)
 		r.consuming[queue] = false
 	}
 }
 
+// namespace returns the name prefix of the worker carried by ctx when
+// options.isolation is "prefix", or an empty string otherwise
+func (r *RabbitMQ) namespace(ctx context.Context) string {
+	if isolationMode(r.config) != "prefix" {
+		return ""
+	}
+	return workerPrefix(ctx)
+}
+
+// resourceName rewrites a queue or exchange name for the worker carried by ctx.
+// The default exchange and built-in amq.* names are never rewritten.
+func (r *RabbitMQ) resourceName(ctx context.Context, name string) string {
+	if name == "" || strings.HasPrefix(name, "amq.") {
+		return name
+	}
+	return r.namespace(ctx) + name
+}
+
+// ensureNamespace declares the configured resources for the worker carried by ctx
+func (r *RabbitMQ) ensureNamespace(ctx context.Context) error {
+	r.declaredMu.Lock()
+	declared := r.declaredNamespaces[r.namespace(ctx)]
+	r.declaredMu.Unlock()
+	if declared {
+		return nil
+	}
+	return r.declareConfiguredResources(ctx)
+}
+
+// lastMessage returns the last message consumed within the worker's namespace
+func (r *RabbitMQ) lastMessage(ctx context.Context) *amqp.Delivery {
+	r.messagesMu.RLock()
+	defer r.messagesMu.RUnlock()
+	return r.lastMessages[r.namespace(ctx)]
+}
+
+// Transcript returns the last messages consumed within the worker's namespace
+func (r *RabbitMQ) Transcript(ctx context.Context) string {
+	n := transcriptSize(r.config)
+	if n == 0 {
+		return ""
+	}
+	ns := r.namespace(ctx)
+
+	r.messagesMu.RLock()
+	queues := make([]string, 0, len(r.messages))
+	for queue := range r.messages {
+		if strings.HasPrefix(queue, ns) {
+			queues = append(queues, queue)
+		}
+	}
+	sort.Strings(queues)
+	type consumed struct {
+		queue string
+		msg   *amqp.Delivery
+	}
+	var msgs []consumed
+	for _, queue := range queues {
+		for _, msg := range r.messages[queue] {
+			msgs = append(msgs, consumed{queue, msg})
+		}
+	}
+	r.messagesMu.RUnlock()
+	sort.SliceStable(msgs, func(i, j int) bool { return msgs[i].msg.Timestamp.Before(msgs[j].msg.Timestamp) })
+
+	var entries []string
+	for _, c := range lastN(msgs, n) {
+		headers := make(map[string]string, len(c.msg.Headers))
+		for k, v := range c.msg.Headers {
+			headers[k] = fmt.Sprint(v)
+		}
+		entries = append(entries, formatMessage(redactorFrom(ctx), c.msg.Timestamp, "queue "+c.queue, c.msg.RoutingKey, headers, c.msg.Body))
+	}
+	return joinTranscript(entries)
+}
+
+func (r *RabbitMQ) trackedQueues(prefix string) []string {
+	r.declaredMu.Lock()
+	defer r.declaredMu.Unlock()
+	return filterPrefix(r.declaredQueues, prefix)
+}
+
+func (r *RabbitMQ) trackedExchanges(prefix string) []string {
+	r.declaredMu.Lock()
+	defer r.declaredMu.Unlock()
+	return filterPrefix(r.declaredExchanges, prefix)
+}
+
+func filterPrefix(names []string, prefix string) []string {
+	result := make([]string, 0, len(names))
+	for _, n := range names {
+		if strings.HasPrefix(n, prefix) {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+func removeNames(names, remove []string) []string {
+	result := make([]string, 0, len(names))
+	for _, n := range names {
+		found := false
+		for _, rm := range remove {
+			if n == rm {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
 func (r *RabbitMQ) RegisterSteps(ctx *godog.ScenarioContext) {
 	RegisterStepsToGodog(ctx, r.name, r.Steps())
 }
 
 // Steps returns the structured step definitions for the RabbitMQ handler
 func (r *RabbitMQ) Steps() StepCategory {
-	return StepCategory{
+	category := StepCategory{
 		Name:        "RabbitMQ",
 		Description: "Steps for interacting with RabbitMQ message broker",
 		Steps: []StepDef{
@@ -437,11 +584,24 @@ func (r *RabbitMQ) Steps() StepCategory {
 			},
 		},
 	}
+	category.Steps = append(category.Steps, messageJSONSteps("{resource}", r.lastMessageBody)...)
+	return category
+}
+
+// lastMessageBody returns the body of the last message consumed within the
+// worker's namespace
+func (r *RabbitMQ) lastMessageBody(ctx context.Context) ([]byte, error) {
+	msg := r.lastMessage(ctx)
+	if msg == nil {
+		return nil, fmt.Errorf("no message received")
+	}
+	return msg.Body, nil
 }
 
 // Queue Management
 
-func (r *RabbitMQ) declareQueue(name string) error {
+func (r *RabbitMQ) declareQueue(ctx context.Context, name string) error {
+	name = r.resourceName(ctx, name)
 	_, err := r.channel.QueueDeclare(name, false, false, false, false, nil)
 	if err != nil {
 		return err
@@ -450,7 +610,8 @@ func (r *RabbitMQ) declareQueue(name string) error {
 	return nil
 }
 
-func (r *RabbitMQ) declareDurableQueue(name string) error {
+func (r *RabbitMQ) declareDurableQueue(ctx context.Context, name string) error {
+	name = r.resourceName(ctx, name)
 	_, err := r.channel.QueueDeclare(name, true, false, false, false, nil)
 	if err != nil {
 		return err
@@ -460,6 +621,8 @@ func (r *RabbitMQ) declareDurableQueue(name string) error {
 }
 
 func (r *RabbitMQ) trackQueue(name string) {
+	r.declaredMu.Lock()
+	defer r.declaredMu.Unlock()
 	for _, q := range r.declaredQueues {
 		if q == name {
 			return
@@ -468,7 +631,8 @@ func (r *RabbitMQ) trackQueue(name string) {
 	r.declaredQueues = append(r.declaredQueues, name)
 }
 
-func (r *RabbitMQ) queueExists(name string) error {
+func (r *RabbitMQ) queueExists(ctx context.Context, name string) error {
+	name = r.resourceName(ctx, name)
 	_, err := r.channel.QueueInspect(name)
 	if err != nil {
 		return fmt.Errorf("queue %q does not exist: %w", name, err)
@@ -476,14 +640,16 @@ func (r *RabbitMQ) queueExists(name string) error {
 	return nil
 }
 
-func (r *RabbitMQ) purgeQueue(name string) error {
+func (r *RabbitMQ) purgeQueue(ctx context.Context, name string) error {
+	name = r.resourceName(ctx, name)
 	_, err := r.channel.QueuePurge(name, false)
 	return err
 }
 
 // Exchange Management
 
-func (r *RabbitMQ) declareExchange(name, exchangeType string) error {
+func (r *RabbitMQ) declareExchange(ctx context.Context, name, exchangeType string) error {
+	name = r.resourceName(ctx, name)
 	err := r.channel.ExchangeDeclare(name, exchangeType, false, false, false, false, nil)
 	if err != nil {
 		return err
@@ -492,7 +658,8 @@ func (r *RabbitMQ) declareExchange(name, exchangeType string) error {
 	return nil
 }
 
-func (r *RabbitMQ) declareDurableExchange(name, exchangeType string) error {
+func (r *RabbitMQ) declareDurableExchange(ctx context.Context, name, exchangeType string) error {
+	name = r.resourceName(ctx, name)
 	err := r.channel.ExchangeDeclare(name, exchangeType, true, false, false, false, nil)
 	if err != nil {
 		return err
@@ -502,6 +669,8 @@ func (r *RabbitMQ) declareDurableExchange(name, exchangeType string) error {
 }
 
 func (r *RabbitMQ) trackExchange(name string) {
+	r.declaredMu.Lock()
+	defer r.declaredMu.Unlock()
 	for _, e := range r.declaredExchanges {
 		if e == name {
 			return
@@ -510,7 +679,8 @@ func (r *RabbitMQ) trackExchange(name string) {
 	r.declaredExchanges = append(r.declaredExchanges, name)
 }
 
-func (r *RabbitMQ) exchangeExists(name string) error {
+func (r *RabbitMQ) exchangeExists(ctx context.Context, name string) error {
+	name = r.resourceName(ctx, name)
 	// Try passive declare - fails if exchange doesn't exist
 	err := r.channel.ExchangeDeclarePassive(name, "", false, false, false, false, nil)
 	if err != nil {
@@ -527,19 +697,24 @@ func (r *RabbitMQ) exchangeExists(name string) error {
 
 // Bindings
 
-func (r *RabbitMQ) bindQueue(queue, exchange string) error {
+func (r *RabbitMQ) bindQueue(ctx context.Context, queue, exchange string) error {
+	queue = r.resourceName(ctx, queue)
+	exchange = r.resourceName(ctx, exchange)
 	return r.channel.QueueBind(queue, "", exchange, false, nil)
 }
 
-func (r *RabbitMQ) bindQueueWithKey(queue, exchange, routingKey string) error {
+func (r *RabbitMQ) bindQueueWithKey(ctx context.Context, queue, exchange, routingKey string) error {
+	queue = r.resourceName(ctx, queue)
+	exchange = r.resourceName(ctx, exchange)
 	return r.channel.QueueBind(queue, routingKey, exchange, false, nil)
 }
 
 // Publishing
 
-func (r *RabbitMQ) publishToQueue(queue string, doc *godog.DocString) error {
+func (r *RabbitMQ) publishToQueue(ctx context.Context, queue string, doc *godog.DocString) error {
+	queue = r.resourceName(ctx, queue)
 	return r.channel.PublishWithContext(
-		context.Background(),
+		ctx,
 		"",    // default exchange
 		queue, // routing key = queue name
 		false,
@@ -551,14 +726,15 @@ func (r *RabbitMQ) publishToQueue(queue string, doc *godog.DocString) error {
 	)
 }
 
-func (r *RabbitMQ) publishJSONToQueue(queue string, doc *godog.DocString) error {
+func (r *RabbitMQ) publishJSONToQueue(ctx context.Context, queue string, doc *godog.DocString) error {
+	queue = r.resourceName(ctx, queue)
 	var js json.RawMessage
 	if err := json.Unmarshal([]byte(doc.Content), &js); err != nil {
 		return fmt.Errorf("invalid JSON: %w", err)
 	}
 
 	return r.channel.PublishWithContext(
-		context.Background(),
+		ctx,
 		"",
 		queue,
 		false,
@@ -570,9 +746,10 @@ func (r *RabbitMQ) publishJSONToQueue(queue string, doc *godog.DocString) error
 	)
 }
 
-func (r *RabbitMQ) publishToExchange(exchange, routingKey string, doc *godog.DocString) error {
+func (r *RabbitMQ) publishToExchange(ctx context.Context, exchange, routingKey string, doc *godog.DocString) error {
+	exchange = r.resourceName(ctx, exchange)
 	return r.channel.PublishWithContext(
-		context.Background(),
+		ctx,
 		exchange,
 		routingKey,
 		false,
@@ -584,14 +761,15 @@ func (r *RabbitMQ) publishToExchange(exchange, routingKey string, doc *godog.Doc
 	)
 }
 
-func (r *RabbitMQ) publishJSONToExchange(exchange, routingKey string, doc *godog.DocString) error {
+func (r *RabbitMQ) publishJSONToExchange(ctx context.Context, exchange, routingKey string, doc *godog.DocString) error {
+	exchange = r.resourceName(ctx, exchange)
 	var js json.RawMessage
 	if err := json.Unmarshal([]byte(doc.Content), &js); err != nil {
 		return fmt.Errorf("invalid JSON: %w", err)
 	}
 
 	return r.channel.PublishWithContext(
-		context.Background(),
+		ctx,
 		exchange,
 		routingKey,
 		false,
@@ -603,7 +781,8 @@ func (r *RabbitMQ) publishJSONToExchange(exchange, routingKey string, doc *godog
 	)
 }
 
-func (r *RabbitMQ) publishMessages(queue string, table *godog.Table) error {
+func (r *RabbitMQ) publishMessages(ctx context.Context, queue string, table *godog.Table) error {
+	queue = r.resourceName(ctx, queue)
 	if len(table.Rows) < 2 {
 		return fmt.Errorf("table must have headers and at least one data row")
 	}
@@ -628,11 +807,12 @@ func (r *RabbitMQ) publishMessages(queue string, table *godog.Table) error {
 	for _, row := range table.Rows[1:] {
 		routingKey := queue
 		if routingKeyIdx >= 0 && routingKeyIdx < len(row.Cells) {
-			routingKey = row.Cells[routingKeyIdx].Value
+			// Published to the default exchange, so the routing key is a queue name
+			routingKey = r.resourceName(ctx, row.Cells[routingKeyIdx].Value)
 		}
 
 		err := r.channel.PublishWithContext(
-			context.Background(),
+			ctx,
 			"",
 			routingKey,
 			false,
@@ -652,7 +832,14 @@ func (r *RabbitMQ) publishMessages(queue string, table *godog.Table) error {
 
 // Consuming
 
-func (r *RabbitMQ) startConsuming(queue string) error {
+func (r *RabbitMQ) startConsuming(ctx context.Context, queue string) error {
+	return r.consume(ctx, r.resourceName(ctx, queue))
+}
+
+// consume starts consuming an already resolved queue name
+func (r *RabbitMQ) consume(ctx context.Context, queue string) error {
+	ns := r.namespace(ctx)
+
 	r.consumingMu.Lock()
 	if r.consuming[queue] {
 		r.consumingMu.Unlock()
@@ -685,7 +872,7 @@ func (r *RabbitMQ) startConsuming(queue string) error {
 				}
 				r.messagesMu.Lock()
 				r.messages[queue] = append(r.messages[queue], &msg)
-				r.lastMessage = &msg
+				r.lastMessages[ns] = &msg
 				r.messagesMu.Unlock()
 			case <-stopCh:
 				return
@@ -696,13 +883,14 @@ func (r *RabbitMQ) startConsuming(queue string) error {
 	return nil
 }
 
-func (r *RabbitMQ) receiveMessage(queue, timeout string) error {
+func (r *RabbitMQ) receiveMessage(ctx context.Context, queue, timeout string) error {
 	duration, err := time.ParseDuration(timeout)
 	if err != nil {
 		return fmt.Errorf("invalid timeout: %w", err)
 	}
 
-	if err := r.startConsuming(queue); err != nil {
+	queue = r.resourceName(ctx, queue)
+	if err := r.consume(ctx, queue); err != nil {
 		return err
 	}
 
@@ -719,14 +907,12 @@ func (r *RabbitMQ) receiveMessage(queue, timeout string) error {
 	return fmt.Errorf("no message received within %s", timeout)
 }
 
-func (r *RabbitMQ) shouldReceiveMessage(queue, timeout string, doc *godog.DocString) error {
-	if err := r.receiveMessage(queue, timeout); err != nil {
+func (r *RabbitMQ) shouldReceiveMessage(ctx context.Context, queue, timeout string, doc *godog.DocString) error {
+	if err := r.receiveMessage(ctx, queue, timeout); err != nil {
 		return err
 	}
 
-	r.messagesMu.RLock()
-	lastMsg := r.lastMessage
-	r.messagesMu.RUnlock()
+	lastMsg := r.lastMessage(ctx)
 
 	if lastMsg == nil {
 		return fmt.Errorf("no message received")
@@ -750,22 +936,20 @@ func (r *RabbitMQ) getMessageCount(queue string) int {
 
 // Assertions
 
-func (r *RabbitMQ) queueShouldHaveMessages(queue string, expected int) error {
-	count := r.getMessageCount(queue)
+func (r *RabbitMQ) queueShouldHaveMessages(ctx context.Context, queue string, expected int) error {
+	count := r.getMessageCount(r.resourceName(ctx, queue))
 	if count != expected {
 		return fmt.Errorf("queue %q: expected %d messages, got %d", queue, expected, count)
 	}
 	return nil
 }
 
-func (r *RabbitMQ) queueShouldBeEmpty(queue string) error {
-	return r.queueShouldHaveMessages(queue, 0)
+func (r *RabbitMQ) queueShouldBeEmpty(ctx context.Context, queue string) error {
+	return r.queueShouldHaveMessages(ctx, queue, 0)
 }
 
-func (r *RabbitMQ) lastMessageShouldContain(doc *godog.DocString) error {
-	r.messagesMu.RLock()
-	lastMsg := r.lastMessage
-	r.messagesMu.RUnlock()
+func (r *RabbitMQ) lastMessageShouldContain(ctx context.Context, doc *godog.DocString) error {
+	lastMsg := r.lastMessage(ctx)
 
 	if lastMsg == nil {
 		return fmt.Errorf("no message received")
@@ -781,10 +965,8 @@ func (r *RabbitMQ) lastMessageShouldContain(doc *godog.DocString) error {
 	return nil
 }
 
-func (r *RabbitMQ) lastMessageShouldHaveRoutingKey(routingKey string) error {
-	r.messagesMu.RLock()
-	lastMsg := r.lastMessage
-	r.messagesMu.RUnlock()
+func (r *RabbitMQ) lastMessageShouldHaveRoutingKey(ctx context.Context, routingKey string) error {
+	lastMsg := r.lastMessage(ctx)
 
 	if lastMsg == nil {
 		return fmt.Errorf("no message received")
@@ -797,10 +979,8 @@ func (r *RabbitMQ) lastMessageShouldHaveRoutingKey(routingKey string) error {
 	return nil
 }
 
-func (r *RabbitMQ) lastMessageShouldHaveHeader(headerKey, headerValue string) error {
-	r.messagesMu.RLock()
-	lastMsg := r.lastMessage
-	r.messagesMu.RUnlock()
+func (r *RabbitMQ) lastMessageShouldHaveHeader(ctx context.Context, headerKey, headerValue string) error {
+	lastMsg := r.lastMessage(ctx)
 
 	if lastMsg == nil {
 		return fmt.Errorf("no message received")
@@ -822,6 +1002,26 @@ func (r *RabbitMQ) lastMessageShouldHaveHeader(headerKey, headerValue string) er
 	return nil
 }
 
+// Publish sends a message to a queue through the default exchange
+func (r *RabbitMQ) Publish(ctx context.Context, queue string, payload []byte, headers map[string]string) error {
+	queue = r.resourceName(ctx, queue)
+	table := amqp.Table{}
+	for k, v := range headers {
+		table[k] = v
+	}
+	return r.channel.PublishWithContext(
+		ctx,
+		"",
+		queue,
+		false,
+		false,
+		amqp.Publishing{
+			Headers: table,
+			Body:    payload,
+		},
+	)
+}
+
 func (r *RabbitMQ) Cleanup(ctx context.Context) error {
 	r.stopAllConsumers()
 
@@ -844,3 +1044,4 @@ func (r *RabbitMQ) Cleanup(ctx context.Context) error {
 }
 
 var _ Handler = (*RabbitMQ)(nil)
+var _ MessagePublisher = (*RabbitMQ)(nil)
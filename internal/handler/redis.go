@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/cucumber/godog"
@@ -18,13 +19,19 @@ type Redis struct {
 	config    config.Resource
 	container *container.Manager
 	client    *redis.Client
+
+	// Per-worker clients when options.isolation is "db"
+	options       redis.Options
+	workerClients map[int]*redis.Client
+	workerMu      sync.Mutex
 }
 
 func NewRedis(name string, cfg config.Resource, cm *container.Manager) (*Redis, error) {
 	return &Redis{
-		name:      name,
-		config:    cfg,
-		container: cm,
+		name:          name,
+		config:        cfg,
+		container:     cm,
+		workerClients: make(map[int]*redis.Client),
 	}, nil
 }
 
@@ -49,14 +56,37 @@ func (r *Redis) Init(ctx context.Context) error {
 		password = p
 	}
 
-	r.client = redis.NewClient(&redis.Options{
+	r.options = redis.Options{
 		Addr:     fmt.Sprintf("%s:%s", host, port),
 		Password: password,
 		DB:       db,
-	})
+	}
+	r.client = redis.NewClient(&r.options)
 	return nil
 }
 
+// clientFor returns the client for the worker carried by ctx. With "db"
+// isolation each worker uses its own logical database: the configured db
+// index plus the worker ID.
+func (r *Redis) clientFor(ctx context.Context) *redis.Client {
+	id := WorkerID(ctx)
+	if isolationMode(r.config) != "db" || id == 0 {
+		return r.client
+	}
+
+	r.workerMu.Lock()
+	defer r.workerMu.Unlock()
+
+	if c, ok := r.workerClients[id]; ok {
+		return c
+	}
+	opts := r.options
+	opts.DB = r.options.DB + id
+	c := redis.NewClient(&opts)
+	r.workerClients[id] = c
+	return c
+}
+
 func (r *Redis) Ready(ctx context.Context) error {
 	return r.client.Ping(ctx).Err()
 }
@@ -69,7 +99,7 @@ func (r *Redis) Reset(ctx context.Context) error {
 
 	switch strategy {
 	case "flush":
-		return r.client.FlushDB(ctx).Err()
+		return r.clientFor(ctx).FlushDB(ctx).Err()
 	case "pattern":
 		pattern := "*"
 		if p, ok := r.config.Options["reset_pattern"].(string); ok {
@@ -77,19 +107,19 @@ func (r *Redis) Reset(ctx context.Context) error {
 		}
 		return r.deleteByPattern(ctx, pattern)
 	default:
-		return r.client.FlushDB(ctx).Err()
+		return r.clientFor(ctx).FlushDB(ctx).Err()
 	}
 }
 
 func (r *Redis) deleteByPattern(ctx context.Context, pattern string) error {
 	var cursor uint64
 	for {
-		keys, nextCursor, err := r.client.Scan(ctx, cursor, pattern, 100).Result()
+		keys, nextCursor, err := r.clientFor(ctx).Scan(ctx, cursor, pattern, 100).Result()
 		if err != nil {
 			return err
 		}
 		if len(keys) > 0 {
-			if err := r.client.Del(ctx, keys...).Err(); err != nil {
+			if err := r.clientFor(ctx).Del(ctx, keys...).Err(); err != nil {
 				return err
 			}
 		}
@@ -301,32 +331,32 @@ func (r *Redis) Steps() StepCategory {
 	}
 }
 
-func (r *Redis) setKey(key, value string) error {
-	return r.client.Set(context.Background(), key, value, 0).Err()
+func (r *Redis) setKey(ctx context.Context, key, value string) error {
+	return r.clientFor(ctx).Set(ctx, key, value, 0).Err()
 }
 
-func (r *Redis) setKeyWithTTL(key, value, ttl string) error {
+func (r *Redis) setKeyWithTTL(ctx context.Context, key, value, ttl string) error {
 	duration, err := time.ParseDuration(ttl)
 	if err != nil {
 		return fmt.Errorf("invalid TTL duration: %w", err)
 	}
-	return r.client.Set(context.Background(), key, value, duration).Err()
+	return r.clientFor(ctx).Set(ctx, key, value, duration).Err()
 }
 
-func (r *Redis) setKeyJSON(key string, doc *godog.DocString) error {
+func (r *Redis) setKeyJSON(ctx context.Context, key string, doc *godog.DocString) error {
 	var js json.RawMessage
 	if err := json.Unmarshal([]byte(doc.Content), &js); err != nil {
 		return fmt.Errorf("invalid JSON: %w", err)
 	}
-	return r.client.Set(context.Background(), key, doc.Content, 0).Err()
+	return r.clientFor(ctx).Set(ctx, key, doc.Content, 0).Err()
 }
 
-func (r *Redis) deleteKey(key string) error {
-	return r.client.Del(context.Background(), key).Err()
+func (r *Redis) deleteKey(ctx context.Context, key string) error {
+	return r.clientFor(ctx).Del(ctx, key).Err()
 }
 
-func (r *Redis) keyShouldExist(key string) error {
-	exists, err := r.client.Exists(context.Background(), key).Result()
+func (r *Redis) keyShouldExist(ctx context.Context, key string) error {
+	exists, err := r.clientFor(ctx).Exists(ctx, key).Result()
 	if err != nil {
 		return err
 	}
@@ -336,8 +366,8 @@ func (r *Redis) keyShouldExist(key string) error {
 	return nil
 }
 
-func (r *Redis) keyShouldNotExist(key string) error {
-	exists, err := r.client.Exists(context.Background(), key).Result()
+func (r *Redis) keyShouldNotExist(ctx context.Context, key string) error {
+	exists, err := r.clientFor(ctx).Exists(ctx, key).Result()
 	if err != nil {
 		return err
 	}
@@ -347,8 +377,8 @@ func (r *Redis) keyShouldNotExist(key string) error {
 	return nil
 }
 
-func (r *Redis) keyShouldHaveValue(key, expected string) error {
-	value, err := r.client.Get(context.Background(), key).Result()
+func (r *Redis) keyShouldHaveValue(ctx context.Context, key, expected string) error {
+	value, err := r.clientFor(ctx).Get(ctx, key).Result()
 	if err != nil {
 		return fmt.Errorf("getting key %q: %w", key, err)
 	}
@@ -358,8 +388,8 @@ func (r *Redis) keyShouldHaveValue(key, expected string) error {
 	return nil
 }
 
-func (r *Redis) keyShouldContain(key, substr string) error {
-	value, err := r.client.Get(context.Background(), key).Result()
+func (r *Redis) keyShouldContain(ctx context.Context, key, substr string) error {
+	value, err := r.clientFor(ctx).Get(ctx, key).Result()
 	if err != nil {
 		return fmt.Errorf("getting key %q: %w", key, err)
 	}
@@ -369,8 +399,8 @@ func (r *Redis) keyShouldContain(key, substr string) error {
 	return nil
 }
 
-func (r *Redis) keyShouldHaveTTL(key string, minSeconds int) error {
-	ttl, err := r.client.TTL(context.Background(), key).Result()
+func (r *Redis) keyShouldHaveTTL(ctx context.Context, key string, minSeconds int) error {
+	ttl, err := r.clientFor(ctx).TTL(ctx, key).Result()
 	if err != nil {
 		return fmt.Errorf("getting TTL for key %q: %w", key, err)
 	}
@@ -380,8 +410,8 @@ func (r *Redis) keyShouldHaveTTL(key string, minSeconds int) error {
 	return nil
 }
 
-func (r *Redis) shouldHaveKeyCount(expected int) error {
-	count, err := r.client.DBSize(context.Background()).Result()
+func (r *Redis) shouldHaveKeyCount(ctx context.Context, expected int) error {
+	count, err := r.clientFor(ctx).DBSize(ctx).Result()
 	if err != nil {
 		return err
 	}
@@ -391,8 +421,8 @@ func (r *Redis) shouldHaveKeyCount(expected int) error {
 	return nil
 }
 
-func (r *Redis) shouldBeEmpty() error {
-	count, err := r.client.DBSize(context.Background()).Result()
+func (r *Redis) shouldBeEmpty(ctx context.Context) error {
+	count, err := r.clientFor(ctx).DBSize(ctx).Result()
 	if err != nil {
 		return err
 	}
@@ -402,7 +432,7 @@ func (r *Redis) shouldBeEmpty() error {
 	return nil
 }
 
-func (r *Redis) setHash(hash string, table *godog.Table) error {
+func (r *Redis) setHash(ctx context.Context, hash string, table *godog.Table) error {
 	if len(table.Rows) < 2 {
 		return fmt.Errorf("table must have headers and at least one data row")
 	}
@@ -412,11 +442,11 @@ func (r *Redis) setHash(hash string, table *godog.Table) error {
 			fields[row.Cells[0].Value] = row.Cells[1].Value
 		}
 	}
-	return r.client.HSet(context.Background(), hash, fields).Err()
+	return r.clientFor(ctx).HSet(ctx, hash, fields).Err()
 }
 
-func (r *Redis) hashFieldShouldBe(hash, field, expected string) error {
-	value, err := r.client.HGet(context.Background(), hash, field).Result()
+func (r *Redis) hashFieldShouldBe(ctx context.Context, hash, field, expected string) error {
+	value, err := r.clientFor(ctx).HGet(ctx, hash, field).Result()
 	if err != nil {
 		return fmt.Errorf("getting hash %q field %q: %w", hash, field, err)
 	}
@@ -426,13 +456,13 @@ func (r *Redis) hashFieldShouldBe(hash, field, expected string) error {
 	return nil
 }
 
-func (r *Redis) hashShouldContain(hash string, table *godog.Table) error {
+func (r *Redis) hashShouldContain(ctx context.Context, hash string, table *godog.Table) error {
 	if len(table.Rows) < 2 {
 		return fmt.Errorf("table must have headers and at least one data row")
 	}
 	for _, row := range table.Rows[1:] {
 		if len(row.Cells) >= 2 {
-			if err := r.hashFieldShouldBe(hash, row.Cells[0].Value, row.Cells[1].Value); err != nil {
+			if err := r.hashFieldShouldBe(ctx, hash, row.Cells[0].Value, row.Cells[1].Value); err != nil {
 				return err
 			}
 		}
@@ -440,22 +470,22 @@ func (r *Redis) hashShouldContain(hash string, table *godog.Table) error {
 	return nil
 }
 
-func (r *Redis) pushToList(list, value string) error {
-	return r.client.RPush(context.Background(), list, value).Err()
+func (r *Redis) pushToList(ctx context.Context, list, value string) error {
+	return r.clientFor(ctx).RPush(ctx, list, value).Err()
 }
 
-func (r *Redis) pushMultipleToList(list string, table *godog.Table) error {
+func (r *Redis) pushMultipleToList(ctx context.Context, list string, table *godog.Table) error {
 	var values []interface{}
 	for _, row := range table.Rows {
 		if len(row.Cells) > 0 {
 			values = append(values, row.Cells[0].Value)
 		}
 	}
-	return r.client.RPush(context.Background(), list, values...).Err()
+	return r.clientFor(ctx).RPush(ctx, list, values...).Err()
 }
 
-func (r *Redis) listShouldHaveLength(list string, expected int) error {
-	length, err := r.client.LLen(context.Background(), list).Result()
+func (r *Redis) listShouldHaveLength(ctx context.Context, list string, expected int) error {
+	length, err := r.clientFor(ctx).LLen(ctx, list).Result()
 	if err != nil {
 		return err
 	}
@@ -465,8 +495,8 @@ func (r *Redis) listShouldHaveLength(list string, expected int) error {
 	return nil
 }
 
-func (r *Redis) listShouldContain(list, expected string) error {
-	values, err := r.client.LRange(context.Background(), list, 0, -1).Result()
+func (r *Redis) listShouldContain(ctx context.Context, list, expected string) error {
+	values, err := r.clientFor(ctx).LRange(ctx, list, 0, -1).Result()
 	if err != nil {
 		return err
 	}
@@ -478,22 +508,22 @@ func (r *Redis) listShouldContain(list, expected string) error {
 	return fmt.Errorf("list %q does not contain %q", list, expected)
 }
 
-func (r *Redis) addToSet(set, member string) error {
-	return r.client.SAdd(context.Background(), set, member).Err()
+func (r *Redis) addToSet(ctx context.Context, set, member string) error {
+	return r.clientFor(ctx).SAdd(ctx, set, member).Err()
 }
 
-func (r *Redis) addMultipleToSet(set string, table *godog.Table) error {
+func (r *Redis) addMultipleToSet(ctx context.Context, set string, table *godog.Table) error {
 	var members []interface{}
 	for _, row := range table.Rows {
 		if len(row.Cells) > 0 {
 			members = append(members, row.Cells[0].Value)
 		}
 	}
-	return r.client.SAdd(context.Background(), set, members...).Err()
+	return r.clientFor(ctx).SAdd(ctx, set, members...).Err()
 }
 
-func (r *Redis) setShouldContain(set, member string) error {
-	isMember, err := r.client.SIsMember(context.Background(), set, member).Result()
+func (r *Redis) setShouldContain(ctx context.Context, set, member string) error {
+	isMember, err := r.clientFor(ctx).SIsMember(ctx, set, member).Result()
 	if err != nil {
 		return err
 	}
@@ -503,8 +533,8 @@ func (r *Redis) setShouldContain(set, member string) error {
 	return nil
 }
 
-func (r *Redis) setShouldHaveSize(set string, expected int) error {
-	size, err := r.client.SCard(context.Background(), set).Result()
+func (r *Redis) setShouldHaveSize(ctx context.Context, set string, expected int) error {
+	size, err := r.clientFor(ctx).SCard(ctx, set).Result()
 	if err != nil {
 		return err
 	}
@@ -514,37 +544,60 @@ func (r *Redis) setShouldHaveSize(set string, expected int) error {
 	return nil
 }
 
-func (r *Redis) incrementKey(key string) error {
-	return r.client.Incr(context.Background(), key).Err()
+func (r *Redis) incrementKey(ctx context.Context, key string) error {
+	return r.clientFor(ctx).Incr(ctx, key).Err()
 }
 
-func (r *Redis) incrementKeyBy(key string, amount int) error {
-	return r.client.IncrBy(context.Background(), key, int64(amount)).Err()
+func (r *Redis) incrementKeyBy(ctx context.Context, key string, amount int) error {
+	return r.clientFor(ctx).IncrBy(ctx, key, int64(amount)).Err()
 }
 
-func (r *Redis) decrementKey(key string) error {
-	return r.client.Decr(context.Background(), key).Err()
+func (r *Redis) decrementKey(ctx context.Context, key string) error {
+	return r.clientFor(ctx).Decr(ctx, key).Err()
 }
 
 // CacheStore interface implementation
 func (r *Redis) Set(ctx context.Context, key, value string) error {
-	return r.client.Set(ctx, key, value, 0).Err()
+	return r.clientFor(ctx).Set(ctx, key, value, 0).Err()
 }
 
 func (r *Redis) Get(ctx context.Context, key string) (string, error) {
-	return r.client.Get(ctx, key).Result()
+	return r.clientFor(ctx).Get(ctx, key).Result()
 }
 
 func (r *Redis) Delete(ctx context.Context, key string) error {
-	return r.client.Del(ctx, key).Err()
+	return r.clientFor(ctx).Del(ctx, key).Err()
 }
 
 func (r *Redis) Exists(ctx context.Context, key string) (bool, error) {
-	result, err := r.client.Exists(ctx, key).Result()
+	result, err := r.clientFor(ctx).Exists(ctx, key).Result()
 	return result > 0, err
 }
 
+// ExecCommand runs a raw Redis command, e.g. ["SET", "flag", "on"]
+func (r *Redis) ExecCommand(ctx context.Context, args []string) (string, error) {
+	if len(args) == 0 {
+		return "", fmt.Errorf("empty command")
+	}
+	cmdArgs := make([]interface{}, len(args))
+	for i, arg := range args {
+		cmdArgs[i] = arg
+	}
+	result, err := r.clientFor(ctx).Do(ctx, cmdArgs...).Result()
+	if err != nil && err != redis.Nil {
+		return "", err
+	}
+	return fmt.Sprintf("%v", result), nil
+}
+
 func (r *Redis) Cleanup(ctx context.Context) error {
+	r.workerMu.Lock()
+	for id, c := range r.workerClients {
+		c.Close()
+		delete(r.workerClients, id)
+	}
+	r.workerMu.Unlock()
+
 	if r.client != nil {
 		return r.client.Close()
 	}
@@ -553,3 +606,4 @@ func (r *Redis) Cleanup(ctx context.Context) error {
 
 var _ Handler = (*Redis)(nil)
 var _ CacheStore = (*Redis)(nil)
+var _ CommandExecutor = (*Redis)(nil)
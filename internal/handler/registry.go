@@ -2,6 +2,7 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 
@@ -9,12 +10,14 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/tomatool/tomato/internal/config"
 	"github.com/tomatool/tomato/internal/container"
+	"github.com/tomatool/tomato/internal/har"
 )
 
 // Registry manages all configured handlers
 type Registry struct {
 	handlers    map[string]Handler
 	resetConfig map[string]*bool // per-handler reset configuration
+	harConfig   map[string]bool  // per-handler HAR export (options.har)
 	container   *container.Manager
 	mu          sync.RWMutex
 }
@@ -24,6 +27,7 @@ func NewRegistry(configs map[string]config.Resource, cm *container.Manager) (*Re
 	r := &Registry{
 		handlers:    make(map[string]Handler),
 		resetConfig: make(map[string]*bool),
+		harConfig:   make(map[string]bool),
 		container:   cm,
 	}
 
@@ -34,6 +38,7 @@ func NewRegistry(configs map[string]config.Resource, cm *container.Manager) (*Re
 		}
 		r.handlers[name] = h
 		r.resetConfig[name] = cfg.Reset
+		r.harConfig[name], _ = cfg.Options["har"].(bool)
 	}
 
 	return r, nil
@@ -122,6 +127,48 @@ func (r *Registry) ResetAll(ctx context.Context) error {
 	return nil
 }
 
+// harExporter is implemented by handlers whose HTTP traffic can be exported as HAR
+type harExporter interface {
+	setHAR(rec *har.Recorder)
+}
+
+// SetHAR records the traffic of the HTTP handlers with options.har set, or
+// of all of them when all is true
+func (r *Registry) SetHAR(rec *har.Recorder, all bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for name, h := range r.handlers {
+		if e, ok := h.(harExporter); ok && (all || r.harConfig[name]) {
+			e.setHAR(rec)
+		}
+	}
+}
+
+// contractChecker is implemented by handlers that validate traffic they
+// receive against an OpenAPI contract
+type contractChecker interface {
+	takeContractViolations() error
+}
+
+// TakeContractViolations returns the contract violations handlers observed
+// since the last call, e.g. requests an HTTP server received that don't
+// match its spec, and forgets them
+func (r *Registry) TakeContractViolations() error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var errs []error
+	for _, h := range r.handlers {
+		if c, ok := h.(contractChecker); ok {
+			if err := c.takeContractViolations(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // RegisterSteps registers step definitions from all handlers
 func (r *Registry) RegisterSteps(ctx *godog.ScenarioContext) {
 	r.mu.RLock()
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"context"
+	"sync"
+)
+
+// ScenarioState holds state that belongs to a single scenario: captured
+// variables and per-handler request/response state. The runner attaches a
+// fresh ScenarioState to the context passed to hooks and steps, so scenarios
+// running in parallel do not overwrite each other.
+type ScenarioState struct {
+	Variables *Variables
+
+	mu     sync.Mutex
+	values map[string]any
+}
+
+type scenarioStateKey struct{}
+
+// NewScenarioState creates an empty scenario state
+func NewScenarioState() *ScenarioState {
+	return &ScenarioState{
+		Variables: NewVariables(),
+		values:    make(map[string]any),
+	}
+}
+
+// WithScenarioState returns a copy of ctx carrying the given scenario state
+func WithScenarioState(ctx context.Context, s *ScenarioState) context.Context {
+	return context.WithValue(ctx, scenarioStateKey{}, s)
+}
+
+// ScenarioStateFrom returns the scenario state carried by ctx, or nil
+func ScenarioStateFrom(ctx context.Context) *ScenarioState {
+	if ctx == nil {
+		return nil
+	}
+	s, _ := ctx.Value(scenarioStateKey{}).(*ScenarioState)
+	return s
+}
+
+// VariablesFrom returns the variable store of the scenario carried by ctx.
+// Outside of a scenario it falls back to the global store.
+func VariablesFrom(ctx context.Context) *Variables {
+	if s := ScenarioStateFrom(ctx); s != nil {
+		return s.Variables
+	}
+	return globalVariables
+}
+
+// Load returns the value stored under key, creating it with init on first use.
+// Handlers use it to keep their per-scenario state, keyed by resource name.
+func (s *ScenarioState) Load(key string, init func() any) any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := s.values[key]; ok {
+		return v
+	}
+	v := init()
+	s.values[key] = v
+	return v
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/cucumber/godog"
@@ -19,13 +20,20 @@ type Shell struct {
 	config    config.Resource
 	container *container.Manager
 
-	// State
+	env     map[string]string
+	workDir string
+	timeout time.Duration
+
+	// defaultState is used when a step runs outside of a scenario context.
+	// Parallel workers reset it while others read it.
+	defaultState atomic.Pointer[shellState]
+}
+
+// shellState is the per-scenario result of the last executed command
+type shellState struct {
 	lastExitCode int
 	lastStdout   string
 	lastStderr   string
-	env          map[string]string
-	workDir      string
-	timeout      time.Duration
 }
 
 func NewShell(name string, cfg config.Resource, cm *container.Manager) (*Shell, error) {
@@ -41,14 +49,16 @@ func NewShell(name string, cfg config.Resource, cm *container.Manager) (*Shell,
 		workDir = w
 	}
 
-	return &Shell{
+	r := &Shell{
 		name:      name,
 		config:    cfg,
 		container: cm,
 		env:       make(map[string]string),
 		workDir:   workDir,
 		timeout:   timeout,
-	}, nil
+	}
+	r.defaultState.Store(&shellState{})
+	return r, nil
 }
 
 func (r *Shell) Name() string { return r.name }
@@ -70,13 +80,19 @@ func (r *Shell) Ready(ctx context.Context) error {
 }
 
 func (r *Shell) Reset(ctx context.Context) error {
-	r.lastExitCode = 0
-	r.lastStdout = ""
-	r.lastStderr = ""
+	r.defaultState.Store(&shellState{})
 	// Keep env and workDir as configured
 	return nil
 }
 
+// state returns the command result state of the scenario carried by ctx
+func (r *Shell) state(ctx context.Context) *shellState {
+	if sc := ScenarioStateFrom(ctx); sc != nil {
+		return sc.Load("shell:"+r.name, func() any { return &shellState{} }).(*shellState)
+	}
+	return r.defaultState.Load()
+}
+
 func (r *Shell) RegisterSteps(ctx *godog.ScenarioContext) {
 	RegisterStepsToGodog(ctx, r.name, r.Steps())
 }
@@ -185,6 +201,13 @@ func (r *Shell) Steps() StepCategory {
 				Example:     `"shell" stdout is empty`,
 				Handler:     r.stdoutShouldBeEmpty,
 			},
+			{
+				Group:       "Output",
+				Pattern:     `^"{resource}" stdout matches snapshot "([^"]*)"$`,
+				Description: "Assert stdout equals a named snapshot, written on the first run or with --update-snapshots",
+				Example:     `"shell" stdout matches snapshot "cli/help"`,
+				Handler:     r.stdoutShouldMatchSnapshot,
+			},
 			{
 				Group:       "Output",
 				Pattern:     `^"{resource}" stderr contains "([^"]*)"$`,
@@ -240,35 +263,37 @@ func (r *Shell) setWorkDir(dir string) error {
 
 // Command execution
 
-func (r *Shell) runCommand(doc *godog.DocString) error {
-	return r.executeCommand(doc.Content, r.timeout)
+func (r *Shell) runCommand(ctx context.Context, doc *godog.DocString) error {
+	return r.executeCommand(ctx, doc.Content, r.timeout)
 }
 
-func (r *Shell) runCommandInline(command string) error {
-	return r.executeCommand(command, r.timeout)
+func (r *Shell) runCommandInline(ctx context.Context, command string) error {
+	return r.executeCommand(ctx, command, r.timeout)
 }
 
-func (r *Shell) runScript(path string) error {
+func (r *Shell) runScript(ctx context.Context, path string) error {
 	content, err := os.ReadFile(path)
 	if err != nil {
 		return fmt.Errorf("reading script: %w", err)
 	}
-	return r.executeCommand(string(content), r.timeout)
+	return r.executeCommand(ctx, string(content), r.timeout)
 }
 
-func (r *Shell) runCommandWithTimeout(timeout string, doc *godog.DocString) error {
+func (r *Shell) runCommandWithTimeout(ctx context.Context, timeout string, doc *godog.DocString) error {
 	d, err := time.ParseDuration(timeout)
 	if err != nil {
 		return fmt.Errorf("invalid timeout: %w", err)
 	}
-	return r.executeCommand(doc.Content, d)
+	return r.executeCommand(ctx, doc.Content, d)
 }
 
-func (r *Shell) executeCommand(command string, timeout time.Duration) error {
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+func (r *Shell) executeCommand(ctx context.Context, command string, timeout time.Duration) error {
+	s := r.state(ctx)
+
+	cmdCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd := exec.CommandContext(cmdCtx, "sh", "-c", command)
 
 	// Set working directory
 	if r.workDir != "" {
@@ -287,20 +312,20 @@ func (r *Shell) executeCommand(command string, timeout time.Duration) error {
 
 	err := cmd.Run()
 
-	r.lastStdout = stdout.String()
-	r.lastStderr = stderr.String()
+	s.lastStdout = stdout.String()
+	s.lastStderr = stderr.String()
 
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
-			r.lastExitCode = exitErr.ExitCode()
-		} else if ctx.Err() == context.DeadlineExceeded {
-			r.lastExitCode = -1
+			s.lastExitCode = exitErr.ExitCode()
+		} else if cmdCtx.Err() == context.DeadlineExceeded {
+			s.lastExitCode = -1
 			return fmt.Errorf("command timed out after %s", timeout)
 		} else {
-			r.lastExitCode = -1
+			s.lastExitCode = -1
 		}
 	} else {
-		r.lastExitCode = 0
+		s.lastExitCode = 0
 	}
 
 	return nil
@@ -308,67 +333,79 @@ func (r *Shell) executeCommand(command string, timeout time.Duration) error {
 
 // Exit code assertions
 
-func (r *Shell) exitCodeShouldBe(expected int) error {
-	if r.lastExitCode != expected {
+func (r *Shell) exitCodeShouldBe(ctx context.Context, expected int) error {
+	s := r.state(ctx)
+	if s.lastExitCode != expected {
 		return fmt.Errorf("expected exit code %d, got %d\nstdout: %s\nstderr: %s",
-			expected, r.lastExitCode, r.lastStdout, r.lastStderr)
+			expected, s.lastExitCode, s.lastStdout, s.lastStderr)
 	}
 	return nil
 }
 
-func (r *Shell) shouldSucceed() error {
-	return r.exitCodeShouldBe(0)
+func (r *Shell) shouldSucceed(ctx context.Context) error {
+	return r.exitCodeShouldBe(ctx, 0)
 }
 
-func (r *Shell) shouldFail() error {
-	if r.lastExitCode == 0 {
-		return fmt.Errorf("expected command to fail, but it succeeded\nstdout: %s", r.lastStdout)
+func (r *Shell) shouldFail(ctx context.Context) error {
+	s := r.state(ctx)
+	if s.lastExitCode == 0 {
+		return fmt.Errorf("expected command to fail, but it succeeded\nstdout: %s", s.lastStdout)
 	}
 	return nil
 }
 
 // Output assertions
 
-func (r *Shell) stdoutShouldContain(substr string) error {
-	if !strings.Contains(r.lastStdout, substr) {
-		return fmt.Errorf("stdout does not contain %q\nstdout: %s", substr, r.lastStdout)
+func (r *Shell) stdoutShouldContain(ctx context.Context, substr string) error {
+	s := r.state(ctx)
+	if !strings.Contains(s.lastStdout, substr) {
+		return fmt.Errorf("stdout does not contain %q\nstdout: %s", substr, s.lastStdout)
 	}
 	return nil
 }
 
-func (r *Shell) stdoutShouldNotContain(substr string) error {
-	if strings.Contains(r.lastStdout, substr) {
-		return fmt.Errorf("stdout should not contain %q\nstdout: %s", substr, r.lastStdout)
+func (r *Shell) stdoutShouldNotContain(ctx context.Context, substr string) error {
+	s := r.state(ctx)
+	if strings.Contains(s.lastStdout, substr) {
+		return fmt.Errorf("stdout should not contain %q\nstdout: %s", substr, s.lastStdout)
 	}
 	return nil
 }
 
-func (r *Shell) stdoutShouldBe(doc *godog.DocString) error {
+func (r *Shell) stdoutShouldBe(ctx context.Context, doc *godog.DocString) error {
+	s := r.state(ctx)
 	expected := strings.TrimSpace(doc.Content)
-	actual := strings.TrimSpace(r.lastStdout)
+	actual := strings.TrimSpace(s.lastStdout)
 	if actual != expected {
 		return fmt.Errorf("stdout mismatch\nexpected: %s\nactual: %s", expected, actual)
 	}
 	return nil
 }
 
-func (r *Shell) stdoutShouldBeEmpty() error {
-	if strings.TrimSpace(r.lastStdout) != "" {
-		return fmt.Errorf("expected empty stdout, got: %s", r.lastStdout)
+func (r *Shell) stdoutShouldBeEmpty(ctx context.Context) error {
+	s := r.state(ctx)
+	if strings.TrimSpace(s.lastStdout) != "" {
+		return fmt.Errorf("expected empty stdout, got: %s", s.lastStdout)
 	}
 	return nil
 }
 
-func (r *Shell) stderrShouldContain(substr string) error {
-	if !strings.Contains(r.lastStderr, substr) {
-		return fmt.Errorf("stderr does not contain %q\nstderr: %s", substr, r.lastStderr)
+func (r *Shell) stdoutShouldMatchSnapshot(ctx context.Context, name string) error {
+	return matchTextSnapshot(ctx, name, r.state(ctx).lastStdout)
+}
+
+func (r *Shell) stderrShouldContain(ctx context.Context, substr string) error {
+	s := r.state(ctx)
+	if !strings.Contains(s.lastStderr, substr) {
+		return fmt.Errorf("stderr does not contain %q\nstderr: %s", substr, s.lastStderr)
 	}
 	return nil
 }
 
-func (r *Shell) stderrShouldBeEmpty() error {
-	if strings.TrimSpace(r.lastStderr) != "" {
-		return fmt.Errorf("expected empty stderr, got: %s", r.lastStderr)
+func (r *Shell) stderrShouldBeEmpty(ctx context.Context) error {
+	s := r.state(ctx)
+	if strings.TrimSpace(s.lastStderr) != "" {
+		return fmt.Errorf("expected empty stderr, got: %s", s.lastStderr)
 	}
 	return nil
 }
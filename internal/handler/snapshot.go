@@ -0,0 +1,306 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cucumber/godog"
+	"github.com/rs/zerolog/log"
+)
+
+// SnapshotSettings tells where the snapshot files of a scenario are and
+// whether snapshot assertions rewrite them instead of failing
+type SnapshotSettings struct {
+	Dir    string // __snapshots__ next to the feature file
+	Update bool   // tomato run --update-snapshots
+}
+
+type snapshotKey struct{}
+
+// WithSnapshots returns a copy of ctx carrying the snapshot settings of a scenario
+func WithSnapshots(ctx context.Context, s SnapshotSettings) context.Context {
+	return context.WithValue(ctx, snapshotKey{}, s)
+}
+
+// snapshotsFrom returns the snapshot settings carried by ctx. Outside of a
+// scenario, snapshots are in __snapshots__ of the working directory.
+func snapshotsFrom(ctx context.Context) SnapshotSettings {
+	if ctx != nil {
+		if s, ok := ctx.Value(snapshotKey{}).(SnapshotSettings); ok {
+			return s
+		}
+	}
+	return SnapshotSettings{Dir: "__snapshots__"}
+}
+
+// snapshotPath returns the file of a named snapshot, e.g. orders/list is
+// __snapshots__/orders/list.json
+func snapshotPath(ctx context.Context, name, ext string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if name == "" || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid snapshot name %q", name)
+	}
+	return filepath.Join(snapshotsFrom(ctx).Dir, clean+ext), nil
+}
+
+// snapshotIgnore replaces the values a path selects with a matcher, for
+// volatile fields such as IDs and timestamps
+type snapshotIgnore struct {
+	path    *JSONPath
+	matcher string
+}
+
+// parseSnapshotIgnores reads a table of path and matcher columns
+func parseSnapshotIgnores(table *godog.Table) ([]snapshotIgnore, error) {
+	var ignores []snapshotIgnore
+	for _, row := range table.Rows[1:] {
+		if len(row.Cells) < 2 {
+			return nil, fmt.Errorf("ignore rules need a path and a matcher column")
+		}
+		path, err := ParseJSONPath(row.Cells[0].Value)
+		if err != nil {
+			return nil, err
+		}
+		matcher := row.Cells[1].Value
+		if !strings.HasPrefix(matcher, "@") {
+			return nil, fmt.Errorf("ignore rule for %q: %q is not a matcher, such as @string or @regex:pattern", path, matcher)
+		}
+		ignores = append(ignores, snapshotIgnore{path: path, matcher: matcher})
+	}
+	return ignores, nil
+}
+
+// apply replaces the values the rule selects in doc with its matcher, once
+// they match it
+func (ig snapshotIgnore) apply(doc any) (any, error) {
+	return ig.path.Replace(doc, func(v any) (any, error) {
+		if v == ig.matcher {
+			return v, nil
+		}
+		if err := MatchSpecial(ig.matcher, v, ig.path.String()); err != nil {
+			return nil, fmt.Errorf("ignored field: %w", err)
+		}
+		return ig.matcher, nil
+	})
+}
+
+// matchJSONSnapshot compares a JSON document to a named snapshot. Matchers in
+// the snapshot, like "@string", stand for any value they match. The snapshot
+// is written when it doesn't exist yet, or when it differs in update mode.
+func matchJSONSnapshot(ctx context.Context, name string, data []byte, ignores []snapshotIgnore) error {
+	var actual any
+	if err := json.Unmarshal(data, &actual); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	for _, ig := range ignores {
+		var err error
+		if actual, err = ig.apply(actual); err != nil {
+			return err
+		}
+	}
+
+	path, err := snapshotPath(ctx, name, ".json")
+	if err != nil {
+		return err
+	}
+	existing, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return writeSnapshot(path, formatSnapshotJSON(actual))
+	}
+	if err != nil {
+		return fmt.Errorf("reading snapshot: %w", err)
+	}
+	var expected any
+	if err := json.Unmarshal(existing, &expected); err != nil {
+		return fmt.Errorf("invalid snapshot %s: %w", path, err)
+	}
+	return compareSnapshot(ctx, path, formatSnapshotJSON(expected), formatSnapshotJSON(keepMatchers(expected, actual)))
+}
+
+// matchTextSnapshot compares a text to a named snapshot, like matchJSONSnapshot
+func matchTextSnapshot(ctx context.Context, name, text string) error {
+	path, err := snapshotPath(ctx, name, ".txt")
+	if err != nil {
+		return err
+	}
+	existing, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return writeSnapshot(path, text)
+	}
+	if err != nil {
+		return fmt.Errorf("reading snapshot: %w", err)
+	}
+	return compareSnapshot(ctx, path, string(existing), text)
+}
+
+func compareSnapshot(ctx context.Context, path, want, got string) error {
+	if want == got {
+		return nil
+	}
+	if snapshotsFrom(ctx).Update {
+		return writeSnapshot(path, got)
+	}
+	return fmt.Errorf("snapshot %s does not match (tomato run --update-snapshots accepts the changes):\n%s", path, lineDiff(want, got))
+}
+
+func writeSnapshot(path, content string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating snapshot directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("writing snapshot: %w", err)
+	}
+	log.Info().Str("snapshot", path).Msg("snapshot written")
+	return nil
+}
+
+// formatSnapshotJSON indents a document with sorted keys, so that snapshots
+// diff line by line
+func formatSnapshotJSON(v any) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+	return buf.String()
+}
+
+// keepMatchers returns actual with the values that match a matcher of the
+// snapshot replaced by that matcher, so they compare equal
+func keepMatchers(expected, actual any) any {
+	switch e := expected.(type) {
+	case string:
+		if strings.HasPrefix(e, "@") && MatchSpecial(e, actual, "") == nil {
+			return e
+		}
+	case map[string]any:
+		if a, ok := actual.(map[string]any); ok {
+			out := make(map[string]any, len(a))
+			for k, v := range a {
+				if ev, ok := e[k]; ok {
+					v = keepMatchers(ev, v)
+				}
+				out[k] = v
+			}
+			return out
+		}
+	case []any:
+		if a, ok := actual.([]any); ok {
+			out := make([]any, len(a))
+			for i, v := range a {
+				if i < len(e) {
+					v = keepMatchers(e[i], v)
+				}
+				out[i] = v
+			}
+			return out
+		}
+	}
+	return actual
+}
+
+// diffContext is the number of unchanged lines shown around a change
+const diffContext = 3
+
+// maxDiffCells bounds the work of the line diff; beyond it, the differing
+// middle of the texts is shown as removed and added as a whole
+const maxDiffCells = 4_000_000
+
+// lineDiff returns the lines of want that got removes (-) and adds (+),
+// with unchanged lines around them
+func lineDiff(want, got string) string {
+	a := strings.Split(strings.TrimSuffix(want, "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+
+	// Common prefix and suffix don't need the quadratic diff
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+
+	type line struct {
+		op   byte
+		text string
+	}
+	var lines []line
+	for _, l := range a[:pre] {
+		lines = append(lines, line{' ', l})
+	}
+	x, y := a[pre:len(a)-suf], b[pre:len(b)-suf]
+	if len(x)*len(y) > maxDiffCells {
+		for _, l := range x {
+			lines = append(lines, line{'-', l})
+		}
+		for _, l := range y {
+			lines = append(lines, line{'+', l})
+		}
+	} else {
+		// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:]
+		lcs := make([][]int, len(x)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(y)+1)
+		}
+		for i := len(x) - 1; i >= 0; i-- {
+			for j := len(y) - 1; j >= 0; j-- {
+				if x[i] == y[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < len(x) || j < len(y) {
+			switch {
+			case i < len(x) && j < len(y) && x[i] == y[j]:
+				lines = append(lines, line{' ', x[i]})
+				i, j = i+1, j+1
+			case j == len(y) || (i < len(x) && lcs[i+1][j] >= lcs[i][j+1]):
+				lines = append(lines, line{'-', x[i]})
+				i++
+			default:
+				lines = append(lines, line{'+', y[j]})
+				j++
+			}
+		}
+	}
+	for _, l := range a[len(a)-suf:] {
+		lines = append(lines, line{' ', l})
+	}
+
+	// Keep the unchanged lines near a change
+	keep := make([]bool, len(lines))
+	for i, l := range lines {
+		if l.op != ' ' {
+			for k := max(0, i-diffContext); k <= min(len(lines)-1, i+diffContext); k++ {
+				keep[k] = true
+			}
+		}
+	}
+	var sb strings.Builder
+	sb.WriteString("  --- snapshot\n  +++ actual\n")
+	skipped := false
+	for i, l := range lines {
+		if !keep[i] {
+			if !skipped {
+				sb.WriteString("  ...\n")
+				skipped = true
+			}
+			continue
+		}
+		skipped = false
+		fmt.Fprintf(&sb, "  %c %s\n", l.op, l.text)
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cucumber/godog"
+	messages "github.com/cucumber/messages/go/v21"
+	"github.com/tomatool/tomato/internal/config"
+)
+
+func ignoreTable(rows ...[2]string) *godog.Table {
+	table := &godog.Table{Rows: []*messages.PickleTableRow{{Cells: []*messages.PickleTableCell{{Value: "path"}, {Value: "matcher"}}}}}
+	for _, r := range rows {
+		table.Rows = append(table.Rows, &messages.PickleTableRow{Cells: []*messages.PickleTableCell{{Value: r[0]}, {Value: r[1]}}})
+	}
+	return table
+}
+
+func TestMatchJSONSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	ctx := WithSnapshots(context.Background(), SnapshotSettings{Dir: dir})
+	file := filepath.Join(dir, "orders", "list.json")
+
+	// The first run writes the snapshot
+	first := `{"items": [{"id": "a1", "sku": "X1", "qty": 2}], "total": 1}`
+	if err := matchJSONSnapshot(ctx, "orders/list", []byte(first), nil); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	written, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("snapshot not written: %v", err)
+	}
+	want := "{\n  \"items\": [\n    {\n      \"id\": \"a1\",\n      \"qty\": 2,\n      \"sku\": \"X1\"\n    }\n  ],\n  \"total\": 1\n}\n"
+	if string(written) != want {
+		t.Errorf("snapshot = %q, want %q", written, want)
+	}
+
+	if err := matchJSONSnapshot(ctx, "orders/list", []byte(`{"total": 1, "items": [{"sku": "X1", "qty": 2, "id": "a1"}]}`), nil); err != nil {
+		t.Errorf("same document in another key order: %v", err)
+	}
+
+	err = matchJSONSnapshot(ctx, "orders/list", []byte(`{"items": [{"id": "a1", "sku": "X1", "qty": 3}], "total": 1}`), nil)
+	if err == nil {
+		t.Fatal("expected a mismatch")
+	}
+	for _, line := range []string{"does not match", "--update-snapshots", `  -       "qty": 2,`, `  +       "qty": 3,`, `          "id": "a1",`} {
+		if !strings.Contains(err.Error(), line) {
+			t.Errorf("error %q does not contain %q", err, line)
+		}
+	}
+
+	// Matchers edited into the snapshot stand for any value they match
+	if err := os.WriteFile(file, []byte(`{"items": [{"id": "@string", "sku": "X1", "qty": "@gt:0"}], "total": 1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := matchJSONSnapshot(ctx, "orders/list", []byte(`{"items": [{"id": "b2", "sku": "X1", "qty": 5}], "total": 1}`), nil); err != nil {
+		t.Errorf("matchers in the snapshot: %v", err)
+	}
+	if err := matchJSONSnapshot(ctx, "orders/list", []byte(`{"items": [{"id": 7, "sku": "X1", "qty": 5}], "total": 1}`), nil); err == nil {
+		t.Error("expected a mismatch for a value the matcher doesn't match")
+	}
+
+	// Update mode rewrites the snapshot and keeps its matchers
+	update := WithSnapshots(context.Background(), SnapshotSettings{Dir: dir, Update: true})
+	if err := matchJSONSnapshot(update, "orders/list", []byte(`{"items": [{"id": "c3", "sku": "X2", "qty": 1}], "total": 1}`), nil); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	written, _ = os.ReadFile(file)
+	if !strings.Contains(string(written), `"id": "@string"`) || !strings.Contains(string(written), `"sku": "X2"`) {
+		t.Errorf("updated snapshot = %s", written)
+	}
+}
+
+func TestMatchJSONSnapshot_Ignoring(t *testing.T) {
+	ctx := WithSnapshots(context.Background(), SnapshotSettings{Dir: t.TempDir()})
+	ignores, err := parseSnapshotIgnores(ignoreTable(
+		[2]string{"items[*].id", "@regex:^[0-9a-f]{8}$"},
+		[2]string{"created_at", "@string"},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := `{"created_at": "2024-01-15T10:30:00Z", "items": [{"id": "0badf00d"}, {"id": "deadbeef"}]}`
+	if err := matchJSONSnapshot(ctx, "ids", []byte(first), ignores); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	second := `{"created_at": "2024-02-01T08:00:00Z", "items": [{"id": "12345678"}, {"id": "abcdef01"}]}`
+	if err := matchJSONSnapshot(ctx, "ids", []byte(second), ignores); err != nil {
+		t.Errorf("ignored fields changed: %v", err)
+	}
+	err = matchJSONSnapshot(ctx, "ids", []byte(`{"created_at": "x", "items": [{"id": "not-hex"}]}`), ignores)
+	if err == nil || !strings.Contains(err.Error(), "ignored field") {
+		t.Errorf("error = %v, want the ignored field that doesn't match its matcher", err)
+	}
+
+	if _, err := parseSnapshotIgnores(ignoreTable([2]string{"id", "uuid"})); err == nil {
+		t.Error("expected an error for a rule without a matcher")
+	}
+}
+
+func TestPostgres_SnapshotIgnores(t *testing.T) {
+	db, _ := NewPostgres("db", config.Resource{}, nil)
+	ctx := WithScenarioState(WithSnapshots(context.Background(), SnapshotSettings{Dir: t.TempDir()}), NewScenarioState())
+	if err := db.ignoreInSnapshot(ctx, "orders", ignoreTable([2]string{"[*].id", "@number"}, [2]string{"[*].created_at", "@string"})); err != nil {
+		t.Fatal(err)
+	}
+
+	// Query results are arrays of rows
+	ignores := db.state(ctx).snapshotIgnores["orders"]
+	first := `[{"id": 1, "status": "paid", "created_at": "2024-01-15T10:30:00Z"}]`
+	if err := matchJSONSnapshot(ctx, "orders", []byte(first), ignores); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	second := `[{"id": 42, "status": "paid", "created_at": "2024-02-01T08:00:00Z"}]`
+	if err := matchJSONSnapshot(ctx, "orders", []byte(second), ignores); err != nil {
+		t.Errorf("ignored columns changed: %v", err)
+	}
+	if other := db.state(ctx).snapshotIgnores["other"]; other != nil {
+		t.Errorf("rules of another snapshot = %v, want none", other)
+	}
+}
+
+func TestMatchTextSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	ctx := WithSnapshots(context.Background(), SnapshotSettings{Dir: dir})
+
+	if err := matchTextSnapshot(ctx, "cli/help", "usage: app\n  -v  verbose\n"); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	if err := matchTextSnapshot(ctx, "cli/help", "usage: app\n  -v  verbose\n"); err != nil {
+		t.Errorf("same text: %v", err)
+	}
+	err := matchTextSnapshot(ctx, "cli/help", "usage: app\n  -q  quiet\n")
+	if err == nil || !strings.Contains(err.Error(), "  -   -v  verbose\n  +   -q  quiet") {
+		t.Errorf("error = %v, want a diff", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cli", "help.txt")); err != nil {
+		t.Errorf("snapshot not written: %v", err)
+	}
+
+	if err := matchTextSnapshot(ctx, "../outside", "x"); err == nil {
+		t.Error("expected an error for a snapshot outside of the snapshot directory")
+	}
+}
+
+func TestLineDiff(t *testing.T) {
+	want := strings.Join([]string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"}, "\n")
+	got := strings.Join([]string{"a", "b", "c", "d", "e", "F", "g", "h", "i", "j", "k"}, "\n")
+
+	diff := lineDiff(want, got)
+	expected := strings.Join([]string{
+		"  --- snapshot",
+		"  +++ actual",
+		"  ...",
+		"    c",
+		"    d",
+		"    e",
+		"  - f",
+		"  + F",
+		"    g",
+		"    h",
+		"    i",
+		"    j",
+		"  + k",
+	}, "\n")
+	if diff != expected {
+		t.Errorf("lineDiff() =\n%s\nwant\n%s", diff, expected)
+	}
+}
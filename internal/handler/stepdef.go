@@ -1,9 +1,14 @@
 package handler
 
 import (
+	"context"
+	"fmt"
+	"reflect"
 	"strings"
+	"time"
 
 	"github.com/cucumber/godog"
+	messages "github.com/cucumber/messages/go/v21"
 	"github.com/tomatool/tomato/internal/config"
 )
 
@@ -79,12 +84,138 @@ type StepProvider interface {
 	Steps() StepCategory
 }
 
-// RegisterStepsToGodog registers steps from a StepCategory to godog, replacing {resource} placeholder
+// RegisterStepsToGodog registers steps from a StepCategory to godog, replacing {resource} placeholder.
+// Every assertion step is also registered with a `within "5s"` suffix that
+// polls the assertion until it passes or the duration expires.
 func RegisterStepsToGodog(ctx *godog.ScenarioContext, resourceName string, category StepCategory) {
 	for _, step := range category.Steps {
 		pattern := strings.ReplaceAll(step.Pattern, "{resource}", resourceName)
-		ctx.Step(pattern, step.Handler)
+		assertion := step.IsAssertion()
+		ctx.Step(pattern, wrapStep(step.Handler, assertion, StepMatch{Pattern: pattern, Resource: resourceName}))
+		if assertion && !strings.Contains(pattern, "within") {
+			within := withinPattern(pattern)
+			ctx.Step(within, wrapWithin(step.Handler, StepMatch{Pattern: within, Resource: resourceName}))
+		}
+	}
+}
+
+// IsAssertion reports whether the step checks live resource state and can be
+// polled. Assertions on a recorded HTTP response or command output are not
+// included, as retrying them cannot change the outcome.
+func (s StepDef) IsAssertion() bool {
+	return strings.Contains(s.Group, "Assertions") || s.Group == "Verification"
+}
+
+// withinPattern adds a `within "<duration>"` suffix to a step pattern,
+// keeping a trailing colon for steps that take a DocString or table
+func withinPattern(pattern string) string {
+	const suffix = ` within "([^"]*)"`
+	if strings.HasSuffix(pattern, ":$") {
+		return strings.TrimSuffix(pattern, ":$") + suffix + ":$"
+	}
+	return strings.TrimSuffix(pattern, "$") + suffix + "$"
+}
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	stringType  = reflect.TypeOf("")
+)
+
+// wrapStep adapts a step handler so it always receives the step context and
+// runs under the step deadline. {{variable}} placeholders in string arguments,
+// DocStrings and table cells are substituted before the handler is called.
+// Assertions are polled when the scenario is tagged @eventually.
+// The wrapper keeps the handler's argument types, so godog converts step
+// arguments exactly as for the handler itself. Handlers that don't return a
+// single error are registered unchanged. When it runs, the step records match
+// as the step definition it was matched to (see WithStepMatch). The error of a
+// failed step carries the transcript of its resource (see WithTranscribers).
+func wrapStep(fn interface{}, assertion bool, match StepMatch) interface{} {
+	return makeStep(fn, assertion, false, match)
+}
+
+// wrapWithin is like wrapStep, but the wrapped step takes an extra duration
+// argument (before a DocString or table) and polls the handler for that long
+func wrapWithin(fn interface{}, match StepMatch) interface{} {
+	return makeStep(fn, true, true, match)
+}
+
+func makeStep(fn interface{}, assertion, within bool, match StepMatch) interface{} {
+	v := reflect.ValueOf(fn)
+	t := v.Type()
+	if t.Kind() != reflect.Func || t.NumOut() != 1 || t.Out(0) != errorType {
+		return fn
+	}
+
+	hasCtx := t.NumIn() > 0 && t.In(0) == contextType
+	var params []reflect.Type
+	for i := 0; i < t.NumIn(); i++ {
+		if i == 0 && hasCtx {
+			continue
+		}
+		params = append(params, t.In(i))
+	}
+
+	// The duration comes last among the regex arguments, before a DocString or table
+	withinAt := -1
+	in := append([]reflect.Type{contextType}, params...)
+	if within {
+		withinAt = len(params)
+		if n := len(params); n > 0 && (params[n-1] == docStringType || params[n-1] == tableType) {
+			withinAt = n - 1
+		}
+		in = append([]reflect.Type{contextType}, params[:withinAt]...)
+		in = append(in, stringType)
+		in = append(in, params[withinAt:]...)
+	}
+	wrapped := reflect.FuncOf(in, []reflect.Type{errorType}, false)
+
+	return reflect.MakeFunc(wrapped, func(args []reflect.Value) []reflect.Value {
+		ctx, _ := args[0].Interface().(context.Context)
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		recordStepMatch(ctx, match)
+		stepArgs := args[1:]
+
+		var err error
+		var timeout time.Duration
+		if within {
+			raw := VariablesFrom(ctx).Replace(stepArgs[withinAt].String())
+			stepArgs = append(append([]reflect.Value{}, stepArgs[:withinAt]...), stepArgs[withinAt+1:]...)
+			if timeout, err = time.ParseDuration(raw); err != nil {
+				return errorResult(fmt.Errorf("invalid duration %q: %w", raw, err))
+			}
+		} else if assertion {
+			timeout = EventuallyTimeout(ctx)
+		}
+
+		call := func(ctx context.Context) error {
+			callArgs := interpolateArgs(VariablesFrom(ctx), stepArgs)
+			if hasCtx {
+				callArgs = append([]reflect.Value{reflect.ValueOf(ctx)}, callArgs...)
+			}
+			out := v.Call(callArgs)
+			err, _ := out[0].Interface().(error)
+			return err
+		}
+		err = runWithDeadline(ctx, func(ctx context.Context) error {
+			if timeout > 0 {
+				return poll(ctx, timeout, call)
+			}
+			return call(ctx)
+		})
+		return errorResult(withTranscript(ctx, match.Resource, err))
+	}).Interface()
+}
+
+func errorResult(err error) []reflect.Value {
+	result := reflect.New(errorType).Elem()
+	if err != nil {
+		result.Set(reflect.ValueOf(err))
 	}
+	return []reflect.Value{result}
 }
 
 // FormatStepPattern replaces {resource} placeholder with the actual resource name
@@ -104,3 +235,41 @@ func DummyConfig() config.Resource {
 		Options: make(map[string]any),
 	}
 }
+
+var (
+	docStringType = reflect.TypeOf((*godog.DocString)(nil))
+	tableType     = reflect.TypeOf((*godog.Table)(nil))
+)
+
+// interpolateArgs returns a copy of step arguments with {{variable}}
+// placeholders replaced. DocStrings and tables are copied rather than
+// modified, since godog reuses them when a scenario runs again.
+func interpolateArgs(vars *Variables, args []reflect.Value) []reflect.Value {
+	out := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		out[i] = arg
+		switch {
+		case arg.Kind() == reflect.String:
+			out[i] = reflect.ValueOf(vars.Replace(arg.String())).Convert(arg.Type())
+		case arg.Type() == docStringType && !arg.IsNil():
+			doc := *arg.Interface().(*godog.DocString)
+			doc.Content = vars.Replace(doc.Content)
+			out[i] = reflect.ValueOf(&doc)
+		case arg.Type() == tableType && !arg.IsNil():
+			out[i] = reflect.ValueOf(replaceTable(vars, arg.Interface().(*godog.Table)))
+		}
+	}
+	return out
+}
+
+func replaceTable(vars *Variables, table *godog.Table) *godog.Table {
+	rows := make([]*messages.PickleTableRow, len(table.Rows))
+	for i, row := range table.Rows {
+		cells := make([]*messages.PickleTableCell, len(row.Cells))
+		for j, cell := range row.Cells {
+			cells[j] = &messages.PickleTableCell{Value: vars.Replace(cell.Value)}
+		}
+		rows[i] = &messages.PickleTableRow{Cells: cells}
+	}
+	return &godog.Table{Rows: rows}
+}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cucumber/godog"
+	messages "github.com/cucumber/messages/go/v21"
+)
+
+func TestWrapStepInterpolation(t *testing.T) {
+	state := NewScenarioState()
+	state.Variables.Set("order_id", "42")
+	ctx := WithScenarioState(context.Background(), state)
+
+	var gotKey, gotDoc string
+	var gotTable [][]string
+	step := func(ctx context.Context, key string, count int, doc *godog.DocString) error {
+		gotKey, gotDoc = key, doc.Content
+		return nil
+	}
+	tableStep := func(table *godog.Table) error {
+		for _, row := range table.Rows {
+			var cells []string
+			for _, cell := range row.Cells {
+				cells = append(cells, cell.Value)
+			}
+			gotTable = append(gotTable, cells)
+		}
+		return nil
+	}
+
+	doc := &godog.DocString{Content: `{"id": "{{order_id}}", "missing": "{{unknown}}"}`}
+	wrapped := wrapStep(step, false, StepMatch{}).(func(context.Context, string, int, *godog.DocString) error)
+	if err := wrapped(ctx, "order:{{order_id}}", 1, doc); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotKey != "order:42" {
+		t.Errorf("expected key %q, got %q", "order:42", gotKey)
+	}
+	if want := `{"id": "42", "missing": "{{unknown}}"}`; gotDoc != want {
+		t.Errorf("expected doc %q, got %q", want, gotDoc)
+	}
+	if doc.Content != `{"id": "{{order_id}}", "missing": "{{unknown}}"}` {
+		t.Errorf("original DocString was modified: %q", doc.Content)
+	}
+
+	table := &godog.Table{Rows: []*messages.PickleTableRow{
+		{Cells: []*messages.PickleTableCell{{Value: "id"}, {Value: "status"}}},
+		{Cells: []*messages.PickleTableCell{{Value: "{{order_id}}"}, {Value: "paid"}}},
+	}}
+	wrappedTable := wrapStep(tableStep, false, StepMatch{}).(func(context.Context, *godog.Table) error)
+	if err := wrappedTable(ctx, table); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotTable[1][0] != "42" || gotTable[1][1] != "paid" {
+		t.Errorf("expected interpolated row [42 paid], got %v", gotTable[1])
+	}
+	if table.Rows[1].Cells[0].Value != "{{order_id}}" {
+		t.Errorf("original table was modified: %q", table.Rows[1].Cells[0].Value)
+	}
+}
+
+func TestWrapStepRecordsMatch(t *testing.T) {
+	match := StepMatch{Pattern: `^"api" sends "([^"]*)"$`, Resource: "api"}
+	wrapped := wrapStep(func(string) error { return nil }, false, match).(func(context.Context, string) error)
+
+	if _, ok := StepMatchFrom(context.Background()); ok {
+		t.Fatal("StepMatchFrom should report no match without a holder")
+	}
+
+	ctx := WithStepMatch(context.Background(), &StepMatch{})
+	if err := wrapped(ctx, "GET"); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := StepMatchFrom(ctx); !ok || got != match {
+		t.Errorf("StepMatchFrom() = %+v, %v, want %+v", got, ok, match)
+	}
+}
+
+// transcriber is a handler that keeps a fixed transcript
+type transcriber struct {
+	Handler
+	transcript string
+}
+
+func (t transcriber) Transcript(ctx context.Context) string { return t.transcript }
+
+func TestWrapStepAppendsTranscript(t *testing.T) {
+	get := func(resource string) (Handler, error) {
+		if resource != "api" {
+			return nil, errors.New("not found")
+		}
+		return transcriber{transcript: "GET /users → 500"}, nil
+	}
+	ctx := WithTranscribers(context.Background(), get)
+
+	tests := []struct {
+		name     string
+		resource string
+		err      error
+		want     bool
+	}{
+		{"failed step", "api", errors.New("expected status 200, got 500"), true},
+		{"passed step", "api", nil, false},
+		{"skipped step", "api", godog.ErrSkip, false},
+		{"resource without transcript", "db", errors.New("boom"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match := StepMatch{Pattern: "^step$", Resource: tt.resource}
+			wrapped := wrapStep(func() error { return tt.err }, false, match).(func(context.Context) error)
+
+			err := wrapped(ctx)
+			var te *TranscriptError
+			if got := errors.As(err, &te); got != tt.want {
+				t.Fatalf("error %v has transcript = %v, want %v", err, got, tt.want)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("error %v should wrap %v", err, tt.err)
+			}
+			if tt.want && !strings.Contains(err.Error(), "api transcript:\nGET /users → 500") {
+				t.Errorf("error should show the transcript, got %q", err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+type stepTimeoutKey struct{}
+
+// WithStepTimeout returns a copy of ctx carrying the deadline applied to every step
+func WithStepTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, stepTimeoutKey{}, d)
+}
+
+// StepTimeout returns the step deadline carried by ctx, or 0 when steps are not limited
+func StepTimeout(ctx context.Context) time.Duration {
+	if ctx == nil {
+		return 0
+	}
+	d, _ := ctx.Value(stepTimeoutKey{}).(time.Duration)
+	return d
+}
+
+// stepGracePeriod is how long a step that reached its deadline gets to
+// return, so that it doesn't change handler state during the next step
+var stepGracePeriod = 5 * time.Second
+
+// runWithDeadline runs a step under the step deadline carried by ctx.
+// The step runs in its own goroutine so that handlers blocking without
+// honoring ctx (e.g. a consumer waiting for a message) still fail once
+// the step, scenario or run deadline is reached. The error returned then
+// is the cause attached to the expired context, once the step returned or
+// stepGracePeriod passed.
+func runWithDeadline(ctx context.Context, step func(context.Context) error) error {
+	if d := StepTimeout(ctx); d > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, d, fmt.Errorf("step timed out after %s (settings.step_timeout)", d))
+		defer cancel()
+	}
+
+	if ctx.Done() == nil {
+		return step(ctx)
+	}
+	if err := ctx.Err(); err != nil {
+		return context.Cause(ctx)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- fmt.Errorf("step panicked: %v", p)
+			}
+		}()
+		done <- step(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+
+	cause := context.Cause(ctx)
+	select {
+	case <-done:
+	case <-time.After(stepGracePeriod):
+		log.Warn().Err(cause).Dur("grace_period", stepGracePeriod).
+			Msg("step still running after its deadline; it may change resource state during the next steps")
+	}
+	return cause
+}
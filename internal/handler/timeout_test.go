@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWrapStepTimeout(t *testing.T) {
+	defer func(d time.Duration) { stepGracePeriod = d }(stepGracePeriod)
+	stepGracePeriod = 10 * time.Millisecond
+
+	block := make(chan struct{})
+	defer close(block)
+
+	tests := []struct {
+		name    string
+		step    interface{}
+		timeout time.Duration
+		wantErr string
+	}{
+		{
+			name:    "step finishing in time",
+			step:    func(s string) error { return nil },
+			timeout: time.Second,
+		},
+		{
+			name:    "step error is returned",
+			step:    func(ctx context.Context, s string) error { return errors.New("boom") },
+			timeout: time.Second,
+			wantErr: "boom",
+		},
+		{
+			name:    "step ignoring ctx times out",
+			step:    func(s string) error { <-block; return nil },
+			timeout: 20 * time.Millisecond,
+			wantErr: "step timed out after 20ms",
+		},
+		{
+			name: "step honoring ctx times out",
+			step: func(ctx context.Context, s string) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+			timeout: 20 * time.Millisecond,
+			wantErr: "step timed out after 20ms",
+		},
+		{
+			name:    "panic becomes an error",
+			step:    func(s string) error { panic("oops") },
+			timeout: time.Second,
+			wantErr: "step panicked: oops",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wrapped, ok := wrapStep(tt.step, false, StepMatch{}).(func(context.Context, string) error)
+			if !ok {
+				t.Fatalf("unexpected wrapped type %T", wrapStep(tt.step, false, StepMatch{}))
+			}
+
+			err := wrapped(WithStepTimeout(context.Background(), tt.timeout), "arg")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestWrapStepWaitsForTimedOutStep(t *testing.T) {
+	// A step honoring ctx has returned by the time the next step starts
+	returned := make(chan struct{})
+	wrapped := wrapStep(func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(20 * time.Millisecond)
+		close(returned)
+		return ctx.Err()
+	}, false, StepMatch{}).(func(context.Context) error)
+
+	if err := wrapped(WithStepTimeout(context.Background(), 10*time.Millisecond)); err == nil {
+		t.Fatal("expected a timeout")
+	}
+	select {
+	case <-returned:
+	default:
+		t.Error("the step was still running when its timeout was returned")
+	}
+}
+
+func TestWrapStepScenarioDeadline(t *testing.T) {
+	defer func(d time.Duration) { stepGracePeriod = d }(stepGracePeriod)
+	stepGracePeriod = 10 * time.Millisecond
+
+	block := make(chan struct{})
+	defer close(block)
+
+	ctx, cancel := context.WithTimeoutCause(context.Background(), 20*time.Millisecond, errors.New("scenario timed out after 20ms"))
+	defer cancel()
+	ctx = WithStepTimeout(ctx, time.Minute)
+
+	wrapped := wrapStep(func() error { <-block; return nil }, false, StepMatch{}).(func(context.Context) error)
+	if err := wrapped(ctx); err == nil || err.Error() != "scenario timed out after 20ms" {
+		t.Fatalf("expected scenario deadline error, got %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tomatool/tomato/internal/config"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// loadTLSConfig builds the client TLS config of options.tls: a CA to trust
+// besides the system ones, a client certificate for mTLS, the server name to
+// verify, whether to skip verification and the minimum TLS version. It
+// returns nil when the option isn't set.
+func loadTLSConfig(cfg config.Resource) (*tls.Config, error) {
+	raw, ok := cfg.Options["tls"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	opts, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("options.tls must be a map")
+	}
+	str := func(key string) string {
+		s, _ := opts[key].(string)
+		return s
+	}
+
+	c := &tls.Config{ServerName: str("server_name")}
+	c.InsecureSkipVerify, _ = opts["insecure_skip_verify"].(bool)
+
+	if caFile := str("ca_file"); caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("options.tls.ca_file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("options.tls.ca_file: no PEM certificates in %s", caFile)
+		}
+		c.RootCAs = pool
+	}
+
+	certFile, keyFile := str("cert_file"), str("key_file")
+	if (certFile == "") != (keyFile == "") {
+		return nil, fmt.Errorf("options.tls: cert_file and key_file go together")
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("options.tls: loading client certificate: %w", err)
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+
+	if v := opts["min_version"]; v != nil {
+		// YAML reads an unquoted 1.2 as a number, and 1.0 as the number 1
+		name := fmt.Sprint(v)
+		switch n := v.(type) {
+		case float64:
+			if name = strconv.FormatFloat(n, 'f', -1, 64); !strings.Contains(name, ".") {
+				name += ".0"
+			}
+		case int:
+			name = strconv.Itoa(n) + ".0"
+		}
+		version, ok := tlsVersions[strings.TrimPrefix(name, "TLS")]
+		if !ok {
+			return nil, fmt.Errorf("options.tls.min_version: unknown TLS version %v (1.0, 1.1, 1.2, 1.3)", v)
+		}
+		c.MinVersion = version
+	}
+	return c, nil
+}
+
+// tlsHandshakeError explains why the TLS handshake with host failed and how
+// to fix it. Other errors are returned as they are.
+func tlsHandshakeError(err error, host string) error {
+	if err == nil {
+		return nil
+	}
+	var (
+		unknownAuthority x509.UnknownAuthorityError
+		hostname         x509.HostnameError
+		invalid          x509.CertificateInvalidError
+		recordHeader     tls.RecordHeaderError
+	)
+	var hint string
+	switch msg := err.Error(); {
+	case errors.As(err, &unknownAuthority):
+		hint = "the server certificate is signed by an unknown authority"
+		if unknownAuthority.Cert != nil {
+			hint += fmt.Sprintf(" (%s)", unknownAuthority.Cert.Issuer)
+		}
+		hint += "; set options.tls.ca_file to the CA that signed it, or options.tls.insecure_skip_verify for a self-signed certificate"
+	case errors.As(err, &hostname):
+		names := slices.Clone(hostname.Certificate.DNSNames)
+		for _, ip := range hostname.Certificate.IPAddresses {
+			names = append(names, ip.String())
+		}
+		hint = fmt.Sprintf("the server certificate is valid for %s, not %s; set options.tls.server_name to one of them",
+			strings.Join(names, ", "), hostname.Host)
+	case errors.As(err, &invalid) && invalid.Reason == x509.Expired:
+		hint = fmt.Sprintf("the server certificate is only valid from %s to %s",
+			invalid.Cert.NotBefore.Format(time.RFC3339), invalid.Cert.NotAfter.Format(time.RFC3339))
+	case errors.As(err, &recordHeader), strings.Contains(msg, "server gave HTTP response to HTTPS client"):
+		hint = "the server did not answer with TLS; use http:// or ws:// for a server without TLS"
+	case strings.Contains(msg, "tls: certificate required"):
+		hint = "the server requires a client certificate; set options.tls.cert_file and key_file"
+	case strings.Contains(msg, "tls: bad certificate"), strings.Contains(msg, "tls: unknown certificate authority"):
+		hint = "the server rejected the client certificate of options.tls.cert_file"
+	case strings.Contains(msg, "tls: protocol version not supported"):
+		hint = "the server and options.tls.min_version have no TLS version in common"
+	default:
+		return err
+	}
+	return fmt.Errorf("TLS handshake with %s failed: %s: %w", host, hint, err)
+}
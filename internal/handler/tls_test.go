@@ -0,0 +1,214 @@
+package handler
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/tomatool/tomato/internal/config"
+)
+
+// writePEM writes PEM blocks to a file of a temporary directory
+func writePEM(t *testing.T, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// clientCertificate creates a self-signed client certificate and returns it
+// with the paths of its certificate and key files
+func clientCertificate(t *testing.T) (*x509.Certificate, string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "tomato"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, writePEM(t, "client.pem", "CERTIFICATE", der), writePEM(t, "client-key.pem", "EC PRIVATE KEY", keyDER)
+}
+
+func TestLoadTLSConfig(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	caFile := writePEM(t, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+	_, certFile, keyFile := clientCertificate(t)
+
+	tests := []struct {
+		name    string
+		tls     any
+		wantErr string
+	}{
+		{name: "all options", tls: map[string]any{
+			"ca_file": caFile, "cert_file": certFile, "key_file": keyFile,
+			"server_name": "example.com", "min_version": 1.2,
+		}},
+		{name: "quoted version", tls: map[string]any{"min_version": "TLS1.3"}},
+		{name: "unquoted 1.0", tls: map[string]any{"min_version": 1.0}},
+		{name: "integer version", tls: map[string]any{"min_version": 1}},
+		{name: "unquoted unknown version", tls: map[string]any{"min_version": 1.25}, wantErr: "unknown TLS version 1.25"},
+		{name: "not a map", tls: true, wantErr: "must be a map"},
+		{name: "missing CA", tls: map[string]any{"ca_file": "missing.pem"}, wantErr: "options.tls.ca_file"},
+		{name: "CA without certificates", tls: map[string]any{"ca_file": keyFile}, wantErr: "no PEM certificates"},
+		{name: "cert without key", tls: map[string]any{"cert_file": certFile}, wantErr: "cert_file and key_file go together"},
+		{name: "mismatched key pair", tls: map[string]any{"cert_file": caFile, "key_file": keyFile}, wantErr: "loading client certificate"},
+		{name: "unknown version", tls: map[string]any{"min_version": "1.4"}, wantErr: "unknown TLS version 1.4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadTLSConfig(config.Resource{Options: map[string]any{"tls": tt.tls}})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHTTPClient_TLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	caFile := writePEM(t, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+
+	plain := httptest.NewServer(http.NotFoundHandler())
+	defer plain.Close()
+
+	clientCert, certFile, keyFile := clientCertificate(t)
+	mtls := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+	mtls.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	mtls.StartTLS()
+	defer mtls.Close()
+
+	tests := []struct {
+		name    string
+		baseURL string
+		tls     map[string]any
+		wantErr string
+	}{
+		{name: "system CAs only", baseURL: server.URL, wantErr: "signed by an unknown authority (O=Acme Co); set options.tls.ca_file"},
+		{name: "private CA", baseURL: server.URL, tls: map[string]any{"ca_file": caFile}},
+		{name: "self-signed", baseURL: server.URL, tls: map[string]any{"insecure_skip_verify": true}},
+		{name: "server name", baseURL: server.URL, tls: map[string]any{"ca_file": caFile, "server_name": "example.com"}},
+		{
+			name:    "wrong server name",
+			baseURL: server.URL,
+			tls:     map[string]any{"ca_file": caFile, "server_name": "api.internal"},
+			wantErr: "valid for example.com, *.example.com, 127.0.0.1, ::1, not api.internal; set options.tls.server_name",
+		},
+		{name: "plain HTTP", baseURL: strings.Replace(plain.URL, "http://", "https://", 1), tls: map[string]any{}, wantErr: "did not answer with TLS"},
+		{
+			name:    "missing client certificate",
+			baseURL: mtls.URL,
+			tls:     map[string]any{"insecure_skip_verify": true},
+			wantErr: "requires a client certificate; set options.tls.cert_file and key_file",
+		},
+		{
+			name:    "client certificate",
+			baseURL: mtls.URL,
+			tls:     map[string]any{"insecure_skip_verify": true, "cert_file": certFile, "key_file": keyFile},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := map[string]any{}
+			if tt.tls != nil {
+				options["tls"] = tt.tls
+			}
+			client := newTestHTTPClient(t, tt.baseURL, options)
+			ctx := WithScenarioState(context.Background(), NewScenarioState())
+			err := client.sendRequest(ctx, "GET", "/")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWebSocketClient_TLS(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.ReadMessage()
+	}))
+	defer server.Close()
+	caFile := writePEM(t, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+	url := strings.Replace(server.URL, "https://", "wss://", 1)
+
+	for _, tt := range []struct {
+		name    string
+		options map[string]any
+		wantErr string
+	}{
+		{name: "private CA", options: map[string]any{"tls": map[string]any{"ca_file": caFile}}},
+		{name: "system CAs only", wantErr: "TLS handshake with " + url + " failed: the server certificate is signed by an unknown authority"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := NewWebSocketClient("ws", config.Resource{URL: url, Options: tt.options}, nil)
+			if err := client.Init(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			defer client.Cleanup(context.Background())
+			err := client.connect()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cucumber/godog"
+	"github.com/tomatool/tomato/internal/config"
+	"github.com/tomatool/tomato/internal/har"
+)
+
+// Transcripts show the last exchanges of a resource when one of its steps
+// fails: the requests and responses of an HTTP client, the calls recorded by
+// an HTTP server or the messages consumed from a topic, queue or socket.
+// Handlers that implement Transcriber keep them, and the number of exchanges
+// is set with options.transcript (0 disables them).
+
+// defaultTranscriptSize is the number of exchanges kept unless set with options.transcript
+const defaultTranscriptSize = 5
+
+// maxTranscriptBody is the number of bytes of a body shown in a transcript
+const maxTranscriptBody = 4096
+
+// Transcriber is implemented by handlers that keep a transcript of their recent exchanges
+type Transcriber interface {
+	// Transcript returns the last exchanges seen in the scenario carried by
+	// ctx, or "" if there were none
+	Transcript(ctx context.Context) string
+}
+
+// TranscriptError is the error of a failed step with the transcript of the
+// step's resource appended
+type TranscriptError struct {
+	Err        error
+	Resource   string
+	Transcript string
+}
+
+func (e *TranscriptError) Error() string {
+	return fmt.Sprintf("%v\n\n%s transcript:\n%s", e.Err, e.Resource, e.Transcript)
+}
+
+func (e *TranscriptError) Unwrap() error { return e.Err }
+
+type transcribersKey struct{}
+
+// WithTranscribers returns a copy of ctx in which failing steps look up the
+// handler of their resource with get, to append its transcript to the error
+func WithTranscribers(ctx context.Context, get func(resource string) (Handler, error)) context.Context {
+	return context.WithValue(ctx, transcribersKey{}, get)
+}
+
+// withTranscript appends the transcript of resource to the error of a failed step
+func withTranscript(ctx context.Context, resource string, err error) error {
+	if err == nil || resource == "" || errors.Is(err, godog.ErrSkip) || errors.Is(err, godog.ErrPending) {
+		return err
+	}
+	get, ok := ctx.Value(transcribersKey{}).(func(string) (Handler, error))
+	if !ok {
+		return err
+	}
+	h, herr := get(resource)
+	if herr != nil {
+		return err
+	}
+	t, ok := h.(Transcriber)
+	if !ok {
+		return err
+	}
+	transcript := t.Transcript(ctx)
+	if transcript == "" {
+		return err
+	}
+	return &TranscriptError{Err: err, Resource: resource, Transcript: transcript}
+}
+
+type redactKey struct{}
+
+// WithRedactedHeaders returns a copy of ctx in which transcripts redact the
+// values of the given headers, in addition to those of har.DefaultRedact
+func WithRedactedHeaders(ctx context.Context, names []string) context.Context {
+	return context.WithValue(ctx, redactKey{}, har.NewRedactor(names...))
+}
+
+// redactorFrom returns the headers transcripts redact in the scenario carried by ctx
+func redactorFrom(ctx context.Context) har.Redactor {
+	if ctx != nil {
+		if r, ok := ctx.Value(redactKey{}).(har.Redactor); ok {
+			return r
+		}
+	}
+	return har.NewRedactor()
+}
+
+// transcriptSize returns the number of exchanges a resource keeps in its transcript
+func transcriptSize(cfg config.Resource) int {
+	if n, ok := cfg.Options["transcript"].(int); ok && n >= 0 {
+		return n
+	}
+	return defaultTranscriptSize
+}
+
+// lastN returns the last n items
+func lastN[T any](items []T, n int) []T {
+	if len(items) > n {
+		return items[len(items)-n:]
+	}
+	return items
+}
+
+// formatHTTPExchange formats an HTTP request and its response for a transcript.
+// A zero status means no response was received. The values of the headers
+// redact redacts are masked.
+func formatHTTPExchange(redact har.Redactor, at time.Time, method, url string, reqHeader http.Header, reqBody []byte, status int, respHeader http.Header, respBody []byte, took time.Duration, err error) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s %s", at.Format("15:04:05.000"), method, url)
+	switch {
+	case err != nil:
+		fmt.Fprintf(&b, " → %v", err)
+	case status != 0:
+		fmt.Fprintf(&b, " → %d", status)
+	}
+	if took > 0 {
+		fmt.Fprintf(&b, " (%s)", took.Round(time.Millisecond))
+	}
+	b.WriteString("\n")
+	writeTranscriptPart(&b, "> ", redact.Header(reqHeader), reqBody)
+	if status != 0 {
+		writeTranscriptPart(&b, "< ", redact.Header(respHeader), respBody)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// formatMessage formats a consumed message for a transcript
+func formatMessage(redact har.Redactor, at time.Time, source, key string, headers map[string]string, body []byte) string {
+	var b strings.Builder
+	if !at.IsZero() {
+		b.WriteString(at.Format("15:04:05.000") + " ")
+	}
+	b.WriteString(source)
+	if key != "" {
+		fmt.Fprintf(&b, " key=%s", key)
+	}
+	b.WriteString("\n")
+	h := make(http.Header, len(headers))
+	for k, v := range headers {
+		h[k] = []string{v}
+	}
+	writeTranscriptPart(&b, "< ", redact.Header(h), body)
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func writeTranscriptPart(b *strings.Builder, prefix string, header http.Header, body []byte) {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range header[k] {
+			fmt.Fprintf(b, "%s%s: %s\n", prefix, k, v)
+		}
+	}
+	if len(body) == 0 {
+		return
+	}
+	if len(keys) > 0 {
+		b.WriteString(strings.TrimSpace(prefix) + "\n")
+	}
+	text := string(body)
+	if len(body) > maxTranscriptBody {
+		text = fmt.Sprintf("%s… (%d bytes)", body[:maxTranscriptBody], len(body))
+	}
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		b.WriteString(prefix + line + "\n")
+	}
+}
+
+// joinTranscript joins the entries of a transcript
+func joinTranscript(entries []string) string {
+	return strings.Join(entries, "\n\n")
+}
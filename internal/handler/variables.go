@@ -19,7 +19,8 @@ type Variables struct {
 	sequences map[string]int
 }
 
-// Global variable store - reset between scenarios
+// Global variable store - used only outside of a scenario context.
+// Scenarios carry their own store, see VariablesFrom.
 var globalVariables = NewVariables()
 
 // NewVariables creates a new variable store
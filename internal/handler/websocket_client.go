@@ -53,8 +53,13 @@ func (r *WebSocketClient) Init(ctx context.Context) error {
 		}
 	}
 
+	tlsConfig, err := loadTLSConfig(r.config)
+	if err != nil {
+		return err
+	}
 	r.dialer = &websocket.Dialer{
 		HandshakeTimeout: handshakeTimeout,
+		TLSClientConfig:  tlsConfig,
 	}
 
 	if protocols, ok := r.config.Options["protocols"].([]interface{}); ok {
@@ -88,7 +93,11 @@ func (r *WebSocketClient) Init(ctx context.Context) error {
 			path = p
 		}
 
-		r.url = fmt.Sprintf("ws://%s:%s%s", host, mappedPort, path)
+		scheme := "ws"
+		if tlsConfig != nil {
+			scheme = "wss"
+		}
+		r.url = fmt.Sprintf("%s://%s:%s%s", scheme, host, mappedPort, path)
 	}
 
 	if headers, ok := r.config.Options["headers"].(map[string]interface{}); ok {
@@ -146,7 +155,7 @@ func (r *WebSocketClient) RegisterSteps(ctx *godog.ScenarioContext) {
 
 // Steps returns the structured step definitions for the WebSocket client handler
 func (r *WebSocketClient) Steps() StepCategory {
-	return StepCategory{
+	category := StepCategory{
 		Name:        "WebSocket Client",
 		Description: "Steps for connecting to WebSocket servers",
 		Steps: []StepDef{
@@ -278,6 +287,18 @@ func (r *WebSocketClient) Steps() StepCategory {
 			},
 		},
 	}
+	category.Steps = append(category.Steps, messageJSONSteps("ws", r.lastMessageBody)...)
+	return category
+}
+
+// lastMessageBody returns the last message received from the server
+func (r *WebSocketClient) lastMessageBody(ctx context.Context) ([]byte, error) {
+	r.messagesMu.RLock()
+	defer r.messagesMu.RUnlock()
+	if r.lastMessage == nil {
+		return nil, fmt.Errorf("no message received")
+	}
+	return r.lastMessage, nil
 }
 
 func (r *WebSocketClient) connect() error {
@@ -300,7 +321,7 @@ func (r *WebSocketClient) connectWithHeaders(table *godog.Table) error {
 
 	conn, _, err := r.dialer.Dial(r.url, headers)
 	if err != nil {
-		return fmt.Errorf("connecting to websocket: %w", err)
+		return fmt.Errorf("connecting to websocket: %w", tlsHandshakeError(err, r.url))
 	}
 
 	r.conn = conn
@@ -334,6 +355,23 @@ func (r *WebSocketClient) readLoop() {
 	}
 }
 
+// Transcript returns the last messages received on the connection
+func (r *WebSocketClient) Transcript(ctx context.Context) string {
+	n := transcriptSize(r.config)
+	if n == 0 {
+		return ""
+	}
+
+	r.messagesMu.RLock()
+	defer r.messagesMu.RUnlock()
+
+	var entries []string
+	for _, msg := range lastN(r.messages, n) {
+		entries = append(entries, formatMessage(redactorFrom(ctx), time.Time{}, "received", "", nil, msg))
+	}
+	return joinTranscript(entries)
+}
+
 func (r *WebSocketClient) disconnectStep() error {
 	r.disconnect()
 	return nil
@@ -622,7 +660,7 @@ func (r *WebSocketClient) Connect(ctx context.Context, headers map[string]string
 
 	conn, _, err := r.dialer.Dial(r.url, h)
 	if err != nil {
-		return err
+		return tlsHandshakeError(err, r.url)
 	}
 
 	r.conn = conn
@@ -172,6 +172,23 @@ func (r *WebSocketServer) Reset(ctx context.Context) error {
 	return nil
 }
 
+// Transcript returns the last messages received from clients
+func (r *WebSocketServer) Transcript(ctx context.Context) string {
+	n := transcriptSize(r.config)
+	if n == 0 {
+		return ""
+	}
+
+	r.receivedMu.RLock()
+	defer r.receivedMu.RUnlock()
+
+	var entries []string
+	for _, msg := range lastN(r.receivedMsgs, n) {
+		entries = append(entries, formatMessage(redactorFrom(ctx), time.Time{}, "received", "", nil, []byte(msg)))
+	}
+	return joinTranscript(entries)
+}
+
 func (r *WebSocketServer) RegisterSteps(ctx *godog.ScenarioContext) {
 	RegisterStepsToGodog(ctx, r.name, r.Steps())
 }
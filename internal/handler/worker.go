@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tomatool/tomato/internal/config"
+)
+
+// Worker isolation lets parallel scenarios use separate namespaces of the
+// same resource. The runner assigns every running scenario a worker ID and
+// handlers configured with options.isolation rewrite names accordingly:
+//
+//   - postgres: "schema" (schema per worker) or "database" (database cloned from a template)
+//   - redis: "db" (logical database index per worker)
+//   - kafka, rabbitmq: "prefix" (topic/queue/exchange names prefixed per worker)
+
+type workerKey struct{}
+
+// WithWorker returns a copy of ctx carrying the given worker ID
+func WithWorker(ctx context.Context, id int) context.Context {
+	return context.WithValue(ctx, workerKey{}, id)
+}
+
+// WorkerID returns the worker ID carried by ctx, or 0 when running outside a worker
+func WorkerID(ctx context.Context) int {
+	if ctx == nil {
+		return 0
+	}
+	id, _ := ctx.Value(workerKey{}).(int)
+	return id
+}
+
+type runningWorkersKey struct{}
+
+// WithRunningWorkers returns a copy of ctx carrying the number of workers
+// running a scenario, including the worker of ctx
+func WithRunningWorkers(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, runningWorkersKey{}, n)
+}
+
+// othersRunning reports whether workers other than the one of ctx are
+// running a scenario. Resources shared by all workers are not reset then,
+// as that would wipe the state of the other scenarios.
+func othersRunning(ctx context.Context) bool {
+	n, _ := ctx.Value(runningWorkersKey{}).(int)
+	return n > 1
+}
+
+// isolationMode returns the configured isolation strategy of a resource
+func isolationMode(cfg config.Resource) string {
+	mode, _ := cfg.Options["isolation"].(string)
+	return mode
+}
+
+// workerPrefix returns the name prefix used for worker-isolated names,
+// or an empty string when ctx carries no worker
+func workerPrefix(ctx context.Context) string {
+	id := WorkerID(ctx)
+	if id == 0 {
+		return ""
+	}
+	return fmt.Sprintf("w%d_", id)
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/tomatool/tomato/internal/config"
+)
+
+func TestWorkerID(t *testing.T) {
+	if id := WorkerID(context.Background()); id != 0 {
+		t.Errorf("expected 0 without worker, got %d", id)
+	}
+
+	ctx := WithWorker(context.Background(), 3)
+	if id := WorkerID(ctx); id != 3 {
+		t.Errorf("expected 3, got %d", id)
+	}
+	if prefix := workerPrefix(ctx); prefix != "w3_" {
+		t.Errorf("expected prefix 'w3_', got %q", prefix)
+	}
+}
+
+func TestWorkerIsolationNames(t *testing.T) {
+	worker := WithWorker(context.Background(), 2)
+	isolated := config.Resource{Options: map[string]any{"isolation": "prefix"}}
+	shared := config.Resource{}
+
+	kafka := func(cfg config.Resource) func(context.Context, string) string {
+		k, _ := NewKafka("queue", cfg, nil)
+		return k.topicName
+	}
+	rabbitmq := func(cfg config.Resource) func(context.Context, string) string {
+		q, _ := NewRabbitMQ("broker", cfg, nil)
+		return q.resourceName
+	}
+
+	tests := []struct {
+		name     string
+		resolve  func(context.Context, string) string
+		ctx      context.Context
+		input    string
+		expected string
+	}{
+		{"kafka topic with prefix isolation", kafka(isolated), worker, "events", "w2_events"},
+		{"kafka topic without isolation", kafka(shared), worker, "events", "events"},
+		{"kafka topic outside of a worker", kafka(isolated), context.Background(), "events", "events"},
+		{"rabbitmq queue with prefix isolation", rabbitmq(isolated), worker, "orders", "w2_orders"},
+		{"rabbitmq default exchange is not rewritten", rabbitmq(isolated), worker, "", ""},
+		{"rabbitmq built-in exchange is not rewritten", rabbitmq(isolated), worker, "amq.topic", "amq.topic"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.resolve(tt.ctx, tt.input); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+// TestResetWhileWorkersRun resets handlers while other workers read their
+// default state, as parallel scenarios do; go test -race catches a data race
+func TestResetWhileWorkersRun(t *testing.T) {
+	client, _ := NewHTTPClient("api", config.Resource{}, nil)
+	shell, _ := NewShell("shell", config.Resource{}, nil)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for worker := 1; worker <= 4; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wctx := WithWorker(ctx, worker)
+			for range 100 {
+				client.Reset(wctx)
+				shell.Reset(wctx)
+				if client.state(wctx) == nil || shell.state(wctx) == nil {
+					t.Error("default state missing")
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,386 @@
+// Package har records the HTTP traffic of a run as a HAR 1.2 file
+// (http://www.softwareishard.com/blog/har-12-spec/), which browser devtools
+// and HAR viewers can open. Requests made by http-client resources and calls
+// received by http-server resources are entries, grouped into one page per
+// scenario.
+package har
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tomatool/tomato/internal/version"
+)
+
+// FileName is the name of the HAR file in the run directory
+const FileName = "traffic.har"
+
+// Redacted replaces the values of redacted headers
+const Redacted = "[REDACTED]"
+
+// DefaultRedact are the headers that are always redacted
+var DefaultRedact = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// Redactor tells which headers have their values redacted, by canonical name
+type Redactor map[string]bool
+
+// NewRedactor creates a redactor of the given headers in addition to DefaultRedact
+func NewRedactor(redact ...string) Redactor {
+	r := make(Redactor)
+	for _, name := range append(append([]string(nil), DefaultRedact...), redact...) {
+		r[http.CanonicalHeaderKey(name)] = true
+	}
+	return r
+}
+
+// Redacts reports whether the values of a header are redacted
+func (r Redactor) Redacts(name string) bool {
+	return r[http.CanonicalHeaderKey(name)]
+}
+
+// With returns a copy of r that also redacts the given headers
+func (r Redactor) With(names ...string) Redactor {
+	out := make(Redactor, len(r)+len(names))
+	for name := range r {
+		out[name] = true
+	}
+	for _, name := range names {
+		out[http.CanonicalHeaderKey(name)] = true
+	}
+	return out
+}
+
+// Header returns a copy of h with the values of redacted headers replaced
+func (r Redactor) Header(h http.Header) http.Header {
+	out := make(http.Header, len(h))
+	for name, values := range h {
+		if r.Redacts(name) {
+			values = []string{Redacted}
+		}
+		out[name] = values
+	}
+	return out
+}
+
+// HAR is the root of a HAR file
+type HAR struct {
+	Log Log `json:"log"`
+}
+
+type Log struct {
+	Version string  `json:"version"`
+	Creator Creator `json:"creator"`
+	Pages   []Page  `json:"pages"`
+	Entries []Entry `json:"entries"`
+}
+
+type Creator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Page groups the entries of a scenario
+type Page struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	ID              string      `json:"id"`
+	Title           string      `json:"title"`
+	PageTimings     PageTimings `json:"pageTimings"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+type PageTimings struct {
+	OnContentLoad float64 `json:"onContentLoad"`
+	OnLoad        float64 `json:"onLoad"`
+}
+
+// Entry is a single request and its response
+type Entry struct {
+	PageRef         string    `json:"pageref,omitempty"`
+	StartedDateTime time.Time `json:"startedDateTime"`
+	Time            float64   `json:"time"` // milliseconds
+	Request         Request   `json:"request"`
+	Response        Response  `json:"response"`
+	Cache           struct{}  `json:"cache"`
+	Timings         Timings   `json:"timings"`
+	Comment         string    `json:"comment,omitempty"`
+	Error           string    `json:"_error,omitempty"` // set when no response was received
+}
+
+type Request struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []Cookie    `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	QueryString []NameValue `json:"queryString"`
+	PostData    *PostData   `json:"postData,omitempty"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int         `json:"bodySize"`
+}
+
+type Response struct {
+	Status      int         `json:"status"`
+	StatusText  string      `json:"statusText"`
+	HTTPVersion string      `json:"httpVersion"`
+	Cookies     []Cookie    `json:"cookies"`
+	Headers     []NameValue `json:"headers"`
+	Content     Content     `json:"content"`
+	RedirectURL string      `json:"redirectURL"`
+	HeadersSize int         `json:"headersSize"`
+	BodySize    int         `json:"bodySize"`
+}
+
+type Cookie struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type NameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type PostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type Content struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// Timings splits the time of an entry. Handlers only measure the whole
+// exchange, which is reported as the wait.
+type Timings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// Exchange is an HTTP exchange as seen by a handler. Status is 0 when no
+// response was received, in which case Err says why.
+type Exchange struct {
+	Resource       string
+	Started        time.Time
+	Duration       time.Duration
+	Method         string
+	URL            string
+	RequestHeader  http.Header
+	RequestBody    []byte
+	Status         int
+	ResponseHeader http.Header
+	ResponseBody   []byte
+	Err            error
+}
+
+// Recorder collects the entries and pages of a run. It is safe for
+// concurrent use.
+type Recorder struct {
+	mu      sync.Mutex
+	redact  Redactor
+	pages   []Page
+	open    []string // IDs of the pages of running scenarios
+	entries []Entry
+}
+
+// NewRecorder creates a recorder that redacts the given headers in addition to DefaultRedact
+func NewRecorder(redact []string) *Recorder {
+	return &Recorder{redact: NewRedactor(redact...)}
+}
+
+// StartPage opens the page of a scenario and returns its ID
+func (r *Recorder) StartPage(title, comment string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := fmt.Sprintf("page_%d", len(r.pages)+1)
+	r.pages = append(r.pages, Page{
+		StartedDateTime: time.Now(),
+		ID:              id,
+		Title:           title,
+		PageTimings:     PageTimings{OnContentLoad: -1, OnLoad: -1},
+		Comment:         comment,
+	})
+	r.open = append(r.open, id)
+	return id
+}
+
+// EndPage closes the page of a finished scenario
+func (r *Recorder) EndPage(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, open := range r.open {
+		if open == id {
+			r.open = append(r.open[:i], r.open[i+1:]...)
+			return
+		}
+	}
+}
+
+// Add records an exchange on the page with the given ID. Without a page ID,
+// as for calls received by a mock server, the exchange belongs to the
+// running scenario; it is left without a page when scenarios run in parallel.
+func (r *Recorder) Add(pageID string, ex Exchange) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if pageID == "" && len(r.open) == 1 {
+		pageID = r.open[0]
+	}
+	r.entries = append(r.entries, r.entry(pageID, ex))
+}
+
+func (r *Recorder) entry(pageID string, ex Exchange) Entry {
+	ms := float64(ex.Duration.Microseconds()) / 1000
+	e := Entry{
+		PageRef:         pageID,
+		StartedDateTime: ex.Started,
+		Time:            ms,
+		Request: Request{
+			Method:      ex.Method,
+			URL:         ex.URL,
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []Cookie{},
+			Headers:     r.headers(ex.RequestHeader),
+			QueryString: queryString(ex.URL),
+			HeadersSize: -1,
+			BodySize:    len(ex.RequestBody),
+		},
+		Response: Response{
+			Status:      ex.Status,
+			StatusText:  http.StatusText(ex.Status),
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []Cookie{},
+			Headers:     r.headers(ex.ResponseHeader),
+			Content:     content(ex.ResponseHeader.Get("Content-Type"), ex.ResponseBody),
+			RedirectURL: ex.ResponseHeader.Get("Location"),
+			HeadersSize: -1,
+			BodySize:    len(ex.ResponseBody),
+		},
+		Timings: Timings{Send: 0, Wait: ms, Receive: 0},
+		Comment: ex.Resource,
+	}
+	if len(ex.RequestBody) > 0 {
+		e.Request.PostData = &PostData{
+			MimeType: ex.RequestHeader.Get("Content-Type"),
+			Text:     string(ex.RequestBody),
+		}
+	}
+	if ex.Err != nil {
+		e.Error = ex.Err.Error()
+		e.Response.BodySize = -1
+	}
+	return e
+}
+
+// headers converts a header to sorted name/value pairs with redacted values
+func (r *Recorder) headers(h http.Header) []NameValue {
+	pairs := []NameValue{}
+	for _, name := range sortedKeys(h) {
+		for _, value := range h[name] {
+			if r.redact.Redacts(name) {
+				value = Redacted
+			}
+			pairs = append(pairs, NameValue{Name: name, Value: value})
+		}
+	}
+	return pairs
+}
+
+// queryString returns the query parameters of a URL
+func queryString(rawURL string) []NameValue {
+	pairs := []NameValue{}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return pairs
+	}
+	query := u.Query()
+	for _, name := range sortedKeys(query) {
+		for _, value := range query[name] {
+			pairs = append(pairs, NameValue{Name: name, Value: value})
+		}
+	}
+	return pairs
+}
+
+// content returns the content of a response, base64 encoded unless it is text
+func content(mimeType string, body []byte) Content {
+	c := Content{Size: len(body), MimeType: mimeType}
+	if utf8.Valid(body) {
+		c.Text = string(body)
+	} else {
+		c.Text = base64.StdEncoding.EncodeToString(body)
+		c.Encoding = "base64"
+	}
+	return c
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// HAR returns the recorded traffic. Pages of scenarios without traffic are left out.
+func (r *Recorder) HAR() *HAR {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	used := make(map[string]bool)
+	for _, e := range r.entries {
+		used[e.PageRef] = true
+	}
+	pages := []Page{}
+	for _, p := range r.pages {
+		if used[p.ID] {
+			pages = append(pages, p)
+		}
+	}
+	return &HAR{Log: Log{
+		Version: "1.2",
+		Creator: Creator{Name: "tomato", Version: version.Version},
+		Pages:   pages,
+		Entries: append([]Entry{}, r.entries...),
+	}}
+}
+
+// WriteFile writes the recorded traffic to path
+func (r *Recorder) WriteFile(path string) error {
+	data, err := json.MarshalIndent(r.HAR(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding HAR: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("writing HAR: %w", err)
+	}
+	return nil
+}
+
+type pageKey struct{}
+
+// WithPage returns a copy of ctx carrying the ID of the scenario's page
+func WithPage(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, pageKey{}, id)
+}
+
+// PageFrom returns the page ID carried by ctx, or ""
+func PageFrom(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(pageKey{}).(string)
+	return id
+}
@@ -0,0 +1,127 @@
+package har
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecorderPages(t *testing.T) {
+	r := NewRecorder(nil)
+
+	first := r.StartPage("create user", "features/users.feature")
+	r.Add(first, Exchange{Method: "POST", URL: "http://api/users"})
+	// A mock server call without a page belongs to the only running scenario
+	r.Add("", Exchange{Method: "GET", URL: "http://mock/profile"})
+	r.EndPage(first)
+
+	empty := r.StartPage("no traffic", "")
+	r.EndPage(empty)
+
+	// Calls received while scenarios run in parallel have no page
+	a := r.StartPage("a", "")
+	b := r.StartPage("b", "")
+	r.Add("", Exchange{Method: "GET", URL: "http://mock/parallel"})
+	r.EndPage(a)
+	r.EndPage(b)
+
+	h := r.HAR()
+	if h.Log.Version != "1.2" || h.Log.Creator.Name != "tomato" {
+		t.Errorf("log = %+v", h.Log)
+	}
+	if len(h.Log.Pages) != 1 || h.Log.Pages[0].ID != first || h.Log.Pages[0].Title != "create user" {
+		t.Errorf("pages without traffic should be left out, got %+v", h.Log.Pages)
+	}
+	refs := []string{first, first, ""}
+	if len(h.Log.Entries) != len(refs) {
+		t.Fatalf("got %d entries, want %d", len(h.Log.Entries), len(refs))
+	}
+	for i, want := range refs {
+		if got := h.Log.Entries[i].PageRef; got != want {
+			t.Errorf("entry %d pageref = %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestRecorderEntry(t *testing.T) {
+	r := NewRecorder([]string{"x-api-key"})
+	started := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	r.Add("", Exchange{
+		Resource: "api",
+		Started:  started,
+		Duration: 1500 * time.Microsecond,
+		Method:   "POST",
+		URL:      "http://api/users?page=2&tag=a&tag=b",
+		RequestHeader: http.Header{
+			"Authorization": {"Bearer secret"},
+			"X-Api-Key":     {"secret"},
+			"Content-Type":  {"application/json"},
+		},
+		RequestBody:    []byte(`{"name":"alice"}`),
+		Status:         201,
+		ResponseHeader: http.Header{"Content-Type": {"application/octet-stream"}, "Set-Cookie": {"session=secret"}},
+		ResponseBody:   []byte{0xff, 0x00},
+	})
+	r.Add("", Exchange{Method: "GET", URL: "http://down/", Err: errors.New("connection refused")})
+
+	entries := r.HAR().Log.Entries
+	e := entries[0]
+	if e.Time != 1.5 || e.Timings.Wait != 1.5 || !e.StartedDateTime.Equal(started) || e.Comment != "api" {
+		t.Errorf("entry = %+v", e)
+	}
+	for _, h := range append(e.Request.Headers, e.Response.Headers...) {
+		if h.Name != "Content-Type" && h.Value != Redacted {
+			t.Errorf("header %s = %q, want it redacted", h.Name, h.Value)
+		}
+	}
+	if len(e.Request.QueryString) != 3 || e.Request.QueryString[2] != (NameValue{Name: "tag", Value: "b"}) {
+		t.Errorf("query string = %+v", e.Request.QueryString)
+	}
+	if e.Request.PostData == nil || e.Request.PostData.Text != `{"name":"alice"}` || e.Request.PostData.MimeType != "application/json" {
+		t.Errorf("post data = %+v", e.Request.PostData)
+	}
+	if e.Response.StatusText != "Created" || e.Response.Content.Encoding != "base64" || e.Response.Content.Text != "/wA=" {
+		t.Errorf("response = %+v", e.Response)
+	}
+
+	failed := entries[1]
+	if failed.Error != "connection refused" || failed.Response.Status != 0 || failed.Response.BodySize != -1 {
+		t.Errorf("failed entry = %+v", failed)
+	}
+}
+
+func TestRecorderWriteFile(t *testing.T) {
+	r := NewRecorder(nil)
+	r.Add(r.StartPage("s", ""), Exchange{Method: "GET", URL: "http://api/"})
+
+	path := filepath.Join(t.TempDir(), FileName)
+	if err := r.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Viewers require these arrays even when they are empty
+	var doc struct {
+		Log struct {
+			Entries []struct {
+				Request map[string]any `json:"request"`
+			} `json:"entries"`
+		} `json:"log"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	req := doc.Log.Entries[0].Request
+	for _, key := range []string{"cookies", "headers", "queryString"} {
+		if _, ok := req[key].([]any); !ok {
+			t.Errorf("request %s = %v, want an array", key, req[key])
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package report
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+
+	messages "github.com/cucumber/messages/go/v21"
+)
+
+// Cucumber JSON, as read by cucumber-reporting and similar tools
+type cucumberFeature struct {
+	URI         string            `json:"uri"`
+	ID          string            `json:"id"`
+	Keyword     string            `json:"keyword"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Line        int               `json:"line"`
+	Tags        []cucumberTag     `json:"tags,omitempty"`
+	Elements    []cucumberElement `json:"elements"`
+}
+
+type cucumberTag struct {
+	Name string `json:"name"`
+	Line int    `json:"line"`
+}
+
+type cucumberElement struct {
+	ID          string         `json:"id"`
+	Keyword     string         `json:"keyword"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Line        int            `json:"line"`
+	Type        string         `json:"type"`
+	StartTime   string         `json:"start_timestamp,omitempty"`
+	Tags        []cucumberTag  `json:"tags,omitempty"`
+	Steps       []cucumberStep `json:"steps"`
+}
+
+type cucumberStep struct {
+	Keyword    string              `json:"keyword"`
+	Name       string              `json:"name"`
+	Line       int                 `json:"line"`
+	Match      *cucumberMatch      `json:"match,omitempty"`
+	Result     cucumberResult      `json:"result"`
+	Embeddings []cucumberEmbedding `json:"embeddings,omitempty"`
+}
+
+// cucumberMatch names the resource implementing the step as its location
+type cucumberMatch struct {
+	Location string `json:"location"`
+	Pattern  string `json:"pattern,omitempty"`
+}
+
+type cucumberResult struct {
+	Status       string `json:"status"`
+	Duration     int64  `json:"duration,omitempty"` // nanoseconds
+	ErrorMessage string `json:"error_message,omitempty"`
+}
+
+type cucumberEmbedding struct {
+	Name     string `json:"name,omitempty"`
+	MimeType string `json:"mime_type"`
+	Data     string `json:"data"` // base64
+}
+
+// WriteCucumberJSON writes the results in the Cucumber JSON format. Feature
+// names, descriptions and keywords are read from the feature files; if a file
+// cannot be read, the recorded names are used.
+func WriteCucumberJSON(w io.Writer, run *Run) error {
+	features := []cucumberFeature{}
+	uris, groups := byFeature(run)
+
+	for _, uri := range uris {
+		scenarios := groups[uri]
+		feature := cucumberFeature{
+			URI:     uri,
+			ID:      cucumberID(scenarios[0].Feature),
+			Keyword: "Feature",
+			Name:    scenarios[0].Feature,
+			Line:    1,
+		}
+
+		file, err := loadFeatureFile(uri, (&messages.Incrementing{}).NewId)
+		if err == nil && file.doc.Feature != nil {
+			f := file.doc.Feature
+			feature.Keyword = f.Keyword
+			feature.Description = strings.TrimSpace(f.Description)
+			feature.Line = int(f.Location.Line)
+			for _, tag := range f.Tags {
+				feature.Tags = append(feature.Tags, cucumberTag{Name: tag.Name, Line: int(tag.Location.Line)})
+			}
+		} else {
+			file = nil
+		}
+
+		for _, sc := range scenarios {
+			feature.Elements = append(feature.Elements, cucumberElementOf(feature.ID, sc, file))
+		}
+		features = append(features, feature)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(features)
+}
+
+func cucumberElementOf(featureID string, sc *Scenario, file *featureFile) cucumberElement {
+	el := cucumberElement{
+		ID:      featureID + ";" + cucumberID(sc.Name),
+		Keyword: "Scenario",
+		Name:    sc.Name,
+		Line:    sc.Line,
+		Type:    "scenario",
+		Steps:   []cucumberStep{},
+	}
+	if !sc.StartedAt.IsZero() {
+		el.StartTime = sc.StartedAt.UTC().Format("2006-01-02T15:04:05.000Z")
+	}
+
+	// Tags are inherited from the feature, scenario and examples
+	tagLines := make(map[string]int)
+	if file != nil {
+		for _, tag := range file.doc.Feature.Tags {
+			tagLines[tag.Name] = int(tag.Location.Line)
+		}
+		if node := file.scenario(sc); node != nil {
+			el.Keyword = node.Keyword
+			el.Description = strings.TrimSpace(node.Description)
+			for _, tag := range node.Tags {
+				tagLines[tag.Name] = int(tag.Location.Line)
+			}
+			for _, examples := range node.Examples {
+				for _, tag := range examples.Tags {
+					tagLines[tag.Name] = int(tag.Location.Line)
+				}
+			}
+		}
+	}
+	for _, tag := range sc.Tags {
+		el.Tags = append(el.Tags, cucumberTag{Name: tag, Line: tagLines[tag]})
+	}
+
+	failed := sc.FailedStep()
+	for _, step := range sc.Steps {
+		cs := cucumberStep{
+			Keyword: step.Keyword,
+			Name:    step.Text,
+			Line:    step.Line,
+			Result: cucumberResult{
+				Status:       step.Status,
+				Duration:     step.Duration.Nanoseconds(),
+				ErrorMessage: step.Error,
+			},
+		}
+		if step.Match != nil {
+			cs.Match = &cucumberMatch{Location: step.Match.Resource, Pattern: step.Match.Pattern}
+		}
+		for _, a := range stepAttachments(sc, step, step == failed) {
+			cs.Embeddings = append(cs.Embeddings, cucumberEmbedding{
+				Name:     a.Name,
+				MimeType: a.MediaType,
+				Data:     base64.StdEncoding.EncodeToString([]byte(a.Body)),
+			})
+		}
+		el.Steps = append(el.Steps, cs)
+	}
+	return el
+}
+
+// stepAttachments returns the attachments of a step. The application log
+// tail of a failed scenario is attached to the step it failed on.
+func stepAttachments(sc *Scenario, step *Step, failed bool) []*Attachment {
+	attachments := step.Attachments
+	if failed && len(sc.AppLogs) > 0 {
+		attachments = append(attachments[:len(attachments):len(attachments)], &Attachment{
+			Name:      "app logs",
+			MediaType: "text/plain",
+			Body:      strings.Join(sc.AppLogs, "\n"),
+		})
+	}
+	return attachments
+}
+
+var nonIDChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// cucumberID turns a name into an ID the way Cucumber does, e.g. "Create user" -> "create-user"
+func cucumberID(name string) string {
+	return strings.Trim(nonIDChars.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
@@ -0,0 +1,251 @@
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	messages "github.com/cucumber/messages/go/v21"
+)
+
+const usersFeature = `@users
+Feature: Users
+  Managing users
+
+  @smoke
+  Scenario: delete user
+    When I send "DELETE" request to "api" "/users/1"
+    Then "api" response status should be "204"
+`
+
+// testRun writes a feature file and returns results recorded for it
+func testRun(t *testing.T) *Run {
+	t.Helper()
+	uri := filepath.Join(t.TempDir(), "users.feature")
+	if err := os.WriteFile(uri, []byte(usersFeature), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	return &Run{
+		ID:         "abc",
+		StartedAt:  start,
+		FinishedAt: start.Add(time.Second),
+		Status:     StatusFailed,
+		Scenarios: []*Scenario{{
+			Feature: "Users", Name: "delete user", URI: uri, Line: 6,
+			Tags: []string{"@users", "@smoke"}, Status: StatusFailed,
+			Error: "expected status 204, got 500", StartedAt: start, Duration: 300 * time.Millisecond,
+			Steps: []*Step{
+				{
+					Keyword: "When ", Text: `I send "DELETE" request to "api" "/users/1"`, Line: 7, Status: StatusPassed,
+					StartedAt: start, Duration: 100 * time.Millisecond,
+					Match:       &Match{Pattern: `^I send "([^"]*)" request to "([^"]*)" "([^"]*)"$`, Resource: "api"},
+					Attachments: []*Attachment{{Name: "request", MediaType: "text/plain", Body: "DELETE /users/1"}},
+				},
+				{
+					Keyword: "Then ", Text: `"api" response status should be "204"`, Line: 8, Status: StatusFailed,
+					Error: "expected status 204, got 500", StartedAt: start.Add(100 * time.Millisecond), Duration: 200 * time.Millisecond,
+					Match: &Match{Pattern: `^"([^"]*)" response status should be "(\d+)"$`, Resource: "api"},
+				},
+			},
+			AppLogs: []string{"panic: nil map"},
+		}},
+	}
+}
+
+func TestWriteCucumberJSON(t *testing.T) {
+	run := testRun(t)
+
+	var buf bytes.Buffer
+	if err := WriteCucumberJSON(&buf, run); err != nil {
+		t.Fatalf("WriteCucumberJSON failed: %v", err)
+	}
+
+	var features []cucumberFeature
+	if err := json.Unmarshal(buf.Bytes(), &features); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+	if len(features) != 1 || features[0].Name != "Users" || features[0].Description != "Managing users" {
+		t.Fatalf("unexpected features %+v", features)
+	}
+
+	el := features[0].Elements[0]
+	if el.ID != "users;delete-user" || el.Keyword != "Scenario" || el.Line != 6 {
+		t.Errorf("element = %+v", el)
+	}
+	if len(el.Tags) != 2 || el.Tags[0] != (cucumberTag{Name: "@users", Line: 1}) || el.Tags[1] != (cucumberTag{Name: "@smoke", Line: 5}) {
+		t.Errorf("tags = %+v", el.Tags)
+	}
+
+	when, then := el.Steps[0], el.Steps[1]
+	if when.Match == nil || when.Match.Location != "api" || when.Result.Duration != int64(100*time.Millisecond) {
+		t.Errorf("step = %+v", when)
+	}
+	if len(when.Embeddings) != 1 || when.Embeddings[0].Data != base64.StdEncoding.EncodeToString([]byte("DELETE /users/1")) {
+		t.Errorf("embeddings = %+v", when.Embeddings)
+	}
+	if then.Result.Status != StatusFailed || then.Result.ErrorMessage != "expected status 204, got 500" {
+		t.Errorf("result = %+v", then.Result)
+	}
+	if len(then.Embeddings) != 1 || then.Embeddings[0].Name != "app logs" {
+		t.Errorf("failed step should embed the app logs, got %+v", then.Embeddings)
+	}
+}
+
+func TestWriteCucumberJSONMissingFile(t *testing.T) {
+	run := testRun(t)
+	run.Scenarios[0].URI = filepath.Join(t.TempDir(), "gone.feature")
+
+	var buf bytes.Buffer
+	if err := WriteCucumberJSON(&buf, run); err != nil {
+		t.Fatalf("WriteCucumberJSON failed: %v", err)
+	}
+	var features []cucumberFeature
+	if err := json.Unmarshal(buf.Bytes(), &features); err != nil {
+		t.Fatal(err)
+	}
+	if features[0].Name != "Users" || features[0].Elements[0].Name != "delete user" {
+		t.Errorf("recorded names should be used, got %+v", features[0])
+	}
+}
+
+// writeMessages writes the run as Cucumber Messages and decodes them again
+func writeMessages(t *testing.T, run *Run) []*messages.Envelope {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := WriteMessages(&buf, run); err != nil {
+		t.Fatalf("WriteMessages failed: %v", err)
+	}
+
+	var envelopes []*messages.Envelope
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var env messages.Envelope
+		if err := json.Unmarshal(scanner.Bytes(), &env); err != nil {
+			t.Fatalf("invalid message %q: %v", scanner.Text(), err)
+		}
+		envelopes = append(envelopes, &env)
+	}
+	return envelopes
+}
+
+func TestWriteMessages(t *testing.T) {
+	envelopes := writeMessages(t, testRun(t))
+
+	var (
+		definitions = make(map[string]*messages.StepDefinition)
+		testCase    *messages.TestCase
+		finished    []*messages.TestStepFinished
+		attachments []*messages.Attachment
+		runFinished *messages.TestRunFinished
+	)
+	for _, env := range envelopes {
+		switch {
+		case env.StepDefinition != nil:
+			definitions[env.StepDefinition.Id] = env.StepDefinition
+		case env.TestCase != nil:
+			testCase = env.TestCase
+		case env.TestStepFinished != nil:
+			finished = append(finished, env.TestStepFinished)
+		case env.Attachment != nil:
+			attachments = append(attachments, env.Attachment)
+		case env.TestRunFinished != nil:
+			runFinished = env.TestRunFinished
+		}
+	}
+
+	if envelopes[0].Meta == nil || envelopes[0].Meta.Implementation.Name != "tomato" {
+		t.Errorf("first message should be meta, got %+v", envelopes[0])
+	}
+	if len(definitions) != 2 {
+		t.Fatalf("expected 2 step definitions, got %d", len(definitions))
+	}
+	if testCase == nil || len(testCase.TestSteps) != 2 {
+		t.Fatalf("test case = %+v", testCase)
+	}
+	def := definitions[testCase.TestSteps[1].StepDefinitionIds[0]]
+	if def.Pattern.Source != `^"([^"]*)" response status should be "(\d+)"$` || def.SourceReference.Uri != "resource:api" {
+		t.Errorf("step definition = %+v", def)
+	}
+
+	if len(finished) != 2 {
+		t.Fatalf("expected 2 finished steps, got %d", len(finished))
+	}
+	result := finished[1].TestStepResult
+	if result.Status != messages.TestStepResultStatus_FAILED || result.Message != "expected status 204, got 500" {
+		t.Errorf("result = %+v", result)
+	}
+	if d := messages.DurationToGoDuration(*result.Duration); d != 200*time.Millisecond {
+		t.Errorf("duration = %v, want 200ms", d)
+	}
+
+	if len(attachments) != 2 || attachments[0].Body != "DELETE /users/1" || attachments[1].Body != "panic: nil map" {
+		t.Errorf("attachments = %+v", attachments)
+	}
+	if runFinished == nil || runFinished.Success {
+		t.Errorf("test run finished = %+v", runFinished)
+	}
+}
+
+func TestWriteMessagesAttempts(t *testing.T) {
+	run := testRun(t)
+	sc := run.Scenarios[0]
+	start := sc.StartedAt
+
+	// The first attempt failed on its first step, a before_scenario hook
+	// failure of the second left no steps, the third passed
+	first := *sc.Steps[0]
+	first.Status, first.Error = StatusFailed, "connection refused"
+	sc.Attempts = []*Attempt{
+		{Status: StatusFailed, Error: "connection refused", StartedAt: start.Add(-2 * time.Second), Duration: time.Second, Steps: []*Step{&first}},
+		{Status: StatusFailed, Error: "before_scenario hooks failed", StartedAt: start.Add(-time.Second), Duration: time.Second},
+	}
+	sc.Retries, sc.Status, sc.Error = 2, StatusPassed, ""
+	sc.Steps[1].Status, sc.Steps[1].Error = StatusPassed, ""
+
+	var (
+		testCases []*messages.TestCase
+		started   []*messages.TestCaseStarted
+		steps     = make(map[string][]string) // statuses by test case started ID
+		finished  []*messages.TestCaseFinished
+	)
+	for _, env := range writeMessages(t, run) {
+		switch {
+		case env.TestCase != nil:
+			testCases = append(testCases, env.TestCase)
+		case env.TestCaseStarted != nil:
+			started = append(started, env.TestCaseStarted)
+		case env.TestStepFinished != nil:
+			id := env.TestStepFinished.TestCaseStartedId
+			steps[id] = append(steps[id], string(env.TestStepFinished.TestStepResult.Status))
+		case env.TestCaseFinished != nil:
+			finished = append(finished, env.TestCaseFinished)
+		}
+	}
+
+	if len(testCases) != 1 || len(testCases[0].TestSteps) != 2 {
+		t.Fatalf("test cases = %+v", testCases)
+	}
+	if len(started) != 3 || len(finished) != 3 {
+		t.Fatalf("got %d started and %d finished test cases, want 3", len(started), len(finished))
+	}
+	want := [][]string{{"FAILED", "SKIPPED"}, {"SKIPPED", "SKIPPED"}, {"PASSED", "PASSED"}}
+	for i, s := range started {
+		if s.Attempt != int64(i) || s.TestCaseId != testCases[0].Id {
+			t.Errorf("attempt %d started = %+v", i, s)
+		}
+		if got := steps[s.Id]; !slices.Equal(got, want[i]) {
+			t.Errorf("attempt %d steps = %v, want %v", i, got, want[i])
+		}
+		if finished[i].WillBeRetried != (i < 2) {
+			t.Errorf("attempt %d willBeRetried = %v", i, finished[i].WillBeRetried)
+		}
+	}
+}
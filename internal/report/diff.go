@@ -0,0 +1,64 @@
+package report
+
+import "fmt"
+
+// Change is a scenario whose outcome differs between two runs. Before is
+// nil for a scenario that only ran in the second run, After for one that
+// only ran in the first.
+type Change struct {
+	Before *Scenario
+	After  *Scenario
+}
+
+// Scenario returns the most recent result of the changed scenario
+func (c Change) Scenario() *Scenario {
+	if c.After != nil {
+		return c.After
+	}
+	return c.Before
+}
+
+// Diff returns the scenarios whose status changed from run a to run b, and
+// the scenarios that ran in only one of them, in the order of b followed by
+// the scenarios missing from b. Scenarios are matched by file and name, so
+// edits that move them to another line do not count as changes.
+func Diff(a, b *Run) []Change {
+	keysA, keysB := diffKeys(a), diffKeys(b)
+	before := make(map[string]*Scenario)
+	for i, sc := range a.Scenarios {
+		before[keysA[i]] = sc
+	}
+
+	var changes []Change
+	matched := make(map[string]bool)
+	for i, sc := range b.Scenarios {
+		prev, ok := before[keysB[i]]
+		matched[keysB[i]] = ok
+		switch {
+		case !ok:
+			changes = append(changes, Change{After: sc})
+		case prev.Status != sc.Status:
+			changes = append(changes, Change{Before: prev, After: sc})
+		}
+	}
+	for i, sc := range a.Scenarios {
+		if !matched[keysA[i]] {
+			changes = append(changes, Change{Before: sc})
+		}
+	}
+	return changes
+}
+
+// diffKeys returns the keys of the scenarios of a run: file, name and
+// occurrence, which tells apart the examples of an outline as they share
+// their name
+func diffKeys(run *Run) []string {
+	keys := make([]string, len(run.Scenarios))
+	seen := make(map[string]int)
+	for i, sc := range run.Scenarios {
+		base := sc.URI + "\x00" + sc.Name
+		keys[i] = fmt.Sprintf("%s\x00%d", base, seen[base])
+		seen[base]++
+	}
+	return keys
+}
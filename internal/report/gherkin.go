@@ -0,0 +1,157 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	gherkin "github.com/cucumber/gherkin/go/v26"
+	messages "github.com/cucumber/messages/go/v21"
+)
+
+type astNode struct {
+	line    int64
+	keyword string
+}
+
+// indexDocument returns the scenarios, steps and example rows of a feature
+// file by AST node ID
+func indexDocument(doc *messages.GherkinDocument) map[string]astNode {
+	nodes := make(map[string]astNode)
+	if doc == nil || doc.Feature == nil {
+		return nodes
+	}
+
+	addSteps := func(steps []*messages.Step) {
+		for _, step := range steps {
+			nodes[step.Id] = astNode{line: step.Location.Line, keyword: step.Keyword}
+		}
+	}
+	addScenario := func(sc *messages.Scenario) {
+		nodes[sc.Id] = astNode{line: sc.Location.Line, keyword: sc.Keyword}
+		addSteps(sc.Steps)
+		for _, examples := range sc.Examples {
+			for _, row := range examples.TableBody {
+				nodes[row.Id] = astNode{line: row.Location.Line}
+			}
+		}
+	}
+
+	for _, child := range doc.Feature.Children {
+		switch {
+		case child.Scenario != nil:
+			addScenario(child.Scenario)
+		case child.Background != nil:
+			addSteps(child.Background.Steps)
+		case child.Rule != nil:
+			for _, rc := range child.Rule.Children {
+				if rc.Scenario != nil {
+					addScenario(rc.Scenario)
+				}
+				if rc.Background != nil {
+					addSteps(rc.Background.Steps)
+				}
+			}
+		}
+	}
+	return nodes
+}
+
+// featureFile is a feature file parsed again for a report, with IDs that
+// are unique within the report
+type featureFile struct {
+	uri     string
+	source  string
+	doc     *messages.GherkinDocument
+	pickles []*messages.Pickle
+	nodes   map[string]astNode
+}
+
+func loadFeatureFile(uri string, newID func() string) (*featureFile, error) {
+	data, err := os.ReadFile(uri)
+	if err != nil {
+		return nil, fmt.Errorf("reading feature file: %w", err)
+	}
+	doc, err := gherkin.ParseGherkinDocument(bytes.NewReader(data), newID)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", uri, err)
+	}
+	doc.Uri = uri
+
+	return &featureFile{
+		uri:     uri,
+		source:  string(data),
+		doc:     doc,
+		pickles: gherkin.Pickles(*doc, uri, newID),
+		nodes:   indexDocument(doc),
+	}, nil
+}
+
+// pickle returns the pickle of a recorded scenario, or nil if the feature
+// file changed since the run
+func (f *featureFile) pickle(sc *Scenario) *messages.Pickle {
+	for _, p := range f.pickles {
+		if int(f.nodes[p.AstNodeIds[0]].line) != sc.Line {
+			continue
+		}
+		if len(p.AstNodeIds) > 1 && int(f.nodes[p.AstNodeIds[1]].line) != sc.Example {
+			continue
+		}
+		return p
+	}
+	return nil
+}
+
+// scenario returns the AST node of a recorded scenario, or nil
+func (f *featureFile) scenario(sc *Scenario) *messages.Scenario {
+	var found *messages.Scenario
+	visit := func(s *messages.Scenario) {
+		if s != nil && int(s.Location.Line) == sc.Line {
+			found = s
+		}
+	}
+	for _, child := range f.doc.Feature.Children {
+		visit(child.Scenario)
+		if child.Rule != nil {
+			for _, rc := range child.Rule.Children {
+				visit(rc.Scenario)
+			}
+		}
+	}
+	return found
+}
+
+// scenarioNamed returns the only scenario with the given name, or nil
+func (f *featureFile) scenarioNamed(name string) *messages.Scenario {
+	var found []*messages.Scenario
+	visit := func(s *messages.Scenario) {
+		if s != nil && s.Name == name {
+			found = append(found, s)
+		}
+	}
+	for _, child := range f.doc.Feature.Children {
+		visit(child.Scenario)
+		if child.Rule != nil {
+			for _, rc := range child.Rule.Children {
+				visit(rc.Scenario)
+			}
+		}
+	}
+	if len(found) != 1 {
+		return nil
+	}
+	return found[0]
+}
+
+// byFeature groups the scenarios of a run by feature file, in run order
+func byFeature(run *Run) ([]string, map[string][]*Scenario) {
+	var uris []string
+	groups := make(map[string][]*Scenario)
+	for _, sc := range run.Scenarios {
+		if _, ok := groups[sc.URI]; !ok {
+			uris = append(uris, sc.URI)
+		}
+		groups[sc.URI] = append(groups[sc.URI], sc)
+	}
+	return uris, groups
+}
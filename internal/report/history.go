@@ -0,0 +1,318 @@
+package report
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	messages "github.com/cucumber/messages/go/v21"
+)
+
+// IndexPath is the results index kept across runs: one line per run with
+// the outcome of each scenario, so the history can be analyzed without
+// reading every run directory
+var IndexPath = filepath.Join(".tomato", "runs", "index.jsonl")
+
+// maxIndexRuns is the number of runs kept in the index
+const maxIndexRuns = 200
+
+// maxIndexError is the number of bytes of a failure message kept in the index
+const maxIndexError = 500
+
+// IndexEntry is the line of a run in the results index
+type IndexEntry struct {
+	Run       string          `json:"run"`
+	StartedAt time.Time       `json:"started_at"`
+	Scenarios []IndexScenario `json:"scenarios"`
+}
+
+// IndexScenario is the outcome of a scenario in a run
+type IndexScenario struct {
+	Name     string        `json:"name"`
+	URI      string        `json:"uri"`
+	Line     int           `json:"line"`
+	Inputs   string        `json:"inputs"`
+	Status   string        `json:"status"`
+	Retries  int           `json:"retries,omitempty"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// Fingerprint returns a hash of the steps of a pickle, including their doc
+// strings and data tables. Runs of a scenario with the same fingerprint ran
+// the same inputs.
+func Fingerprint(pickle *messages.Pickle) string {
+	h := sha256.New()
+	for _, step := range pickle.Steps {
+		fmt.Fprintf(h, "%s\n", step.Text)
+		if step.Argument == nil {
+			continue
+		}
+		if doc := step.Argument.DocString; doc != nil {
+			fmt.Fprintf(h, "%s\n%s\n", doc.MediaType, doc.Content)
+		}
+		if table := step.Argument.DataTable; table != nil {
+			for _, row := range table.Rows {
+				for _, cell := range row.Cells {
+					fmt.Fprintf(h, "|%s", cell.Value)
+				}
+				fmt.Fprintln(h, "|")
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+// IndexEntry returns the index line of the run. Skipped scenarios are left out.
+func (r *Run) IndexEntry() IndexEntry {
+	entry := IndexEntry{Run: r.ID, StartedAt: r.StartedAt, Scenarios: []IndexScenario{}}
+	for _, sc := range r.Scenarios {
+		if sc.Status == StatusSkipped {
+			continue
+		}
+		// A scenario that passed on retry keeps the error of its last failed attempt
+		msg := sc.Error
+		if msg == "" && len(sc.PreviousErrors) > 0 {
+			msg = sc.PreviousErrors[len(sc.PreviousErrors)-1]
+		}
+		if len(msg) > maxIndexError {
+			msg = strings.ToValidUTF8(msg[:maxIndexError], "") + "…"
+		}
+		entry.Scenarios = append(entry.Scenarios, IndexScenario{
+			Name:     sc.Name,
+			URI:      sc.URI,
+			Line:     sc.Line,
+			Inputs:   sc.Inputs,
+			Status:   sc.Status,
+			Retries:  sc.Retries,
+			Duration: sc.Duration,
+			Error:    msg,
+		})
+	}
+	return entry
+}
+
+// AppendIndex adds the run to the index at path, dropping the oldest runs
+// beyond the last maxIndexRuns
+func AppendIndex(path string, run *Run) error {
+	entries, err := ReadIndex(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	entries = append(entries, run.IndexEntry())
+	if len(entries) > maxIndexRuns {
+		entries = entries[len(entries)-maxIndexRuns:]
+	}
+	return WriteIndex(path, entries)
+}
+
+// WriteIndex replaces the index at path with the given entries
+func WriteIndex(path string, entries []IndexEntry) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return fmt.Errorf("encoding results index: %w", err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating results index directory: %w", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("writing results index: %w", err)
+	}
+	return nil
+}
+
+// ReadIndex reads the index at path, oldest run first
+func ReadIndex(path string) ([]IndexEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []IndexEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var e IndexEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("parsing %s line %d: %w", path, n, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// FlakyScenario summarizes the history of a scenario whose outcome changed
+// between runs with the same inputs
+type FlakyScenario struct {
+	Name   string `json:"name"`
+	URI    string `json:"uri"`
+	Line   int    `json:"line"` // line in the most recent run
+	Inputs string `json:"inputs"`
+
+	Runs     int `json:"runs"`
+	Failures int `json:"failures"`
+	// Flips counts the runs whose outcome differs from the run before, and
+	// the runs in which the scenario passed only on retry
+	Flips    int     `json:"flips"`
+	FlipRate float64 `json:"flip_rate"` // share of the runs that flipped
+
+	LastError   string        `json:"last_error,omitempty"`
+	LastFailed  string        `json:"last_failed_run,omitempty"` // ID of the run of LastError
+	AvgDuration time.Duration `json:"avg_duration"`
+}
+
+// Location returns the scenario's file:line
+func (f *FlakyScenario) Location() string {
+	return fmt.Sprintf("%s:%d", f.URI, f.Line)
+}
+
+// FindFlaky analyzes the index entries, oldest first, and returns the
+// scenarios whose outcome changed between runs with the same inputs,
+// most flaky first. Scenarios are told apart by file, name and inputs,
+// so edits to other scenarios of a feature do not split their history.
+func FindFlaky(entries []IndexEntry) []*FlakyScenario {
+	type history struct {
+		flaky    *FlakyScenario
+		lastPass *bool
+		total    time.Duration
+	}
+	byKey := make(map[string]*history)
+	var order []string
+
+	for _, e := range entries {
+		for _, sc := range e.Scenarios {
+			key := strings.Join([]string{sc.URI, sc.Name, sc.Inputs}, "\x00")
+			h, ok := byKey[key]
+			if !ok {
+				h = &history{flaky: &FlakyScenario{Name: sc.Name, URI: sc.URI, Inputs: sc.Inputs}}
+				byKey[key] = h
+				order = append(order, key)
+			}
+			f := h.flaky
+			f.Line = sc.Line
+			f.Runs++
+			h.total += sc.Duration
+
+			passed := sc.Status == StatusPassed
+			if !passed {
+				f.Failures++
+			}
+			if sc.Error != "" {
+				f.LastError = sc.Error
+				f.LastFailed = e.Run
+			}
+			if (h.lastPass != nil && *h.lastPass != passed) || (passed && sc.Retries > 0) {
+				f.Flips++
+			}
+			h.lastPass = &passed
+		}
+	}
+
+	var flaky []*FlakyScenario
+	for _, key := range order {
+		h := byKey[key]
+		f := h.flaky
+		if f.Flips == 0 {
+			continue
+		}
+		f.AvgDuration = h.total / time.Duration(f.Runs)
+		f.FlipRate = float64(f.Flips) / float64(f.Runs)
+		flaky = append(flaky, f)
+	}
+	sort.SliceStable(flaky, func(i, j int) bool {
+		if flaky[i].FlipRate != flaky[j].FlipRate {
+			return flaky[i].FlipRate > flaky[j].FlipRate
+		}
+		return flaky[i].Flips > flaky[j].Flips
+	})
+	return flaky
+}
+
+// TagScenarios adds tag on its own line above each of the scenarios in its
+// feature file, unless the scenario already has it, and returns the
+// locations it tagged. A scenario is found by the line it had in the most
+// recent run or, when the file changed since, by its name.
+func TagScenarios(scenarios []*FlakyScenario, tag string) ([]string, error) {
+	var uris []string
+	byURI := make(map[string][]*FlakyScenario)
+	for _, sc := range scenarios {
+		if _, ok := byURI[sc.URI]; !ok {
+			uris = append(uris, sc.URI)
+		}
+		byURI[sc.URI] = append(byURI[sc.URI], sc)
+	}
+
+	var tagged []string
+	for _, uri := range uris {
+		file, err := loadFeatureFile(uri, (&messages.Incrementing{}).NewId)
+		if err != nil {
+			return tagged, err
+		}
+		var targets []*messages.Scenario
+		for _, f := range byURI[uri] {
+			// Outline names may have <placeholders> that differ in each row
+			sc := file.scenario(&Scenario{Line: f.Line})
+			if sc == nil || (sc.Name != f.Name && !strings.Contains(sc.Name, "<")) {
+				if sc = file.scenarioNamed(f.Name); sc == nil {
+					return tagged, fmt.Errorf("%s: scenario %q not found; it was renamed or removed since the last run", f.Location(), f.Name)
+				}
+			}
+			if !slices.Contains(targets, sc) {
+				targets = append(targets, sc)
+			}
+		}
+
+		source := strings.SplitAfter(file.source, "\n")
+		var added []string
+
+		// Insert from the bottom up so the lines above keep their numbers
+		slices.SortFunc(targets, func(a, b *messages.Scenario) int { return int(a.Location.Line - b.Location.Line) })
+		for _, sc := range slices.Backward(targets) {
+			if slices.ContainsFunc(sc.Tags, func(t *messages.Tag) bool { return t.Name == tag }) {
+				continue
+			}
+			line := int(sc.Location.Line)
+			text := source[line-1]
+			indent := text[:len(text)-len(strings.TrimLeft(text, " \t"))]
+			eol := text[len(strings.TrimRight(text, "\r\n")):]
+			if eol == "" {
+				eol = "\n"
+			}
+			source = slices.Insert(source, line-1, indent+tag+eol)
+			added = append(added, fmt.Sprintf("%s:%d", uri, line))
+		}
+		if len(added) == 0 {
+			continue
+		}
+
+		info, err := os.Stat(uri)
+		if err != nil {
+			return tagged, err
+		}
+		if err := os.WriteFile(uri, []byte(strings.Join(source, "")), info.Mode().Perm()); err != nil {
+			return tagged, fmt.Errorf("writing %s: %w", uri, err)
+		}
+		slices.Reverse(added)
+		tagged = append(tagged, added...)
+	}
+	return tagged, nil
+}
@@ -0,0 +1,145 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	messages "github.com/cucumber/messages/go/v21"
+)
+
+func TestFingerprint(t *testing.T) {
+	pickle := func(body string) *messages.Pickle {
+		return &messages.Pickle{Steps: []*messages.PickleStep{
+			{Text: `"api" sends "POST" to "/users" with json:`, Argument: &messages.PickleStepArgument{
+				DocString: &messages.PickleDocString{Content: body},
+			}},
+			{Text: `"api" response status is "201"`},
+		}}
+	}
+
+	a := Fingerprint(pickle(`{"name":"alice"}`))
+	if len(a) != 12 {
+		t.Errorf("Fingerprint = %q, want 12 hex characters", a)
+	}
+	if b := Fingerprint(pickle(`{"name":"alice"}`)); b != a {
+		t.Errorf("same steps should have the same fingerprint: %q != %q", a, b)
+	}
+	if b := Fingerprint(pickle(`{"name":"bob"}`)); b == a {
+		t.Error("a different doc string should change the fingerprint")
+	}
+}
+
+func TestAppendIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "runs", "index.jsonl")
+	for i := 0; i < maxIndexRuns+2; i++ {
+		run := &Run{
+			ID: string(rune('a' + i%26)),
+			Scenarios: []*Scenario{
+				{Name: "passes on retry", URI: "a.feature", Line: 3, Status: StatusPassed, Retries: 1, PreviousErrors: []string{"boom"}},
+				{Name: "filtered", URI: "a.feature", Line: 9, Status: StatusSkipped},
+			},
+		}
+		if err := AppendIndex(path, run); err != nil {
+			t.Fatalf("AppendIndex failed: %v", err)
+		}
+	}
+
+	entries, err := ReadIndex(path)
+	if err != nil {
+		t.Fatalf("ReadIndex failed: %v", err)
+	}
+	if len(entries) != maxIndexRuns || entries[0].Run != "c" {
+		t.Fatalf("got %d entries starting with run %q, want %d starting with %q", len(entries), entries[0].Run, maxIndexRuns, "c")
+	}
+	scenarios := entries[0].Scenarios
+	if len(scenarios) != 1 || scenarios[0].Error != "boom" || scenarios[0].Retries != 1 {
+		t.Errorf("scenarios = %+v, want the retried scenario with its last error", scenarios)
+	}
+}
+
+func TestFindFlaky(t *testing.T) {
+	sc := func(name, inputs, status string, duration time.Duration) IndexScenario {
+		s := IndexScenario{Name: name, URI: "a.feature", Line: 3, Inputs: inputs, Status: status, Duration: duration}
+		if status == StatusFailed {
+			s.Error = name + " failed"
+		}
+		return s
+	}
+	entries := []IndexEntry{
+		{Run: "r1", Scenarios: []IndexScenario{
+			sc("flips", "x", StatusPassed, time.Second),
+			sc("stable", "x", StatusPassed, time.Second),
+			sc("changed inputs", "x", StatusPassed, time.Second),
+		}},
+		{Run: "r2", Scenarios: []IndexScenario{
+			sc("flips", "x", StatusFailed, 3*time.Second),
+			sc("stable", "x", StatusPassed, time.Second),
+			sc("changed inputs", "y", StatusFailed, time.Second),
+		}},
+		{Run: "r3", Scenarios: []IndexScenario{
+			sc("flips", "x", StatusPassed, 2*time.Second),
+			sc("stable", "x", StatusPassed, time.Second),
+			{Name: "retried", URI: "b.feature", Line: 7, Inputs: "x", Status: StatusPassed, Retries: 1, Error: "timeout"},
+		}},
+	}
+
+	flaky := FindFlaky(entries)
+	if len(flaky) != 2 {
+		t.Fatalf("got %d flaky scenarios, want 2: %+v", len(flaky), flaky)
+	}
+
+	retried := flaky[0]
+	if retried.Name != "retried" || retried.FlipRate != 1 || retried.Failures != 0 || retried.LastError != "timeout" {
+		t.Errorf("retried = %+v", retried)
+	}
+
+	flips := flaky[1]
+	if flips.Name != "flips" || flips.Runs != 3 || flips.Flips != 2 || flips.Failures != 1 {
+		t.Errorf("flips = %+v", flips)
+	}
+	if flips.LastError != "flips failed" || flips.LastFailed != "r2" || flips.AvgDuration != 2*time.Second {
+		t.Errorf("flips = %+v", flips)
+	}
+	if flips.Location() != "a.feature:3" {
+		t.Errorf("Location() = %q", flips.Location())
+	}
+}
+
+func TestTagScenarios(t *testing.T) {
+	uri := filepath.Join(t.TempDir(), "orders.feature")
+	feature := "Feature: Orders\n\n  Scenario: create\n    Given x\n\n  @smoke\n  Scenario: list\n    Given y\n\n  @flaky\n  Scenario: delete\n    Given z\n"
+	if err := os.WriteFile(uri, []byte(feature), 0644); err != nil {
+		t.Fatal(err)
+	}
+	flaky := []*FlakyScenario{
+		{Name: "list", URI: uri, Line: 7},
+		{Name: "create", URI: uri, Line: 3},
+		{Name: "create", URI: uri, Line: 3}, // another row of an outline
+		{Name: "delete", URI: uri, Line: 11},
+	}
+
+	tagged, err := TagScenarios(flaky, "@flaky")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{uri + ":3", uri + ":7"}; !slices.Equal(tagged, want) {
+		t.Errorf("tagged = %v, want %v", tagged, want)
+	}
+	data, _ := os.ReadFile(uri)
+	want := "Feature: Orders\n\n  @flaky\n  Scenario: create\n    Given x\n\n  @smoke\n  @flaky\n  Scenario: list\n    Given y\n\n  @flaky\n  Scenario: delete\n    Given z\n"
+	if string(data) != want {
+		t.Errorf("feature file =\n%s\nwant\n%s", data, want)
+	}
+
+	// The scenarios moved down since the run, so they are found by name
+	tagged, err = TagScenarios(flaky, "@flaky")
+	if err != nil || len(tagged) != 0 {
+		t.Errorf("tagging again = %v, %v, want nothing tagged", tagged, err)
+	}
+	if _, err := TagScenarios([]*FlakyScenario{{Name: "gone", URI: uri, Line: 3}}, "@flaky"); err == nil {
+		t.Error("expected an error for a removed scenario")
+	}
+}
@@ -0,0 +1,139 @@
+package report
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tomatool/tomato/internal/runlog"
+)
+
+// HTMLFileName is the name of the HTML report in a run directory
+const HTMLFileName = "report.html"
+
+// logWindowSlack extends a scenario's log window, as container log lines
+// are timestamped when tomato receives them rather than when written
+const logWindowSlack = time.Second
+
+//go:embed html.tmpl
+var htmlTemplate string
+
+var htmlReport = template.Must(template.New("report").Funcs(template.FuncMap{
+	"duration": formatDuration,
+	"join":     strings.Join,
+	"inc":      func(i int) int { return i + 1 },
+	"millis": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return fmt.Sprint(t.UnixMilli())
+	},
+}).Parse(htmlTemplate))
+
+// ContainerLog is the log of a container during the run
+type ContainerLog struct {
+	Name  string
+	Lines []runlog.LogLine
+}
+
+type htmlData struct {
+	Run                            *Run
+	Passed, Failed, Skipped, Flaky int
+	Features                       []htmlFeature
+	Logs                           []ContainerLog
+	Windows                        []htmlWindow
+}
+
+type htmlFeature struct {
+	Name      string
+	URI       string
+	Scenarios []htmlScenario
+}
+
+type htmlScenario struct {
+	*Scenario
+	ID string // element ID
+}
+
+// htmlWindow is the time window of a failed scenario, in Unix milliseconds
+type htmlWindow struct {
+	ID, Name, Location string
+	From, To           int64
+}
+
+// WriteHTML writes the results as a self-contained HTML page. Container logs
+// are embedded and can be filtered by the time window of a failed scenario.
+func WriteHTML(w io.Writer, run *Run, logs []ContainerLog) error {
+	data := htmlData{Run: run, Logs: logs}
+	data.Passed, data.Failed, data.Skipped = run.Counts()
+	data.Flaky = len(run.Flaky())
+
+	uris, groups := byFeature(run)
+	n := 0
+	for _, uri := range uris {
+		feature := htmlFeature{Name: groups[uri][0].Feature, URI: uri}
+		for _, sc := range groups[uri] {
+			n++
+			hs := htmlScenario{Scenario: sc, ID: fmt.Sprintf("scenario-%d", n)}
+			feature.Scenarios = append(feature.Scenarios, hs)
+			if sc.Failed() && !sc.StartedAt.IsZero() {
+				data.Windows = append(data.Windows, htmlWindow{
+					ID:       hs.ID,
+					Name:     sc.Name,
+					Location: sc.Location(),
+					From:     sc.StartedAt.Add(-logWindowSlack).UnixMilli(),
+					To:       sc.StartedAt.Add(sc.Duration + logWindowSlack).UnixMilli(),
+				})
+			}
+		}
+		data.Features = append(data.Features, feature)
+	}
+
+	return htmlReport.Execute(w, data)
+}
+
+// WriteHTMLFile writes report.html to the run directory dir, embedding the
+// container logs stored there
+func WriteHTMLFile(dir string, run *Run) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "container-*.log"))
+	if err != nil {
+		return err
+	}
+	var logs []ContainerLog
+	for _, path := range paths {
+		lines, err := runlog.ReadLog(path)
+		if err != nil {
+			return fmt.Errorf("reading container log: %w", err)
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "container-"), ".log")
+		logs = append(logs, ContainerLog{Name: name, Lines: lines})
+	}
+
+	path := filepath.Join(dir, HTMLFileName)
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating HTML report: %w", err)
+	}
+	if err := WriteHTML(f, run, logs); err != nil {
+		f.Close()
+		return fmt.Errorf("writing HTML report: %w", err)
+	}
+	return f.Close()
+}
+
+// formatDuration rounds a duration for display, e.g. 1.25s or 310ms
+func formatDuration(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(10 * time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(time.Millisecond).String()
+	default:
+		return d.String()
+	}
+}
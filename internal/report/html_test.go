@@ -0,0 +1,71 @@
+package report
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tomatool/tomato/internal/runlog"
+)
+
+func TestWriteHTML(t *testing.T) {
+	run := testRun(t)
+	run.ResetLevel = "feature"
+	start := run.Scenarios[0].StartedAt
+	logs := []ContainerLog{{
+		Name: "postgres",
+		Lines: []runlog.LogLine{
+			{Time: start.Add(100 * time.Millisecond), Text: "ERROR: relation <users> does not exist"},
+			{Text: "untimestamped"},
+		},
+	}}
+
+	var buf bytes.Buffer
+	if err := WriteHTML(&buf, run, logs); err != nil {
+		t.Fatalf("WriteHTML failed: %v", err)
+	}
+	page := buf.String()
+
+	for _, want := range []string{
+		`<details class="scenario" id="scenario-1" open>`,
+		"expected status 204, got 500",
+		"DELETE /users/1",
+		"panic: nil map",
+		"reset level feature",
+		"ERROR: relation &lt;users&gt; does not exist",
+		`data-window="scenario-1"`,
+		`data-from="` + strconv.FormatInt(start.Add(-logWindowSlack).UnixMilli(), 10) + `" data-to="` + strconv.FormatInt(start.Add(300*time.Millisecond+logWindowSlack).UnixMilli(), 10) + `"`,
+		`data-time="` + strconv.FormatInt(start.Add(100*time.Millisecond).UnixMilli(), 10) + `"`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("report should contain %q", want)
+		}
+	}
+	if strings.Contains(page, "<users>") {
+		t.Error("log lines should be escaped")
+	}
+}
+
+func TestWriteHTMLFile(t *testing.T) {
+	run := testRun(t)
+	dir := t.TempDir()
+	line := run.Scenarios[0].StartedAt.Format(time.RFC3339Nano) + " ready to accept connections\n"
+	if err := os.WriteFile(filepath.Join(dir, "container-postgres.log"), []byte(line), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteHTMLFile(dir, run); err != nil {
+		t.Fatalf("WriteHTMLFile failed: %v", err)
+	}
+	page, err := os.ReadFile(filepath.Join(dir, HTMLFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(page), `<option value="postgres">postgres</option>`) || !strings.Contains(string(page), "ready to accept connections") {
+		t.Error("report should embed the container log")
+	}
+}
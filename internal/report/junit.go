@@ -0,0 +1,162 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// JUnit XML as understood by Jenkins, GitLab and the GitHub test reporters
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	File       string          `xml:"file,attr,omitempty"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr,omitempty"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr"`
+	Line      int           `xml:"line,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the results as JUnit XML, with one testsuite per feature
+// file and one testcase per scenario
+func WriteJUnit(w io.Writer, run *Run) error {
+	doc := junitTestSuites{
+		Name: "tomato",
+		Time: seconds(run.Duration),
+	}
+
+	suites := make(map[string]*junitTestSuite)
+	durations := make(map[string]time.Duration)
+	var order []string
+
+	for _, sc := range run.Scenarios {
+		suite, ok := suites[sc.URI]
+		if !ok {
+			suite = &junitTestSuite{
+				Name:      sc.Feature,
+				File:      sc.URI,
+				Timestamp: sc.StartedAt.Format(time.RFC3339),
+			}
+			if run.ResetLevel != "" {
+				suite.Properties = []junitProperty{{Name: "reset_level", Value: run.ResetLevel}}
+			}
+			suites[sc.URI] = suite
+			order = append(order, sc.URI)
+		}
+
+		tc := junitTestCase{
+			Name:      sc.Name,
+			Classname: sc.Feature,
+			File:      sc.URI,
+			Line:      sc.Line,
+			Time:      seconds(sc.Duration),
+		}
+
+		switch {
+		case sc.Status == StatusSkipped:
+			tc.Skipped = &struct{}{}
+			suite.Skipped++
+			doc.Skipped++
+		case sc.Failed():
+			tc.Failure = junitFailureOf(sc)
+			suite.Failures++
+			doc.Failures++
+		}
+
+		var out []string
+		if sc.Retries > 0 {
+			out = append(out, sc.Outcome())
+			for i, msg := range sc.PreviousErrors {
+				out = append(out, fmt.Sprintf("attempt %d: %s", i+1, msg))
+			}
+		}
+		if len(sc.AppLogs) > 0 {
+			out = append(out, "app logs:")
+			out = append(out, sc.AppLogs...)
+		}
+		tc.SystemOut = strings.Join(out, "\n")
+
+		suite.Cases = append(suite.Cases, tc)
+		suite.Tests++
+		doc.Tests++
+		durations[sc.URI] += sc.Duration
+	}
+
+	for _, uri := range order {
+		suite := suites[uri]
+		suite.Time = seconds(durations[uri])
+		doc.Suites = append(doc.Suites, *suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func junitFailureOf(sc *Scenario) *junitFailure {
+	f := &junitFailure{Message: sc.Error, Type: sc.Status}
+	if step := sc.FailedStep(); step != nil {
+		if f.Message == "" {
+			f.Message = step.Error
+		}
+		f.Text = fmt.Sprintf("%s%s (%s:%d)\n%s", step.Keyword, step.Text, sc.URI, step.Line, step.Error)
+		for _, a := range step.Attachments {
+			if a.MediaType == "text/plain" {
+				f.Text += fmt.Sprintf("\n\n%s:\n%s", a.Name, a.Body)
+			}
+		}
+	} else {
+		f.Text = sc.Error
+	}
+	if f.Message == "" {
+		f.Message = "scenario " + sc.Status
+	}
+	return f
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
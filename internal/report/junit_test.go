@@ -0,0 +1,73 @@
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteJUnit(t *testing.T) {
+	run := &Run{
+		Duration:   3 * time.Second,
+		ResetLevel: "feature",
+		Scenarios: []*Scenario{
+			{Feature: "Users", Name: "create", URI: "features/users.feature", Line: 3, Status: StatusPassed, Duration: 1500 * time.Millisecond},
+			{
+				Feature: "Users", Name: "delete", URI: "features/users.feature", Line: 9, Status: StatusFailed,
+				Error:    "expected status 204, got 500",
+				Duration: time.Second,
+				Steps: []*Step{
+					{Keyword: "When ", Text: `I send "DELETE" to "/users/1"`, Line: 10, Status: StatusPassed},
+					{Keyword: "Then ", Text: "the status is 204", Line: 11, Status: StatusFailed, Error: "expected status 204, got 500"},
+				},
+				AppLogs: []string{"panic: nil map", "goroutine 1"},
+			},
+			{Feature: "Orders", Name: "flaky", URI: "features/orders.feature", Line: 5, Status: StatusPassed, Retries: 1, PreviousErrors: []string{"timeout"}},
+			{Feature: "Orders", Name: "filtered", URI: "features/orders.feature", Line: 12, Status: StatusSkipped},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteJUnit(&buf, run); err != nil {
+		t.Fatalf("WriteJUnit failed: %v", err)
+	}
+
+	var doc junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, buf.String())
+	}
+
+	if doc.Tests != 4 || doc.Failures != 1 || doc.Skipped != 1 || doc.Time != "3.000" {
+		t.Errorf("testsuites = %d tests, %d failures, %d skipped, %s s", doc.Tests, doc.Failures, doc.Skipped, doc.Time)
+	}
+	if len(doc.Suites) != 2 || doc.Suites[0].Name != "Users" || doc.Suites[0].Time != "2.500" {
+		t.Fatalf("unexpected testsuites %+v", doc.Suites)
+	}
+	if props := doc.Suites[1].Properties; len(props) != 1 || props[0] != (junitProperty{Name: "reset_level", Value: "feature"}) {
+		t.Errorf("properties = %+v, want the reset level", props)
+	}
+
+	failed := doc.Suites[0].Cases[1]
+	if failed.File != "features/users.feature" || failed.Line != 9 || failed.Time != "1.000" {
+		t.Errorf("testcase = %+v", failed)
+	}
+	if failed.Failure == nil || failed.Failure.Message != "expected status 204, got 500" {
+		t.Fatalf("failure = %+v", failed.Failure)
+	}
+	if !strings.Contains(failed.Failure.Text, "Then the status is 204 (features/users.feature:11)") {
+		t.Errorf("failure text %q should name the failed step", failed.Failure.Text)
+	}
+	if !strings.Contains(failed.SystemOut, "panic: nil map") {
+		t.Errorf("system-out %q should contain the app logs", failed.SystemOut)
+	}
+
+	flaky := doc.Suites[1].Cases[0]
+	if flaky.Failure != nil || !strings.Contains(flaky.SystemOut, "passed on retry 1") {
+		t.Errorf("flaky testcase = %+v", flaky)
+	}
+	if doc.Suites[1].Cases[1].Skipped == nil {
+		t.Error("filtered scenario should be skipped")
+	}
+}
@@ -0,0 +1,216 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"time"
+
+	messages "github.com/cucumber/messages/go/v21"
+	"github.com/tomatool/tomato/internal/version"
+)
+
+// messagesProtocolVersion is the version of the Cucumber Messages protocol written
+const messagesProtocolVersion = "21.0.1"
+
+// WriteMessages writes the results as a Cucumber Messages NDJSON stream. The
+// feature files are read again for the source, document and pickle messages.
+// Steps reference their step definition, whose source is the resource
+// implementing it.
+func WriteMessages(w io.Writer, run *Run) error {
+	ids := &messages.Incrementing{}
+	enc := json.NewEncoder(w)
+	emit := func(e *messages.Envelope) error {
+		return enc.Encode(e)
+	}
+
+	if err := emit(&messages.Envelope{Meta: &messages.Meta{
+		ProtocolVersion: messagesProtocolVersion,
+		Implementation:  &messages.Product{Name: "tomato", Version: version.Version},
+		Runtime:         &messages.Product{Name: "go", Version: runtime.Version()},
+		Os:              &messages.Product{Name: runtime.GOOS},
+		Cpu:             &messages.Product{Name: runtime.GOARCH},
+	}}); err != nil {
+		return err
+	}
+
+	// Sources, documents and the pickles that ran
+	uris, groups := byFeature(run)
+	pickles := make(map[*Scenario]*messages.Pickle)
+	for _, uri := range uris {
+		file, err := loadFeatureFile(uri, ids.NewId)
+		if err != nil {
+			return err
+		}
+		if err := emit(&messages.Envelope{Source: &messages.Source{
+			Uri:       uri,
+			Data:      file.source,
+			MediaType: "text/x.cucumber.gherkin+plain",
+		}}); err != nil {
+			return err
+		}
+		if err := emit(&messages.Envelope{GherkinDocument: file.doc}); err != nil {
+			return err
+		}
+		for _, sc := range groups[uri] {
+			pickle := file.pickle(sc)
+			if pickle == nil {
+				return fmt.Errorf("%s: scenario %q not found, the feature file changed since the run", sc.Location(), sc.Name)
+			}
+			pickles[sc] = pickle
+			if err := emit(&messages.Envelope{Pickle: pickle}); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Step definitions, one per pattern
+	definitions := make(map[Match]string)
+	for _, sc := range run.Scenarios {
+		for _, step := range sc.Steps {
+			if step.Match == nil {
+				continue
+			}
+			if _, ok := definitions[*step.Match]; ok {
+				continue
+			}
+			id := ids.NewId()
+			definitions[*step.Match] = id
+			if err := emit(&messages.Envelope{StepDefinition: &messages.StepDefinition{
+				Id: id,
+				Pattern: &messages.StepDefinitionPattern{
+					Source: step.Match.Pattern,
+					Type:   messages.StepDefinitionPatternType_REGULAR_EXPRESSION,
+				},
+				SourceReference: &messages.SourceReference{Uri: "resource:" + step.Match.Resource},
+			}}); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := emit(&messages.Envelope{TestRunStarted: &messages.TestRunStarted{
+		Timestamp: timestamp(run.StartedAt),
+	}}); err != nil {
+		return err
+	}
+
+	for _, sc := range run.Scenarios {
+		if err := writeTestCase(emit, ids, sc, pickles[sc], definitions); err != nil {
+			return err
+		}
+	}
+
+	return emit(&messages.Envelope{TestRunFinished: &messages.TestRunFinished{
+		Success:   len(run.Failed()) == 0,
+		Timestamp: timestamp(run.FinishedAt),
+	}})
+}
+
+// writeTestCase writes the test case of a scenario and each of its attempts.
+// The test case has a test step per pickle step; steps an attempt didn't
+// record, e.g. after a failed before_scenario hook, are reported as skipped.
+func writeTestCase(emit func(*messages.Envelope) error, ids *messages.Incrementing, sc *Scenario, pickle *messages.Pickle, definitions map[Match]string) error {
+	attempts := append(sc.Attempts[:len(sc.Attempts):len(sc.Attempts)], &Attempt{
+		Status:    sc.Status,
+		Error:     sc.Error,
+		StartedAt: sc.StartedAt,
+		Duration:  sc.Duration,
+		Steps:     sc.Steps,
+	})
+
+	// Recorded steps are in pickle step order
+	testCase := &messages.TestCase{Id: ids.NewId(), PickleId: pickle.Id}
+	for i, ps := range pickle.Steps {
+		ts := &messages.TestStep{Id: ids.NewId(), PickleStepId: ps.Id, StepDefinitionIds: []string{}}
+		for _, a := range attempts {
+			if i < len(a.Steps) && a.Steps[i].Match != nil {
+				ts.StepDefinitionIds = []string{definitions[*a.Steps[i].Match]}
+				break
+			}
+		}
+		testCase.TestSteps = append(testCase.TestSteps, ts)
+	}
+	if err := emit(&messages.Envelope{TestCase: testCase}); err != nil {
+		return err
+	}
+
+	for n, a := range attempts {
+		last := n == len(attempts)-1
+		started := &messages.TestCaseStarted{
+			Attempt:    int64(n),
+			Id:         ids.NewId(),
+			TestCaseId: testCase.Id,
+			Timestamp:  timestamp(a.StartedAt),
+		}
+		if err := emit(&messages.Envelope{TestCaseStarted: started}); err != nil {
+			return err
+		}
+
+		// App logs are only kept for the last attempt
+		var failed *Step
+		if last {
+			failed = sc.FailedStep()
+		}
+		at := a.StartedAt
+		for i, ts := range testCase.TestSteps {
+			step := &Step{Status: StatusSkipped, StartedAt: at}
+			if i < len(a.Steps) {
+				step = a.Steps[i]
+			}
+			if err := writeTestStep(emit, started.Id, ts.Id, sc, step, step == failed); err != nil {
+				return err
+			}
+			at = step.StartedAt.Add(step.Duration)
+		}
+
+		if err := emit(&messages.Envelope{TestCaseFinished: &messages.TestCaseFinished{
+			TestCaseStartedId: started.Id,
+			Timestamp:         timestamp(a.StartedAt.Add(a.Duration)),
+			WillBeRetried:     !last,
+		}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeTestStep(emit func(*messages.Envelope) error, testCaseStartedID, testStepID string, sc *Scenario, step *Step, failed bool) error {
+	if err := emit(&messages.Envelope{TestStepStarted: &messages.TestStepStarted{
+		TestCaseStartedId: testCaseStartedID,
+		TestStepId:        testStepID,
+		Timestamp:         timestamp(step.StartedAt),
+	}}); err != nil {
+		return err
+	}
+	for _, a := range stepAttachments(sc, step, failed) {
+		if err := emit(&messages.Envelope{Attachment: &messages.Attachment{
+			Body:              a.Body,
+			ContentEncoding:   messages.AttachmentContentEncoding_IDENTITY,
+			FileName:          a.Name,
+			MediaType:         a.MediaType,
+			TestCaseStartedId: testCaseStartedID,
+			TestStepId:        testStepID,
+		}}); err != nil {
+			return err
+		}
+	}
+	duration := messages.GoDurationToDuration(step.Duration)
+	return emit(&messages.Envelope{TestStepFinished: &messages.TestStepFinished{
+		TestCaseStartedId: testCaseStartedID,
+		TestStepId:        testStepID,
+		TestStepResult: &messages.TestStepResult{
+			Duration: &duration,
+			Message:  step.Error,
+			Status:   messages.TestStepResultStatus(strings.ToUpper(step.Status)),
+		},
+		Timestamp: timestamp(step.StartedAt.Add(step.Duration)),
+	}})
+}
+
+func timestamp(t time.Time) *messages.Timestamp {
+	ts := messages.GoTimeToTimestamp(t)
+	return &ts
+}
@@ -0,0 +1,297 @@
+package report
+
+import (
+	"sync"
+	"time"
+
+	messages "github.com/cucumber/messages/go/v21"
+)
+
+// Recorder collects scenario and step results while godog runs. It is fed
+// by the runner's scenario and step hooks and is safe for concurrent use.
+// A nil Recorder discards everything.
+type Recorder struct {
+	mu  sync.Mutex
+	run *Run
+
+	running    map[string]*Scenario // in-flight scenarios by pickle ID
+	stepStarts map[string]time.Time // start times by pickle step ID
+	scenarios  map[string]*Scenario // scenarios of the current godog run by pickle ID
+	steps      map[string]*Step     // finished steps of the current godog run by pickle step ID
+	nodes      map[string]astNode   // gherkin AST nodes by ID
+	features   map[string]string    // feature names by file path
+
+	// During a retry attempt, the scenarios being retried by key
+	attempt  int
+	retrying map[string]*Scenario
+}
+
+// NewRecorder creates a recorder for the run with the given ID
+func NewRecorder(runID string) *Recorder {
+	return &Recorder{
+		run: &Run{
+			ID:        runID,
+			StartedAt: time.Now(),
+		},
+		running:    make(map[string]*Scenario),
+		stepStarts: make(map[string]time.Time),
+		scenarios:  make(map[string]*Scenario),
+		steps:      make(map[string]*Step),
+		nodes:      make(map[string]astNode),
+		features:   make(map[string]string),
+	}
+}
+
+// AddDocument indexes a parsed feature file, so that scenarios and steps
+// can be reported with their line numbers and feature name. Documents must
+// be added again before each retry attempt, as node IDs are per godog run.
+func (r *Recorder) AddDocument(doc *messages.GherkinDocument) {
+	if r == nil || doc == nil || doc.Feature == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	uri, _ := SplitURI(doc.Uri)
+	r.features[uri] = doc.Feature.Name
+	for id, node := range indexDocument(doc) {
+		r.nodes[id] = node
+	}
+}
+
+// ScenarioStarted records the start of a scenario. During a retry attempt it
+// returns false for scenarios that are not being retried, which are then
+// skipped and not recorded.
+func (r *Recorder) ScenarioStarted(pickle *messages.Pickle) bool {
+	if r == nil {
+		return true
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	uri, line := SplitURI(pickle.Uri)
+	if len(pickle.AstNodeIds) > 0 {
+		if node, ok := r.nodes[pickle.AstNodeIds[0]]; ok {
+			line = int(node.line)
+		}
+	}
+	var example int
+	if len(pickle.AstNodeIds) > 1 {
+		example = int(r.nodes[pickle.AstNodeIds[1]].line)
+	}
+
+	if r.retrying != nil {
+		key := (&Scenario{URI: uri, Line: line, Example: example}).key()
+		sc, ok := r.retrying[key]
+		if !ok {
+			return false
+		}
+		delete(r.retrying, key)
+
+		sc.PreviousErrors = append(sc.PreviousErrors, sc.Error)
+		sc.Attempts = append(sc.Attempts, &Attempt{
+			Status:    sc.Status,
+			Error:     sc.Error,
+			StartedAt: sc.StartedAt,
+			Duration:  sc.Duration,
+			Steps:     sc.Steps,
+		})
+		sc.Retries = r.attempt
+		sc.Status, sc.Error, sc.Steps, sc.AppLogs = "", "", nil, nil
+		sc.Reset, sc.Hooks = 0, 0
+		sc.StartedAt = time.Now()
+		r.running[pickle.Id] = sc
+		r.scenarios[pickle.Id] = sc
+		return true
+	}
+
+	tags := make([]string, len(pickle.Tags))
+	for i, tag := range pickle.Tags {
+		tags[i] = tag.Name
+	}
+
+	sc := &Scenario{
+		Feature:   r.features[uri],
+		Name:      pickle.Name,
+		URI:       uri,
+		Line:      line,
+		Example:   example,
+		Tags:      tags,
+		Inputs:    Fingerprint(pickle),
+		StartedAt: time.Now(),
+	}
+	r.running[pickle.Id] = sc
+	r.scenarios[pickle.Id] = sc
+	r.run.Scenarios = append(r.run.Scenarios, sc)
+	return true
+}
+
+// BeginRetry starts retry attempt n (counting from 1) of the given scenarios.
+// Their results are replaced by those of the new attempt.
+func (r *Recorder) BeginRetry(attempt int, scenarios []*Scenario) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.attempt = attempt
+	r.scenarios = make(map[string]*Scenario)
+	r.steps = make(map[string]*Step)
+	r.retrying = make(map[string]*Scenario, len(scenarios))
+	for _, sc := range scenarios {
+		r.retrying[sc.key()] = sc
+	}
+}
+
+// Results returns the results recorded so far
+func (r *Recorder) Results() *Run {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.run
+}
+
+// StepStarted records the start of a step
+func (r *Recorder) StepStarted(step *messages.PickleStep) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stepStarts[step.Id] = time.Now()
+}
+
+// StepFinished records the result of a step of the given scenario. match is
+// nil for steps that did not run.
+func (r *Recorder) StepFinished(pickleID string, step *messages.PickleStep, status string, err error, match *Match, attachments ...*Attachment) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sc, ok := r.running[pickleID]
+	if !ok {
+		return
+	}
+
+	result := &Step{Text: step.Text, Status: status, Match: match, Attachments: attachments}
+	if len(step.AstNodeIds) > 0 {
+		node := r.nodes[step.AstNodeIds[0]]
+		result.Line, result.Keyword = int(node.line), node.keyword
+	}
+	if start, ok := r.stepStarts[step.Id]; ok {
+		result.StartedAt = start
+		result.Duration = time.Since(start)
+		delete(r.stepStarts, step.Id)
+	}
+	if err != nil && status != StatusPassed && status != StatusSkipped {
+		result.Error = err.Error()
+	}
+	sc.Steps = append(sc.Steps, result)
+	r.steps[step.Id] = result
+}
+
+// AddOverhead adds time a scenario spent resetting resources and running
+// scenario hooks
+func (r *Recorder) AddOverhead(pickleID string, reset, hooks time.Duration) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if sc, ok := r.running[pickleID]; ok {
+		sc.Reset += reset
+		sc.Hooks += hooks
+	}
+}
+
+// Scenario returns a copy of the recorded scenario with the given pickle ID
+func (r *Recorder) Scenario(pickleID string) (Scenario, bool) {
+	if r == nil {
+		return Scenario{}, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sc, ok := r.scenarios[pickleID]
+	if !ok {
+		return Scenario{}, false
+	}
+	return *sc, true
+}
+
+// Step returns a copy of the recorded step with the given pickle step ID
+func (r *Recorder) Step(stepID string) (Step, bool) {
+	if r == nil {
+		return Step{}, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	step, ok := r.steps[stepID]
+	if !ok {
+		return Step{}, false
+	}
+	return *step, true
+}
+
+// AttachAppLogs records the tail of the application log for a scenario
+func (r *Recorder) AttachAppLogs(pickleID string, lines []string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if sc, ok := r.running[pickleID]; ok {
+		sc.AppLogs = lines
+	}
+}
+
+// ScenarioFinished records the final status of a scenario
+func (r *Recorder) ScenarioFinished(pickleID, status string, err error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sc, ok := r.running[pickleID]
+	if !ok {
+		return
+	}
+	delete(r.running, pickleID)
+
+	sc.Status = status
+	sc.Duration = time.Since(sc.StartedAt)
+	if err != nil && status != StatusPassed && status != StatusSkipped {
+		sc.Error = err.Error()
+	}
+}
+
+// Finish marks the run as finished and returns its results
+func (r *Recorder) Finish() *Run {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.retrying = nil
+	r.run.FinishedAt = time.Now()
+	r.run.Duration = r.run.FinishedAt.Sub(r.run.StartedAt)
+	r.run.Status = StatusPassed
+	for _, sc := range r.run.Scenarios {
+		if sc.Failed() {
+			r.run.Status = StatusFailed
+			break
+		}
+	}
+	return r.run
+}
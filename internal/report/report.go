@@ -0,0 +1,286 @@
+// Package report records the results of a test run and persists them in
+// the run directory, so later runs and commands can use them.
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileName is the name of the results file in a run directory
+const FileName = "results.json"
+
+// Scenario and step statuses
+const (
+	StatusPassed    = "passed"
+	StatusFailed    = "failed"
+	StatusSkipped   = "skipped"
+	StatusUndefined = "undefined"
+	StatusPending   = "pending"
+	StatusAmbiguous = "ambiguous"
+)
+
+// Run holds the results of a test run
+type Run struct {
+	ID         string        `json:"id"`
+	StartedAt  time.Time     `json:"started_at"`
+	FinishedAt time.Time     `json:"finished_at"`
+	Duration   time.Duration `json:"duration"`
+	Status     string        `json:"status"`
+	ResetLevel string        `json:"reset_level,omitempty"` // scenario, feature, run or none
+	Scenarios  []*Scenario   `json:"scenarios"`
+}
+
+// Scenario holds the result of a single scenario (pickle)
+type Scenario struct {
+	Feature   string        `json:"feature"`
+	Name      string        `json:"name"`
+	URI       string        `json:"uri"`
+	Line      int           `json:"line"`
+	Example   int           `json:"example_line,omitempty"` // line of the Examples row of an outline
+	Tags      []string      `json:"tags,omitempty"`
+	Inputs    string        `json:"inputs,omitempty"` // fingerprint of the steps, see Fingerprint
+	Status    string        `json:"status"`
+	Error     string        `json:"error,omitempty"`
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"duration"`
+	Steps     []*Step       `json:"steps,omitempty"`
+
+	// Time spent resetting resources and running before/after_scenario
+	// hooks, included in Duration
+	Reset time.Duration `json:"reset,omitempty"`
+	Hooks time.Duration `json:"hooks,omitempty"`
+
+	// Retries is the number of times the scenario was re-executed after
+	// failing; the status and steps are those of the last attempt
+	Retries        int        `json:"retries,omitempty"`
+	PreviousErrors []string   `json:"previous_errors,omitempty"`
+	Attempts       []*Attempt `json:"attempts,omitempty"` // the earlier attempts, oldest first
+
+	// AppLogs is the tail of the application log when the scenario failed
+	AppLogs []string `json:"app_logs,omitempty"`
+}
+
+// Attempt holds the result of an earlier attempt of a retried scenario
+type Attempt struct {
+	Status    string        `json:"status"`
+	Error     string        `json:"error,omitempty"`
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"duration"`
+	Steps     []*Step       `json:"steps,omitempty"`
+}
+
+// Step holds the result of a single step
+type Step struct {
+	Keyword   string        `json:"keyword,omitempty"` // e.g. "Given ", as written in the feature file
+	Text      string        `json:"text"`
+	Line      int           `json:"line"`
+	Status    string        `json:"status"`
+	Error     string        `json:"error,omitempty"`
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"duration"`
+
+	Match       *Match        `json:"match,omitempty"` // step definition the step ran for
+	Attachments []*Attachment `json:"attachments,omitempty"`
+}
+
+// Match is the step definition a step was matched to
+type Match struct {
+	Pattern  string `json:"pattern"`
+	Resource string `json:"resource"`
+}
+
+// Attachment is data attached to a step, e.g. the exchanges with a resource
+type Attachment struct {
+	Name      string `json:"name"`
+	MediaType string `json:"media_type"`
+	Body      string `json:"body"`
+}
+
+// Location returns the scenario's file:line, as accepted by godog paths
+func (s *Scenario) Location() string {
+	return fmt.Sprintf("%s:%d", s.URI, s.Line)
+}
+
+// key identifies the scenario, including the example row of an outline
+func (s *Scenario) key() string {
+	return fmt.Sprintf("%s:%d:%d", s.URI, s.Line, s.Example)
+}
+
+// Flaky reports whether the scenario passed only after being retried
+func (s *Scenario) Flaky() bool {
+	return s.Status == StatusPassed && s.Retries > 0
+}
+
+// Outcome describes the status including retries, e.g. "passed on retry 2"
+func (s *Scenario) Outcome() string {
+	switch {
+	case s.Retries == 0:
+		return s.Status
+	case s.Flaky():
+		return fmt.Sprintf("passed on retry %d", s.Retries)
+	default:
+		return fmt.Sprintf("%s after %d retries", s.Status, s.Retries)
+	}
+}
+
+// Failed reports whether the scenario did not pass. Skipped scenarios
+// (e.g. filtered out by name) are not failures.
+func (s *Scenario) Failed() bool {
+	switch s.Status {
+	case StatusPassed, StatusSkipped:
+		return false
+	}
+	return true
+}
+
+// Failed returns the scenarios that did not pass
+func (r *Run) Failed() []*Scenario {
+	var failed []*Scenario
+	for _, sc := range r.Scenarios {
+		if sc.Failed() {
+			failed = append(failed, sc)
+		}
+	}
+	return failed
+}
+
+// FailedLocations returns the file:line of every failed scenario, once per
+// location. Examples of a scenario outline share the outline's line.
+func (r *Run) FailedLocations() []string {
+	seen := make(map[string]bool)
+	var locations []string
+	for _, sc := range r.Failed() {
+		loc := sc.Location()
+		if !seen[loc] {
+			seen[loc] = true
+			locations = append(locations, loc)
+		}
+	}
+	return locations
+}
+
+// Flaky returns the scenarios that passed only after being retried
+func (r *Run) Flaky() []*Scenario {
+	var flaky []*Scenario
+	for _, sc := range r.Scenarios {
+		if sc.Flaky() {
+			flaky = append(flaky, sc)
+		}
+	}
+	return flaky
+}
+
+// Retried returns the number of scenarios that were retried
+func (r *Run) Retried() int {
+	n := 0
+	for _, sc := range r.Scenarios {
+		if sc.Retries > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// Counts returns the number of passed, failed and skipped scenarios
+func (r *Run) Counts() (passed, failed, skipped int) {
+	for _, sc := range r.Scenarios {
+		switch {
+		case sc.Status == StatusPassed:
+			passed++
+		case sc.Status == StatusSkipped:
+			skipped++
+		default:
+			failed++
+		}
+	}
+	return passed, failed, skipped
+}
+
+// Save writes the results as JSON
+func (r *Run) Save(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding results: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("writing results: %w", err)
+	}
+	return nil
+}
+
+// writers maps report formats to the functions that write them
+var writers = map[string]func(io.Writer, *Run) error{
+	"junit":    WriteJUnit,
+	"cucumber": WriteCucumberJSON,
+	"messages": WriteMessages,
+}
+
+// WriteFile writes the results as a report in the given format, creating
+// the parent directory of path if needed
+func (r *Run) WriteFile(format, path string) error {
+	write, ok := writers[format]
+	if !ok {
+		return fmt.Errorf("unsupported report format %q", format)
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("creating report directory: %w", err)
+		}
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating %s report: %w", format, err)
+	}
+	if err := write(f, r); err != nil {
+		f.Close()
+		return fmt.Errorf("writing %s report: %w", format, err)
+	}
+	return f.Close()
+}
+
+// FailedStep returns the step the scenario failed on, or nil
+func (s *Scenario) FailedStep() *Step {
+	for _, step := range s.Steps {
+		switch step.Status {
+		case StatusPassed, StatusSkipped:
+			continue
+		}
+		return step
+	}
+	return nil
+}
+
+// Load reads results written by Save
+func Load(path string) (*Run, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var run Run
+	if err := json.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return &run, nil
+}
+
+// SplitURI splits a godog URI such as "features/a.feature:12" into the file
+// path and line. The line is 0 when the URI has no line suffix.
+func SplitURI(uri string) (string, int) {
+	i := strings.LastIndexByte(uri, ':')
+	if i < 0 {
+		return uri, 0
+	}
+	line, err := strconv.Atoi(uri[i+1:])
+	if err != nil {
+		return uri, 0
+	}
+	return uri[:i], line
+}
@@ -0,0 +1,126 @@
+package report
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSplitURI(t *testing.T) {
+	tests := []struct {
+		uri      string
+		wantPath string
+		wantLine int
+	}{
+		{"features/a.feature", "features/a.feature", 0},
+		{"features/a.feature:12", "features/a.feature", 12},
+		{"C:/features/a.feature", "C:/features/a.feature", 0},
+		{"features/a.feature:x", "features/a.feature:x", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			path, line := SplitURI(tt.uri)
+			if path != tt.wantPath || line != tt.wantLine {
+				t.Errorf("SplitURI(%q) = %q, %d, want %q, %d", tt.uri, path, line, tt.wantPath, tt.wantLine)
+			}
+		})
+	}
+}
+
+func TestRunResults(t *testing.T) {
+	run := &Run{
+		ID: "abc",
+		Scenarios: []*Scenario{
+			{Name: "a", URI: "a.feature", Line: 3, Status: StatusPassed},
+			{Name: "b", URI: "a.feature", Line: 7, Status: StatusFailed, Error: "boom"},
+			{Name: "c 1", URI: "b.feature", Line: 4, Status: StatusFailed},
+			{Name: "c 2", URI: "b.feature", Line: 4, Status: StatusUndefined},
+			{Name: "d", URI: "b.feature", Line: 9, Status: StatusSkipped},
+		},
+	}
+
+	want := []string{"a.feature:7", "b.feature:4"}
+	if got := run.FailedLocations(); !reflect.DeepEqual(got, want) {
+		t.Errorf("FailedLocations() = %v, want %v", got, want)
+	}
+
+	passed, failed, skipped := run.Counts()
+	if passed != 1 || failed != 3 || skipped != 1 {
+		t.Errorf("Counts() = %d, %d, %d, want 1, 3, 1", passed, failed, skipped)
+	}
+
+	path := filepath.Join(t.TempDir(), FileName)
+	if err := run.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, run) {
+		t.Errorf("Load() = %+v, want %+v", loaded, run)
+	}
+}
+
+func TestScenarioOutcome(t *testing.T) {
+	tests := []struct {
+		sc   Scenario
+		want string
+	}{
+		{Scenario{Status: StatusPassed}, "passed"},
+		{Scenario{Status: StatusFailed}, "failed"},
+		{Scenario{Status: StatusPassed, Retries: 2}, "passed on retry 2"},
+		{Scenario{Status: StatusFailed, Retries: 3}, "failed after 3 retries"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := tt.sc.Outcome(); got != tt.want {
+				t.Errorf("Outcome() = %q, want %q", got, tt.want)
+			}
+			if got := tt.sc.Flaky(); got != (tt.want == "passed on retry 2") {
+				t.Errorf("Flaky() = %v", got)
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := &Run{Scenarios: []*Scenario{
+		{Name: "stays", URI: "a.feature", Line: 3, Status: StatusPassed},
+		{Name: "breaks", URI: "a.feature", Line: 7, Status: StatusPassed},
+		{Name: "outline", URI: "b.feature", Line: 4, Example: 10, Status: StatusPassed},
+		{Name: "outline", URI: "b.feature", Line: 4, Example: 11, Status: StatusFailed},
+		{Name: "removed", URI: "b.feature", Line: 20, Status: StatusPassed},
+	}}
+	b := &Run{Scenarios: []*Scenario{
+		{Name: "stays", URI: "a.feature", Line: 5, Status: StatusPassed},
+		{Name: "breaks", URI: "a.feature", Line: 9, Status: StatusFailed},
+		{Name: "outline", URI: "b.feature", Line: 4, Example: 10, Status: StatusPassed},
+		{Name: "outline", URI: "b.feature", Line: 4, Example: 11, Status: StatusPassed},
+		{Name: "added", URI: "b.feature", Line: 30, Status: StatusFailed},
+	}}
+
+	var got []string
+	for _, c := range Diff(a, b) {
+		before, after := "-", "-"
+		if c.Before != nil {
+			before = c.Before.Status
+		}
+		if c.After != nil {
+			after = c.After.Status
+		}
+		got = append(got, fmt.Sprintf("%s:%d %s→%s", c.Scenario().Name, c.Scenario().Example, before, after))
+	}
+	want := []string{
+		"breaks:0 passed→failed",
+		"outline:11 failed→passed",
+		"added:0 -→failed",
+		"removed:0 passed→-",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff() = %v, want %v", got, want)
+	}
+}
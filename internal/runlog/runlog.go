@@ -1,9 +1,13 @@
 package runlog
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -37,6 +41,11 @@ func New() (*RunContext, error) {
 	}, nil
 }
 
+// Path returns the full path for a file in the run directory
+func (r *RunContext) Path(name string) string {
+	return filepath.Join(r.Dir, name)
+}
+
 // LogPath returns the full path for a log file
 func (r *RunContext) LogPath(name string) string {
 	return filepath.Join(r.Dir, name+".log")
@@ -104,6 +113,28 @@ func ListRuns() ([]RunInfo, error) {
 	return runs, nil
 }
 
+// FindRun returns the stored run with the given ID, which is either the
+// short ID printed at the start of a run or the full directory name.
+// "latest" is the most recent run.
+func FindRun(id string) (*RunInfo, error) {
+	runs, err := ListRuns()
+	if err != nil {
+		return nil, err
+	}
+	if id == "latest" {
+		if len(runs) == 0 {
+			return nil, fmt.Errorf("no runs found in %s", filepath.Join(".tomato", "runs"))
+		}
+		return &runs[0], nil
+	}
+	for _, run := range runs {
+		if run.Name == id || strings.HasSuffix(run.Name, "_"+id) {
+			return &run, nil
+		}
+	}
+	return nil, fmt.Errorf("run %q not found in %s", id, filepath.Join(".tomato", "runs"))
+}
+
 // RunInfo contains information about a stored run
 type RunInfo struct {
 	Name      string    `json:"name"`
@@ -112,6 +143,45 @@ type RunInfo struct {
 	Logs      []LogFile `json:"logs"`
 }
 
+// dirTimeFormat is the time prefix of run directory names
+const dirTimeFormat = "2006-01-02_150405"
+
+// ID returns the short ID of the run, the suffix of its directory name
+func (r RunInfo) ID() string {
+	if i := strings.LastIndexByte(r.Name, '_'); i >= 0 {
+		return r.Name[i+1:]
+	}
+	return r.Name
+}
+
+// StartedAt returns the start time encoded in the directory name, or the
+// modification time of the directory if the name has none
+func (r RunInfo) StartedAt() time.Time {
+	if len(r.Name) >= len(dirTimeFormat) {
+		if t, err := time.ParseInLocation(dirTimeFormat, r.Name[:len(dirTimeFormat)], time.Local); err == nil {
+			return t
+		}
+	}
+	return r.Timestamp
+}
+
+// PruneCandidates returns the runs to delete, given runs ordered newest
+// first as by ListRuns: those beyond the keep most recent ones that started
+// more than olderThan before now. A zero keep or olderThan does not limit.
+func PruneCandidates(runs []RunInfo, keep int, olderThan time.Duration, now time.Time) []RunInfo {
+	var prune []RunInfo
+	for i, run := range runs {
+		if i < keep {
+			continue
+		}
+		if olderThan > 0 && now.Sub(run.StartedAt()) <= olderThan {
+			continue
+		}
+		prune = append(prune, run)
+	}
+	return prune
+}
+
 // LogFile represents a log file in a run directory
 type LogFile struct {
 	Name string `json:"name"`
@@ -146,12 +216,108 @@ func listLogs(runDir string) ([]LogFile, error) {
 	return logs, nil
 }
 
-// GetLogContent reads the content of a log file
+// GetLogContent reads the content of a log file. The timestamps of a
+// container log are left out.
 func GetLogContent(runName, logName string) (string, error) {
 	path := filepath.Join(".tomato", "runs", runName, logName+".log")
 	content, err := os.ReadFile(path)
 	if err != nil {
 		return "", err
 	}
+	if IsTimestamped(logName) {
+		return StripTimestamps(string(content)), nil
+	}
 	return string(content), nil
 }
+
+// IsTimestamped reports whether the named log is written by a
+// TimestampWriter, which container logs are
+func IsTimestamped(logName string) bool {
+	return strings.HasPrefix(logName, "container-")
+}
+
+// StripTimestamps removes the timestamps a TimestampWriter put in front of
+// the lines of content
+func StripTimestamps(content string) string {
+	lines := strings.SplitAfter(content, "\n")
+	for i, line := range lines {
+		lines[i] = ParseLogLine(line).Text
+	}
+	return strings.Join(lines, "")
+}
+
+// TimestampWriter prefixes every line written to w with the time it was
+// received, in RFC 3339 format like `docker logs --timestamps`, so logs can
+// later be filtered by the time window of a scenario.
+type TimestampWriter struct {
+	w       io.Writer
+	midLine bool // the last write did not end with a newline
+}
+
+// NewTimestampWriter creates a TimestampWriter writing to w
+func NewTimestampWriter(w io.Writer) *TimestampWriter {
+	return &TimestampWriter{w: w}
+}
+
+func (t *TimestampWriter) Write(p []byte) (int, error) {
+	var buf bytes.Buffer
+	stamp := time.Now().UTC().Format(time.RFC3339Nano) + " "
+	for rest := p; len(rest) > 0; {
+		if !t.midLine {
+			buf.WriteString(stamp)
+		}
+		i := bytes.IndexByte(rest, '\n')
+		if i < 0 {
+			buf.Write(rest)
+			t.midLine = true
+			break
+		}
+		buf.Write(rest[:i+1])
+		rest = rest[i+1:]
+		t.midLine = false
+	}
+	if _, err := t.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// LogLine is a line of a log file
+type LogLine struct {
+	Time time.Time // zero if the line has no timestamp
+	Text string
+}
+
+// ReadLog reads a log file, splitting off the timestamps written by a
+// TimestampWriter
+func ReadLog(path string) ([]LogLine, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []LogLine
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, ParseLogLine(scanner.Text()))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return lines, nil
+}
+
+// ParseLogLine splits the timestamp off a line written by a TimestampWriter
+func ParseLogLine(line string) LogLine {
+	stamp, text, ok := strings.Cut(line, " ")
+	if !ok {
+		return LogLine{Text: line}
+	}
+	t, err := time.Parse(time.RFC3339Nano, stamp)
+	if err != nil {
+		return LogLine{Text: line}
+	}
+	return LogLine{Time: t, Text: text}
+}
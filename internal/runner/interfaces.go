@@ -29,3 +29,20 @@ type ScenarioContext interface {
 	After(h godog.AfterScenarioHook)
 	Step(expr interface{}, stepFunc interface{})
 }
+
+// StepContext abstracts godog.StepContext for testing
+type StepContext interface {
+	Before(h godog.BeforeStepHook)
+	After(h godog.AfterStepHook)
+}
+
+// LogSource provides the most recent application log lines (apprunner.Runner)
+type LogSource interface {
+	GetRecentLogs(n int) []string
+}
+
+// ContractChecker is implemented by registries whose handlers validate the
+// traffic they receive against an OpenAPI contract (handler.Registry)
+type ContractChecker interface {
+	TakeContractViolations() error
+}
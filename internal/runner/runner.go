@@ -1,16 +1,31 @@
 package runner
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/cucumber/godog"
 	"github.com/rs/zerolog/log"
 	"github.com/tomatool/tomato/internal/config"
 	"github.com/tomatool/tomato/internal/container"
-	_ "github.com/tomatool/tomato/internal/formatter" // Register tomato formatter
+	"github.com/tomatool/tomato/internal/formatter"
 	"github.com/tomatool/tomato/internal/handler"
+	"github.com/tomatool/tomato/internal/har"
+	"github.com/tomatool/tomato/internal/report"
+	"github.com/tomatool/tomato/internal/tagexpr"
 )
 
 // Options configures runner behavior
@@ -18,6 +33,10 @@ type Options struct {
 	NoReset bool
 	Watch   bool
 	Format  string // Override output format (e.g., "tomato" for structured events)
+	RunID   string // ID of the run directory, recorded in the results
+	HAR     bool   // record the traffic of all HTTP resources as HAR
+
+	UpdateSnapshots bool // rewrite snapshots that don't match instead of failing
 }
 
 // Runner executes behavioral tests
@@ -27,6 +46,44 @@ type Runner struct {
 	handlers      HandlerRegistry
 	opts          Options
 	scenarioRegex *regexp.Regexp
+
+	// Reset tracking for feature and run level resets. Parallel scenarios
+	// of different features interleave, so features are tracked per worker.
+	resetMu       sync.Mutex
+	resetDone     bool           // at least one reset has been performed
+	resetFeatures map[int]string // feature URI of the last reset by worker
+
+	// Failure state kept when settings.reset.on_failure is "keep"
+	failure *FailureState
+
+	// Scenario state shared by a worker's scenarios until its next reset
+	states map[int]*handler.ScenarioState
+
+	// Worker slots for resource isolation; busy[i] is true while worker i+1 runs a scenario
+	workerMu sync.Mutex
+	busy     []bool
+
+	// Scenario and step results of the run
+	recorder     *report.Recorder
+	results      *report.Run
+	retryAttempt int // set while failed scenarios are being retried
+
+	// Application logs attached to failed scenarios
+	appLogs LogSource
+
+	// HTTP traffic exported as HAR, nil unless enabled
+	har *har.Recorder
+}
+
+// appLogTail is the number of application log lines kept for a failed scenario
+const appLogTail = 50
+
+// FailureState describes the scenario whose state was kept after failing
+type FailureState struct {
+	Scenario string
+	Feature  string
+	Error    string
+	Time     time.Time
 }
 
 // New creates a new test runner
@@ -36,7 +93,14 @@ func New(cfg *config.Config, cm *container.Manager, opts Options) (*Runner, erro
 		return nil, fmt.Errorf("initializing handlers: %w", err)
 	}
 
-	return newRunner(cfg, cm, registry, opts)
+	r, err := newRunner(cfg, cm, registry, opts)
+	if err != nil {
+		return nil, err
+	}
+	if r.har != nil {
+		registry.SetHAR(r.har, opts.HAR || cfg.Settings.HAR.Enabled)
+	}
+	return r, nil
 }
 
 // newRunner is the internal constructor that allows dependency injection for testing
@@ -46,6 +110,10 @@ func newRunner(cfg *config.Config, container ContainerExecutor, handlers Handler
 		container: container,
 		handlers:  handlers,
 		opts:      opts,
+		recorder:  report.NewRecorder(opts.RunID),
+	}
+	if harEnabled(cfg, opts) {
+		r.har = har.NewRecorder(cfg.Settings.HAR.Redact)
 	}
 
 	// Compile scenario filter regex if provided
@@ -69,7 +137,18 @@ func (r *Runner) Run(ctx context.Context) error {
 		return fmt.Errorf("handlers not ready: %w", err)
 	}
 
-	if err := r.runHooks(ctx, r.config.Hooks.BeforeAll); err != nil {
+	log.Info().Str("level", r.ResetLevel()).Msg("state reset level")
+
+	// settings.timeout bounds the whole run. Once it expires, the remaining
+	// scenarios fail immediately, but after_all hooks still run on ctx.
+	runCtx := ctx
+	if timeout := r.config.Settings.Timeout; timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeoutCause(ctx, timeout, fmt.Errorf("run timed out after %s (settings.timeout)", timeout))
+		defer cancel()
+	}
+
+	if err := r.runHooks(runCtx, r.config.Hooks.BeforeAll); err != nil {
 		return fmt.Errorf("before_all hooks failed: %w", err)
 	}
 
@@ -78,59 +157,321 @@ func (r *Runner) Run(ctx context.Context) error {
 		format = r.opts.Format
 	}
 
+	// The tomato format reports the timings measured by the hooks
+	formatter.SetTimings(r.recorder)
+
+	status := r.runSuite(runCtx, suiteName, format, r.config.Features.Paths)
+
+	// Re-execute failed scenarios that have retries left. A kept failure
+	// state must not be reset, so there are no retries in that case.
+	retried := false
+	for attempt := 1; runCtx.Err() == nil && r.FailureState() == nil; attempt++ {
+		scenarios := r.retryCandidates(attempt)
+		if len(scenarios) == 0 {
+			break
+		}
+		log.Info().Int("attempt", attempt).Int("scenarios", len(scenarios)).Msg("retrying failed scenarios")
+
+		r.retryAttempt = attempt
+		r.recorder.BeginRetry(attempt, scenarios)
+		r.runSuite(runCtx, formatter.RetrySuiteName(suiteName, attempt), format, locations(scenarios))
+		retried = true
+	}
+	r.retryAttempt = 0
+	r.results = r.recorder.Finish()
+	if r.results != nil {
+		r.results.ResetLevel = r.ResetLevel()
+	}
+
+	if r.results != nil {
+		if format == "tomato" {
+			formatter.WriteSummary(os.Stdout, r.results)
+		} else {
+			formatter.WriteRetries(os.Stdout, r.results)
+		}
+		// The outcome of the last attempt of each scenario counts
+		if retried {
+			status = 0
+			if len(r.results.Failed()) > 0 {
+				status = 1
+			}
+		}
+	}
+
+	if err := r.runHooks(ctx, r.config.Hooks.AfterAll); err != nil {
+		log.Warn().Err(err).Msg("after_all hooks failed")
+	}
+
+	if status != 0 {
+		return fmt.Errorf("tests failed with status %d", status)
+	}
+
+	return nil
+}
+
+const suiteName = "tomato"
+
+// runSuite runs the features at paths with godog and returns its exit status
+func (r *Runner) runSuite(ctx context.Context, name, format string, paths []string) int {
 	opts := &godog.Options{
 		Format:        format,
-		Paths:         r.config.Features.Paths,
+		Paths:         paths,
 		Tags:          r.config.Features.Tags,
 		StopOnFailure: r.config.Settings.FailFast,
 		Strict:        true,
 		Concurrency:   r.config.Settings.Parallel,
+		// Scenario contexts derive from the run deadline
+		DefaultContext: ctx,
 	}
 
 	suite := godog.TestSuite{
-		Name:                "tomato",
+		Name:                name,
 		ScenarioInitializer: r.initializeScenario,
 		Options:             opts,
 	}
 
-	status := suite.Run()
+	// Index feature files so results carry line numbers
+	if features, err := suite.RetrieveFeatures(); err == nil {
+		for _, f := range features {
+			r.recorder.AddDocument(f.GherkinDocument)
+		}
+	}
 
-	if err := r.runHooks(ctx, r.config.Hooks.AfterAll); err != nil {
-		log.Warn().Err(err).Msg("after_all hooks failed")
+	return suite.Run()
+}
+
+// retryCandidates returns the failed scenarios whose retry limit allows the given attempt
+func (r *Runner) retryCandidates(attempt int) []*report.Scenario {
+	results := r.recorder.Results()
+	if results == nil {
+		return nil
 	}
 
-	if status != 0 {
-		return fmt.Errorf("tests failed with status %d", status)
+	var scenarios []*report.Scenario
+	for _, sc := range results.Scenarios {
+		if sc.Status != report.StatusFailed {
+			continue
+		}
+		if limit, err := r.retryLimit(sc.Tags); err == nil && limit >= attempt {
+			scenarios = append(scenarios, sc)
+		}
 	}
+	return scenarios
+}
 
-	return nil
+// locations returns the file:line paths that select the given scenarios
+func locations(scenarios []*report.Scenario) []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, sc := range scenarios {
+		if loc := sc.Location(); !seen[loc] {
+			seen[loc] = true
+			paths = append(paths, loc)
+		}
+	}
+	return paths
 }
 
 func (r *Runner) initializeScenario(ctx *godog.ScenarioContext) {
 	r.setupScenarioHooks(ctx)
+	r.setupStepHooks(ctx.StepContext())
 	r.handlers.RegisterSteps(ctx)
 }
 
+// harEnabled reports whether the traffic of any HTTP resource is exported as HAR
+func harEnabled(cfg *config.Config, opts Options) bool {
+	if opts.HAR || cfg.Settings.HAR.Enabled {
+		return true
+	}
+	for _, res := range cfg.Resources {
+		if enabled, _ := res.Options["har"].(bool); enabled {
+			return true
+		}
+	}
+	return false
+}
+
+// HAR returns the recorded HTTP traffic, or nil when the HAR export is disabled
+func (r *Runner) HAR() *har.Recorder {
+	return r.har
+}
+
+// SetAppLogs sets the source of the application log lines that are
+// attached to the results of failed scenarios
+func (r *Runner) SetAppLogs(src LogSource) {
+	r.appLogs = src
+}
+
+// Results returns the results of the last Run, or nil before the run finished
+func (r *Runner) Results() *report.Run {
+	return r.results
+}
+
+type pickleIDKey struct{}
+
+// setupStepHooks records step results
+func (r *Runner) setupStepHooks(ctx StepContext) {
+	ctx.Before(func(ctx context.Context, st *godog.Step) (context.Context, error) {
+		r.recorder.StepStarted(st)
+		return handler.WithStepMatch(ctx, &handler.StepMatch{}), nil
+	})
+
+	ctx.After(func(ctx context.Context, st *godog.Step, status godog.StepResultStatus, err error) (context.Context, error) {
+		// Requests a mock received during the step that violate its contract
+		// fail the step. Calls carry no scenario, so with parallel workers
+		// they are left to the scenario's After hook.
+		var violations error
+		if r.config.Settings.Parallel <= 1 {
+			if violations = r.takeContractViolations(); violations != nil {
+				err = errors.Join(err, violations)
+				status = godog.StepFailed
+			}
+		}
+
+		// The transcript of a failed step's resource is kept as an attachment,
+		// which godog's cucumber format includes as well
+		var attachments []*report.Attachment
+		var te *handler.TranscriptError
+		if errors.As(err, &te) {
+			a := &report.Attachment{Name: te.Resource + " transcript", MediaType: "text/plain", Body: te.Transcript}
+			attachments = append(attachments, a)
+			ctx = godog.Attach(ctx, godog.Attachment{Body: []byte(a.Body), FileName: a.Name, MediaType: a.MediaType})
+		}
+
+		if id, ok := ctx.Value(pickleIDKey{}).(string); ok {
+			var match *report.Match
+			if m, ok := handler.StepMatchFrom(ctx); ok {
+				match = &report.Match{Pattern: m.Pattern, Resource: m.Resource}
+			}
+			r.recorder.StepFinished(id, st, status.String(), withoutTranscript(err), match, attachments...)
+		}
+		return ctx, violations
+	})
+}
+
+// withoutTranscript removes a resource transcript from the message of a step
+// error, as results keep the transcript as an attachment
+func withoutTranscript(err error) error {
+	var te *handler.TranscriptError
+	if !errors.As(err, &te) {
+		return err
+	}
+	return errors.New(strings.Replace(err.Error(), te.Error(), te.Err.Error(), 1))
+}
+
+// scenarioStatus maps the error a scenario ended with to a report status
+func scenarioStatus(err error) string {
+	switch {
+	case err == nil:
+		return report.StatusPassed
+	case errors.Is(err, godog.ErrSkip):
+		return report.StatusSkipped
+	case errors.Is(err, godog.ErrUndefined):
+		return report.StatusUndefined
+	case errors.Is(err, godog.ErrPending):
+		return report.StatusPending
+	case errors.Is(err, godog.ErrAmbiguous):
+		return report.StatusAmbiguous
+	default:
+		return report.StatusFailed
+	}
+}
+
 // setupScenarioHooks sets up before/after hooks on the scenario context
 // This internal method accepts an interface for testability
 func (r *Runner) setupScenarioHooks(ctx ScenarioContext) {
 	ctx.Before(func(ctx context.Context, sc *godog.Scenario) (context.Context, error) {
+		// During a retry attempt, only the retried scenarios run
+		if !r.recorder.ScenarioStarted(sc) {
+			return ctx, godog.ErrSkip
+		}
+		ctx = context.WithValue(ctx, pickleIDKey{}, sc.Id)
+		ctx = handler.WithTranscribers(ctx, r.handlers.Get)
+
 		// Skip scenarios that don't match the filter regex
 		if r.scenarioRegex != nil && !r.scenarioRegex.MatchString(sc.Name) {
 			log.Info().Str("scenario", sc.Name).Msg("skipping scenario (doesn't match filter)")
 			return ctx, godog.ErrSkip
 		}
 
-		if !r.opts.NoReset {
-			log.Debug().Str("scenario", sc.Name).Msg("resetting state")
-			if err := r.handlers.ResetAll(ctx); err != nil {
+		// The run deadline has passed; fail without touching resources
+		if ctx.Err() != nil {
+			return ctx, context.Cause(ctx)
+		}
+
+		if _, err := r.retryLimit(tagNames(sc)); err != nil {
+			return ctx, err
+		}
+
+		timeout, err := scenarioTimeout(sc)
+		if err != nil {
+			return ctx, err
+		}
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeoutCause(ctx, timeout, fmt.Errorf("scenario timed out after %s (@timeout tag)", timeout))
+			ctx = context.WithValue(ctx, scenarioCancelKey{}, cancel)
+		}
+		ctx = handler.WithStepTimeout(ctx, r.config.Settings.StepTimeout)
+
+		// Assertions are polled with a `within` suffix or in @eventually scenarios
+		ctx = handler.WithPollInterval(ctx, r.config.Settings.Eventually.Interval)
+		eventually, err := r.eventuallyTimeout(sc)
+		if err != nil {
+			return ctx, err
+		}
+		if eventually > 0 {
+			ctx = handler.WithEventually(ctx, eventually)
+		}
+
+		// Transcripts mask credentials like the HAR export does
+		ctx = handler.WithRedactedHeaders(ctx, r.config.Settings.HAR.Redact)
+
+		// Snapshots live in __snapshots__ next to the feature file
+		ctx = handler.WithSnapshots(ctx, handler.SnapshotSettings{
+			Dir:    filepath.Join(filepath.Dir(sc.Uri), "__snapshots__"),
+			Update: r.opts.UpdateSnapshots,
+		})
+
+		// HTTP traffic is grouped into a page per scenario attempt
+		if r.har != nil {
+			title := sc.Name
+			if r.retryAttempt > 0 {
+				title = fmt.Sprintf("%s (retry %d)", sc.Name, r.retryAttempt)
+			}
+			ctx = har.WithPage(ctx, r.har.StartPage(title, sc.Uri))
+		}
+
+		// Assign a worker so isolated resources use the worker's namespace
+		worker := r.acquireWorker()
+		ctx = handler.WithWorker(ctx, worker)
+		ctx = handler.WithRunningWorkers(ctx, r.runningWorkers())
+
+		// A retried scenario always starts from a clean state
+		reset := r.shouldReset(sc, worker) || (r.retryAttempt > 0 && r.ResetLevel() != "none")
+		if reset {
+			log.Debug().Str("scenario", sc.Name).Str("level", r.ResetLevel()).Msg("resetting state")
+			start := time.Now()
+			err := r.handlers.ResetAll(ctx)
+			r.recorder.AddOverhead(sc.Id, time.Since(start), 0)
+			if err != nil {
 				return ctx, fmt.Errorf("reset failed: %w", err)
 			}
-			// Reset captured variables between scenarios
-			handler.ResetGlobalVariables()
 		}
 
-		if err := r.runHooks(ctx, r.config.Hooks.BeforeScenario); err != nil {
+		// Captured variables and handler state live on the context, so
+		// parallel scenarios don't overwrite each other
+		ctx = handler.WithScenarioState(ctx, r.scenarioState(worker, reset))
+		handler.VariablesFrom(ctx).Set("worker_id", strconv.Itoa(worker))
+
+		hooks, err := scenarioHooks(sc, r.config.Hooks.BeforeScenario)
+		if err != nil {
+			return ctx, err
+		}
+		start := time.Now()
+		err = r.runHooks(ctx, hooks)
+		r.recorder.AddOverhead(sc.Id, 0, time.Since(start))
+		if err != nil {
 			return ctx, fmt.Errorf("before_scenario hooks failed: %w", err)
 		}
 
@@ -138,22 +479,348 @@ func (r *Runner) setupScenarioHooks(ctx ScenarioContext) {
 	})
 
 	ctx.After(func(ctx context.Context, sc *godog.Scenario, err error) (context.Context, error) {
-		if hookErr := r.runHooks(ctx, r.config.Hooks.AfterScenario); hookErr != nil {
+		// Violations a mock received after the last step, or during any step
+		// with parallel workers, fail the scenario rather than the next one
+		var violations error
+		if !errors.Is(err, godog.ErrSkip) {
+			if violations = r.takeContractViolations(); violations != nil {
+				err = errors.Join(err, violations)
+			}
+		}
+		if err != nil && !errors.Is(err, godog.ErrSkip) {
+			r.keepFailureState(sc, err)
+			if r.appLogs != nil {
+				r.recorder.AttachAppLogs(sc.Id, r.appLogs.GetRecentLogs(appLogTail))
+			}
+		}
+		// Cleanup hooks run even when the scenario or run deadline has expired
+		hooks, hookErr := scenarioHooks(sc, r.config.Hooks.AfterScenario)
+		if hookErr == nil {
+			start := time.Now()
+			hookErr = r.runHooks(context.WithoutCancel(ctx), hooks)
+			r.recorder.AddOverhead(sc.Id, 0, time.Since(start))
+		}
+		if hookErr != nil {
 			log.Warn().Err(hookErr).Msg("after_scenario hooks failed")
 		}
-		return ctx, nil
+		r.releaseWorker(handler.WorkerID(ctx))
+		if r.har != nil {
+			r.har.EndPage(har.PageFrom(ctx))
+		}
+		if cancel, ok := ctx.Value(scenarioCancelKey{}).(context.CancelFunc); ok {
+			cancel()
+		}
+		r.recorder.ScenarioFinished(sc.Id, scenarioStatus(err), withoutTranscript(err))
+		return ctx, violations
 	})
 }
 
+// takeContractViolations returns the contract violations the mocks received
+// since the last call
+func (r *Runner) takeContractViolations() error {
+	if c, ok := r.handlers.(ContractChecker); ok {
+		return c.TakeContractViolations()
+	}
+	return nil
+}
+
+type scenarioCancelKey struct{}
+
+var durationTagRe = regexp.MustCompile(`^@([a-z_]+)(?:\((.*)\))?$`)
+
+// tagNames returns the names of the scenario's tags, including the leading "@"
+func tagNames(sc *godog.Scenario) []string {
+	names := make([]string, len(sc.Tags))
+	for i, tag := range sc.Tags {
+		names[i] = tag.Name
+	}
+	return names
+}
+
+// tagArg looks up a @name or @name(arg) tag. Scenario tags include the tags
+// of their feature; since the scenario's own tags come last, they take
+// precedence. A tag without an argument returns "".
+func tagArg(tags []string, name string) (string, bool) {
+	var (
+		arg   string
+		found bool
+	)
+	for _, tag := range tags {
+		m := durationTagRe.FindStringSubmatch(tag)
+		if m == nil || m[1] != name {
+			continue
+		}
+		found, arg = true, m[2]
+	}
+	return arg, found
+}
+
+// tagDuration looks up a @name or @name(duration) tag on the scenario. A tag
+// without a duration returns 0.
+func tagDuration(sc *godog.Scenario, name string) (time.Duration, bool, error) {
+	arg, found := tagArg(tagNames(sc), name)
+	if !found || arg == "" {
+		return 0, found, nil
+	}
+	d, err := time.ParseDuration(arg)
+	if err != nil || d <= 0 {
+		return 0, true, fmt.Errorf("invalid @%s(%s) tag: expected a positive duration such as @%s(30s)", name, arg, name)
+	}
+	return d, true, nil
+}
+
+// retryLimit returns how often a failed scenario with the given tags is
+// retried: N for a @retry(N) tag, otherwise settings.retry
+func (r *Runner) retryLimit(tags []string) (int, error) {
+	arg, found := tagArg(tags, "retry")
+	if !found {
+		return r.config.Settings.Retry, nil
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid @retry tag: expected a count such as @retry(3)")
+	}
+	return n, nil
+}
+
+// scenarioTimeout returns the deadline set by a @timeout(30s) tag, or 0 if there is none
+func scenarioTimeout(sc *godog.Scenario) (time.Duration, error) {
+	d, found, err := tagDuration(sc, "timeout")
+	if err == nil && found && d == 0 {
+		err = fmt.Errorf("invalid @timeout tag: expected a duration such as @timeout(30s)")
+	}
+	return d, err
+}
+
+// eventuallyTimeout returns how long assertions are polled in a scenario tagged
+// @eventually or @eventually(10s), or 0 if the scenario is not tagged
+func (r *Runner) eventuallyTimeout(sc *godog.Scenario) (time.Duration, error) {
+	d, found, err := tagDuration(sc, "eventually")
+	if err != nil || !found {
+		return 0, err
+	}
+	if d == 0 {
+		d = r.config.Settings.Eventually.Timeout
+	}
+	return d, nil
+}
+
+// ResetLevel returns the effective reset level for this run
+// (scenario, feature, run or none)
+func (r *Runner) ResetLevel() string {
+	if r.opts.NoReset {
+		return "none"
+	}
+	if r.config.Settings.Reset.Level == "" {
+		return "scenario"
+	}
+	return r.config.Settings.Reset.Level
+}
+
+// shouldReset reports whether state must be reset before the given scenario
+// runs on worker. Feature boundaries are detected by a change of the feature
+// URI of the worker's scenarios.
+func (r *Runner) shouldReset(sc *godog.Scenario, worker int) bool {
+	// Scenarios selected by file:line carry the line in their URI
+	uri, _ := report.SplitURI(sc.Uri)
+
+	r.resetMu.Lock()
+	defer r.resetMu.Unlock()
+
+	// State of a failed scenario is preserved for inspection
+	if r.failure != nil {
+		return false
+	}
+
+	switch r.ResetLevel() {
+	case "none":
+		return false
+	case "run":
+		if r.resetDone {
+			return false
+		}
+	case "feature":
+		if last, ok := r.resetFeatures[worker]; ok && last == uri {
+			return false
+		}
+	}
+
+	r.resetDone = true
+	if r.resetFeatures == nil {
+		r.resetFeatures = make(map[int]string)
+	}
+	r.resetFeatures[worker] = uri
+	return true
+}
+
+// scenarioState returns the state for the worker's next scenario. A fresh
+// state is created on every reset; otherwise the worker's previous state is
+// carried over, so feature and run level resets keep variables between
+// scenarios.
+func (r *Runner) scenarioState(worker int, reset bool) *handler.ScenarioState {
+	r.resetMu.Lock()
+	defer r.resetMu.Unlock()
+
+	if r.states == nil {
+		r.states = make(map[int]*handler.ScenarioState)
+	}
+	state, ok := r.states[worker]
+	if reset || !ok {
+		state = handler.NewScenarioState()
+		r.states[worker] = state
+	}
+	return state
+}
+
+// acquireWorker returns the lowest free worker ID, starting at 1. With
+// settings.parallel N at most N workers are busy at the same time.
+func (r *Runner) acquireWorker() int {
+	r.workerMu.Lock()
+	defer r.workerMu.Unlock()
+
+	for i, busy := range r.busy {
+		if !busy {
+			r.busy[i] = true
+			return i + 1
+		}
+	}
+	r.busy = append(r.busy, true)
+	return len(r.busy)
+}
+
+// runningWorkers returns the number of busy workers
+func (r *Runner) runningWorkers() int {
+	r.workerMu.Lock()
+	defer r.workerMu.Unlock()
+
+	n := 0
+	for _, busy := range r.busy {
+		if busy {
+			n++
+		}
+	}
+	return n
+}
+
+// releaseWorker marks a worker ID as free again
+func (r *Runner) releaseWorker(id int) {
+	r.workerMu.Lock()
+	defer r.workerMu.Unlock()
+
+	if id > 0 && id <= len(r.busy) {
+		r.busy[id-1] = false
+	}
+}
+
+// keepFailureState records the first failed scenario when on_failure is "keep".
+// Once recorded, no further resets are performed.
+func (r *Runner) keepFailureState(sc *godog.Scenario, err error) {
+	if r.config.Settings.Reset.OnFailure != "keep" {
+		return
+	}
+
+	r.resetMu.Lock()
+	defer r.resetMu.Unlock()
+
+	if r.failure != nil {
+		return
+	}
+
+	r.failure = &FailureState{
+		Scenario: sc.Name,
+		Feature:  sc.Uri,
+		Error:    err.Error(),
+		Time:     time.Now(),
+	}
+	log.Warn().Str("scenario", sc.Name).Msg("scenario failed, keeping state (reset disabled for the rest of the run)")
+}
+
+// FailureState returns the kept failure state, or nil if state was not kept
+func (r *Runner) FailureState() *FailureState {
+	r.resetMu.Lock()
+	defer r.resetMu.Unlock()
+	return r.failure
+}
+
+// Summary returns a human readable description of the kept failure state
+func (f *FailureState) Summary(resources map[string]config.Resource) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Failed scenario: %s\n", f.Scenario)
+	fmt.Fprintf(&b, "Feature:         %s\n", f.Feature)
+	fmt.Fprintf(&b, "Failed at:       %s\n", f.Time.Format(time.RFC3339))
+	fmt.Fprintf(&b, "Error:           %s\n", f.Error)
+	b.WriteString("\nState was not reset after this failure (settings.reset.on_failure: keep).\n")
+
+	if len(resources) > 0 {
+		b.WriteString("\nResources:\n")
+		names := make([]string, 0, len(resources))
+		for name := range resources {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			res := resources[name]
+			if res.Container != "" {
+				fmt.Fprintf(&b, "  %s (%s, container %s)\n", name, res.Type, res.Container)
+			} else {
+				fmt.Fprintf(&b, "  %s (%s)\n", name, res.Type)
+			}
+		}
+	}
+	return b.String()
+}
+
 func (r *Runner) runHooks(ctx context.Context, hooks []config.Hook) error {
 	for _, hook := range hooks {
-		if err := r.executeHook(ctx, hook); err != nil {
+		if err := r.runHook(ctx, hook); err != nil {
+			if hook.ContinueOnError {
+				log.Warn().Err(err).Str("hook", hook.Kind()).Msg("hook failed, continuing (continue_on_error)")
+				continue
+			}
 			return err
 		}
 	}
 	return nil
 }
 
+// scenarioHooks returns the hooks whose tags expression matches the scenario
+func scenarioHooks(sc *godog.Scenario, hooks []config.Hook) ([]config.Hook, error) {
+	tags := make([]string, len(sc.Tags))
+	for i, tag := range sc.Tags {
+		tags[i] = tag.Name
+	}
+
+	var matched []config.Hook
+	for _, hook := range hooks {
+		if hook.Tags != "" {
+			expr, err := tagexpr.Parse(hook.Tags)
+			if err != nil {
+				return nil, fmt.Errorf("%s hook: %w", hook.Kind(), err)
+			}
+			if !expr.Match(tags) {
+				continue
+			}
+		}
+		matched = append(matched, hook)
+	}
+	return matched, nil
+}
+
+// runHook executes a single hook, bounded by its timeout
+func (r *Runner) runHook(ctx context.Context, hook config.Hook) error {
+	if hook.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, hook.Timeout, fmt.Errorf("%s hook timed out after %s", hook.Kind(), hook.Timeout))
+		defer cancel()
+	}
+
+	err := r.executeHook(ctx, hook)
+	if err != nil && ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+	return err
+}
+
 func (r *Runner) executeHook(ctx context.Context, hook config.Hook) error {
 	switch {
 	case hook.SQL != "":
@@ -187,11 +854,147 @@ func (r *Runner) executeHook(ctx context.Context, hook config.Hook) error {
 			return fmt.Errorf("executing command in %s: %w", hook.Container, err)
 		}
 
-	case hook.Shell != "":
+	case hook.Shell != "" && hook.Container != "":
 		if _, _, err := r.container.Exec(ctx, hook.Container, []string{"sh", "-c", hook.Shell}); err != nil {
 			return fmt.Errorf("executing shell in %s: %w", hook.Container, err)
 		}
+
+	case hook.Shell != "":
+		cmd := exec.CommandContext(ctx, "sh", "-c", hook.Shell)
+		// Don't wait for background processes holding the output open once ctx expires
+		cmd.WaitDelay = time.Second
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("executing shell %q: %w\n%s", hook.Shell, err, strings.TrimSpace(string(out)))
+		}
+
+	case hook.HTTP != nil:
+		return r.executeHTTPHook(ctx, hook)
+
+	case hook.Redis != "":
+		h, err := r.handlers.Get(hook.Resource)
+		if err != nil {
+			return fmt.Errorf("handler %s not found: %w", hook.Resource, err)
+		}
+		cmdHandler, ok := h.(handler.CommandExecutor)
+		if !ok {
+			return fmt.Errorf("handler %s does not support Redis commands", hook.Resource)
+		}
+		if _, err := cmdHandler.ExecCommand(ctx, splitCommand(hook.Redis)); err != nil {
+			return fmt.Errorf("executing Redis command %q: %w", hook.Redis, err)
+		}
+
+	case hook.Publish != nil:
+		h, err := r.handlers.Get(hook.Resource)
+		if err != nil {
+			return fmt.Errorf("handler %s not found: %w", hook.Resource, err)
+		}
+		publisher, ok := h.(handler.MessagePublisher)
+		if !ok {
+			return fmt.Errorf("handler %s does not support publishing", hook.Resource)
+		}
+		if err := publisher.Publish(ctx, hook.Publish.Target, []byte(hook.Publish.Payload), hook.Publish.Headers); err != nil {
+			return fmt.Errorf("publishing to %s: %w", hook.Publish.Target, err)
+		}
 	}
 
 	return nil
 }
+
+func (r *Runner) executeHTTPHook(ctx context.Context, hook config.Hook) error {
+	req := hook.HTTP
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	var body []byte
+	if req.Body != "" {
+		body = []byte(req.Body)
+	}
+
+	var (
+		status   int
+		respBody []byte
+		err      error
+	)
+	if hook.Resource != "" {
+		h, getErr := r.handlers.Get(hook.Resource)
+		if getErr != nil {
+			return fmt.Errorf("handler %s not found: %w", hook.Resource, getErr)
+		}
+		requester, ok := h.(handler.HTTPRequester)
+		if !ok {
+			return fmt.Errorf("handler %s does not support HTTP requests", hook.Resource)
+		}
+		status, respBody, err = requester.Request(ctx, method, req.URL, req.Headers, body)
+	} else {
+		status, respBody, err = sendHTTP(ctx, method, req.URL, req.Headers, body)
+	}
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, req.URL, err)
+	}
+
+	if (req.Status != 0 && status != req.Status) || (req.Status == 0 && status >= 400) {
+		return fmt.Errorf("%s %s: unexpected status %d\nBody: %s", method, req.URL, status, string(respBody))
+	}
+	return nil
+}
+
+// sendHTTP sends a request to an absolute URL for hooks without an http client resource
+func sendHTTP(ctx context.Context, method, url string, headers map[string]string, body []byte) (int, []byte, error) {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
+	if err != nil {
+		return 0, nil, fmt.Errorf("creating request: %w", err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, respBody, err
+}
+
+// splitCommand splits a command line into arguments, keeping single or
+// double quoted strings together
+func splitCommand(line string) []string {
+	var (
+		args    []string
+		current strings.Builder
+		quote   rune
+		inArg   bool
+	)
+	for _, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				current.WriteRune(c)
+			}
+		case c == '"' || c == '\'':
+			quote, inArg = c, true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(c)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args
+}
@@ -2,12 +2,24 @@ package runner
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/cucumber/godog"
+	messages "github.com/cucumber/messages/go/v21"
 	"github.com/tomatool/tomato/internal/config"
+	"github.com/tomatool/tomato/internal/formatter"
 	"github.com/tomatool/tomato/internal/handler"
+	"github.com/tomatool/tomato/internal/har"
 )
 
 // Mock implementations
@@ -20,6 +32,7 @@ type mockRegistry struct {
 	registerCalled  bool
 	waitReadyCalled bool
 	resetAllCalled  bool
+	resetAllCount   int
 }
 
 func (m *mockRegistry) WaitReady(ctx context.Context) error {
@@ -29,6 +42,7 @@ func (m *mockRegistry) WaitReady(ctx context.Context) error {
 
 func (m *mockRegistry) ResetAll(ctx context.Context) error {
 	m.resetAllCalled = true
+	m.resetAllCount++
 	return m.resetAllErr
 }
 
@@ -89,6 +103,24 @@ func (m *mockSQLHandler) ExecSQLFile(ctx context.Context, path string) error {
 	return m.execFileErr
 }
 
+// mockCommandHandler is a handler that accepts raw commands and publishes messages
+type mockCommandHandler struct {
+	mockHandler
+	commands  [][]string
+	published []string
+	err       error
+}
+
+func (m *mockCommandHandler) ExecCommand(ctx context.Context, args []string) (string, error) {
+	m.commands = append(m.commands, args)
+	return "OK", m.err
+}
+
+func (m *mockCommandHandler) Publish(ctx context.Context, target string, payload []byte, headers map[string]string) error {
+	m.published = append(m.published, target+":"+string(payload))
+	return m.err
+}
+
 // Test helper functions
 
 func newTestConfig() *config.Config {
@@ -375,6 +407,45 @@ func TestExecuteHook(t *testing.T) {
 			wantErr:     true,
 			errContains: "executing shell in app",
 		},
+		{
+			name:      "Shell hook on host success",
+			hook:      config.Hook{Shell: "true"},
+			registry:  &mockRegistry{},
+			container: &mockContainerExecutor{},
+			wantErr:   false,
+		},
+		{
+			name:        "Shell hook on host fails",
+			hook:        config.Hook{Shell: "echo broken >&2; exit 3"},
+			registry:    &mockRegistry{},
+			container:   &mockContainerExecutor{},
+			wantErr:     true,
+			errContains: "broken",
+		},
+		{
+			name:        "Redis hook - handler does not support commands",
+			hook:        config.Hook{Resource: "db", Redis: "SET flag on"},
+			registry:    &mockRegistry{getHandler: &mockSQLHandler{}},
+			container:   &mockContainerExecutor{},
+			wantErr:     true,
+			errContains: "does not support Redis commands",
+		},
+		{
+			name:        "Publish hook - handler does not support publishing",
+			hook:        config.Hook{Resource: "db", Publish: &config.PublishHook{Target: "orders", Payload: "{}"}},
+			registry:    &mockRegistry{getHandler: &mockSQLHandler{}},
+			container:   &mockContainerExecutor{},
+			wantErr:     true,
+			errContains: "does not support publishing",
+		},
+		{
+			name:        "HTTP hook - handler does not support requests",
+			hook:        config.Hook{Resource: "db", HTTP: &config.HTTPHook{URL: "/reset"}},
+			registry:    &mockRegistry{getHandler: &mockSQLHandler{}},
+			container:   &mockContainerExecutor{},
+			wantErr:     true,
+			errContains: "does not support HTTP requests",
+		},
 		{
 			name:      "empty hook - no operation",
 			hook:      config.Hook{},
@@ -410,6 +481,117 @@ func TestExecuteHook(t *testing.T) {
 	}
 }
 
+func TestExecuteRedisAndPublishHooks(t *testing.T) {
+	h := &mockCommandHandler{}
+	runner := &Runner{config: newTestConfig(), handlers: &mockRegistry{getHandler: h}, container: &mockContainerExecutor{}}
+
+	hooks := []config.Hook{
+		{Resource: "cache", Redis: `SET greeting "hello world"`},
+		{Resource: "queue", Publish: &config.PublishHook{Target: "orders", Payload: `{"id":1}`}},
+	}
+	if err := runner.runHooks(context.Background(), hooks); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(h.commands) != 1 || strings.Join(h.commands[0], "|") != "SET|greeting|hello world" {
+		t.Errorf("unexpected Redis commands: %q", h.commands)
+	}
+	if len(h.published) != 1 || h.published[0] != `orders:{"id":1}` {
+		t.Errorf("unexpected published messages: %q", h.published)
+	}
+}
+
+func TestExecuteHTTPHook(t *testing.T) {
+	var gotMethod, gotBody, gotHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotMethod, gotBody, gotHeader = r.Method, string(body), r.Header.Get("X-Token")
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	runner := &Runner{config: newTestConfig(), handlers: &mockRegistry{}, container: &mockContainerExecutor{}}
+
+	hook := config.Hook{HTTP: &config.HTTPHook{
+		Method:  "POST",
+		URL:     server.URL + "/admin/seed",
+		Headers: map[string]string{"X-Token": "secret"},
+		Body:    `{"plan":"pro"}`,
+		Status:  http.StatusAccepted,
+	}}
+	if err := runner.executeHook(context.Background(), hook); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotMethod != "POST" || gotBody != `{"plan":"pro"}` || gotHeader != "secret" {
+		t.Errorf("unexpected request: %s %q (X-Token %q)", gotMethod, gotBody, gotHeader)
+	}
+
+	hook = config.Hook{HTTP: &config.HTTPHook{URL: server.URL + "/missing"}}
+	if err := runner.executeHook(context.Background(), hook); err == nil || !contains(err.Error(), "unexpected status 404") {
+		t.Errorf("expected unexpected status error, got %v", err)
+	}
+}
+
+func TestScenarioHooks(t *testing.T) {
+	hooks := []config.Hook{
+		{SQL: "seed billing", Tags: "@billing"},
+		{SQL: "always"},
+		{SQL: "not wip", Tags: "not @wip"},
+	}
+
+	tests := []struct {
+		name string
+		tags []string
+		want []string
+	}{
+		{name: "billing scenario", tags: []string{"@billing"}, want: []string{"seed billing", "always", "not wip"}},
+		{name: "wip scenario", tags: []string{"@wip"}, want: []string{"always"}},
+		{name: "untagged scenario", want: []string{"always", "not wip"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc := &godog.Scenario{Name: tt.name}
+			for _, tag := range tt.tags {
+				sc.Tags = append(sc.Tags, &messages.PickleTag{Name: tag})
+			}
+			matched, err := scenarioHooks(sc, hooks)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got []string
+			for _, hook := range matched {
+				got = append(got, hook.SQL)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("expected hooks %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"SET flag on", []string{"SET", "flag", "on"}},
+		{`SET greeting "hello world"`, []string{"SET", "greeting", "hello world"}},
+		{`HSET user:1 name 'Alice Smith'  age 30`, []string{"HSET", "user:1", "name", "Alice Smith", "age", "30"}},
+		{`SET empty ""`, []string{"SET", "empty", ""}},
+	}
+
+	for _, tt := range tests {
+		if got := splitCommand(tt.input); strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+			t.Errorf("splitCommand(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
 // Tests for runHooks
 
 func TestRunHooks(t *testing.T) {
@@ -461,6 +643,28 @@ func TestRunHooks(t *testing.T) {
 			wantErr:     true,
 			errContains: "executing command",
 		},
+		{
+			name: "continue_on_error - later hooks still run",
+			hooks: []config.Hook{
+				{Container: "app", Exec: "fail", ContinueOnError: true},
+				{Shell: "true"},
+			},
+			registry: &mockRegistry{},
+			container: &mockContainerExecutor{
+				execErr: errors.New("command failed"),
+			},
+			wantErr: false,
+		},
+		{
+			name: "hook timeout",
+			hooks: []config.Hook{
+				{Shell: "exec sleep 5", Timeout: 50 * time.Millisecond},
+			},
+			registry:    &mockRegistry{},
+			container:   &mockContainerExecutor{},
+			wantErr:     true,
+			errContains: "shell hook timed out after 50ms",
+		},
 		{
 			name: "mixed hook types",
 			hooks: []config.Hook{
@@ -915,6 +1119,346 @@ func TestInitializeScenario(t *testing.T) {
 	}
 }
 
+// Tests for reset levels
+
+func TestResetLevels(t *testing.T) {
+	scenarios := []godog.Scenario{
+		{Name: "A1", Uri: "features/a.feature"},
+		{Name: "A2", Uri: "features/a.feature"},
+		{Name: "B1", Uri: "features/b.feature"},
+		{Name: "B2", Uri: "features/b.feature"},
+		{Name: "A3", Uri: "features/a.feature"},
+	}
+
+	tests := []struct {
+		name       string
+		level      string
+		noReset    bool
+		wantResets int
+	}{
+		{name: "default level resets every scenario", level: "", wantResets: 5},
+		{name: "scenario level resets every scenario", level: "scenario", wantResets: 5},
+		{name: "feature level resets on feature change", level: "feature", wantResets: 3},
+		{name: "run level resets once", level: "run", wantResets: 1},
+		{name: "none level never resets", level: "none", wantResets: 0},
+		{name: "no-reset option overrides level", level: "scenario", noReset: true, wantResets: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.Settings.Reset.Level = tt.level
+			registry := &mockRegistry{}
+
+			runner, err := newRunner(cfg, &mockContainerExecutor{}, registry, Options{NoReset: tt.noReset})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			capturingCtx := &capturingScenarioContext{}
+			runner.setupScenarioHooks(capturingCtx)
+
+			for i := range scenarios {
+				ctx, err := capturingCtx.beforeHook(context.Background(), &scenarios[i])
+				if err != nil {
+					t.Fatalf("before hook failed: %v", err)
+				}
+				capturingCtx.afterHook(ctx, &scenarios[i], nil)
+			}
+
+			if registry.resetAllCount != tt.wantResets {
+				t.Errorf("expected %d resets, got %d", tt.wantResets, registry.resetAllCount)
+			}
+		})
+	}
+}
+
+func TestFeatureResetPerWorker(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Settings.Reset.Level = "feature"
+	registry := &mockRegistry{}
+	runner, err := newRunner(cfg, &mockContainerExecutor{}, registry, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	capturingCtx := &capturingScenarioContext{}
+	runner.setupScenarioHooks(capturingCtx)
+
+	// Two workers run the scenarios of two features side by side, so the
+	// scenarios started one after the other alternate between features
+	run := func(batch ...godog.Scenario) {
+		var ctxs []context.Context
+		for i := range batch {
+			ctx, err := capturingCtx.beforeHook(context.Background(), &batch[i])
+			if err != nil {
+				t.Fatalf("before hook failed: %v", err)
+			}
+			ctxs = append(ctxs, ctx)
+		}
+		for i, ctx := range ctxs {
+			capturingCtx.afterHook(ctx, &batch[i], nil)
+		}
+	}
+	run(godog.Scenario{Name: "A1", Uri: "features/a.feature"}, godog.Scenario{Name: "B1", Uri: "features/b.feature"})
+	run(godog.Scenario{Name: "A2", Uri: "features/a.feature"}, godog.Scenario{Name: "B2", Uri: "features/b.feature"})
+
+	if registry.resetAllCount != 2 {
+		t.Errorf("expected a reset per worker, got %d", registry.resetAllCount)
+	}
+}
+
+func TestResetLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		level   string
+		noReset bool
+		want    string
+	}{
+		{name: "empty defaults to scenario", level: "", want: "scenario"},
+		{name: "configured level", level: "feature", want: "feature"},
+		{name: "no-reset forces none", level: "run", noReset: true, want: "none"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.Settings.Reset.Level = tt.level
+
+			runner, _ := newRunner(cfg, &mockContainerExecutor{}, &mockRegistry{}, Options{NoReset: tt.noReset})
+			if got := runner.ResetLevel(); got != tt.want {
+				t.Errorf("ResetLevel() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResetOnFailureKeep(t *testing.T) {
+	tests := []struct {
+		name       string
+		onFailure  string
+		wantResets int
+		wantKept   bool
+	}{
+		{name: "reset on failure", onFailure: "reset", wantResets: 3, wantKept: false},
+		{name: "keep on failure", onFailure: "keep", wantResets: 2, wantKept: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.Settings.Reset.OnFailure = tt.onFailure
+			registry := &mockRegistry{}
+
+			runner, _ := newRunner(cfg, &mockContainerExecutor{}, registry, Options{})
+			capturingCtx := &capturingScenarioContext{}
+			runner.setupScenarioHooks(capturingCtx)
+
+			ctx := context.Background()
+			first := &godog.Scenario{Name: "passes", Uri: "a.feature"}
+			second := &godog.Scenario{Name: "fails", Uri: "a.feature"}
+			third := &godog.Scenario{Name: "after failure", Uri: "a.feature"}
+
+			capturingCtx.beforeHook(ctx, first)
+			capturingCtx.afterHook(ctx, first, nil)
+			capturingCtx.beforeHook(ctx, second)
+			capturingCtx.afterHook(ctx, second, errors.New("boom"))
+			capturingCtx.beforeHook(ctx, third)
+			capturingCtx.afterHook(ctx, third, errors.New("another"))
+
+			if registry.resetAllCount != tt.wantResets {
+				t.Errorf("expected %d resets, got %d", tt.wantResets, registry.resetAllCount)
+			}
+
+			failure := runner.FailureState()
+			if (failure != nil) != tt.wantKept {
+				t.Fatalf("expected kept=%v, got %v", tt.wantKept, failure)
+			}
+			if failure != nil {
+				if failure.Scenario != "fails" || failure.Error != "boom" {
+					t.Errorf("unexpected failure state: %+v", failure)
+				}
+				summary := failure.Summary(map[string]config.Resource{"db": {Type: "postgres", Container: "postgres"}})
+				if !contains(summary, "fails") || !contains(summary, "db (postgres, container postgres)") {
+					t.Errorf("unexpected summary: %s", summary)
+				}
+			}
+		})
+	}
+}
+
+func TestScenarioStatePerResetLevel(t *testing.T) {
+	tests := []struct {
+		name       string
+		level      string
+		wantShared bool
+	}{
+		{name: "scenario level gets fresh state", level: "scenario", wantShared: false},
+		{name: "run level shares state", level: "run", wantShared: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.Settings.Reset.Level = tt.level
+
+			runner, _ := newRunner(cfg, &mockContainerExecutor{}, &mockRegistry{}, Options{})
+			capturingCtx := &capturingScenarioContext{}
+			runner.setupScenarioHooks(capturingCtx)
+
+			sc := &godog.Scenario{Name: "first", Uri: "a.feature"}
+			first, _ := capturingCtx.beforeHook(context.Background(), sc)
+			handler.VariablesFrom(first).Set("token", "abc")
+			capturingCtx.afterHook(first, sc, nil)
+
+			second, _ := capturingCtx.beforeHook(context.Background(), &godog.Scenario{Name: "second", Uri: "a.feature"})
+			_, shared := handler.VariablesFrom(second).Get("token")
+
+			if handler.ScenarioStateFrom(second) == nil {
+				t.Fatal("expected scenario state on context")
+			}
+			if shared != tt.wantShared {
+				t.Errorf("expected shared=%v, got %v", tt.wantShared, shared)
+			}
+		})
+	}
+}
+
+func TestWorkerAssignment(t *testing.T) {
+	runner, _ := newRunner(newTestConfig(), &mockContainerExecutor{}, &mockRegistry{}, Options{})
+	capturingCtx := &capturingScenarioContext{}
+	runner.setupScenarioHooks(capturingCtx)
+
+	first, _ := capturingCtx.beforeHook(context.Background(), &godog.Scenario{Name: "first"})
+	second, _ := capturingCtx.beforeHook(context.Background(), &godog.Scenario{Name: "second"})
+
+	if id := handler.WorkerID(first); id != 1 {
+		t.Errorf("first scenario: expected worker 1, got %d", id)
+	}
+	if id := handler.WorkerID(second); id != 2 {
+		t.Errorf("second scenario: expected worker 2, got %d", id)
+	}
+	if v, _ := handler.VariablesFrom(second).Get("worker_id"); v != "2" {
+		t.Errorf("expected worker_id variable '2', got %q", v)
+	}
+
+	// Worker 1 becomes free and is reused by the next scenario
+	capturingCtx.afterHook(first, &godog.Scenario{Name: "first"}, nil)
+	third, _ := capturingCtx.beforeHook(context.Background(), &godog.Scenario{Name: "third"})
+	if id := handler.WorkerID(third); id != 1 {
+		t.Errorf("third scenario: expected worker 1, got %d", id)
+	}
+}
+
+func TestScenarioTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		tags    []string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "no tags", want: 0},
+		{name: "unrelated tags", tags: []string{"@smoke", "@wip"}, want: 0},
+		{name: "scenario tag", tags: []string{"@timeout(30s)"}, want: 30 * time.Second},
+		{name: "scenario overrides feature", tags: []string{"@timeout(2m)", "@smoke", "@timeout(5s)"}, want: 5 * time.Second},
+		{name: "invalid duration", tags: []string{"@timeout(soon)"}, wantErr: true},
+		{name: "zero duration", tags: []string{"@timeout(0s)"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc := &godog.Scenario{Name: tt.name}
+			for _, tag := range tt.tags {
+				sc.Tags = append(sc.Tags, &messages.PickleTag{Name: tag})
+			}
+			got, err := scenarioTimeout(sc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("scenarioTimeout() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("scenarioTimeout() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventuallyTag(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Settings.Eventually.Timeout = 5 * time.Second
+	runner, _ := newRunner(cfg, &mockContainerExecutor{}, &mockRegistry{}, Options{})
+
+	tests := []struct {
+		name    string
+		tags    []string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "not tagged", tags: []string{"@timeout(1m)"}, want: 0},
+		{name: "default duration", tags: []string{"@eventually"}, want: 5 * time.Second},
+		{name: "explicit duration", tags: []string{"@eventually(10s)"}, want: 10 * time.Second},
+		{name: "invalid duration", tags: []string{"@eventually(later)"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc := &godog.Scenario{Name: tt.name}
+			for _, tag := range tt.tags {
+				sc.Tags = append(sc.Tags, &messages.PickleTag{Name: tag})
+			}
+			got, err := runner.eventuallyTimeout(sc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("eventuallyTimeout() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("eventuallyTimeout() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	capturingCtx := &capturingScenarioContext{}
+	runner.setupScenarioHooks(capturingCtx)
+	ctx, err := capturingCtx.beforeHook(context.Background(), &godog.Scenario{Name: "async", Tags: []*messages.PickleTag{{Name: "@eventually"}}})
+	if err != nil {
+		t.Fatalf("before hook failed: %v", err)
+	}
+	if d := handler.EventuallyTimeout(ctx); d != 5*time.Second {
+		t.Errorf("expected assertions to be polled for 5s, got %v", d)
+	}
+}
+
+func TestScenarioDeadlines(t *testing.T) {
+	cfg := newTestConfig()
+	cfg.Settings.StepTimeout = 10 * time.Second
+	runner, _ := newRunner(cfg, &mockContainerExecutor{}, &mockRegistry{}, Options{})
+	capturingCtx := &capturingScenarioContext{}
+	runner.setupScenarioHooks(capturingCtx)
+
+	sc := &godog.Scenario{Name: "slow", Tags: []*messages.PickleTag{{Name: "@timeout(1m)"}}}
+	ctx, err := capturingCtx.beforeHook(context.Background(), sc)
+	if err != nil {
+		t.Fatalf("before hook failed: %v", err)
+	}
+	if d := handler.StepTimeout(ctx); d != 10*time.Second {
+		t.Errorf("expected step timeout 10s, got %v", d)
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok || time.Until(deadline) > time.Minute {
+		t.Errorf("expected scenario deadline within 1m, got %v (set: %v)", deadline, ok)
+	}
+
+	capturingCtx.afterHook(ctx, sc, nil)
+	if ctx.Err() == nil {
+		t.Error("expected scenario context to be cancelled after the scenario")
+	}
+
+	// Once the run deadline has passed, scenarios fail with the deadline cause
+	runCtx, cancel := context.WithTimeoutCause(context.Background(), time.Nanosecond, errors.New("run timed out"))
+	defer cancel()
+	<-runCtx.Done()
+	if _, err := capturingCtx.beforeHook(runCtx, &godog.Scenario{Name: "late"}); err == nil || err.Error() != "run timed out" {
+		t.Errorf("expected run timeout error, got %v", err)
+	}
+}
+
 // Tests for after scenario hook
 
 func TestInitializeScenarioAfterHook(t *testing.T) {
@@ -1038,3 +1582,476 @@ func sliceEqual(a, b []string) bool {
 	}
 	return true
 }
+
+// stepRegistry is a mockRegistry that registers the given steps like a
+// handler of a resource named "fake"
+type stepRegistry struct {
+	mockRegistry
+	steps map[string]any
+}
+
+func (m *stepRegistry) RegisterSteps(ctx *godog.ScenarioContext) {
+	var category handler.StepCategory
+	for pattern, fn := range m.steps {
+		category.Steps = append(category.Steps, handler.StepDef{Pattern: pattern, Handler: fn})
+	}
+	handler.RegisterStepsToGodog(ctx, "fake", category)
+}
+
+type staticLogs []string
+
+func (l staticLogs) GetRecentLogs(n int) []string { return l }
+
+func TestRunRecordsResults(t *testing.T) {
+	dir := t.TempDir()
+	feature := filepath.Join(dir, "results.feature")
+	content := `Feature: Results
+
+  Scenario: passing
+    Given a passing step
+
+  Scenario: failing
+    Given a passing step
+    Then a failing step
+
+  Scenario Outline: outline <n>
+    Then a failing step
+
+    Examples:
+      | n |
+      | 1 |
+      | 2 |
+`
+	if err := os.WriteFile(feature, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := &stepRegistry{steps: map[string]any{
+		`^a passing step$`: func() error { return nil },
+		`^a failing step$`: func() error { return errors.New("boom") },
+	}}
+
+	run := func(paths ...string) *Runner {
+		cfg := newTestConfig()
+		cfg.Settings.Output = "progress"
+		cfg.Features.Paths = paths
+		r, err := newRunner(cfg, &mockContainerExecutor{}, registry, Options{NoReset: true, RunID: "abc"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.SetAppLogs(staticLogs{"GET /users 500"})
+		if err := r.Run(context.Background()); err == nil {
+			t.Fatal("expected the run to fail")
+		}
+		return r
+	}
+
+	results := run(feature).Results()
+	if results.ID != "abc" || results.Status != "failed" {
+		t.Errorf("run = %s/%s, want abc/failed", results.ID, results.Status)
+	}
+	if len(results.Scenarios) != 4 {
+		t.Fatalf("got %d scenarios, want 4", len(results.Scenarios))
+	}
+
+	failing := results.Scenarios[1]
+	if failing.Status != "failed" || failing.Line != 6 || failing.URI != feature || failing.Feature != "Results" {
+		t.Errorf("failing scenario = %+v", failing)
+	}
+	if len(failing.Steps) != 2 || failing.Steps[1].Line != 8 || failing.Steps[1].Keyword != "Then " || failing.Steps[1].Error != "boom" {
+		t.Errorf("failing steps = %+v", failing.Steps)
+	}
+	if match := failing.Steps[1].Match; match == nil || match.Resource != "fake" || match.Pattern != `^a failing step$` {
+		t.Errorf("failing step match = %+v", match)
+	}
+	if len(failing.AppLogs) != 1 || len(results.Scenarios[0].AppLogs) != 0 {
+		t.Errorf("app logs should only be attached to failed scenarios")
+	}
+
+	want := []string{feature + ":6", feature + ":10"}
+	got := results.FailedLocations()
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("FailedLocations() = %v, want %v", got, want)
+	}
+
+	// Rerunning the failed locations runs only those scenarios
+	rerun := run(got...).Results()
+	if len(rerun.Scenarios) != 3 {
+		t.Fatalf("rerun got %d scenarios, want 3", len(rerun.Scenarios))
+	}
+	for _, sc := range rerun.Scenarios {
+		if sc.Name == "passing" || sc.URI != feature {
+			t.Errorf("unexpected rerun scenario %+v", sc)
+		}
+	}
+}
+
+func TestRunRetriesFailedScenarios(t *testing.T) {
+	dir := t.TempDir()
+	feature := filepath.Join(dir, "retry.feature")
+	content := `Feature: Retry
+
+  @retry(2)
+  Scenario: flaky
+    Then "flaky" fails 1 times
+
+  @retry(1)
+  Scenario: broken
+    Then "broken" fails 5 times
+
+  Scenario: no retries
+    Then "plain" fails 1 times
+
+  @retry(1)
+  Scenario Outline: outline <name>
+    Then "<name>" fails <n> times
+
+    Examples:
+      | name | n |
+      | ok   | 0 |
+      | row  | 1 |
+`
+	if err := os.WriteFile(feature, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := make(map[string]int)
+	registry := &stepRegistry{steps: map[string]any{
+		`^"([^"]*)" fails (\d+) times$`: func(name string, n int) error {
+			calls[name]++
+			if calls[name] <= n {
+				return fmt.Errorf("%s failed on call %d", name, calls[name])
+			}
+			return nil
+		},
+	}}
+
+	cfg := newTestConfig()
+	cfg.Settings.Output = "progress"
+	cfg.Features.Paths = []string{feature}
+	r, err := newRunner(cfg, &mockContainerExecutor{}, registry, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Run(context.Background()); err == nil {
+		t.Fatal("expected the run to fail")
+	}
+
+	want := map[string]string{
+		"flaky":       "passed on retry 1",
+		"broken":      "failed after 1 retries",
+		"no retries":  "failed",
+		"outline ok":  "passed",
+		"outline row": "passed on retry 1",
+	}
+	results := r.Results()
+	if len(results.Scenarios) != len(want) {
+		t.Fatalf("got %d scenarios, want %d", len(results.Scenarios), len(want))
+	}
+	for _, sc := range results.Scenarios {
+		if got := sc.Outcome(); got != want[sc.Name] {
+			t.Errorf("%s: outcome %q, want %q", sc.Name, got, want[sc.Name])
+		}
+	}
+
+	broken := results.Scenarios[1]
+	if len(broken.PreviousErrors) != 1 || broken.PreviousErrors[0] != "broken failed on call 1" || broken.Error != "broken failed on call 2" {
+		t.Errorf("broken errors = %q, previous %q", broken.Error, broken.PreviousErrors)
+	}
+	if len(broken.Attempts) != 1 || broken.Attempts[0].Status != "failed" || len(broken.Attempts[0].Steps) == 0 {
+		t.Errorf("broken attempts = %+v, want the failed first attempt", broken.Attempts)
+	}
+	if calls["ok"] != 1 || calls["broken"] != 2 || calls["plain"] != 1 {
+		t.Errorf("unexpected step calls %v", calls)
+	}
+	if results.Retried() != 3 || len(results.Flaky()) != 2 {
+		t.Errorf("retried %d, flaky %d, want 3 and 2", results.Retried(), len(results.Flaky()))
+	}
+	// Every scenario and every retry starts from a reset
+	if registry.resetAllCount != 8 {
+		t.Errorf("ResetAll called %d times, want 8", registry.resetAllCount)
+	}
+}
+
+func TestRetryLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		tags     []string
+		settings int
+		want     int
+		wantErr  bool
+	}{
+		{name: "default", tags: nil, want: 0},
+		{name: "settings", tags: nil, settings: 2, want: 2},
+		{name: "tag", tags: []string{"@retry(3)"}, settings: 1, want: 3},
+		{name: "scenario tag overrides feature tag", tags: []string{"@retry(3)", "@retry(0)"}, settings: 1, want: 0},
+		{name: "missing count", tags: []string{"@retry"}, wantErr: true},
+		{name: "invalid count", tags: []string{"@retry(many)"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig()
+			cfg.Settings.Retry = tt.settings
+			r := &Runner{config: cfg}
+
+			got, err := r.retryLimit(tt.tags)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("retryLimit() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("retryLimit() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunReportsTimings(t *testing.T) {
+	dir := t.TempDir()
+	feature := filepath.Join(dir, "timings.feature")
+	content := `Feature: Timings
+
+  Scenario: slow
+    Given a quick step
+    When a slow step
+`
+	if err := os.WriteFile(feature, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := &stepRegistry{steps: map[string]any{
+		`^a quick step$`: func() error { return nil },
+		`^a slow step$`:  func() error { time.Sleep(20 * time.Millisecond); return nil },
+	}}
+	cfg := newTestConfig()
+	cfg.Features.Paths = []string{feature}
+	r, err := newRunner(cfg, &mockContainerExecutor{}, registry, Options{Format: "tomato"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The tomato format writes its events to stdout
+	stdout := os.Stdout
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = pw
+	output := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(pr)
+		output <- data
+	}()
+	runErr := r.Run(context.Background())
+	pw.Close()
+	os.Stdout = stdout
+	if runErr != nil {
+		t.Fatalf("Run failed: %v", runErr)
+	}
+
+	events := make(map[string][]formatter.Event)
+	for _, line := range strings.Split(string(<-output), "\n") {
+		data, ok := strings.CutPrefix(line, "TOMATO_EVENT:")
+		if !ok {
+			continue
+		}
+		var event formatter.Event
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			t.Fatalf("invalid event %q: %v", data, err)
+		}
+		events[event.Type] = append(events[event.Type], event)
+	}
+
+	steps := events[formatter.EventStepEnd]
+	if len(steps) != 2 || steps[1].Duration < 20*time.Millisecond || steps[1].StartedAt.IsZero() {
+		t.Fatalf("step_end events = %+v", steps)
+	}
+	if !steps[1].FinishedAt.Equal(steps[1].StartedAt.Add(steps[1].Duration)) {
+		t.Errorf("step finished at %v, want start + duration", steps[1].FinishedAt)
+	}
+	scenario := events[formatter.EventScenarioEnd]
+	if len(scenario) != 1 || scenario[0].Duration < steps[1].Duration || scenario[0].Reset <= 0 {
+		t.Errorf("scenario_end events = %+v, want duration and reset time", scenario)
+	}
+
+	summary := events[formatter.EventSummary]
+	if len(summary) != 1 || len(summary[0].SlowestSteps) != 2 {
+		t.Fatalf("summary events = %+v", summary)
+	}
+	if slowest := summary[0].SlowestSteps[0]; slowest.Step != "When a slow step" || slowest.File != feature+":5" {
+		t.Errorf("slowest step = %+v", slowest)
+	}
+	if len(summary[0].SlowestScenarios) != 1 || summary[0].SlowestScenarios[0].Scenario != "slow" {
+		t.Errorf("slowest scenarios = %+v", summary[0].SlowestScenarios)
+	}
+}
+
+// transcriptHandler is a handler that keeps a fixed transcript
+type transcriptHandler struct {
+	handler.Handler
+}
+
+func (transcriptHandler) Transcript(ctx context.Context) string { return "GET /users → 500" }
+
+func TestRunAttachesTranscripts(t *testing.T) {
+	dir := t.TempDir()
+	feature := filepath.Join(dir, "transcript.feature")
+	content := `Feature: Transcript
+
+  Scenario: failing
+    Then a failing step
+`
+	if err := os.WriteFile(feature, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := &stepRegistry{
+		mockRegistry: mockRegistry{getHandler: transcriptHandler{}},
+		steps: map[string]any{
+			`^a failing step$`: func() error { return errors.New("boom") },
+		},
+	}
+	cfg := newTestConfig()
+	cfg.Settings.Output = "progress"
+	cfg.Features.Paths = []string{feature}
+	r, err := newRunner(cfg, &mockContainerExecutor{}, registry, Options{NoReset: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Run(context.Background()); err == nil {
+		t.Fatal("expected the run to fail")
+	}
+
+	sc := r.Results().Scenarios[0]
+	step := sc.Steps[0]
+	if step.Error != "boom" || sc.Error != "boom" {
+		t.Errorf("errors = %q, %q; the transcript should only be attached", step.Error, sc.Error)
+	}
+	if len(step.Attachments) != 1 || step.Attachments[0].Name != "fake transcript" || step.Attachments[0].Body != "GET /users → 500" {
+		t.Errorf("attachments = %+v", step.Attachments)
+	}
+}
+
+func TestRunRecordsHARPages(t *testing.T) {
+	dir := t.TempDir()
+	feature := filepath.Join(dir, "har.feature")
+	content := `Feature: HAR
+
+  Scenario: first
+    When a request is made
+
+  Scenario: second
+    When a request is made
+`
+	if err := os.WriteFile(feature, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var r *Runner
+	registry := &stepRegistry{
+		steps: map[string]any{
+			`^a request is made$`: func(ctx context.Context) error {
+				r.HAR().Add(har.PageFrom(ctx), har.Exchange{Method: "GET", URL: "http://api/"})
+				return nil
+			},
+		},
+	}
+	cfg := newTestConfig()
+	cfg.Settings.Output = "progress"
+	cfg.Features.Paths = []string{feature}
+	r, err := newRunner(cfg, &mockContainerExecutor{}, registry, Options{NoReset: true, HAR: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	log := r.HAR().HAR().Log
+	if len(log.Pages) != 2 || log.Pages[0].Title != "first" || log.Pages[1].Title != "second" {
+		t.Fatalf("pages = %+v", log.Pages)
+	}
+	for i, e := range log.Entries {
+		if e.PageRef != log.Pages[i].ID {
+			t.Errorf("entry %d pageref = %q, want %q", i, e.PageRef, log.Pages[i].ID)
+		}
+	}
+}
+
+// contractRegistry is a stepRegistry whose handlers report the given
+// contract violations, one per call; a nil entry reports none
+type contractRegistry struct {
+	stepRegistry
+	violations []error
+}
+
+func (m *contractRegistry) TakeContractViolations() error {
+	if len(m.violations) == 0 {
+		return nil
+	}
+	err := m.violations[0]
+	m.violations = m.violations[1:]
+	return err
+}
+
+func TestRunFailsStepsOnContractViolations(t *testing.T) {
+	dir := t.TempDir()
+	feature := filepath.Join(dir, "contract.feature")
+	content := `Feature: Contract
+
+  Scenario: mock called with an invalid request
+    When a request is made
+    Then a passing step
+`
+	if err := os.WriteFile(feature, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	violation := errors.New("users received a request that violates the contract")
+
+	tests := []struct {
+		name       string
+		parallel   int
+		violations []error
+		failedStep int // -1 when the violation only fails the scenario
+	}{
+		{name: "during a step", parallel: 1, violations: []error{violation}, failedStep: 0},
+		{name: "after the last step", parallel: 1, violations: []error{nil, nil, violation}, failedStep: -1},
+		{name: "parallel workers", parallel: 2, violations: []error{violation}, failedStep: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := &contractRegistry{
+				stepRegistry: stepRegistry{steps: map[string]any{
+					`^a request is made$`: func() error { return nil },
+					`^a passing step$`:    func() error { return nil },
+				}},
+				violations: tt.violations,
+			}
+			cfg := newTestConfig()
+			cfg.Settings.Output = "progress"
+			cfg.Settings.Parallel = tt.parallel
+			cfg.Features.Paths = []string{feature}
+			r, err := newRunner(cfg, &mockContainerExecutor{}, registry, Options{NoReset: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := r.Run(context.Background()); err == nil {
+				t.Fatal("expected the run to fail")
+			}
+
+			sc := r.Results().Scenarios[0]
+			if sc.Status != "failed" || !strings.Contains(sc.Error, "violates the contract") {
+				t.Errorf("scenario = %s %q, want the violation", sc.Status, sc.Error)
+			}
+			for i, step := range sc.Steps {
+				if i == tt.failedStep {
+					if step.Status != "failed" || !strings.Contains(step.Error, "violates the contract") {
+						t.Errorf("step %d = %s %q, want the violation", i, step.Status, step.Error)
+					}
+				} else if step.Status != "passed" {
+					t.Errorf("step %d status = %q, want passed", i, step.Status)
+				}
+			}
+		})
+	}
+}
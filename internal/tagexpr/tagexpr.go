@@ -0,0 +1,150 @@
+// Package tagexpr evaluates Cucumber tag expressions such as
+// "@smoke and not (@slow or @wip)" against the tags of a scenario.
+package tagexpr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Expr is a parsed tag expression
+type Expr struct {
+	root node
+	src  string
+}
+
+type node interface {
+	eval(tags map[string]bool) bool
+}
+
+type (
+	tagNode struct{ name string }
+	notNode struct{ expr node }
+	andNode struct{ left, right node }
+	orNode  struct{ left, right node }
+)
+
+func (n tagNode) eval(tags map[string]bool) bool { return tags[n.name] }
+func (n notNode) eval(tags map[string]bool) bool { return !n.expr.eval(tags) }
+func (n andNode) eval(tags map[string]bool) bool { return n.left.eval(tags) && n.right.eval(tags) }
+func (n orNode) eval(tags map[string]bool) bool  { return n.left.eval(tags) || n.right.eval(tags) }
+
+// Parse parses a tag expression. Supported operators are "and", "or", "not"
+// and parentheses; "not" binds tighter than "and", which binds tighter than "or".
+func Parse(expr string) (*Expr, error) {
+	p := &parser{tokens: tokenize(expr)}
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("empty tag expression")
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("invalid tag expression %q: %w", expr, err)
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("invalid tag expression %q: unexpected %q", expr, p.tokens[p.pos])
+	}
+	return &Expr{root: root, src: expr}, nil
+}
+
+// Match reports whether the given tags (with their leading "@") satisfy the expression
+func (e *Expr) Match(tags []string) bool {
+	set := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		set[tag] = true
+	}
+	return e.root.eval(set)
+}
+
+// String returns the source of the expression
+func (e *Expr) String() string {
+	return e.src
+}
+
+func tokenize(expr string) []string {
+	expr = strings.NewReplacer("(", " ( ", ")", " ) ").Replace(expr)
+	return strings.Fields(expr)
+}
+
+type parser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *parser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *parser) next() string {
+	tok := p.peek()
+	p.pos++
+	return tok
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "or" {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "and" {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.peek() == "not" {
+		p.next()
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{expr}, nil
+	}
+	return p.parseOperand()
+}
+
+func (p *parser) parseOperand() (node, error) {
+	tok := p.next()
+	switch {
+	case tok == "":
+		return nil, fmt.Errorf("unexpected end of expression")
+	case tok == "(":
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		return expr, nil
+	case strings.HasPrefix(tok, "@") && len(tok) > 1:
+		return tagNode{tok}, nil
+	default:
+		return nil, fmt.Errorf("unexpected %q, expected a tag such as @smoke", tok)
+	}
+}
@@ -0,0 +1,55 @@
+package tagexpr
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		expr string
+		tags []string
+		want bool
+	}{
+		{"@billing", []string{"@billing"}, true},
+		{"@billing", []string{"@smoke"}, false},
+		{"@billing and @api", []string{"@billing", "@api"}, true},
+		{"@billing and @api", []string{"@billing"}, false},
+		{"@billing or @api", []string{"@api"}, true},
+		{"not @slow", []string{"@smoke"}, true},
+		{"not @slow", []string{"@slow"}, false},
+		{"@smoke and not @wip", []string{"@smoke", "@wip"}, false},
+		{"@a or @b and @c", []string{"@a"}, true},
+		{"(@a or @b) and @c", []string{"@a"}, false},
+		{"(@a or @b) and @c", []string{"@b", "@c"}, true},
+		{"not (@a or @b)", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.expr, err)
+			}
+			if got := expr.Match(tt.tags); got != tt.want {
+				t.Errorf("Match(%v) = %v, want %v", tt.tags, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"billing",
+		"@a and",
+		"(@a or @b",
+		"@a @b",
+		"not",
+	}
+
+	for _, expr := range tests {
+		t.Run(expr, func(t *testing.T) {
+			if _, err := Parse(expr); err == nil {
+				t.Errorf("Parse(%q) expected an error", expr)
+			}
+		})
+	}
+}